@@ -7,6 +7,14 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"tion.work/backend/handlers"
+	appconfig "tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/ratelimit"
+	accounts "tion.work/backend/internal/services"
+	"tion.work/backend/pkg/logging"
+	"tion.work/backend/pkg/metrics"
 	"tion.work/backend/services"
 )
 
@@ -19,6 +27,35 @@ func main() {
 		log.Fatalf("配置验证失败: %v", err)
 	}
 
+	// 用户与会话存放在 API 服务的数据库中，两个服务共用同一套登录和权限
+	if err := appconfig.InitConfig(); err != nil {
+		log.Fatalf("加载数据库配置失败: %v", err)
+	}
+	if err := logging.InitLogging(logging.Options{
+		Level:  appconfig.AppConfig.LogLevel,
+		Format: appconfig.AppConfig.LogFormat,
+	}); err != nil {
+		log.Fatalf("日志配置无效: %v", err)
+	}
+	if err := database.InitDatabase(); err != nil {
+		logging.Fatalf("数据库初始化失败: %v", err)
+	}
+	middleware.InitMiddleware()
+
+	// 限流与 API 服务使用相同的配置，使用 Redis 时两个服务共享计数
+	rateLimitStore, err := ratelimit.NewStore(appconfig.AppConfig.RateLimitStore, appconfig.AppConfig.RedisURL, appconfig.AppConfig.RateLimitMemorySize)
+	if err != nil {
+		logging.Fatalf("限流配置无效: %v", err)
+	}
+	authLimiter, err := ratelimit.New("auth", appconfig.AppConfig.RateLimitAuth, rateLimitStore)
+	if err != nil {
+		logging.Fatalf("限流配置无效: %v", err)
+	}
+	assistantLimiter, err := ratelimit.New("assistant", appconfig.AppConfig.RateLimitAssistant, rateLimitStore)
+	if err != nil {
+		logging.Fatalf("限流配置无效: %v", err)
+	}
+
 	// 设置 Gin 模式
 	if config.Debug {
 		gin.SetMode(gin.DebugMode)
@@ -26,17 +63,21 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// 创建路由器
-	r := gin.Default()
+	// 创建路由器，请求日志由 LoggingMiddleware 带请求 ID 记录
+	r := gin.New()
+	r.Use(gin.Recovery())
 
 	// 配置 CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader, "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
 		AllowCredentials: true,
 	}))
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.LoggingMiddleware())
+	r.Use(middleware.MetricsMiddleware())
 
 	// 创建服务
 	cursorService := services.NewCursorService(config.CursorAPIKey, config.Workspace)
@@ -47,16 +88,39 @@ func main() {
 		netlifyService = services.NewNetlifyService(config.NetlifyAuthToken, config.NetlifySiteID, config.Workspace)
 	}
 
+	// 项目权限检查，所有处理器在访问项目之前都要通过它
+	projectAccess := handlers.NewProjectAccess(cursorService)
+
+	// AI 任务的并发槽位、排队和每日预算
+	agentQuotas := accounts.NewAgentQuotaService(accounts.AgentQuotaOptions{
+		MaxConcurrent:           appconfig.AppConfig.AgentMaxConcurrent,
+		MaxConcurrentPerActor:   appconfig.AppConfig.AgentMaxConcurrentPerUser,
+		MaxConcurrentPerProject: appconfig.AppConfig.AgentMaxConcurrentPerProject,
+		QueueSize:               appconfig.AppConfig.AgentQueueSize,
+		QueueTimeout:            appconfig.AppConfig.AgentQueueTimeout,
+		DailyRuns:               appconfig.AppConfig.AgentDailyRuns,
+		DailyMinutes:            appconfig.AppConfig.AgentDailyMinutes,
+	})
+	if interrupted, err := agentQuotas.CloseInterruptedRuns(); err != nil {
+		logging.Fatalf("AI 任务记录初始化失败: %v", err)
+	} else if interrupted > 0 {
+		logging.Warnf("%d 个 AI 任务在上次停止服务时被中断", interrupted)
+	}
+
 	// 创建处理器
-	chatHandler := handlers.NewChatHandler(cursorService)
-	projectHandler := handlers.NewProjectHandler(cursorService, gitService)
-	gitHandler := handlers.NewGitHandler(gitService)
-	streamHandler := handlers.NewStreamHandler(cursorService)
-	monitorHandler := handlers.NewMonitorHandler(cursorService, gitService)
+	chatHandler := handlers.NewChatHandler(cursorService, projectAccess, agentQuotas)
+	quotaHandler := handlers.NewQuotaHandler(agentQuotas)
+	projectHandler := handlers.NewProjectHandler(cursorService, gitService, projectAccess)
+	gitHandler := handlers.NewGitHandler(gitService, projectAccess)
+	streamHandler := handlers.NewStreamHandler(cursorService, projectAccess)
+	monitorHandler := handlers.NewMonitorHandler(cursorService, gitService, projectAccess)
+
+	authHandler := handlers.NewAuthHandler(accounts.NewUserService(appconfig.AppConfig.SessionTTL))
+	grantHandler := handlers.NewGrantHandler(cursorService, accounts.NewProjectAccessService())
 
 	var deployHandler *handlers.DeployHandler
 	if netlifyService != nil {
-		deployHandler = handlers.NewDeployHandler(netlifyService)
+		deployHandler = handlers.NewDeployHandler(netlifyService, projectAccess)
 	}
 
 	// 健康检查
@@ -69,71 +133,104 @@ func main() {
 		})
 	})
 
+	// Prometheus 指标
+	r.GET("/metrics", middleware.MetricsAuthMiddleware(), gin.WrapH(metrics.Default.Handler()))
+
 	// 静态文件服务
 	r.Static("/static", "./static")
 	r.StaticFile("/", "./templates/enhanced-chat-app.html")
 	r.StaticFile("/chat", "./templates/chat-app.html") // 保留原版本
+	r.StaticFile("/login", "./templates/login.html")
+
+	// 权限检查，需在 AuthMiddleware 之后
+	projectsRead := middleware.RequireScope(models.ScopeProjectsRead)
+	projectsWrite := middleware.RequireScope(models.ScopeProjectsWrite)
+	gitRead := middleware.RequireScope(models.ScopeGitRead)
+	gitWrite := middleware.RequireScope(models.ScopeGitWrite)
+	statsRead := middleware.RequireScope(models.ScopeStatsRead)
+	deploy := middleware.RequireScope(models.ScopeDeploy)
+	adminOnly := middleware.RequireScope(models.ScopeAdmin)
+
+	// 登录会话
+	// 登录和所有修改操作都写入审计日志
+	auth := r.Group("/api/auth")
+	auth.Use(middleware.RateLimitMiddleware(authLimiter), middleware.AuditMiddleware())
+	{
+		auth.POST("/login", authHandler.HandleLogin)
+		auth.POST("/logout", authHandler.HandleLogout)
+		auth.GET("/me", middleware.AuthMiddleware(), authHandler.HandleMe)
+	}
 
-	// API 路由组
+	// API 路由组，需要登录或 API Key
 	api := r.Group("/api")
+	api.Use(middleware.AuditMiddleware(), middleware.AuthMiddleware(), middleware.RateLimitMiddleware(assistantLimiter))
 	{
-		// 聊天相关
-		api.POST("/chat", chatHandler.HandleChat)
-		api.POST("/chat/simple", chatHandler.HandleChatSimple)
-		api.POST("/review", chatHandler.HandleReview)
-		api.POST("/analyze", chatHandler.HandleAnalyze)
+		// 聊天相关（运行 AI 助手，可能修改项目文件）
+		api.POST("/chat", projectsWrite, chatHandler.HandleChat)
+		api.POST("/chat/simple", projectsWrite, chatHandler.HandleChatSimple)
+		api.POST("/review", projectsWrite, chatHandler.HandleReview)
+		api.POST("/analyze", projectsWrite, chatHandler.HandleAnalyze)
+
+		// AI 任务配额与使用量，admin 可查看所有用户
+		api.GET("/agent/quota", quotaHandler.HandleGetQuota)
+		api.GET("/agent/usage", quotaHandler.HandleGetUsage)
 
 		// 项目管理
-		api.GET("/projects", projectHandler.HandleGetProjects)
-		api.GET("/projects/:project", projectHandler.HandleGetProjectInfo)
-		api.GET("/projects/:project/status", projectHandler.HandleGetGitStatus)
-		api.POST("/projects/:project/install", projectHandler.HandleInstallDependencies)
-		api.POST("/projects/:project/build", projectHandler.HandleBuildProject)
-		api.POST("/projects/:project/validate", projectHandler.HandleValidateProject)
+		api.GET("/projects", projectsRead, projectHandler.HandleGetProjects)
+		api.GET("/projects/:project", projectsRead, projectHandler.HandleGetProjectInfo)
+		api.GET("/projects/:project/status", projectsRead, projectHandler.HandleGetGitStatus)
+		api.POST("/projects/:project/install", projectsWrite, projectHandler.HandleInstallDependencies)
+		api.POST("/projects/:project/build", projectsWrite, projectHandler.HandleBuildProject)
+		api.POST("/projects/:project/validate", projectsWrite, projectHandler.HandleValidateProject)
+
+		// 项目权限管理，项目为 * 时表示所有项目
+		api.GET("/projects/:project/grants", adminOnly, grantHandler.HandleGetGrants)
+		api.PUT("/projects/:project/grants", adminOnly, grantHandler.HandleSetGrant)
+		api.DELETE("/projects/:project/grants/:subjectType/:subjectID", adminOnly, grantHandler.HandleRevokeGrant)
 
 		// Git 操作
-		api.POST("/git/commit", gitHandler.HandleCommit)
-		api.POST("/git/push", gitHandler.HandlePush)
-		api.POST("/git/branch", gitHandler.HandleCreateBranch)
-		api.POST("/git/switch", gitHandler.HandleSwitchBranch)
-		api.GET("/git/:project/branches", gitHandler.HandleGetBranches)
-		api.GET("/git/:project/diff", gitHandler.HandleGetDiff)
-		api.POST("/git/:project/reset", gitHandler.HandleResetChanges)
+		api.POST("/git/commit", gitWrite, gitHandler.HandleCommit)
+		api.POST("/git/push", gitWrite, gitHandler.HandlePush)
+		api.POST("/git/branch", gitWrite, gitHandler.HandleCreateBranch)
+		api.POST("/git/switch", gitWrite, gitHandler.HandleSwitchBranch)
+		api.GET("/git/:project/branches", gitRead, gitHandler.HandleGetBranches)
+		api.GET("/git/:project/diff", gitRead, gitHandler.HandleGetDiff)
+		api.POST("/git/:project/reset", gitWrite, gitHandler.HandleResetChanges)
 
 		// 流式输出
-		api.POST("/stream/command", streamHandler.HandleStreamCommand)
-		api.GET("/stream/:project/logs", streamHandler.HandleStreamLogs)
+		api.POST("/stream/command", projectsWrite, streamHandler.HandleStreamCommand)
+		api.GET("/stream/:project/logs", projectsRead, streamHandler.HandleStreamLogs)
 
 		// 监控面板
-		api.GET("/monitor/stats", monitorHandler.HandleGetSystemStats)
-		api.GET("/monitor/projects", monitorHandler.HandleGetProjectStats)
-		api.GET("/monitor/health", monitorHandler.HandleGetServiceHealth)
+		api.GET("/monitor/stats", statsRead, monitorHandler.HandleGetSystemStats)
+		api.GET("/monitor/projects", statsRead, monitorHandler.HandleGetProjectStats)
+		api.GET("/monitor/health", statsRead, monitorHandler.HandleGetServiceHealth)
 
 		// 部署相关
 		if deployHandler != nil {
-			api.POST("/deploy", deployHandler.HandleDeploy)
-			api.POST("/deploy/stream", deployHandler.HandleDeployWithStream)
-			api.GET("/deploy/:deployId/status", deployHandler.HandleDeployStatus)
-			api.GET("/deploy/site/info", deployHandler.HandleGetSiteInfo)
+			api.POST("/deploy", deploy, deployHandler.HandleDeploy)
+			api.POST("/deploy/stream", deploy, deployHandler.HandleDeployWithStream)
+			api.GET("/deploy/:deployId/status", deploy, deployHandler.HandleDeployStatus)
+			api.GET("/deploy/site/info", deploy, deployHandler.HandleGetSiteInfo)
 		}
 	}
 
 	// 启动服务器
 	port := config.GetServerAddress()
-	log.Printf("🚀 AI 开发助手服务器启动在端口 %s", port)
-	log.Printf("📁 工作目录: %s", config.Workspace)
-	log.Printf("🔧 Cursor API Key: %s", maskAPIKey(config.CursorAPIKey))
+	logging.Infof("AI 开发助手服务器启动在端口 %s", port)
+	logging.Infof("工作目录: %s", config.Workspace)
+	logging.Infof("Cursor API Key: %s", maskAPIKey(config.CursorAPIKey))
 
 	if config.IsGitHubConfigured() {
-		log.Printf("🐙 GitHub 仓库: %s", config.GitHubRepo)
+		logging.Infof("GitHub 仓库: %s", config.GitHubRepo)
 	}
 
 	if config.IsNetlifyConfigured() {
-		log.Printf("🌐 Netlify 站点: %s", config.NetlifySiteID)
+		logging.Infof("Netlify 站点: %s", config.NetlifySiteID)
 	}
 
 	if err := r.Run(port); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+		logging.Fatalf("服务器启动失败: %v", err)
 	}
 }
 
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"tion.work/backend/internal/services"
+)
+
+// cliActor is recorded in the audit log for changes made on the command line
+const cliActor = "cli"
+
+// runAPIKeyCommand manages API keys
+func runAPIKeyCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return runAPIKeyList(args[1:])
+	case "create":
+		return runAPIKeyCreate(args[1:])
+	case "update":
+		return runAPIKeyUpdate(args[1:])
+	case "rotate":
+		return runAPIKeyRotate(args[1:])
+	case "revoke":
+		return runAPIKeyRevoke(args[1:])
+	}
+	return fmt.Errorf("unknown apikey command %q\n%s", args[0], commandUsage)
+}
+
+func runAPIKeyList(args []string) error {
+	flags := flag.NewFlagSet("apikey list", flag.ContinueOnError)
+	all := flags.Bool("all", false, "include revoked and expired keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	keys, err := services.NewAPIKeyService().ListAPIKeys(*all)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tKEY\tSCOPES\tSTATUS\tEXPIRES\tLAST USED")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.MaskedKey,
+			strings.Join(key.Scopes, ","), key.Status, formatCommandTime(key.ExpiresAt), formatCommandTime(key.LastUsedAt))
+	}
+	return w.Flush()
+}
+
+func runAPIKeyCreate(args []string) error {
+	flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the key")
+	description := flags.String("description", "", "what the key is used for")
+	scopes := flags.String("scopes", "", "comma separated scopes, at least one")
+	expires := flags.String("expires", "", "expiry as a duration such as 90d or 720h, or a date")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New(commandUsage)
+	}
+
+	input := services.APIKeyInput{
+		Name:        *name,
+		Description: *description,
+		Scopes:      splitCommandList(*scopes),
+	}
+	if *expires != "" {
+		expiresAt, err := parseExpiry(*expires)
+		if err != nil {
+			return err
+		}
+		input.ExpiresAt = &expiresAt
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	created, err := services.NewAPIKeyService().CreateAPIKey(input, cliActor)
+	if err != nil {
+		return err
+	}
+
+	printCreatedAPIKey(created)
+	return nil
+}
+
+func runAPIKeyUpdate(args []string) error {
+	flags := flag.NewFlagSet("apikey update", flag.ContinueOnError)
+	name := flags.String("name", "", "new name of the key")
+	description := flags.String("description", "", "new description of the key")
+	scopes := flags.String("scopes", "", "comma separated scopes replacing the current ones")
+	expires := flags.String("expires", "", "new expiry as a duration such as 90d or 720h, or a date")
+	noExpiry := flags.Bool("no-expiry", false, "remove the expiry")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := commandID(flags)
+	if err != nil {
+		return err
+	}
+
+	// Only flags given on the command line are changed
+	var update services.APIKeyUpdate
+	update.NeverExpires = *noExpiry
+	var parseErr error
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			update.Name = name
+		case "description":
+			update.Description = description
+		case "scopes":
+			update.Scopes = splitCommandList(*scopes)
+		case "expires":
+			expiresAt, err := parseExpiry(*expires)
+			if err != nil {
+				parseErr = err
+				return
+			}
+			update.ExpiresAt = &expiresAt
+		}
+	})
+	if parseErr != nil {
+		return parseErr
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	key, err := services.NewAPIKeyService().UpdateAPIKey(id, update, cliActor)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated API key %d (%s): scopes %s, expires %s\n",
+		key.ID, key.MaskedKey, strings.Join(key.Scopes, ","), formatCommandTime(key.ExpiresAt))
+	return nil
+}
+
+func runAPIKeyRotate(args []string) error {
+	flags := flag.NewFlagSet("apikey rotate", flag.ContinueOnError)
+	grace := flags.Duration("grace", services.DefaultRotationGrace, "how long the old key keeps working")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := commandID(flags)
+	if err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	created, err := services.NewAPIKeyService().RotateAPIKey(id, *grace, cliActor)
+	if err != nil {
+		return err
+	}
+
+	printCreatedAPIKey(created)
+	fmt.Fprintf(os.Stderr, "The old key %d keeps working for %s\n", id, *grace)
+	return nil
+}
+
+func runAPIKeyRevoke(args []string) error {
+	flags := flag.NewFlagSet("apikey revoke", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := commandID(flags)
+	if err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	key, err := services.NewAPIKeyService().RevokeAPIKey(id, cliActor)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked API key %d (%s)\n", key.ID, key.MaskedKey)
+	return nil
+}
+
+// printCreatedAPIKey prints the plain key alone on stdout, so it can be
+// captured by scripts, and its details on stderr
+func printCreatedAPIKey(created *services.CreatedAPIKey) {
+	key := created.APIKey
+	fmt.Fprintf(os.Stderr, "Created API key %d (%s) with scopes %s, expires %s\n",
+		key.ID, key.Name, strings.Join(key.Scopes, ","), formatCommandTime(key.ExpiresAt))
+	fmt.Fprintln(os.Stderr, "Store the key now, it will not be shown again:")
+	fmt.Fprintln(os.Stdout, created.Key)
+}
+
+// commandID reads the single ID argument of a command
+func commandID(flags *flag.FlagSet) (uint, error) {
+	if flags.NArg() != 1 {
+		return 0, errors.New(commandUsage)
+	}
+	id, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid API key ID %q", flags.Arg(0))
+	}
+	return uint(id), nil
+}
+
+// parseExpiry reads an expiry relative to now, as a number of days such as
+// 90d or a Go duration, or as an RFC 3339 time or a date
+func parseExpiry(value string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Now().AddDate(0, 0, n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid expiry %q, expected a duration such as 90d or a date", value)
+}
+
+// splitCommandList splits a comma separated flag value, skipping empty items
+func splitCommandList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func formatCommandTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/services"
+
+	"gorm.io/gorm/logger"
+)
+
+const commandUsage = `Usage:
+  server                                   start the API server
+  server catalog export [-format json|yaml|csv] [-o file]
+  server catalog import [-format json|yaml|csv] [-dry-run] <file|->
+  server apikey list [-all]
+  server apikey create -name name -scopes scope,... [-description text] [-expires 90d|date]
+  server apikey update [-name name] [-description text] [-scopes scope,...] [-expires 90d|date] [-no-expiry] <id>
+  server apikey rotate [-grace 24h] <id>
+  server apikey revoke <id>
+  server user list
+  server user create -username name -role viewer|editor|developer|admin [-email address]
+  server user update [-role role] [-email address] [-password] [-disable|-enable] <id>`
+
+// runCommand runs a command line subcommand instead of the server
+func runCommand(args []string) error {
+	switch args[0] {
+	case "catalog":
+		return runCatalogCommand(args[1:])
+	case "apikey":
+		return runAPIKeyCommand(args[1:])
+	case "user":
+		return runUserCommand(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(commandUsage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+}
+
+// initCommandDatabase opens the database with SQL logging on stderr, keeping
+// stdout free for command output
+func initCommandDatabase() error {
+	database.SetLogger(logger.New(log.New(os.Stderr, "", log.LstdFlags), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	}))
+	return database.InitDatabase()
+}
+
+// runCatalogCommand imports or exports the tool catalog
+func runCatalogCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandUsage)
+	}
+
+	switch args[0] {
+	case "export":
+		return runCatalogExport(args[1:])
+	case "import":
+		return runCatalogImport(args[1:])
+	}
+	return fmt.Errorf("unknown catalog command %q\n%s", args[0], commandUsage)
+}
+
+func runCatalogExport(args []string) error {
+	flags := flag.NewFlagSet("catalog export", flag.ContinueOnError)
+	format := flags.String("format", "", "output format: json, yaml or csv (default from -o extension, else yaml)")
+	output := flags.String("o", "-", "output file, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	name := *format
+	if name == "" {
+		name = services.CatalogFormatYAML
+		if *output != "-" {
+			name = strings.TrimPrefix(filepath.Ext(*output), ".")
+		}
+	}
+	catalogFormat, err := services.ParseCatalogFormat(name)
+	if err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	catalog, err := services.NewCatalogService().Export()
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := services.EncodeCatalog(w, catalog, catalogFormat); err != nil {
+		return err
+	}
+	if *output != "-" {
+		fmt.Fprintf(os.Stderr, "Exported %d categories, %d tags and %d tools to %s\n",
+			len(catalog.Categories), len(catalog.Tags), len(catalog.Tools), *output)
+	}
+	return nil
+}
+
+func runCatalogImport(args []string) error {
+	flags := flag.NewFlagSet("catalog import", flag.ContinueOnError)
+	format := flags.String("format", "", "input format: json, yaml or csv (default from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "report the changes without saving them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(commandUsage)
+	}
+	path := flags.Arg(0)
+
+	name := *format
+	if name == "" {
+		if path == "-" {
+			return errors.New("-format is required when reading from stdin")
+		}
+		name = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	catalogFormat, err := services.ParseCatalogFormat(name)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	catalog, err := services.DecodeCatalog(r, catalogFormat)
+	if err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	report, err := services.NewCatalogService().Import(catalog, *dryRun, "cli")
+	if err != nil {
+		return err
+	}
+
+	printImportReport(os.Stdout, report)
+	return nil
+}
+
+// printImportReport prints one line per created record and one line per changed
+// field of updated records, followed by totals
+func printImportReport(w io.Writer, report *services.ImportReport) {
+	for _, change := range report.Changes {
+		if change.Action == services.CatalogActionCreate {
+			fmt.Fprintf(w, "+ %s %s\n", change.Kind, change.Key)
+			continue
+		}
+		fmt.Fprintf(w, "~ %s %s\n", change.Kind, change.Key)
+		for _, field := range change.Fields {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", field.Field, field.Old, field.New)
+		}
+	}
+
+	summary := fmt.Sprintf("%d created, %d updated, %d unchanged", report.Created, report.Updated, report.Unchanged)
+	if report.DryRun {
+		summary += " (dry run, nothing saved)"
+	}
+	fmt.Fprintln(w, summary)
+}
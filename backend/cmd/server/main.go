@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"tion-backend/internal/api"
-	"tion-backend/internal/config"
-	"tion-backend/internal/database"
-	"tion-backend/pkg/logging"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // usage statistics accept IANA timezones, also in images without zoneinfo
+	"tion.work/backend/internal/api"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/pkg/logging"
 
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long in-flight requests and buffered usage get on shutdown
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Initialize configuration
 	if err := config.InitConfig(); err != nil {
@@ -17,18 +29,35 @@ func main() {
 	}
 
 	// Initialize logging
-	logging.InitLogging()
+	if err := logging.InitLogging(logging.Options{
+		Level:  config.AppConfig.LogLevel,
+		Format: config.AppConfig.LogFormat,
+	}); err != nil {
+		log.Fatal("Failed to initialize logging:", err)
+	}
+
+	// Run a command instead of the server, e.g. "server catalog export"
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	// Initialize database
 	if err := database.InitDatabase(); err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
+	logging.Infof("Using %s full-text search backend", database.GetSearchBackend())
 
 	// Set Gin mode
 	gin.SetMode(config.AppConfig.Mode)
 
-	// Create Gin engine
-	r := gin.Default()
+	// Create Gin engine. Requests are logged by the request logging
+	// middleware, with their request ID.
+	r := gin.New()
+	r.Use(gin.Recovery())
 
 	// Setup CORS
 	r.Use(func(c *gin.Context) {
@@ -45,13 +74,36 @@ func main() {
 	})
 
 	// Setup routes
-	api.SetupRoutes(r)
+	if err := api.SetupRoutes(r); err != nil {
+		log.Fatal("Failed to set up routes:", err)
+	}
+
+	// Start background jobs, stopped on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	api.StartScheduledJobs(ctx)
 
 	// Start server
 	port := config.AppConfig.Port
-	logging.Infof("Starting tion-backend server on port %s", port)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		logging.Infof("Starting tion-backend server on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	<-ctx.Done()
+	stop()
+	logging.Infof("Shutting down tion-backend server")
+
+	// Stop taking requests before draining what they queued
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logging.Errorf("Server shutdown failed: %v", err)
+	}
+	if err := api.StopBackgroundJobs(shutdownCtx); err != nil {
+		logging.Errorf("Failed to drain background jobs: %v", err)
 	}
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/services"
+)
+
+// runUserCommand manages user accounts. Passwords are read from stdin so
+// they do not end up in the shell history.
+func runUserCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(commandUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return runUserList(args[1:])
+	case "create":
+		return runUserCreate(args[1:])
+	case "update":
+		return runUserUpdate(args[1:])
+	}
+	return fmt.Errorf("unknown user command %q\n%s", args[0], commandUsage)
+}
+
+func runUserList(args []string) error {
+	flags := flag.NewFlagSet("user list", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	users, err := newCommandUserService().ListUsers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tROLE\tSTATUS\tLAST LOGIN")
+	for _, user := range users {
+		status := "active"
+		if user.Disabled {
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", user.ID, user.Username, user.Email,
+			user.Role, status, formatCommandTime(user.LastLoginAt))
+	}
+	return w.Flush()
+}
+
+func runUserCreate(args []string) error {
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := flags.String("username", "", "login name")
+	email := flags.String("email", "", "email address")
+	role := flags.String("role", "", "viewer, editor, developer or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New(commandUsage)
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	user, err := newCommandUserService().CreateUser(services.UserInput{
+		Username: *username,
+		Email:    *email,
+		Password: password,
+		Role:     *role,
+	}, cliActor)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created user %d (%s) with role %s\n", user.ID, user.Username, user.Role)
+	return nil
+}
+
+func runUserUpdate(args []string) error {
+	flags := flag.NewFlagSet("user update", flag.ContinueOnError)
+	role := flags.String("role", "", "new role")
+	email := flags.String("email", "", "new email address")
+	password := flags.Bool("password", false, "set a new password, read from stdin")
+	disable := flags.Bool("disable", false, "disable the user and end their sessions")
+	enable := flags.Bool("enable", false, "enable a disabled user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := commandID(flags)
+	if err != nil {
+		return err
+	}
+	if *disable && *enable {
+		return errors.New("-disable and -enable cannot be combined")
+	}
+
+	// Only flags given on the command line are changed
+	var update services.UserUpdate
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "role":
+			update.Role = role
+		case "email":
+			update.Email = email
+		case "disable":
+			update.Disabled = disable
+		case "enable":
+			disabled := !*enable
+			update.Disabled = &disabled
+		}
+	})
+	if *password {
+		value, err := readPassword()
+		if err != nil {
+			return err
+		}
+		update.Password = &value
+	}
+
+	if err := initCommandDatabase(); err != nil {
+		return err
+	}
+	user, err := newCommandUserService().UpdateUser(id, update, cliActor)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated user %d (%s): role %s, disabled %t\n", user.ID, user.Username, user.Role, user.Disabled)
+	return nil
+}
+
+func newCommandUserService() *services.UserService {
+	return services.NewUserService(config.AppConfig.SessionTTL)
+}
+
+// readPassword reads a password from the first line of stdin
+func readPassword() (string, error) {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", errors.New("no password given on stdin")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/services"
+)
+
+// ProjectAccess 项目访问控制。所有处理工作区项目的处理器在访问文件系统之前
+// 都必须通过它检查调用者对该项目的权限
+type ProjectAccess struct {
+	cursorService *services.CursorService
+}
+
+// NewProjectAccess 创建新的项目访问控制
+func NewProjectAccess(cursorService *services.CursorService) *ProjectAccess {
+	return &ProjectAccess{
+		cursorService: cursorService,
+	}
+}
+
+// Authorize 检查调用者拥有项目的指定权限，且项目是工作区中的有效项目。
+// 项目名称必须出现在 GetAvailableProjects 的结果中，因此不能借助路径跳出工作区。
+// 检查失败时写入错误响应并返回 false
+func (a *ProjectAccess) Authorize(c *gin.Context, project, right string) bool {
+	if !middleware.AuthorizeProject(c, project, right) {
+		return false
+	}
+
+	projects, err := a.cursorService.GetAvailableProjects()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	for _, available := range projects {
+		if available == project {
+			return true
+		}
+	}
+
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+		"success": false,
+		"error":   "项目不存在: " + project,
+	})
+	return false
+}
+
+// Visible 返回调用者至少拥有读取权限的项目，以及调用者在这些项目上的权限
+func (a *ProjectAccess) Visible(c *gin.Context) ([]string, map[string][]string, error) {
+	projects, err := a.cursorService.GetAvailableProjects()
+	if err != nil {
+		return nil, nil, err
+	}
+	rights, err := middleware.ProjectRights(c, projects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	visible := []string{}
+	visibleRights := make(map[string][]string, len(rights))
+	for _, project := range projects {
+		if list, ok := rights[project]; ok {
+			visible = append(visible, project)
+			visibleRights[project] = uniqueRights(list)
+		}
+	}
+	return visible, visibleRights, nil
+}
+
+// uniqueRights 去除重复的权限并排序
+func uniqueRights(rights []string) []string {
+	seen := make(map[string]bool, len(rights))
+	unique := []string{}
+	for _, right := range rights {
+		if !seen[right] {
+			seen[right] = true
+			unique = append(unique, right)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// Audit 记录对项目的操作及其结果，失败时同时记录错误信息
+func (a *ProjectAccess) Audit(c *gin.Context, action, project string, err error, details models.AuditDetails) {
+	if details == nil {
+		details = models.AuditDetails{}
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	middleware.RecordAudit(c, &models.AuditLog{
+		Action:     action,
+		TargetType: "project",
+		TargetID:   project,
+		Project:    project,
+		Outcome:    middleware.AuditOutcome(err),
+		Details:    details,
+	})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm/logger"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	accounts "tion.work/backend/internal/services"
+	"tion.work/backend/services"
+)
+
+// newAccessRouter 创建测试数据库和包含 site、blog 两个项目的工作区，
+// 返回挂载了聊天、Git 和部署处理器的路由
+func newAccessRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	database.SetLogger(logger.Discard)
+	db, err := database.Connect(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB = db
+
+	config.AppConfig = &config.Config{SessionTTL: time.Hour}
+	middleware.InitMiddleware()
+
+	workspace := t.TempDir()
+	for _, project := range []string{"site", "blog"} {
+		if err := os.MkdirAll(filepath.Join(workspace, "frontends", "frontends", project), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cursorService := services.NewCursorService("", workspace)
+	access := NewProjectAccess(cursorService)
+	quotas := accounts.NewAgentQuotaService(accounts.AgentQuotaOptions{MaxConcurrent: 1})
+	chatHandler := NewChatHandler(cursorService, access, quotas)
+	gitHandler := NewGitHandler(services.NewGitService(workspace, ""), access)
+	deployHandler := NewDeployHandler(services.NewNetlifyService("", "", workspace), access)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api", middleware.AuthMiddleware())
+	api.POST("/chat", chatHandler.HandleChat)
+	api.POST("/git/commit", gitHandler.HandleCommit)
+	api.POST("/git/push", gitHandler.HandlePush)
+	api.GET("/git/branches/:project", gitHandler.HandleGetBranches)
+	api.POST("/deploy", deployHandler.HandleDeploy)
+	return router
+}
+
+// 拒绝请求必须发生在处理器执行任何命令或访问文件系统之前
+func TestHandlersDenyProjectsWithoutRights(t *testing.T) {
+	router := newAccessRouter(t)
+
+	keys := accounts.NewAPIKeyService()
+	createKey := func(name string, scopes ...string) *accounts.CreatedAPIKey {
+		t.Helper()
+		created, err := keys.CreateAPIKey(accounts.APIKeyInput{Name: name, Scopes: scopes}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created
+	}
+	devKey := createKey("dev", models.ScopeProjectsWrite, models.ScopeGitWrite, models.ScopeDeploy)
+	adminKey := createKey("admin", models.ScopeAdmin)
+
+	users := accounts.NewUserService(time.Hour)
+	user, err := users.CreateUser(accounts.UserInput{Username: "dev", Password: "correct horse", Role: models.RoleDeveloper}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := users.Login("dev", "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	grants := accounts.NewProjectAccessService()
+	for _, input := range []accounts.ProjectGrantInput{
+		{SubjectType: models.GrantSubjectAPIKey, SubjectID: devKey.APIKey.ID, Rights: []string{models.ProjectRightRead}},
+		{SubjectType: models.GrantSubjectUser, SubjectID: user.ID, Rights: []string{models.ProjectRightAgent, models.ProjectRightCommit}},
+	} {
+		if _, err := grants.SetGrant("site", input, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		bearer string
+		method string
+		path   string
+		body   gin.H
+		status int
+	}{
+		{"chat without the agent right", devKey.Key, "", http.MethodPost, "/api/chat", gin.H{"project": "site", "prompt": "hi"}, http.StatusForbidden},
+		{"chat on an ungranted project", "", login.Token, http.MethodPost, "/api/chat", gin.H{"project": "blog", "prompt": "hi"}, http.StatusForbidden},
+		{"commit without the commit right", devKey.Key, "", http.MethodPost, "/api/git/commit", gin.H{"project": "site", "message": "update"}, http.StatusForbidden},
+		{"commit on an ungranted project", "", login.Token, http.MethodPost, "/api/git/commit", gin.H{"project": "blog", "message": "update"}, http.StatusForbidden},
+		{"push without the push right", "", login.Token, http.MethodPost, "/api/git/push", gin.H{"project": "site"}, http.StatusForbidden},
+		{"branches of an ungranted project", devKey.Key, "", http.MethodGet, "/api/git/branches/blog", nil, http.StatusForbidden},
+		{"deploy without the deploy right", devKey.Key, "", http.MethodPost, "/api/deploy", gin.H{"project": "site"}, http.StatusForbidden},
+		{"deploy on an ungranted project", "", login.Token, http.MethodPost, "/api/deploy", gin.H{"project": "blog"}, http.StatusForbidden},
+		{"admin chat outside the workspace", adminKey.Key, "", http.MethodPost, "/api/chat", gin.H{"project": "../site", "prompt": "hi"}, http.StatusNotFound},
+		{"admin commit to an unknown project", adminKey.Key, "", http.MethodPost, "/api/git/commit", gin.H{"project": "shop", "message": "update"}, http.StatusNotFound},
+		{"admin deploy of an unknown project", adminKey.Key, "", http.MethodPost, "/api/deploy", gin.H{"project": "shop"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			if tt.body != nil {
+				if err := json.NewEncoder(&body).Encode(tt.body); err != nil {
+					t.Fatal(err)
+				}
+			}
+			req := httptest.NewRequest(tt.method, tt.path, &body)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	accounts "tion.work/backend/internal/services"
+)
+
+// AuthHandler 登录会话处理器，与 API 服务共用用户和会话
+type AuthHandler struct {
+	userService *accounts.UserService
+}
+
+// NewAuthHandler 创建新的登录会话处理器
+func NewAuthHandler(userService *accounts.UserService) *AuthHandler {
+	return &AuthHandler{
+		userService: userService,
+	}
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AuthResponse 登录会话响应
+type AuthResponse struct {
+	Success bool             `json:"success"`
+	Message string           `json:"message,omitempty"`
+	Actor   string           `json:"actor,omitempty"`
+	User    *models.User     `json:"user,omitempty"`
+	Scopes  models.ScopeList `json:"scopes,omitempty"`
+	Token   string           `json:"token,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// HandleLogin 处理登录请求，会话令牌写入 Cookie 并在响应中返回
+func (h *AuthHandler) HandleLogin(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, AuthResponse{
+			Success: false,
+			Error:   "用户名和密码不能为空",
+		})
+		return
+	}
+
+	result, err := h.userService.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if errors.Is(err, accounts.ErrInvalidCredentials) {
+			c.JSON(http.StatusUnauthorized, AuthResponse{
+				Success: false,
+				Error:   "用户名或密码错误",
+			})
+			return
+		}
+		middleware.Logger(c).Errorf("登录失败: %v", err)
+		c.JSON(http.StatusInternalServerError, AuthResponse{
+			Success: false,
+			Error:   "登录失败",
+		})
+		return
+	}
+
+	middleware.SetSessionCookie(c, result.Token, result.ExpiresAt)
+	c.JSON(http.StatusOK, AuthResponse{
+		Success: true,
+		Message: "登录成功",
+		User:    &result.User,
+		Scopes:  result.Scopes,
+		Token:   result.Token,
+	})
+}
+
+// HandleLogout 处理退出登录请求
+func (h *AuthHandler) HandleLogout(c *gin.Context) {
+	if token := middleware.SessionToken(c); token != "" {
+		if err := h.userService.Logout(token); err != nil {
+			middleware.Logger(c).Errorf("退出登录失败: %v", err)
+			c.JSON(http.StatusInternalServerError, AuthResponse{
+				Success: false,
+				Error:   "退出登录失败",
+			})
+			return
+		}
+	}
+
+	middleware.ClearSessionCookie(c)
+	c.JSON(http.StatusOK, AuthResponse{
+		Success: true,
+		Message: "已退出登录",
+	})
+}
+
+// HandleMe 返回当前登录的用户及其权限
+func (h *AuthHandler) HandleMe(c *gin.Context) {
+	scopes, _ := c.Get(middleware.ScopesKey)
+	resp := AuthResponse{
+		Success: true,
+		Actor:   middleware.GetActor(c),
+	}
+	resp.Scopes, _ = scopes.(models.ScopeList)
+
+	if id := c.GetUint(middleware.UserIDKey); id != 0 {
+		user, err := h.userService.GetUser(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, AuthResponse{
+				Success: false,
+				Error:   "获取用户信息失败",
+			})
+			return
+		}
+		resp.User = user
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	accounts "tion.work/backend/internal/services"
 	"tion.work/backend/services"
 )
 
 // ChatHandler 聊天处理器
 type ChatHandler struct {
 	cursorService *services.CursorService
+	access        *ProjectAccess
+	quotas        *accounts.AgentQuotaService
 }
 
 // NewChatHandler 创建新的聊天处理器
-func NewChatHandler(cursorService *services.CursorService) *ChatHandler {
+func NewChatHandler(cursorService *services.CursorService, access *ProjectAccess, quotas *accounts.AgentQuotaService) *ChatHandler {
 	return &ChatHandler{
 		cursorService: cursorService,
+		access:        access,
+		quotas:        quotas,
 	}
 }
 
@@ -32,9 +42,10 @@ type ChatRequest struct {
 
 // ChatResponse 聊天响应结构
 type ChatResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	Success bool                       `json:"success"`
+	Message string                     `json:"message"`
+	Error   string                     `json:"error,omitempty"`
+	Budget  *accounts.AgentBudgetError `json:"budget,omitempty"` // 超出的每日预算
 }
 
 // HandleChat 处理聊天请求
@@ -65,6 +76,31 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 		return
 	}
 
+	h.streamAgent(c, req)
+}
+
+// streamAgent 检查项目权限后以 SSE 流式执行 AI 任务
+func (h *ChatHandler) streamAgent(c *gin.Context, req ChatRequest) {
+	if !h.access.Authorize(c, req.Project, models.ProjectRightAgent) {
+		return
+	}
+
+	// 创建 SSE 流
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, ChatResponse{
+			Success: false,
+			Error:   "不支持流式响应",
+		})
+		return
+	}
+
+	// 超出预算或队列已满时在开始流式响应之前拒绝
+	ticket, ok := h.enqueueAgent(c, req)
+	if !ok {
+		return
+	}
+
 	// 设置 CORS 头
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
@@ -75,13 +111,29 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 
-	// 创建 SSE 流
-	flusher, ok := c.Writer.(http.Flusher)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, ChatResponse{
-			Success: false,
-			Error:   "不支持流式响应",
-		})
+	// 没有空闲槽位时先告知排队位置，等待期间客户端断开则放弃排队
+	if position := ticket.Position(); position > 0 {
+		queuedResponse := map[string]interface{}{
+			"type":     "queued",
+			"message":  fmt.Sprintf("等待空闲的 AI 执行槽位，排在第 %d 位...", position),
+			"position": position,
+			"time":     time.Now().Format(time.RFC3339),
+		}
+
+		jsonData, _ := json.Marshal(queuedResponse)
+		fmt.Fprintf(c.Writer, "data: %s\n\n", string(jsonData))
+		flusher.Flush()
+	}
+	if err := ticket.Wait(c.Request.Context()); err != nil {
+		errorResponse := map[string]interface{}{
+			"type":    "error",
+			"message": agentQuotaMessage(err),
+			"time":    time.Now().Format(time.RFC3339),
+		}
+
+		jsonData, _ := json.Marshal(errorResponse)
+		fmt.Fprintf(c.Writer, "data: %s\n\n", string(jsonData))
+		flusher.Flush()
 		return
 	}
 
@@ -90,7 +142,7 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 	flusher.Flush()
 
 	// 执行 Cursor Agent 命令
-	err := h.cursorService.ExecuteCommandStream(req.Project, req.Prompt, func(line string) {
+	err := h.cursorService.ExecuteCommandStream(c.Request.Context(), req.Project, req.Prompt, func(line string) {
 		// 发送数据事件
 		response := map[string]interface{}{
 			"type":    "data",
@@ -102,6 +154,7 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 		fmt.Fprintf(c.Writer, "data: %s\n\n", string(jsonData))
 		flusher.Flush()
 	})
+	ticket.Finish(err)
 
 	if err != nil {
 		// 发送错误事件
@@ -157,11 +210,26 @@ func (h *ChatHandler) HandleChatSimple(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightAgent) {
+		return
+	}
+
+	req.Type = "chat"
+	ticket, ok := h.enqueueAgent(c, req)
+	if !ok {
+		return
+	}
+	if err := ticket.Wait(c.Request.Context()); err != nil {
+		h.handleQuotaError(c, err)
+		return
+	}
+
 	// 执行 Cursor Agent 命令
 	var result strings.Builder
-	err := h.cursorService.ExecuteCommand(req.Project, req.Prompt, func(line string) {
+	err := h.cursorService.ExecuteCommand(c.Request.Context(), req.Project, req.Prompt, func(line string) {
 		result.WriteString(line + "\n")
 	})
+	ticket.Finish(err)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ChatResponse{
@@ -188,6 +256,14 @@ func (h *ChatHandler) HandleReview(c *gin.Context) {
 		return
 	}
 
+	if req.Project == "" {
+		c.JSON(http.StatusBadRequest, ChatResponse{
+			Success: false,
+			Error:   "项目名称不能为空",
+		})
+		return
+	}
+
 	// 构建审查提示词
 	reviewPrompt := fmt.Sprintf("请对项目 %s 进行代码审查，重点关注代码质量、安全性、性能和最佳实践。", req.Project)
 	if req.Prompt != "" {
@@ -198,8 +274,8 @@ func (h *ChatHandler) HandleReview(c *gin.Context) {
 	req.Type = "review"
 	req.Prompt = reviewPrompt
 
-	// 调用聊天处理器
-	h.HandleChat(c)
+	// 请求体已读取，直接执行 AI 任务
+	h.streamAgent(c, req)
 }
 
 // HandleAnalyze 处理架构分析请求
@@ -213,6 +289,14 @@ func (h *ChatHandler) HandleAnalyze(c *gin.Context) {
 		return
 	}
 
+	if req.Project == "" {
+		c.JSON(http.StatusBadRequest, ChatResponse{
+			Success: false,
+			Error:   "项目名称不能为空",
+		})
+		return
+	}
+
 	// 构建分析提示词
 	analyzePrompt := fmt.Sprintf("请对项目 %s 进行架构分析，包括系统设计、组件结构、依赖关系和技术栈评估。", req.Project)
 	if req.Prompt != "" {
@@ -223,6 +307,75 @@ func (h *ChatHandler) HandleAnalyze(c *gin.Context) {
 	req.Type = "analyze"
 	req.Prompt = analyzePrompt
 
-	// 调用聊天处理器
-	h.HandleChat(c)
+	// 请求体已读取，直接执行 AI 任务
+	h.streamAgent(c, req)
+}
+
+// enqueueAgent 检查调用者的每日预算并为 AI 任务申请执行槽位，没有空闲槽位时排队。
+// 被拒绝时写入错误响应并返回 false
+func (h *ChatHandler) enqueueAgent(c *gin.Context, req ChatRequest) (*accounts.AgentTicket, bool) {
+	kind := req.Type
+	if kind == "" {
+		kind = "chat"
+	}
+
+	ticket, err := h.quotas.Enqueue(accounts.AgentRunRequest{
+		Actor:     middleware.GetActor(c),
+		Project:   req.Project,
+		Kind:      kind,
+		RequestID: middleware.GetRequestID(c),
+	})
+	if err != nil {
+		h.handleQuotaError(c, err)
+		return nil, false
+	}
+	return ticket, true
+}
+
+// handleQuotaError 将配额错误转换为响应：超出每日预算返回 429，
+// 执行槽位不足返回 503，两者都带有 Retry-After
+func (h *ChatHandler) handleQuotaError(c *gin.Context, err error) {
+	var budgetErr *accounts.AgentBudgetError
+	switch {
+	case errors.As(err, &budgetErr):
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(budgetErr.ResetsAt).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, ChatResponse{
+			Success: false,
+			Error:   agentQuotaMessage(err),
+			Budget:  budgetErr,
+		})
+	case errors.Is(err, accounts.ErrAgentQueueFull), errors.Is(err, accounts.ErrAgentQueueTimeout):
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, ChatResponse{
+			Success: false,
+			Error:   agentQuotaMessage(err),
+		})
+	case errors.Is(err, context.Canceled):
+		// 客户端已断开，不再响应
+		c.Abort()
+	default:
+		middleware.Logger(c).Errorf("AI 任务配额检查失败: %v", err)
+		c.JSON(http.StatusInternalServerError, ChatResponse{
+			Success: false,
+			Error:   "AI 任务配额检查失败",
+		})
+	}
+}
+
+// agentQuotaMessage 返回配额错误的提示信息
+func agentQuotaMessage(err error) string {
+	var budgetErr *accounts.AgentBudgetError
+	switch {
+	case errors.As(err, &budgetErr) && budgetErr.Budget == accounts.AgentBudgetRuns:
+		return fmt.Sprintf("今日 AI 运行次数已用完（%d 次），将于 %s 重置",
+			budgetErr.Limit, budgetErr.ResetsAt.Format(time.RFC3339))
+	case errors.As(err, &budgetErr):
+		return fmt.Sprintf("今日 AI 运行时长已用完（%d 分钟），将于 %s 重置",
+			budgetErr.Limit, budgetErr.ResetsAt.Format(time.RFC3339))
+	case errors.Is(err, accounts.ErrAgentQueueFull):
+		return "排队中的 AI 任务过多，请稍后再试"
+	case errors.Is(err, accounts.ErrAgentQueueTimeout):
+		return "等待 AI 执行槽位超时，请稍后再试"
+	}
+	return fmt.Sprintf("排队失败: %v", err)
 }
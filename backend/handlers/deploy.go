@@ -6,18 +6,21 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/models"
 	"tion.work/backend/services"
 )
 
 // DeployHandler 部署处理器
 type DeployHandler struct {
 	netlifyService *services.NetlifyService
+	access         *ProjectAccess
 }
 
 // NewDeployHandler 创建新的部署处理器
-func NewDeployHandler(netlifyService *services.NetlifyService) *DeployHandler {
+func NewDeployHandler(netlifyService *services.NetlifyService, access *ProjectAccess) *DeployHandler {
 	return &DeployHandler{
 		netlifyService: netlifyService,
+		access:         access,
 	}
 }
 
@@ -70,8 +73,13 @@ func (h *DeployHandler) HandleDeploy(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightDeploy) {
+		return
+	}
+
 	// 执行部署
-	deployResp, err := h.netlifyService.DeployProjectFromPath(req.Project, h.netlifyService.Workspace)
+	deployResp, err := h.netlifyService.DeployProjectFromPath(c.Request.Context(), req.Project, h.netlifyService.Workspace)
+	h.access.Audit(c, models.AuditProjectDeploy, req.Project, err, deployAuditDetails(deployResp))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, DeployResponse{
 			Success: false,
@@ -108,7 +116,7 @@ func (h *DeployHandler) HandleDeployStatus(c *gin.Context) {
 	}
 
 	// 获取部署状态
-	status, err := h.netlifyService.GetDeployStatus(deployID)
+	status, err := h.netlifyService.GetDeployStatus(c.Request.Context(), deployID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, DeployStatusResponse{
 			Success: false,
@@ -145,7 +153,7 @@ func (h *DeployHandler) HandleGetSiteInfo(c *gin.Context) {
 	}
 
 	// 获取站点信息
-	siteInfo, err := h.netlifyService.GetSiteInfo()
+	siteInfo, err := h.netlifyService.GetSiteInfo(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -189,6 +197,10 @@ func (h *DeployHandler) HandleDeployWithStream(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightDeploy) {
+		return
+	}
+
 	// 设置 CORS 头
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
@@ -214,7 +226,8 @@ func (h *DeployHandler) HandleDeployWithStream(c *gin.Context) {
 	flusher.Flush()
 
 	// 执行部署
-	deployResp, err := h.netlifyService.DeployProjectFromPath(req.Project, h.netlifyService.Workspace)
+	deployResp, err := h.netlifyService.DeployProjectFromPath(c.Request.Context(), req.Project, h.netlifyService.Workspace)
+	h.access.Audit(c, models.AuditProjectDeploy, req.Project, err, deployAuditDetails(deployResp))
 	if err != nil {
 		// 发送错误事件
 		errorResponse := map[string]interface{}{
@@ -239,3 +252,14 @@ func (h *DeployHandler) HandleDeployWithStream(c *gin.Context) {
 	fmt.Fprintf(c.Writer, "data: %s\n\n", string(jsonData))
 	flusher.Flush()
 }
+
+// deployAuditDetails 返回写入审计日志的部署信息
+func deployAuditDetails(deployResp *services.DeployResponse) models.AuditDetails {
+	if deployResp == nil {
+		return nil
+	}
+	return models.AuditDetails{
+		"deploy_id": deployResp.ID,
+		"url":       deployResp.URL,
+	}
+}
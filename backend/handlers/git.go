@@ -5,18 +5,21 @@ import (
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/models"
 	"tion.work/backend/services"
 )
 
 // GitHandler Git 操作处理器
 type GitHandler struct {
 	gitService *services.GitService
+	access     *ProjectAccess
 }
 
 // NewGitHandler 创建新的 Git 处理器
-func NewGitHandler(gitService *services.GitService) *GitHandler {
+func NewGitHandler(gitService *services.GitService, access *ProjectAccess) *GitHandler {
 	return &GitHandler{
 		gitService: gitService,
+		access:     access,
 	}
 }
 
@@ -74,8 +77,13 @@ func (h *GitHandler) HandleCommit(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightCommit) {
+		return
+	}
+
 	// 执行提交
-	err := h.gitService.CommitProject(req.Project, req.Message)
+	err := h.gitService.CommitProject(c.Request.Context(), req.Project, req.Message)
+	h.access.Audit(c, models.AuditGitCommit, req.Project, err, models.AuditDetails{"message": req.Message})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitResponse{
 			Success: false,
@@ -114,8 +122,13 @@ func (h *GitHandler) HandlePush(c *gin.Context) {
 		req.Branch = "main" // 默认分支
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightPush) {
+		return
+	}
+
 	// 执行推送
-	err := h.gitService.PushProject(req.Project, req.Branch)
+	err := h.gitService.PushProject(c.Request.Context(), req.Project, req.Branch)
+	h.access.Audit(c, models.AuditGitPush, req.Project, err, models.AuditDetails{"branch": req.Branch})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitResponse{
 			Success: false,
@@ -158,9 +171,14 @@ func (h *GitHandler) HandleCreateBranch(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightCommit) {
+		return
+	}
+
 	// 执行创建分支
 	projectPath := filepath.Join(h.gitService.Workspace, "frontends", "frontends", req.Project)
-	err := h.gitService.CreateBranch(projectPath, req.Branch)
+	err := h.gitService.CreateBranch(c.Request.Context(), projectPath, req.Branch)
+	h.access.Audit(c, models.AuditGitBranch, req.Project, err, models.AuditDetails{"branch": req.Branch})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitResponse{
 			Success: false,
@@ -203,9 +221,14 @@ func (h *GitHandler) HandleSwitchBranch(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightCommit) {
+		return
+	}
+
 	// 执行切换分支
 	projectPath := filepath.Join(h.gitService.Workspace, "frontends", "frontends", req.Project)
-	err := h.gitService.SwitchBranch(projectPath, req.Branch)
+	err := h.gitService.SwitchBranch(c.Request.Context(), projectPath, req.Branch)
+	h.access.Audit(c, models.AuditGitSwitch, req.Project, err, models.AuditDetails{"branch": req.Branch})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitResponse{
 			Success: false,
@@ -231,8 +254,12 @@ func (h *GitHandler) HandleGetBranches(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
 	projectPath := filepath.Join(h.gitService.Workspace, "frontends", "frontends", project)
-	branches, err := h.gitService.GetBranches(projectPath)
+	branches, err := h.gitService.GetBranches(c.Request.Context(), projectPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -258,8 +285,12 @@ func (h *GitHandler) HandleGetDiff(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
 	projectPath := filepath.Join(h.gitService.Workspace, "frontends", "frontends", project)
-	diff, err := h.gitService.GetDiff(projectPath)
+	diff, err := h.gitService.GetDiff(c.Request.Context(), projectPath)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -285,8 +316,14 @@ func (h *GitHandler) HandleResetChanges(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightCommit) {
+		return
+	}
+
 	projectPath := filepath.Join(h.gitService.Workspace, "frontends", "frontends", project)
-	err := h.gitService.ResetChanges(projectPath)
+	// git reset --hard 会丢弃未提交的更改，必须留下记录
+	err := h.gitService.ResetChanges(c.Request.Context(), projectPath)
+	h.access.Audit(c, models.AuditGitReset, project, err, nil)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitResponse{
 			Success: false,
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	accounts "tion.work/backend/internal/services"
+	"tion.work/backend/services"
+)
+
+// GrantHandler 项目权限管理处理器，为用户或 API Key 授予项目权限
+type GrantHandler struct {
+	cursorService *services.CursorService
+	accessService *accounts.ProjectAccessService
+}
+
+// NewGrantHandler 创建新的项目权限管理处理器
+func NewGrantHandler(cursorService *services.CursorService, accessService *accounts.ProjectAccessService) *GrantHandler {
+	return &GrantHandler{
+		cursorService: cursorService,
+		accessService: accessService,
+	}
+}
+
+// GrantResponse 项目权限响应
+type GrantResponse struct {
+	Success bool                  `json:"success"`
+	Message string                `json:"message,omitempty"`
+	Grant   *models.ProjectGrant  `json:"grant,omitempty"`
+	Grants  []models.ProjectGrant `json:"grants,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+// HandleGetGrants 获取项目上的权限，包括授予所有项目（*）的权限
+func (h *GrantHandler) HandleGetGrants(c *gin.Context) {
+	project := c.Param("project")
+	if !h.checkProject(c, project) {
+		return
+	}
+
+	grants, err := h.accessService.ListGrants(project)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, GrantResponse{
+		Success: true,
+		Grants:  grants,
+	})
+}
+
+// HandleSetGrant 设置用户或 API Key 在项目上的权限，替换已有的权限
+func (h *GrantHandler) HandleSetGrant(c *gin.Context) {
+	project := c.Param("project")
+	if !h.checkProject(c, project) {
+		return
+	}
+
+	var input accounts.ProjectGrantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, GrantResponse{
+			Success: false,
+			Error:   "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	grant, err := h.accessService.SetGrant(project, input, middleware.GetActor(c))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, GrantResponse{
+		Success: true,
+		Message: "项目权限已更新",
+		Grant:   grant,
+	})
+}
+
+// HandleRevokeGrant 撤销用户或 API Key 在项目上的全部权限
+func (h *GrantHandler) HandleRevokeGrant(c *gin.Context) {
+	project := c.Param("project")
+	if !h.checkProject(c, project) {
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("subjectID"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GrantResponse{
+			Success: false,
+			Error:   "无效的授权对象 ID",
+		})
+		return
+	}
+	subject := accounts.ProjectSubject{Type: c.Param("subjectType"), ID: uint(id)}
+
+	if err := h.accessService.RevokeGrant(project, subject, middleware.GetActor(c)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, GrantResponse{
+		Success: true,
+		Message: "项目权限已撤销",
+	})
+}
+
+// checkProject 检查项目是工作区中的有效项目或代表所有项目的 *
+func (h *GrantHandler) checkProject(c *gin.Context, project string) bool {
+	if project == models.AllProjects {
+		return true
+	}
+
+	projects, err := h.cursorService.GetAvailableProjects()
+	if err != nil {
+		h.handleError(c, err)
+		return false
+	}
+	for _, available := range projects {
+		if available == project {
+			return true
+		}
+	}
+
+	c.JSON(http.StatusNotFound, GrantResponse{
+		Success: false,
+		Error:   "项目不存在: " + project,
+	})
+	return false
+}
+
+// handleError 将服务错误转换为响应
+func (h *GrantHandler) handleError(c *gin.Context, err error) {
+	var validationErr *accounts.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, GrantResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, accounts.ErrUserNotFound),
+		errors.Is(err, accounts.ErrAPIKeyNotFound),
+		errors.Is(err, accounts.ErrProjectGrantNotFound):
+		c.JSON(http.StatusNotFound, GrantResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+	default:
+		middleware.Logger(c).Errorf("项目权限操作失败: %v", err)
+		c.JSON(http.StatusInternalServerError, GrantResponse{
+			Success: false,
+			Error:   "项目权限操作失败",
+		})
+	}
+}
@@ -19,14 +19,16 @@ import (
 type MonitorHandler struct {
 	cursorService *services.CursorService
 	gitService    *services.GitService
+	access        *ProjectAccess
 	startTime     time.Time
 }
 
 // NewMonitorHandler 创建新的监控处理器
-func NewMonitorHandler(cursorService *services.CursorService, gitService *services.GitService) *MonitorHandler {
+func NewMonitorHandler(cursorService *services.CursorService, gitService *services.GitService, access *ProjectAccess) *MonitorHandler {
 	return &MonitorHandler{
 		cursorService: cursorService,
 		gitService:    gitService,
+		access:        access,
 		startTime:     time.Now(),
 	}
 }
@@ -57,7 +59,7 @@ func (h *MonitorHandler) HandleGetSystemStats(c *gin.Context) {
 		MemoryUsage:   h.getMemoryUsage(),
 		DiskUsage:     h.getDiskUsage(),
 		CPUUsage:      h.getCPUUsage(),
-		ProjectStats:  h.getProjectStats(),
+		ProjectStats:  h.getProjectStats(c),
 		ServiceStatus: h.getServiceStatus(),
 	}
 
@@ -70,7 +72,7 @@ func (h *MonitorHandler) HandleGetSystemStats(c *gin.Context) {
 
 // HandleGetProjectStats 获取项目统计信息
 func (h *MonitorHandler) HandleGetProjectStats(c *gin.Context) {
-	projectStats := h.getDetailedProjectStats()
+	projectStats := h.getDetailedProjectStats(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -157,9 +159,9 @@ func (h *MonitorHandler) getCPUUsage() map[string]interface{} {
 	}
 }
 
-// getProjectStats 获取项目统计信息
-func (h *MonitorHandler) getProjectStats() map[string]interface{} {
-	projects, err := h.cursorService.GetAvailableProjects()
+// getProjectStats 获取调用者可读项目的统计信息
+func (h *MonitorHandler) getProjectStats(c *gin.Context) map[string]interface{} {
+	projects, _, err := h.access.Visible(c)
 	if err != nil {
 		return map[string]interface{}{
 			"error": "无法获取项目列表",
@@ -206,9 +208,9 @@ func (h *MonitorHandler) getServiceStatus() map[string]interface{} {
 	return status
 }
 
-// getDetailedProjectStats 获取详细的项目统计信息
-func (h *MonitorHandler) getDetailedProjectStats() *ProjectStats {
-	projects, err := h.cursorService.GetAvailableProjects()
+// getDetailedProjectStats 获取调用者可读项目的详细统计信息
+func (h *MonitorHandler) getDetailedProjectStats(c *gin.Context) *ProjectStats {
+	projects, _, err := h.access.Visible(c)
 	if err != nil {
 		return &ProjectStats{
 			TotalProjects: 0,
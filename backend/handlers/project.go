@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/models"
 	"tion.work/backend/services"
 )
 
@@ -11,21 +12,24 @@ import (
 type ProjectHandler struct {
 	cursorService *services.CursorService
 	gitService    *services.GitService
+	access        *ProjectAccess
 }
 
 // NewProjectHandler 创建新的项目管理处理器
-func NewProjectHandler(cursorService *services.CursorService, gitService *services.GitService) *ProjectHandler {
+func NewProjectHandler(cursorService *services.CursorService, gitService *services.GitService, access *ProjectAccess) *ProjectHandler {
 	return &ProjectHandler{
 		cursorService: cursorService,
 		gitService:    gitService,
+		access:        access,
 	}
 }
 
 // ProjectListResponse 项目列表响应
 type ProjectListResponse struct {
-	Success bool     `json:"success"`
-	Projects []string `json:"projects"`
-	Error   string   `json:"error,omitempty"`
+	Success  bool                `json:"success"`
+	Projects []string            `json:"projects"`
+	Rights   map[string][]string `json:"rights,omitempty"` // 调用者在每个项目上的权限
+	Error    string              `json:"error,omitempty"`
 }
 
 // ProjectInfoResponse 项目信息响应
@@ -42,9 +46,9 @@ type GitStatusResponse struct {
 	Error   string                 `json:"error,omitempty"`
 }
 
-// HandleGetProjects 获取项目列表
+// HandleGetProjects 获取调用者有权读取的项目列表
 func (h *ProjectHandler) HandleGetProjects(c *gin.Context) {
-	projects, err := h.cursorService.GetAvailableProjects()
+	projects, rights, err := h.access.Visible(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ProjectListResponse{
 			Success: false,
@@ -56,6 +60,7 @@ func (h *ProjectHandler) HandleGetProjects(c *gin.Context) {
 	c.JSON(http.StatusOK, ProjectListResponse{
 		Success:  true,
 		Projects: projects,
+		Rights:   rights,
 	})
 }
 
@@ -70,6 +75,10 @@ func (h *ProjectHandler) HandleGetProjectInfo(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
 	info, err := h.cursorService.GetProjectStatus(project)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ProjectInfoResponse{
@@ -96,7 +105,11 @@ func (h *ProjectHandler) HandleGetGitStatus(c *gin.Context) {
 		return
 	}
 
-	status, err := h.gitService.GetProjectGitStatus(project)
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
+	status, err := h.gitService.GetProjectGitStatus(c.Request.Context(), project)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GitStatusResponse{
 			Success: false,
@@ -122,7 +135,11 @@ func (h *ProjectHandler) HandleInstallDependencies(c *gin.Context) {
 		return
 	}
 
-	err := h.cursorService.InstallDependencies(project)
+	if !h.access.Authorize(c, project, models.ProjectRightAgent) {
+		return
+	}
+
+	err := h.cursorService.InstallDependencies(c.Request.Context(), project)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -148,7 +165,11 @@ func (h *ProjectHandler) HandleBuildProject(c *gin.Context) {
 		return
 	}
 
-	err := h.cursorService.BuildProject(project)
+	if !h.access.Authorize(c, project, models.ProjectRightAgent) {
+		return
+	}
+
+	err := h.cursorService.BuildProject(c.Request.Context(), project)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -174,6 +195,10 @@ func (h *ProjectHandler) HandleValidateProject(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
 	err := h.cursorService.ValidateProject(project)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	accounts "tion.work/backend/internal/services"
+)
+
+// QuotaHandler AI 任务配额处理器，查询执行槽位、每日预算和使用量报告
+type QuotaHandler struct {
+	quotas *accounts.AgentQuotaService
+}
+
+// NewQuotaHandler 创建新的配额处理器
+func NewQuotaHandler(quotas *accounts.AgentQuotaService) *QuotaHandler {
+	return &QuotaHandler{
+		quotas: quotas,
+	}
+}
+
+// QuotaResponse 配额响应
+type QuotaResponse struct {
+	Success bool                       `json:"success"`
+	Quota   *accounts.AgentQuotaStatus `json:"quota,omitempty"`
+	Usage   []accounts.AgentUsage      `json:"usage,omitempty"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+// HandleGetQuota 获取调用者今日的配额使用情况和当前的执行槽位，
+// admin 可以通过 actor 参数查看其他用户或 API Key
+func (h *QuotaHandler) HandleGetQuota(c *gin.Context) {
+	actor, ok := h.actor(c)
+	if !ok {
+		return
+	}
+	if actor == "" {
+		actor = middleware.GetActor(c)
+	}
+
+	status, err := h.quotas.Status(actor)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, QuotaResponse{
+		Success: true,
+		Quota:   status,
+	})
+}
+
+// HandleGetUsage 获取每日 AI 任务使用量（按 UTC 日期和操作者汇总），
+// 支持 from、to（YYYY-MM-DD）、project 和 actor 参数。非 admin 只能查看自己的使用量
+func (h *QuotaHandler) HandleGetUsage(c *gin.Context) {
+	actor, ok := h.actor(c)
+	if !ok {
+		return
+	}
+
+	usage, err := h.quotas.Usage(accounts.AgentUsageFilter{
+		Actor:   actor,
+		Project: c.Query("project"),
+		From:    c.Query("from"),
+		To:      c.Query("to"),
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, QuotaResponse{
+		Success: true,
+		Usage:   usage,
+	})
+}
+
+// actor 返回要查询的操作者：admin 使用 actor 参数（为空时表示所有操作者），
+// 其他调用者只能查询自己，查询他人时返回 403
+func (h *QuotaHandler) actor(c *gin.Context) (string, bool) {
+	actor := c.Query("actor")
+	if middleware.HasScope(c, models.ScopeAdmin) {
+		return actor, true
+	}
+
+	self := middleware.GetActor(c)
+	if actor != "" && actor != self {
+		c.JSON(http.StatusForbidden, QuotaResponse{
+			Success: false,
+			Error:   "只有 admin 可以查看其他用户的配额",
+		})
+		return "", false
+	}
+	return self, true
+}
+
+// handleError 将服务错误转换为响应
+func (h *QuotaHandler) handleError(c *gin.Context, err error) {
+	var validationErr *accounts.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusBadRequest, QuotaResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	middleware.Logger(c).Errorf("查询 AI 任务配额失败: %v", err)
+	c.JSON(http.StatusInternalServerError, QuotaResponse{
+		Success: false,
+		Error:   "查询 AI 任务配额失败",
+	})
+}
@@ -7,18 +7,21 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"tion.work/backend/internal/models"
 	"tion.work/backend/services"
 )
 
 // StreamHandler 流式输出处理器
 type StreamHandler struct {
 	cursorService *services.CursorService
+	access        *ProjectAccess
 }
 
 // NewStreamHandler 创建新的流式输出处理器
-func NewStreamHandler(cursorService *services.CursorService) *StreamHandler {
+func NewStreamHandler(cursorService *services.CursorService, access *ProjectAccess) *StreamHandler {
 	return &StreamHandler{
 		cursorService: cursorService,
+		access:        access,
 	}
 }
 
@@ -66,6 +69,10 @@ func (h *StreamHandler) HandleStreamCommand(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, req.Project, models.ProjectRightAgent) {
+		return
+	}
+
 	// 设置 CORS 头
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
@@ -157,7 +164,7 @@ func (h *StreamHandler) handleBuildCommand(project string, c *gin.Context, flush
 	flusher.Flush()
 
 	// 执行构建
-	err := h.cursorService.BuildProject(project)
+	err := h.cursorService.BuildProject(c.Request.Context(), project)
 	if err != nil {
 		return err
 	}
@@ -225,7 +232,7 @@ func (h *StreamHandler) handleInstallCommand(project string, c *gin.Context, flu
 	flusher.Flush()
 
 	// 执行安装
-	err := h.cursorService.InstallDependencies(project)
+	err := h.cursorService.InstallDependencies(c.Request.Context(), project)
 	if err != nil {
 		return err
 	}
@@ -291,6 +298,10 @@ func (h *StreamHandler) HandleStreamLogs(c *gin.Context) {
 		return
 	}
 
+	if !h.access.Authorize(c, project, models.ProjectRightRead) {
+		return
+	}
+
 	// 设置 CORS 头
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
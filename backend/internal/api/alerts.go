@@ -0,0 +1,69 @@
+package api
+
+import (
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	alertEngine    *services.AlertEngine
+	requestMetrics *services.RequestMetrics
+)
+
+// GetAlerts gets a page of alerts, newest first, optionally filtered by status
+func GetAlerts(c *gin.Context) {
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid offset")
+		return
+	}
+
+	page, err := alertEngine.ListAlerts(c.Query("status"), limit, offset)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, page)
+}
+
+// GetAlertRules lists the configured alert rules
+func GetAlertRules(c *gin.Context) {
+	response.Success(c, gin.H{
+		"rules": alertEngine.Rules(),
+	})
+}
+
+// EvaluateAlerts evaluates the alert rules now
+func EvaluateAlerts(c *gin.Context) {
+	summary, err := alertEngine.Evaluate(c.Request.Context())
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"evaluation": summary,
+	})
+}
+
+// TestAlertNotifiers sends a test notification through the notifier given by
+// the notifier parameter, or through every notifier
+func TestAlertNotifiers(c *gin.Context) {
+	results, err := alertEngine.TestNotifiers(c.Request.Context(), c.Query("notifier"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"notifiers": results,
+	})
+}
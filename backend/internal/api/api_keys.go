@@ -0,0 +1,130 @@
+package api
+
+import (
+	"time"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var apiKeyService *services.APIKeyService
+
+// GetAPIKeys lists the API keys. Revoked and expired keys are included when
+// include_inactive is true.
+func GetAPIKeys(c *gin.Context) {
+	keys, err := apiKeyService.ListAPIKeys(c.Query("include_inactive") == "true")
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"api_keys": keys,
+	})
+}
+
+// GetAPIKey gets a single API key
+func GetAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	key, err := apiKeyService.GetAPIKey(id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"api_key": key,
+	})
+}
+
+// CreateAPIKey creates an API key. The plain key is only part of this response.
+func CreateAPIKey(c *gin.Context) {
+	var input services.APIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	created, err := apiKeyService.CreateAPIKey(input, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "API key created, it will not be shown again", created)
+}
+
+// UpdateAPIKey changes the name, description, scopes or expiry of an API key
+func UpdateAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	var update services.APIKeyUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	key, err := apiKeyService.UpdateAPIKey(id, update, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "API key updated successfully", gin.H{
+		"api_key": key,
+	})
+}
+
+// RotateAPIKey replaces an API key with a new one. The old key keeps working
+// for the grace period given by the grace parameter.
+func RotateAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	grace := services.DefaultRotationGrace
+	if value := c.Query("grace"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			response.BadRequest(c, "Invalid grace period")
+			return
+		}
+		grace = parsed
+	}
+
+	created, err := apiKeyService.RotateAPIKey(id, grace, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "API key rotated, the new key will not be shown again", created)
+}
+
+// RevokeAPIKey revokes an API key immediately
+func RevokeAPIKey(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid API key ID")
+	if !ok {
+		return
+	}
+
+	key, err := apiKeyService.RevokeAPIKey(id, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "API key revoked successfully", gin.H{
+		"api_key": key,
+	})
+}
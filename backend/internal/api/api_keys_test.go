@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/services"
+)
+
+// createTestKey creates an API key with the given scopes and returns the plain key
+func createTestKey(t *testing.T, name string, scopes ...string) (*services.CreatedAPIKey, string) {
+	t.Helper()
+	created, err := services.NewAPIKeyService().CreateAPIKey(services.APIKeyInput{Name: name, Scopes: scopes}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created, created.Key
+}
+
+func TestAdminRouteScopes(t *testing.T) {
+	router := newTestRouter(t)
+	config.AppConfig.APIKey = "bootstrap"
+
+	_, toolsRead := createTestKey(t, "tools read", models.ScopeToolsRead)
+	_, toolsWrite := createTestKey(t, "tools write", models.ScopeToolsWrite)
+	_, statsRead := createTestKey(t, "stats", models.ScopeStatsRead)
+	_, admin := createTestKey(t, "admin", models.ScopeAdmin)
+	names := map[string]string{toolsRead: "tools:read", toolsWrite: "tools:write", statsRead: "stats:read", admin: "admin", "bootstrap": "the bootstrap key"}
+
+	tests := []struct {
+		method, path string
+		allowed      []string
+		denied       []string
+	}{
+		{http.MethodGet, "/api/admin/tools", []string{toolsRead, toolsWrite, admin, "bootstrap"}, []string{statsRead}},
+		{http.MethodPost, "/api/admin/tags", nil, []string{toolsRead, statsRead}},
+		{http.MethodGet, "/api/admin/stats", []string{statsRead, admin}, []string{toolsRead, toolsWrite}},
+		{http.MethodGet, "/api/stats/visitors", []string{statsRead, admin}, []string{toolsWrite}},
+		{http.MethodGet, "/api/admin/alerts", []string{statsRead}, []string{toolsWrite}},
+		{http.MethodPost, "/api/admin/stats/usage/rollup", []string{admin}, []string{statsRead, toolsWrite}},
+		{http.MethodPost, "/api/admin/stats/scores/refresh", []string{admin}, []string{statsRead}},
+		{http.MethodGet, "/api/admin/api-keys", []string{admin, "bootstrap"}, []string{toolsWrite, statsRead}},
+		{http.MethodGet, "/api/admin/audit", []string{admin}, []string{statsRead}},
+	}
+	for _, tt := range tests {
+		route := tt.method + " " + tt.path
+		if code := serveJSON(t, router, tt.method, tt.path, "", nil); code != http.StatusUnauthorized {
+			t.Errorf("%s without a key: status %d, want 401", route, code)
+		}
+		if code := serveJSON(t, router, tt.method, tt.path, "tion_unknown", nil); code != http.StatusUnauthorized {
+			t.Errorf("%s with an unknown key: status %d, want 401", route, code)
+		}
+		for _, key := range tt.allowed {
+			if code := serveJSON(t, router, tt.method, tt.path, key, nil); code != http.StatusOK {
+				t.Errorf("%s with %s: status %d, want 200", route, names[key], code)
+			}
+		}
+		for _, key := range tt.denied {
+			if code := serveJSON(t, router, tt.method, tt.path, key, nil); code != http.StatusForbidden {
+				t.Errorf("%s with %s: status %d, want 403", route, names[key], code)
+			}
+		}
+	}
+}
+
+func TestRotateAPIKeyGrace(t *testing.T) {
+	router := newTestRouter(t)
+	config.AppConfig.APIKey = "bootstrap"
+	old, oldKey := createTestKey(t, "stats", models.ScopeStatsRead)
+	rotatePath := fmt.Sprintf("/api/admin/api-keys/%d/rotate", old.APIKey.ID)
+
+	if code := serveJSON(t, router, http.MethodPost, rotatePath+"?grace=soon", "bootstrap", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid grace: status %d, want 400", code)
+	}
+	if code := serveJSON(t, router, http.MethodPost, rotatePath+"?grace=1000h", "bootstrap", nil); code != http.StatusBadRequest {
+		t.Errorf("grace over 30 days: status %d, want 400", code)
+	}
+
+	var rotated services.CreatedAPIKey
+	if code := serveJSON(t, router, http.MethodPost, rotatePath+"?grace=1h", "bootstrap", &rotated); code != http.StatusOK {
+		t.Fatalf("rotate: status %d", code)
+	}
+	if rotated.Key == "" || rotated.APIKey.RotatedFrom == nil || *rotated.APIKey.RotatedFrom != old.APIKey.ID {
+		t.Fatalf("rotated key %+v, want a new key replacing %d", rotated.APIKey, old.APIKey.ID)
+	}
+	for name, key := range map[string]string{"old": oldKey, "new": rotated.Key} {
+		if code := serveJSON(t, router, http.MethodGet, "/api/admin/stats", key, nil); code != http.StatusOK {
+			t.Errorf("%s key during the grace period: status %d, want 200", name, code)
+		}
+	}
+
+	var view struct {
+		APIKey services.APIKeyView `json:"api_key"`
+	}
+	if code := serveJSON(t, router, http.MethodGet, fmt.Sprintf("/api/admin/api-keys/%d", old.APIKey.ID), "bootstrap", &view); code != http.StatusOK {
+		t.Fatalf("get old key: status %d", code)
+	}
+	if view.APIKey.Status != services.APIKeyRotating {
+		t.Errorf("old key %s, want rotating", view.APIKey.Status)
+	}
+
+	// Without a grace period the replaced key stops working right away
+	if code := serveJSON(t, router, http.MethodPost, fmt.Sprintf("/api/admin/api-keys/%d/rotate?grace=0s", rotated.APIKey.ID), "bootstrap", nil); code != http.StatusOK {
+		t.Fatalf("rotate without grace: status %d", code)
+	}
+	if code := serveJSON(t, router, http.MethodGet, "/api/admin/stats", rotated.Key, nil); code != http.StatusUnauthorized {
+		t.Errorf("key rotated without grace: status %d, want 401", code)
+	}
+
+	// Revoking ends the grace period of the old key
+	if code := serveJSON(t, router, http.MethodPost, fmt.Sprintf("/api/admin/api-keys/%d/revoke", old.APIKey.ID), "bootstrap", nil); code != http.StatusOK {
+		t.Fatalf("revoke: status %d", code)
+	}
+	if code := serveJSON(t, router, http.MethodGet, "/api/admin/stats", oldKey, nil); code != http.StatusUnauthorized {
+		t.Errorf("revoked key: status %d, want 401", code)
+	}
+	if code := serveJSON(t, router, http.MethodPost, rotatePath, "bootstrap", nil); code != http.StatusConflict {
+		t.Errorf("rotate a revoked key: status %d, want 409", code)
+	}
+}
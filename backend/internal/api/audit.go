@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditService *services.AuditService
+
+// GetAuditLog gets a page of audit log entries, newest first
+func GetAuditLog(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid offset")
+		return
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+
+	page, err := auditService.ListAuditLog(filter)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, page)
+}
+
+// ExportAuditLog downloads every audit log entry matching the filters, oldest
+// first, as JSON lines or CSV
+func ExportAuditLog(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", services.AuditFormatJSONL)
+	contentType := "application/x-ndjson"
+	switch format {
+	case services.AuditFormatJSONL:
+	case services.AuditFormatCSV:
+		contentType = "text/csv; charset=utf-8"
+	default:
+		response.BadRequest(c, "Invalid format, expected jsonl or csv")
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the export short
+	if err := auditService.ExportAuditLog(c.Writer, filter, format); err != nil {
+		middleware.Logger(c).Errorf("Audit log export failed: %v", err)
+	}
+}
+
+// VerifyAuditLog checks that no audit log entry was changed, removed or
+// reordered since it was written
+func VerifyAuditLog(c *gin.Context) {
+	result, err := auditService.VerifyAuditChain()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"verification": result,
+	})
+}
+
+// parseAuditFilter reads the audit log filters from the query. It responds
+// with 400 and returns false when the time range is invalid.
+func parseAuditFilter(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Project:    c.Query("project"),
+		RequestID:  c.Query("request_id"),
+		Outcome:    c.Query("outcome"),
+	}
+	if value := c.Query("from"); value != "" {
+		t, err := parseTimeParam(value, time.UTC)
+		if err != nil {
+			response.BadRequest(c, "Invalid from, expected RFC 3339 or YYYY-MM-DD")
+			return filter, false
+		}
+		filter.From = t
+	}
+	if value := c.Query("to"); value != "" {
+		t, err := parseTimeParam(value, time.UTC)
+		if err != nil {
+			response.BadRequest(c, "Invalid to, expected RFC 3339 or YYYY-MM-DD")
+			return filter, false
+		}
+		filter.To = t
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		response.BadRequest(c, "Invalid range, from must be before to")
+		return filter, false
+	}
+	return filter, true
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var userService *services.UserService
+
+// LoginRequest holds the credentials of a sign in
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Login checks the credentials and starts a session. The token is set as a
+// cookie for browsers and returned for clients that send it as a bearer token.
+func Login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	result, err := userService.Login(req.Username, req.Password, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			response.Unauthorized(c, err.Error())
+			return
+		}
+		handleServiceError(c, err)
+		return
+	}
+
+	middleware.SetSessionCookie(c, result.Token, result.ExpiresAt)
+	response.Success(c, result)
+}
+
+// Logout ends the session of the request, if any, and clears the cookie
+func Logout(c *gin.Context) {
+	if token := middleware.SessionToken(c); token != "" {
+		if err := userService.Logout(token); err != nil {
+			handleServiceError(c, err)
+			return
+		}
+	}
+
+	middleware.ClearSessionCookie(c)
+	response.SuccessWithMessage(c, "Signed out", nil)
+}
+
+// GetCurrentUser describes who is authenticated and which scopes they hold
+func GetCurrentUser(c *gin.Context) {
+	scopes, _ := c.Get(middleware.ScopesKey)
+	data := gin.H{
+		"actor":  middleware.GetActor(c),
+		"scopes": scopes,
+	}
+	if id := c.GetUint(middleware.UserIDKey); id != 0 {
+		user, err := userService.GetUser(id)
+		if err != nil {
+			handleServiceError(c, err)
+			return
+		}
+		data["user"] = user
+	}
+
+	response.Success(c, data)
+}
+
+// GetUsers lists every user
+func GetUsers(c *gin.Context) {
+	users, err := userService.ListUsers()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"users": users,
+		"roles": models.RoleScopes,
+	})
+}
+
+// GetUser gets a single user
+func GetUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	user, err := userService.GetUser(id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"user": user,
+	})
+}
+
+// CreateUser creates a user with a password and role
+func CreateUser(c *gin.Context) {
+	var input services.UserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	user, err := userService.CreateUser(input, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "User created successfully", gin.H{
+		"user": user,
+	})
+}
+
+// UpdateUser changes the email, password, role or disabled state of a user
+func UpdateUser(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
+
+	var update services.UserUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	user, err := userService.UpdateUser(id, update, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "User updated successfully", gin.H{
+		"user": user,
+	})
+}
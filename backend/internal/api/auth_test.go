@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginAs creates a user with the role and returns the token of a new session
+func loginAs(t *testing.T, username, role string) string {
+	t.Helper()
+	users := services.NewUserService(time.Hour)
+	if _, err := users.CreateUser(services.UserInput{Username: username, Password: "correct horse", Role: role}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	login, err := users.Login(username, "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return login.Token
+}
+
+// serveWithSession serves a request carrying a session token as a bearer token
+func serveWithSession(router *gin.Engine, method, path, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestAdminRouteRoles(t *testing.T) {
+	router := newTestRouter(t)
+	tokens := map[string]string{}
+	for _, role := range models.Roles {
+		tokens[role] = loginAs(t, role, role)
+	}
+
+	// Routes that need more than the role grants are forbidden; the others
+	// reach their handler, which may still reject the empty request
+	tests := []struct {
+		method, path string
+		forbidden    []string
+	}{
+		{http.MethodGet, "/api/admin/tools", nil},
+		{http.MethodPost, "/api/admin/tags", []string{models.RoleViewer}},
+		{http.MethodDelete, "/api/tools/1", []string{models.RoleViewer}},
+		{http.MethodGet, "/api/admin/stats", nil},
+		{http.MethodGet, "/api/stats/visitors", nil},
+		{http.MethodPost, "/api/admin/stats/usage/rollup", []string{models.RoleViewer, models.RoleEditor, models.RoleDeveloper}},
+		{http.MethodPost, "/api/admin/stats/scores/refresh", []string{models.RoleViewer, models.RoleEditor, models.RoleDeveloper}},
+		{http.MethodGet, "/api/admin/api-keys", []string{models.RoleViewer, models.RoleEditor, models.RoleDeveloper}},
+		{http.MethodGet, "/api/admin/users", []string{models.RoleViewer, models.RoleEditor, models.RoleDeveloper}},
+	}
+	for _, tt := range tests {
+		forbidden := make(map[string]bool)
+		for _, role := range tt.forbidden {
+			forbidden[role] = true
+		}
+		for _, role := range models.Roles {
+			code := serveWithSession(router, tt.method, tt.path, tokens[role])
+			if forbidden[role] && code != http.StatusForbidden {
+				t.Errorf("%s %s as %s: status %d, want 403", tt.method, tt.path, role, code)
+			}
+			if !forbidden[role] && (code == http.StatusForbidden || code == http.StatusUnauthorized) {
+				t.Errorf("%s %s as %s: status %d, want access", tt.method, tt.path, role, code)
+			}
+		}
+	}
+}
+
+func TestLoginSession(t *testing.T) {
+	router := newTestRouter(t)
+	loginAs(t, "erin", models.RoleEditor)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		body := `{"username": "erin", "password": "` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := login("wrong password"); w.Code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password: status %d, want 401", w.Code)
+	}
+	w := login("correct horse")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: status %d", w.Code)
+	}
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == middleware.SessionCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.Value == "" {
+		t.Fatalf("cookies %v, want an HTTP-only session cookie", w.Result().Cookies())
+	}
+
+	me := func() (int, string) {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var body struct {
+			Data struct {
+				Actor  string
+				Scopes models.ScopeList
+			}
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body.Data.Actor + " " + strings.Join(body.Data.Scopes, " ")
+	}
+	if code, got := me(); code != http.StatusOK || got != "user:erin tools:write stats:read projects:read git:read" {
+		t.Errorf("me: status %d, %q, want the editor scopes of erin", code, got)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if code, _ := me(); code != http.StatusUnauthorized {
+		t.Errorf("me after signing out: status %d, want 401", code)
+	}
+}
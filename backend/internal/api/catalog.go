@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCatalogSize limits the size of an uploaded catalog file
+const maxCatalogSize = 10 << 20
+
+var catalogService *services.CatalogService
+
+// ExportCatalog downloads all categories, tags and tools as JSON, YAML or CSV
+func ExportCatalog(c *gin.Context) {
+	format, err := services.ParseCatalogFormat(c.DefaultQuery("format", services.CatalogFormatJSON))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	catalog, err := catalogService.Export()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.EncodeCatalog(&buf, catalog, format); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("catalog-%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, services.CatalogContentType(format), buf.Bytes())
+}
+
+// ImportCatalog upserts a catalog sent as the request body or as the "file"
+// field of a multipart form. With dry_run=true nothing is written and the
+// response only reports the differences.
+func ImportCatalog(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	data, filename, err := readCatalogUpload(c)
+	if err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	format, err := catalogUploadFormat(c, filename)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	catalog, err := services.DecodeCatalog(bytes.NewReader(data), format)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	report, err := catalogService.Import(catalog, dryRun, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	message := "Catalog imported successfully"
+	if dryRun {
+		message = "Dry run completed, no changes were saved"
+	}
+	response.SuccessWithMessage(c, message, gin.H{
+		"report": report,
+	})
+}
+
+// readCatalogUpload reads the uploaded catalog and its filename, if any
+func readCatalogUpload(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCatalogSize)
+
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		return data, header.Filename, err
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	return data, "", err
+}
+
+// catalogUploadFormat picks the format from the format query parameter, the
+// uploaded filename or the Content-Type header, in that order
+func catalogUploadFormat(c *gin.Context, filename string) (string, error) {
+	if format := c.Query("format"); format != "" {
+		return services.ParseCatalogFormat(format)
+	}
+	if filename != "" {
+		return services.CatalogFormatFromFilename(filename)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		return services.CatalogFormatYAML, nil
+	case "text/csv":
+		return services.CatalogFormatCSV, nil
+	}
+	return services.CatalogFormatJSON, nil
+}
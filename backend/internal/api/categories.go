@@ -0,0 +1,224 @@
+package api
+
+import (
+	"strconv"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var categoryService *services.CategoryService
+
+// CategoryRequest is the request body for creating a category
+type CategoryRequest struct {
+	Slug      string `json:"slug" binding:"omitempty,max=100"`
+	Name      string `json:"name" binding:"required,max=100"`
+	Icon      string `json:"icon" binding:"max=50"`
+	SortOrder int    `json:"sort_order"`
+	ParentID  *uint  `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+// CategoryUpdateRequest is the request body for partially updating a category
+type CategoryUpdateRequest struct {
+	Slug      *string `json:"slug" binding:"omitempty,min=1,max=100"`
+	Name      *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Icon      *string `json:"icon" binding:"omitempty,max=50"`
+	SortOrder *int    `json:"sort_order"`
+	ParentID  *uint   `json:"parent_id" binding:"omitempty,min=1"`
+	IsRoot    bool    `json:"is_root"` // move the category to the top level
+}
+
+// CategoryTranslationRequest is the request body for setting a category translation
+type CategoryTranslationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// toUpdates converts the request into a column update map
+func (r *CategoryUpdateRequest) toUpdates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	if r.Slug != nil {
+		updates["slug"] = *r.Slug
+	}
+	if r.Name != nil {
+		updates["name"] = *r.Name
+	}
+	if r.Icon != nil {
+		updates["icon"] = *r.Icon
+	}
+	if r.SortOrder != nil {
+		updates["sort_order"] = *r.SortOrder
+	}
+	if r.ParentID != nil {
+		updates["parent_id"] = r.ParentID
+	} else if r.IsRoot {
+		updates["parent_id"] = nil
+	}
+	return updates
+}
+
+// GetCategories lists categories with tool counts, as a tree when tree=true
+func GetCategories(c *gin.Context) {
+	tree, _ := strconv.ParseBool(c.Query("tree"))
+
+	categories, err := categoryService.ListCategories(middleware.GetLocales(c), tree)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"categories": categories,
+	})
+}
+
+// GetCategory gets a category by ID or slug
+func GetCategory(c *gin.Context) {
+	category, err := categoryService.GetCategory(c.Param("ref"))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	categories := []models.Category{*category}
+	if err := translationService.LocalizeCategories(categories, middleware.GetLocales(c)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	if categories[0].Locale != "" {
+		c.Header("Content-Language", categories[0].Locale)
+	}
+
+	response.Success(c, gin.H{
+		"category": categories[0],
+	})
+}
+
+// CreateCategory creates a new category
+func CreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	category, err := categoryService.CreateCategory(services.CategoryInput{
+		Slug:      req.Slug,
+		Name:      req.Name,
+		Icon:      req.Icon,
+		SortOrder: req.SortOrder,
+		ParentID:  req.ParentID,
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Category created successfully", gin.H{
+		"category": category,
+	})
+}
+
+// UpdateCategory updates an existing category
+func UpdateCategory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	var req CategoryUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	updates := req.toUpdates()
+	if len(updates) == 0 {
+		response.BadRequest(c, "No fields to update")
+		return
+	}
+
+	category, err := categoryService.UpdateCategory(id, updates)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Category updated successfully", gin.H{
+		"category": category,
+	})
+}
+
+// DeleteCategory deletes a category without tools or child categories
+func DeleteCategory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	if err := categoryService.DeleteCategory(id); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Category deleted successfully", nil)
+}
+
+// GetCategoryTranslations lists all translations of a category
+func GetCategoryTranslations(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	translations, err := translationService.GetCategoryTranslations(id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"translations": translations,
+	})
+}
+
+// UpsertCategoryTranslation creates or replaces a category translation for a locale
+func UpsertCategoryTranslation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	var req CategoryTranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	translation, err := translationService.UpsertCategoryTranslation(id, c.Param("locale"), req.Name)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Translation saved successfully", gin.H{
+		"translation": translation,
+	})
+}
+
+// DeleteCategoryTranslation deletes a category translation for a locale
+func DeleteCategoryTranslation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid category ID")
+	if !ok {
+		return
+	}
+
+	if err := translationService.DeleteCategoryTranslation(id, c.Param("locale")); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Translation deleted successfully", nil)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleServiceError maps service errors to typed API error responses
+func handleServiceError(c *gin.Context, err error) {
+	var validationErr *services.ValidationError
+	var validationErrs services.ValidationErrors
+
+	switch {
+	case errors.As(err, &validationErr):
+		response.BadRequest(c, validationErr.Error())
+	case errors.As(err, &validationErrs):
+		response.BadRequest(c, validationErrs.Error())
+	case errors.Is(err, services.ErrToolNotFound),
+		errors.Is(err, services.ErrTranslationNotFound),
+		errors.Is(err, services.ErrCategoryNotFound),
+		errors.Is(err, services.ErrTagNotFound),
+		errors.Is(err, services.ErrRevisionNotFound),
+		errors.Is(err, services.ErrAPIKeyNotFound),
+		errors.Is(err, services.ErrUserNotFound):
+		response.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrToolNameTaken),
+		errors.Is(err, services.ErrCategorySlugTaken),
+		errors.Is(err, services.ErrCategoryInUse),
+		errors.Is(err, services.ErrTagSlugTaken),
+		errors.Is(err, services.ErrLinkCheckRunning),
+		errors.Is(err, services.ErrAPIKeyRevoked),
+		errors.Is(err, services.ErrAPIKeyExpired),
+		errors.Is(err, services.ErrUsernameTaken),
+		errors.Is(err, services.ErrLastAdmin):
+		response.Conflict(c, err.Error())
+	case errors.Is(err, services.ErrUsageQueueFull),
+		errors.Is(err, services.ErrUsageIngestClosed),
+		errors.Is(err, services.ErrLiveFeedFull),
+		errors.Is(err, services.ErrLiveFeedClosed):
+		response.ServiceUnavailable(c, err.Error())
+	default:
+		middleware.Logger(c).Errorf("%s %s failed: %v", c.Request.Method, c.FullPath(), err)
+		response.InternalError(c, "Internal server error")
+	}
+}
@@ -0,0 +1,21 @@
+package api
+
+import "context"
+
+// StartScheduledJobs starts the background jobs whose state is shared with the
+// API handlers. SetupRoutes must be called first.
+func StartScheduledJobs(ctx context.Context) {
+	linkChecker.Start(ctx)
+	usageRollup.Start(ctx)
+	toolScorer.Start(ctx)
+	alertEngine.Start(ctx)
+	liveFeed.Start(ctx)
+	usageIngestor.Start(ctx)
+}
+
+// StopBackgroundJobs writes out buffered work, waiting until it is done or the
+// context expires. The HTTP server should be shut down first so that nothing
+// is queued afterwards.
+func StopBackgroundJobs(ctx context.Context) error {
+	return usageIngestor.Stop(ctx)
+}
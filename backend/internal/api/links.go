@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var linkChecker *services.LinkChecker
+
+// GetLinkStatus lists every tool with its latest link check, only flagged tools when flagged=true
+func GetLinkStatus(c *gin.Context) {
+	flaggedOnly, _ := strconv.ParseBool(c.Query("flagged"))
+
+	statuses, err := linkChecker.ListLinkStatus(flaggedOnly)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"links": statuses,
+	})
+}
+
+// StartLinkCheck starts checking all tool links in the background
+func StartLinkCheck(c *gin.Context) {
+	if err := linkChecker.StartCheckAll(context.Background()); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Link check started", nil)
+}
+
+// GetToolLinkChecks lists the most recent link checks of a tool
+func GetToolLinkChecks(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	checks, err := linkChecker.GetToolChecks(id, limit)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"checks": checks,
+	})
+}
+
+// CheckToolLink checks the link of a single tool now
+func CheckToolLink(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	check, err := linkChecker.CheckTool(c.Request.Context(), id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"check": check,
+	})
+}
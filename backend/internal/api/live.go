@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveRetry is the reconnect delay suggested to EventSource clients
+const liveRetry = 3 * time.Second
+
+var liveFeed *services.LiveFeed
+
+// GetLiveStats streams tool usage and rolling per-minute counters as
+// Server-Sent Events. Usage events carry an id, so a reconnecting client
+// resumes after its Last-Event-ID header (or last_event_id parameter).
+func GetLiveStats(c *gin.Context) {
+	var lastEventID uint64
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		lastEventID, _ = strconv.ParseUint(value, 10, 64)
+	} else if value := c.Query("last_event_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			response.BadRequest(c, "Invalid last_event_id")
+			return
+		}
+		lastEventID = id
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		response.InternalError(c, "Streaming is not supported")
+		return
+	}
+
+	sub, replay, err := liveFeed.Subscribe(lastEventID)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	defer liveFeed.Unsubscribe(sub)
+
+	// Set SSE headers
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", liveRetry.Milliseconds())
+	for _, event := range replay {
+		if writeLiveEvent(c, event) != nil {
+			return
+		}
+	}
+	if writeLiveEvent(c, services.LiveEvent{
+		Type:      services.LiveEventCounters,
+		Timestamp: time.Now(),
+		Counters:  sub.Counters(),
+	}) != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(liveFeed.Heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		var event services.LiveEvent
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case usage, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			event = usage
+		case <-sub.CountersReady():
+			event = services.LiveEvent{
+				Type:      services.LiveEventCounters,
+				Timestamp: time.Now(),
+				Counters:  sub.Counters(),
+			}
+		case <-heartbeat.C:
+			event = services.LiveEvent{Type: services.LiveEventHeartbeat, Timestamp: time.Now()}
+		}
+
+		// Tell the client about events it missed before sending newer ones
+		if dropped := sub.TakeDropped(); dropped > 0 {
+			if writeLiveEvent(c, services.LiveEvent{
+				Type:      services.LiveEventDropped,
+				Timestamp: time.Now(),
+				Dropped:   dropped,
+			}) != nil {
+				return
+			}
+		}
+		if writeLiveEvent(c, event) != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeLiveEvent writes an event as an SSE message, with an id line for
+// events that can be resumed from
+func writeLiveEvent(c *gin.Context, event services.LiveEvent) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID != 0 {
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(c.Writer, "data: %s\n\n", string(jsonData))
+	return err
+}
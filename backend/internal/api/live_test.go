@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveMessage is one SSE message of the live feed
+type liveMessage struct {
+	id    string
+	event services.LiveEvent
+}
+
+// liveStream is an open connection to the live feed
+type liveStream struct {
+	resp    *http.Response
+	scanner *bufio.Scanner
+}
+
+// newLiveServer serves GetLiveStats from a fresh feed
+func newLiveServer(t *testing.T, opts services.LiveFeedOptions) *httptest.Server {
+	t.Helper()
+	liveFeed = services.NewLiveFeed(opts)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/live", GetLiveStats)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openLive connects to the live feed, resuming after lastEventID unless it is empty
+func openLive(t *testing.T, server *httptest.Server, lastEventID string) *liveStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/live", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /live: status %d", resp.StatusCode)
+	}
+	stream := &liveStream{resp: resp, scanner: bufio.NewScanner(resp.Body)}
+	if line := stream.line(t); line != "retry: 3000" {
+		t.Fatalf("first line %q, want the retry delay", line)
+	}
+	stream.line(t)
+	return stream
+}
+
+func (s *liveStream) line(t *testing.T) string {
+	t.Helper()
+	if !s.scanner.Scan() {
+		t.Fatalf("stream ended: %v", s.scanner.Err())
+	}
+	return s.scanner.Text()
+}
+
+// next reads the next message of the stream
+func (s *liveStream) next(t *testing.T) liveMessage {
+	t.Helper()
+	var msg liveMessage
+	for line := s.line(t); line != ""; line = s.line(t) {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			msg.id = id
+		} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &msg.event); err != nil {
+				t.Fatalf("data %q: %v", data, err)
+			}
+		}
+	}
+	return msg
+}
+
+// nextOfType skips messages until one of the given type arrives
+func (s *liveStream) nextOfType(t *testing.T, eventType string) liveMessage {
+	t.Helper()
+	for {
+		if msg := s.next(t); msg.event.Type == eventType {
+			return msg
+		}
+	}
+}
+
+func publishUses(toolIDs ...uint) {
+	rows := make([]models.ToolUsage, len(toolIDs))
+	for i, id := range toolIDs {
+		rows[i] = models.ToolUsage{ToolID: id, CreatedAt: time.Now()}
+	}
+	liveFeed.PublishUsage(rows)
+}
+
+func TestGetLiveStatsResume(t *testing.T) {
+	server := newLiveServer(t, services.LiveFeedOptions{Heartbeat: time.Hour})
+	first := openLive(t, server, "")
+	if msg := first.next(t); msg.event.Type != services.LiveEventCounters {
+		t.Fatalf("first event %+v, want the counters", msg.event)
+	}
+
+	publishUses(1, 2, 3)
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		msg := first.nextOfType(t, services.LiveEventUsage)
+		if msg.id == "" || msg.event.Usage == nil || msg.event.Usage.ToolID != uint(i) {
+			t.Fatalf("usage message %+v, want tool %d with an id", msg, i)
+		}
+		ids = append(ids, msg.id)
+	}
+
+	// A reconnecting client gets the events after its last one, then the counters
+	resumed := openLive(t, server, ids[0])
+	for i, want := range ids[1:] {
+		msg := resumed.next(t)
+		if msg.id != want || msg.event.Usage == nil || msg.event.Usage.ToolID != uint(i+2) {
+			t.Errorf("replayed %+v with id %s, want tool %d with id %s", msg.event, msg.id, i+2, want)
+		}
+	}
+	if msg := resumed.next(t); msg.event.Type != services.LiveEventCounters || msg.event.Counters.LastHour != 3 {
+		t.Errorf("after the replay %+v, want counters of 3 uses", msg.event)
+	}
+
+	// An ID the feed does not know tells the client to start over
+	reset := openLive(t, server, "1")
+	if msg := reset.next(t); msg.event.Type != services.LiveEventReset {
+		t.Errorf("resuming from an unknown id sent %+v, want a reset", msg.event)
+	}
+}
+
+func TestGetLiveStatsHeartbeat(t *testing.T) {
+	server := newLiveServer(t, services.LiveFeedOptions{Heartbeat: 20 * time.Millisecond})
+	stream := openLive(t, server, "")
+	stream.nextOfType(t, services.LiveEventHeartbeat)
+	if msg := stream.nextOfType(t, services.LiveEventHeartbeat); msg.id != "" {
+		t.Errorf("heartbeat has id %s, which clients would resume from", msg.id)
+	}
+}
+
+func TestGetLiveStatsRejects(t *testing.T) {
+	server := newLiveServer(t, services.LiveFeedOptions{MaxClients: 1, Heartbeat: time.Hour})
+
+	resp, err := http.Get(server.URL + "/live?last_event_id=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid last_event_id: status %d, want 400", resp.StatusCode)
+	}
+
+	openLive(t, server, "")
+	resp, err = http.Get(server.URL + "/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("client past the limit: status %d, want 503", resp.StatusCode)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serve(router *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+// scrape reads every sample /metrics exposes, keyed by name and labels
+func scrape(t *testing.T, router *gin.Engine) map[string]float64 {
+	t.Helper()
+	w := serve(router, http.MethodGet, "/metrics")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", w.Code)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("invalid sample %q", line)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestMetricsThroughRouter(t *testing.T) {
+	router := newTestRouter(t)
+	tool := models.Tool{Name: "Calculator", URL: "/tools/calculator", IsActive: true}
+	if err := database.DB.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	before := scrape(t, router)
+	requests := []struct {
+		path   string
+		status int
+	}{
+		{"/api/health", http.StatusOK},
+		{"/api/tools/", http.StatusOK},
+		{"/api/tools/" + strconv.Itoa(int(tool.ID)), http.StatusOK},
+		{"/api/tools/99999", http.StatusNotFound},
+		{"/no/such/route", http.StatusNotFound},
+	}
+	for _, req := range requests {
+		if w := serve(router, http.MethodGet, req.path); w.Code != req.status {
+			t.Fatalf("GET %s: status %d, want %d", req.path, w.Code, req.status)
+		}
+	}
+	after := scrape(t, router)
+
+	delta := func(series string) float64 {
+		return after[series] - before[series]
+	}
+	for series, want := range map[string]float64{
+		`http_requests_total{method="GET",route="/api/health",status="200"}`:       1,
+		`http_requests_total{method="GET",route="/api/tools/",status="200"}`:       1,
+		`http_requests_total{method="GET",route="/api/tools/:id",status="200"}`:    1,
+		`http_requests_total{method="GET",route="/api/tools/:id",status="404"}`:    1,
+		`http_requests_total{method="GET",route="unmatched",status="404"}`:         1,
+		`http_request_duration_seconds_count{method="GET",route="/api/tools/:id"}`: 2,
+	} {
+		if got := delta(series); got != want {
+			t.Errorf("%s grew by %g, want %g", series, got, want)
+		}
+	}
+
+	// Looking up tools queries the tools table; a missing tool is not an error
+	if got := delta(`db_query_duration_seconds_count{operation="query",table="tools"}`); got < 3 {
+		t.Errorf("observed %g queries of tools, want at least 3", got)
+	}
+	if got := delta(`db_query_errors_total{operation="query",table="tools"}`); got != 0 {
+		t.Errorf("counted %g query errors on tools, want 0", got)
+	}
+
+	// A failing statement is counted as an error
+	database.DB.Exec("SELECT * FROM missing_table")
+	rawErrors := `db_query_errors_total{operation="raw",table=""}`
+	if got := scrape(t, router)[rawErrors] - before[rawErrors]; got != 1 {
+		t.Errorf("counted %g raw query errors, want 1", got)
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	router := newTestRouter(t)
+	config.AppConfig.MetricsToken = "scraper"
+
+	if w := serve(router, http.MethodGet, "/metrics"); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /metrics without the token: status %d, want 401", w.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer scraper")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "# TYPE http_requests_total counter") {
+		t.Errorf("GET /metrics with the token: status %d", w.Code)
+	}
+}
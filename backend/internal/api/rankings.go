@@ -0,0 +1,57 @@
+package api
+
+import (
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var toolScorer *services.ToolScorer
+
+// GetTrendingTools lists the tools whose usage rose the most above their
+// baseline, with the numbers behind their trending score
+func GetTrendingTools(c *gin.Context) {
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	trending, err := toolScorer.GetTrending(limit)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	tools := make([]models.Tool, len(trending))
+	for i := range trending {
+		tools[i] = trending[i].Tool
+	}
+	if err := translationService.LocalizeTools(tools, middleware.GetLocales(c)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	for i := range trending {
+		trending[i].Tool = tools[i]
+	}
+
+	response.Success(c, gin.H{
+		"tools": trending,
+	})
+}
+
+// RefreshToolScores recomputes the popularity and trending scores now
+func RefreshToolScores(c *gin.Context) {
+	summary, err := toolScorer.Refresh()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"scores": summary,
+	})
+}
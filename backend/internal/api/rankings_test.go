@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm/logger"
+)
+
+// newTestRouter sets up every route against a fresh SQLite database
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	if err := config.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	// Serve /metrics without a token whatever the environment sets
+	config.AppConfig.MetricsToken = ""
+
+	database.SetLogger(logger.Discard)
+	db, err := database.Connect(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB = db
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	if err := SetupRoutes(router); err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+// serveJSON serves a request and decodes the data of the response into data
+func serveJSON(t *testing.T, router *gin.Engine, method, path, apiKey string, data interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code == http.StatusOK && data != nil {
+		body := struct{ Data interface{} }{data}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return w.Code
+}
+
+func TestRankings(t *testing.T) {
+	router := newTestRouter(t)
+	config.AppConfig.APIKey = "secret"
+
+	db := database.GetDB()
+	tools := []models.Tool{
+		{Name: "Formatter", URL: "https://tion.work/formatter", IsActive: true},
+		{Name: "Counter", URL: "https://tion.work/counter", IsActive: true},
+		{Name: "Timer", URL: "https://tion.work/timer", IsActive: true},
+	}
+	if err := db.Create(&tools).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	usages := []models.ToolUsage{{ToolID: tools[0].ID, CreatedAt: now.AddDate(0, 0, -3)}}
+	for i := 1; i <= 5; i++ {
+		usages = append(usages, models.ToolUsage{ToolID: tools[1].ID, CreatedAt: now.Add(-time.Duration(i) * time.Hour)})
+	}
+	if err := db.Create(&usages).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serveJSON(t, router, http.MethodPost, "/api/admin/stats/scores/refresh", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("refresh without an API key: status %d, want 401", code)
+	}
+	var refreshed struct {
+		Scores struct{ Tools int }
+	}
+	if code := serveJSON(t, router, http.MethodPost, "/api/admin/stats/scores/refresh", "secret", &refreshed); code != http.StatusOK {
+		t.Fatalf("refresh: status %d", code)
+	}
+	if refreshed.Scores.Tools != 3 {
+		t.Errorf("refreshed %d tools, want 3", refreshed.Scores.Tools)
+	}
+
+	var trending struct {
+		Tools []struct {
+			Tool       models.Tool
+			RecentUses int64 `json:"recent_uses"`
+		}
+	}
+	if code := serveJSON(t, router, http.MethodGet, "/api/tools/trending", "", &trending); code != http.StatusOK {
+		t.Fatalf("trending: status %d", code)
+	}
+	var got []string
+	for _, item := range trending.Tools {
+		got = append(got, fmt.Sprintf("%s:%d", item.Tool.Name, item.RecentUses))
+	}
+	if fmt.Sprint(got) != "[Counter:5]" {
+		t.Errorf("trending %v, want [Counter:5]", got)
+	}
+	if code := serveJSON(t, router, http.MethodGet, "/api/tools/trending?limit=many", "", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid limit: status %d, want 400", code)
+	}
+
+	var page struct{ Tools []models.Tool }
+	if code := serveJSON(t, router, http.MethodGet, "/api/tools/?sort=popular", "", &page); code != http.StatusOK {
+		t.Fatalf("popular tools: status %d", code)
+	}
+	got = nil
+	for _, tool := range page.Tools {
+		got = append(got, tool.Name)
+	}
+	if fmt.Sprint(got) != "[Counter Formatter Timer]" {
+		t.Errorf("popular tools %v, want [Counter Formatter Timer]", got)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var revisionService *services.RevisionService
+
+// GetToolRevisions lists the revisions of a tool, newest first
+func GetToolRevisions(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid offset")
+		return
+	}
+
+	page, err := revisionService.ListRevisions(id, limit, offset)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, page)
+}
+
+// GetToolRevision gets a single revision of a tool
+func GetToolRevision(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	revision, ok := parseRevision(c)
+	if !ok {
+		return
+	}
+
+	rev, err := revisionService.GetRevision(id, revision)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"revision": rev,
+	})
+}
+
+// DiffToolRevisions compares two revisions of a tool. Without "to" the latest
+// revision is used, without "from" the revision before "to".
+func DiffToolRevisions(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	from, err := queryInt(c, "from", 0)
+	if err != nil || from < 0 {
+		response.BadRequest(c, "Invalid from revision")
+		return
+	}
+	to, err := queryInt(c, "to", 0)
+	if err != nil || to < 0 {
+		response.BadRequest(c, "Invalid to revision")
+		return
+	}
+
+	diff, err := revisionService.DiffRevisions(id, from, to)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"diff": diff,
+	})
+}
+
+// RestoreToolRevision restores a tool to the state of one of its revisions
+func RestoreToolRevision(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	revision, ok := parseRevision(c)
+	if !ok {
+		return
+	}
+
+	tool, err := revisionService.RestoreRevision(id, revision, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tool restored successfully", gin.H{
+		"tool": tool,
+	})
+}
+
+// parseRevision parses the revision number path parameter
+func parseRevision(c *gin.Context) (int, bool) {
+	revision, ok := parseIDParam(c, "revision", "Invalid revision")
+	return int(revision), ok
+}
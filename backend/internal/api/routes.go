@@ -1,16 +1,86 @@
 package api
 
 import (
-	"tion-backend/internal/middleware"
-	"tion-backend/internal/response"
+	"fmt"
+	"time"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/ratelimit"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+	"tion.work/backend/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRoutes sets up all routes
-func SetupRoutes(r *gin.Engine) {
+// requestMetricsRetention covers the week-old windows of alert change rules
+const requestMetricsRetention = 8 * 24 * time.Hour
+
+// SetupRoutes sets up all routes. It fails when the alerting configuration is invalid.
+func SetupRoutes(r *gin.Engine) error {
 	// Initialize middleware
 	middleware.InitMiddleware()
+	requestMetrics = services.NewRequestMetrics(requestMetricsRetention)
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.LoggingMiddleware())
+	r.Use(middleware.RequestMetricsMiddleware(requestMetrics))
+	r.Use(middleware.MetricsMiddleware())
+
+	// Initialize services
+	toolService = services.NewToolService()
+	searchService = services.NewSearchService()
+	translationService = services.NewTranslationService()
+	categoryService = services.NewCategoryService()
+	tagService = services.NewTagService()
+	catalogService = services.NewCatalogService()
+	revisionService = services.NewRevisionService()
+	statsService = services.NewStatsService()
+	apiKeyService = services.NewAPIKeyService()
+	auditService = services.NewAuditService()
+	userService = services.NewUserService(config.AppConfig.SessionTTL)
+	linkChecker = services.NewLinkChecker(services.LinkCheckerOptions{
+		Interval:         config.AppConfig.LinkCheckInterval,
+		Timeout:          config.AppConfig.LinkCheckTimeout,
+		Concurrency:      config.AppConfig.LinkCheckConcurrency,
+		FailureThreshold: config.AppConfig.LinkCheckFailureThreshold,
+		RetentionDays:    config.AppConfig.LinkCheckRetentionDays,
+	})
+	usageRollup = services.NewUsageRollup(services.UsageRollupOptions{
+		Interval:      config.AppConfig.UsageRollupInterval,
+		Delay:         config.AppConfig.UsageRollupDelay,
+		RetentionDays: config.AppConfig.UsageRetentionDays,
+	})
+	liveFeed = services.NewLiveFeed(services.LiveFeedOptions{
+		MaxClients: config.AppConfig.LiveMaxClients,
+		Heartbeat:  config.AppConfig.LiveHeartbeatInterval,
+	})
+	usageIngestor = services.NewUsageIngestor(services.UsageIngestOptions{
+		QueueSize:      config.AppConfig.UsageIngestQueueSize,
+		BatchSize:      config.AppConfig.UsageIngestBatchSize,
+		FlushInterval:  config.AppConfig.UsageIngestFlushInterval,
+		EnqueueTimeout: config.AppConfig.UsageIngestEnqueueTimeout,
+		OnFlush:        liveFeed.PublishUsage,
+	})
+	toolScorer = services.NewToolScorer(services.ToolScorerOptions{
+		Interval:      config.AppConfig.ToolScoreInterval,
+		HalfLife:      config.AppConfig.PopularityHalfLife,
+		TrendWindow:   config.AppConfig.TrendingWindow,
+		TrendBaseline: config.AppConfig.TrendingBaseline,
+	})
+	if err := setupAlerting(); err != nil {
+		return err
+	}
+	limits, err := setupRateLimits()
+	if err != nil {
+		return err
+	}
+
+	// Scope checks, applied after AuthMiddleware
+	toolsRead := middleware.RequireScope(models.ScopeToolsRead)
+	toolsWrite := middleware.RequireScope(models.ScopeToolsWrite)
+	statsRead := middleware.RequireScope(models.ScopeStatsRead)
+	adminOnly := middleware.RequireScope(models.ScopeAdmin)
 
 	// API route group
 	api := r.Group("/api")
@@ -18,34 +88,110 @@ func SetupRoutes(r *gin.Engine) {
 		// Health check
 		api.GET("/health", HealthCheck)
 
+		// Sessions
+		auth := api.Group("/auth")
+		auth.Use(limits.auth, middleware.AuditMiddleware())
+		{
+			auth.POST("/login", Login)
+			auth.POST("/logout", Logout)
+			auth.GET("/me", middleware.AuthMiddleware(), GetCurrentUser)
+		}
+
 		// Tools routes
 		tools := api.Group("/tools")
+		tools.Use(middleware.LocaleMiddleware())
 		{
-			tools.GET("/", GetTools)
-			tools.GET("/:id", GetTool)
-			tools.POST("/", CreateTool)
-			tools.PUT("/:id", UpdateTool)
-			tools.DELETE("/:id", DeleteTool)
-			tools.POST("/:id/use", RecordToolUsage)
+			tools.GET("/", limits.public, GetTools)
+			tools.GET("/search", limits.public, SearchTools)
+			tools.GET("/trending", limits.public, GetTrendingTools)
+			tools.GET("/:id", limits.public, GetTool)
+			tools.POST("/", middleware.AuditMiddleware(), middleware.AuthMiddleware(), limits.admin, toolsWrite, CreateTool)
+			tools.PUT("/:id", middleware.AuditMiddleware(), middleware.AuthMiddleware(), limits.admin, toolsWrite, UpdateTool)
+			tools.DELETE("/:id", middleware.AuditMiddleware(), middleware.AuthMiddleware(), limits.admin, toolsWrite, DeleteTool)
+			tools.POST("/:id/use", limits.tracking, RecordToolUsage)
 		}
 
-		// Statistics routes
+		// Category and tag routes
+		categories := api.Group("/categories")
+		categories.Use(limits.public, middleware.LocaleMiddleware())
+		{
+			categories.GET("/", GetCategories)
+			categories.GET("/:ref", GetCategory)
+		}
+		api.GET("/tags", limits.public, GetTags)
+
+		// Search events reported by the frontend
+		api.POST("/search/events", limits.tracking, middleware.LocaleMiddleware(), RecordSearchEvent)
+
+		// Statistics routes. Visitor counts and the live usage feed need
+		// stats:read, so that anonymous clients cannot hold the stream slots.
 		stats := api.Group("/stats")
+		stats.Use(limits.public)
 		{
 			stats.GET("/tools", GetToolStats)
 			stats.GET("/usage", GetUsageStats)
 			stats.GET("/overview", GetOverviewStats)
+			stats.GET("/visitors", middleware.AuthMiddleware(), statsRead, GetVisitors)
+			stats.GET("/live", middleware.AuthMiddleware(), statsRead, GetLiveStats)
 		}
 
-		// Admin routes (require API key)
+		// Admin routes (require an API key or a session)
 		admin := api.Group("/admin")
-		admin.Use(middleware.APIKeyMiddleware())
+		admin.Use(middleware.AuditMiddleware(), middleware.AuthMiddleware(), limits.admin)
 		{
-			admin.GET("/tools", GetTools)
-			admin.POST("/tools", CreateTool)
-			admin.PUT("/tools/:id", UpdateTool)
-			admin.DELETE("/tools/:id", DeleteTool)
-			admin.GET("/stats", GetAdminStats)
+			admin.GET("/tools", toolsRead, GetAdminTools)
+			admin.POST("/tools", toolsWrite, CreateTool)
+			admin.PUT("/tools/:id", toolsWrite, UpdateTool)
+			admin.DELETE("/tools/:id", toolsWrite, DeleteTool)
+			admin.GET("/tools/:id/revisions", toolsRead, GetToolRevisions)
+			admin.GET("/tools/:id/revisions/diff", toolsRead, DiffToolRevisions)
+			admin.GET("/tools/:id/revisions/:revision", toolsRead, GetToolRevision)
+			admin.POST("/tools/:id/revisions/:revision/restore", toolsWrite, RestoreToolRevision)
+			admin.GET("/tools/:id/translations", toolsRead, GetToolTranslations)
+			admin.PUT("/tools/:id/translations/:locale", toolsWrite, UpsertToolTranslation)
+			admin.DELETE("/tools/:id/translations/:locale", toolsWrite, DeleteToolTranslation)
+			admin.POST("/categories", toolsWrite, CreateCategory)
+			admin.PUT("/categories/:id", toolsWrite, UpdateCategory)
+			admin.DELETE("/categories/:id", toolsWrite, DeleteCategory)
+			admin.GET("/categories/:id/translations", toolsRead, GetCategoryTranslations)
+			admin.PUT("/categories/:id/translations/:locale", toolsWrite, UpsertCategoryTranslation)
+			admin.DELETE("/categories/:id/translations/:locale", toolsWrite, DeleteCategoryTranslation)
+			admin.POST("/tags", toolsWrite, CreateTag)
+			admin.PUT("/tags/:id", toolsWrite, UpdateTag)
+			admin.DELETE("/tags/:id", toolsWrite, DeleteTag)
+			admin.GET("/links", toolsRead, GetLinkStatus)
+			admin.POST("/links/check", adminOnly, StartLinkCheck)
+			admin.GET("/tools/:id/link-checks", toolsRead, GetToolLinkChecks)
+			admin.POST("/tools/:id/link-checks", toolsWrite, CheckToolLink)
+			admin.GET("/catalog/export", toolsRead, ExportCatalog)
+			admin.POST("/catalog/import", toolsWrite, ImportCatalog)
+			admin.GET("/stats", statsRead, GetAdminStats)
+			admin.GET("/stats/usage/timeseries", statsRead, GetUsageTimeSeries)
+			admin.POST("/stats/usage/rollup", adminOnly, CompactUsage)
+			admin.GET("/stats/usage/ingest", statsRead, GetUsageIngestStats)
+			admin.POST("/stats/scores/refresh", adminOnly, RefreshToolScores)
+			admin.GET("/stats/search/ctr", statsRead, GetSearchCTR)
+			admin.GET("/stats/search/queries", statsRead, GetTopQueries)
+			admin.GET("/stats/search/zero-results", statsRead, GetZeroResultQueries)
+			admin.GET("/stats/search/locales", statsRead, GetSearchLocales)
+			admin.GET("/stats/tools/:id/sources", statsRead, GetToolSources)
+			admin.GET("/alerts", statsRead, GetAlerts)
+			admin.GET("/alerts/rules", statsRead, GetAlertRules)
+			admin.POST("/alerts/evaluate", adminOnly, EvaluateAlerts)
+			admin.POST("/alerts/test", adminOnly, TestAlertNotifiers)
+			admin.GET("/api-keys", adminOnly, GetAPIKeys)
+			admin.POST("/api-keys", adminOnly, CreateAPIKey)
+			admin.GET("/api-keys/:id", adminOnly, GetAPIKey)
+			admin.PUT("/api-keys/:id", adminOnly, UpdateAPIKey)
+			admin.POST("/api-keys/:id/rotate", adminOnly, RotateAPIKey)
+			admin.POST("/api-keys/:id/revoke", adminOnly, RevokeAPIKey)
+			admin.GET("/users", adminOnly, GetUsers)
+			admin.POST("/users", adminOnly, CreateUser)
+			admin.GET("/users/:id", adminOnly, GetUser)
+			admin.PUT("/users/:id", adminOnly, UpdateUser)
+			admin.GET("/audit", adminOnly, GetAuditLog)
+			admin.GET("/audit/export", adminOnly, ExportAuditLog)
+			admin.GET("/audit/verify", adminOnly, VerifyAuditLog)
 		}
 	}
 
@@ -57,94 +203,131 @@ func SetupRoutes(r *gin.Engine) {
 			"version": "1.0.0",
 		})
 	})
-}
 
-// HealthCheck returns API health status
-func HealthCheck(c *gin.Context) {
-	response.Success(c, gin.H{
-		"status":  "healthy",
-		"service": "tion-backend",
-		"version": "1.0.0",
-	})
-}
+	// Prometheus metrics
+	r.GET("/metrics", middleware.MetricsAuthMiddleware(), gin.WrapH(metrics.Default.Handler()))
 
-// GetTools gets all tools
-func GetTools(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"tools": []gin.H{},
-		"total": 0,
-	})
+	return nil
 }
 
-// GetTool gets a specific tool by ID
-func GetTool(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"tool": gin.H{},
-	})
-}
+// setupAlerting loads the alert rules and creates the configured notifiers
+func setupAlerting() error {
+	rules, err := services.LoadAlertRules(config.AppConfig.AlertRulesFile)
+	if err != nil {
+		return err
+	}
 
-// CreateTool creates a new tool
-func CreateTool(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"message": "Tool created successfully",
-	})
-}
+	notifiers := []services.Notifier{services.LogNotifier{}}
+	if config.AppConfig.AlertWebhookURL != "" {
+		notifiers = append(notifiers, services.NewWebhookNotifier(config.AppConfig.AlertWebhookURL, 10*time.Second))
+	}
+	if config.AppConfig.AlertSMTPAddr != "" {
+		if len(config.AppConfig.AlertEmailTo) == 0 {
+			return fmt.Errorf("ALERT_EMAIL_TO is required when ALERT_SMTP_ADDR is set")
+		}
+		notifiers = append(notifiers, &services.EmailNotifier{
+			Addr:     config.AppConfig.AlertSMTPAddr,
+			Username: config.AppConfig.AlertSMTPUsername,
+			Password: config.AppConfig.AlertSMTPPassword,
+			From:     config.AppConfig.AlertEmailFrom,
+			To:       config.AppConfig.AlertEmailTo,
+		})
+	}
 
-// UpdateTool updates an existing tool
-func UpdateTool(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"message": "Tool updated successfully",
+	alertEngine, err = services.NewAlertEngine(services.AlertEngineOptions{
+		Interval:  config.AppConfig.AlertEvaluationInterval,
+		Rules:     rules,
+		Notifiers: notifiers,
+		Metrics:   requestMetrics,
 	})
+	if err != nil {
+		return fmt.Errorf("invalid alert rules: %w", err)
+	}
+	return nil
 }
 
-// DeleteTool deletes a tool
-func DeleteTool(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"message": "Tool deleted successfully",
-	})
+// rateLimits holds the rate limit middleware of each route group
+type rateLimits struct {
+	public   gin.HandlerFunc
+	tracking gin.HandlerFunc
+	auth     gin.HandlerFunc
+	admin    gin.HandlerFunc
 }
 
-// RecordToolUsage records tool usage
-func RecordToolUsage(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"message": "Usage recorded successfully",
-	})
-}
+// setupRateLimits creates the configured rate limit store and the limiters of
+// the route groups. It fails when a limit is invalid.
+func setupRateLimits() (*rateLimits, error) {
+	store, err := ratelimit.NewStore(config.AppConfig.RateLimitStore, config.AppConfig.RedisURL, config.AppConfig.RateLimitMemorySize)
+	if err != nil {
+		return nil, err
+	}
 
-// GetToolStats gets tool statistics
-func GetToolStats(c *gin.Context) {
-	// This will be implemented in the services
-	response.Success(c, gin.H{
-		"stats": gin.H{},
-	})
+	limits := &rateLimits{}
+	for _, group := range []struct {
+		name    string
+		spec    string
+		handler *gin.HandlerFunc
+	}{
+		{"public", config.AppConfig.RateLimitPublic, &limits.public},
+		{"tracking", config.AppConfig.RateLimitTracking, &limits.tracking},
+		{"auth", config.AppConfig.RateLimitAuth, &limits.auth},
+		{"admin", config.AppConfig.RateLimitAdmin, &limits.admin},
+	} {
+		limiter, err := ratelimit.New(group.name, group.spec, store)
+		if err != nil {
+			return nil, err
+		}
+		*group.handler = middleware.RateLimitMiddleware(limiter)
+	}
+	return limits, nil
 }
 
-// GetUsageStats gets usage statistics
-func GetUsageStats(c *gin.Context) {
-	// This will be implemented in the services
+// HealthCheck returns API health status
+func HealthCheck(c *gin.Context) {
 	response.Success(c, gin.H{
-		"usage": gin.H{},
+		"status":  "healthy",
+		"service": "tion-backend",
+		"version": "1.0.0",
 	})
 }
 
-// GetOverviewStats gets overview statistics
-func GetOverviewStats(c *gin.Context) {
-	// This will be implemented in the services
+// GetToolStats gets tool counts overall and per category
+func GetToolStats(c *gin.Context) {
+	stats, err := statsService.GetToolStats()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
 	response.Success(c, gin.H{
-		"overview": gin.H{},
+		"stats": stats,
 	})
 }
 
-// GetAdminStats gets admin statistics
+// GetAdminStats gets the tool counts and the usage overview, with "today" in
+// the timezone given by tz, together with the state of the usage ingest queue
 func GetAdminStats(c *gin.Context) {
-	// This will be implemented in the services
+	loc, ok := parseTimezone(c)
+	if !ok {
+		return
+	}
+
+	tools, err := statsService.GetToolStats()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	overview, err := statsService.GetOverviewStats(loc)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
 	response.Success(c, gin.H{
-		"admin_stats": gin.H{},
+		"admin_stats": gin.H{
+			"tools":    tools,
+			"overview": overview,
+			"ingest":   usageIngestor.Stats(),
+		},
 	})
 }
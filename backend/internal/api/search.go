@@ -0,0 +1,93 @@
+package api
+
+import (
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var searchService *services.SearchService
+
+// SearchTools runs a ranked full-text search over active tools, localizing the results
+func SearchTools(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		response.BadRequest(c, "Search query is required")
+		return
+	}
+
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid offset")
+		return
+	}
+
+	page, err := searchService.Search(services.SearchOptions{
+		Query:   query,
+		Limit:   limit,
+		Offset:  offset,
+		Locales: middleware.GetLocales(c),
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	// Record each search once, not every page of its results
+	if offset == 0 {
+		if _, err := statsService.RecordSearchEvent(services.SearchEventInput{
+			Query:   query,
+			Locale:  middleware.GetLocales(c)[0],
+			Results: int(page.Total),
+			Source:  models.SearchSourceAPI,
+		}, c.ClientIP(), c.Request.UserAgent()); err != nil {
+			middleware.Logger(c).Errorf("Failed to record search: %v", err)
+		}
+	}
+
+	response.Success(c, page)
+}
+
+// SearchEventRequest is the request body for reporting a search made in the frontend
+type SearchEventRequest struct {
+	Query   string `json:"query" binding:"required,max=500"`
+	Locale  string `json:"locale" binding:"max=35"`
+	Results *int   `json:"results" binding:"required,min=0"`
+}
+
+// RecordSearchEvent records a search the frontend resolved itself, so that
+// searches which never reach GET /api/tools/search show up in the analytics.
+// Without a locale in the body the negotiated one is used.
+func RecordSearchEvent(c *gin.Context) {
+	var req SearchEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+	if req.Locale == "" {
+		req.Locale = middleware.GetLocales(c)[0]
+	}
+
+	recorded, err := statsService.RecordSearchEvent(services.SearchEventInput{
+		Query:   req.Query,
+		Locale:  req.Locale,
+		Results: *req.Results,
+		Source:  models.SearchSourceClient,
+	}, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"recorded": recorded,
+	})
+}
@@ -0,0 +1,421 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultUsageRanges is how far back a usage time series reaches without "from"
+var defaultUsageRanges = map[string]time.Duration{
+	services.BucketHour: 24 * time.Hour,
+	services.BucketDay:  30 * 24 * time.Hour,
+	services.BucketWeek: 12 * 7 * 24 * time.Hour,
+}
+
+var (
+	statsService  *services.StatsService
+	usageRollup   *services.UsageRollup
+	usageIngestor *services.UsageIngestor
+)
+
+// UsageRequest is the optional request body describing where a tool was clicked
+type UsageRequest struct {
+	Source   string `json:"source" binding:"max=2048"`
+	Query    string `json:"query" binding:"max=500"`
+	Locale   string `json:"locale" binding:"max=35"`
+	Position int    `json:"position" binding:"min=0,max=10000"`
+}
+
+// RecordToolUsage queues a use of a tool, ignoring bots. Uses of tools that do
+// not exist or are inactive are discarded when the queue is flushed. Without a
+// source in the body the Referer header is used, without a locale the
+// negotiated one.
+func RecordToolUsage(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	var req UsageRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request: "+err.Error())
+			return
+		}
+	}
+	if req.Source == "" {
+		req.Source = c.Request.Referer()
+	}
+	if req.Locale == "" {
+		req.Locale = middleware.GetLocales(c)[0]
+	}
+
+	usage, err := statsService.NewToolUsage(id, c.ClientIP(), c.Request.UserAgent(), services.UsageContext{
+		Source:   req.Source,
+		Query:    req.Query,
+		Locale:   req.Locale,
+		Position: req.Position,
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	if usage == nil {
+		response.Success(c, gin.H{
+			"message":  "Bot traffic is not recorded",
+			"recorded": false,
+		})
+		return
+	}
+
+	if err := usageIngestor.Enqueue(*usage); err != nil {
+		if errors.Is(err, services.ErrUsageQueueFull) {
+			c.Header("Retry-After", "1")
+		}
+		handleServiceError(c, err)
+		return
+	}
+	response.Accepted(c, gin.H{
+		"message":  "Usage recorded successfully",
+		"recorded": true,
+	})
+}
+
+// GetUsageIngestStats gets the queue length and counters of usage ingestion
+func GetUsageIngestStats(c *gin.Context) {
+	response.Success(c, gin.H{
+		"ingest": usageIngestor.Stats(),
+	})
+}
+
+// GetUsageStats gets usage counters, with "today" in the timezone given by tz
+func GetUsageStats(c *gin.Context) {
+	loc, ok := parseTimezone(c)
+	if !ok {
+		return
+	}
+	days, err := queryInt(c, "days", 7)
+	if err != nil || days <= 0 {
+		response.BadRequest(c, "Invalid days")
+		return
+	}
+
+	usage, err := statsService.GetUsageStats(days, loc)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"usage": usage,
+	})
+}
+
+// GetOverviewStats gets overview statistics, with "today" in the timezone given by tz
+func GetOverviewStats(c *gin.Context) {
+	loc, ok := parseTimezone(c)
+	if !ok {
+		return
+	}
+
+	overview, err := statsService.GetOverviewStats(loc)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"overview": overview,
+	})
+}
+
+// GetUsageTimeSeries gets usage per tool bucketed by hour, day or week. The
+// range and bucket boundaries are interpreted in the IANA timezone given by tz.
+func GetUsageTimeSeries(c *gin.Context) {
+	loc, ok := parseTimezone(c)
+	if !ok {
+		return
+	}
+
+	bucket := c.DefaultQuery("bucket", services.BucketDay)
+	defaultRange, ok := defaultUsageRanges[bucket]
+	if !ok {
+		response.BadRequest(c, "Invalid bucket, expected hour, day or week")
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		t, err := parseTimeParam(value, loc)
+		if err != nil {
+			response.BadRequest(c, "Invalid to, expected RFC 3339 or YYYY-MM-DD")
+			return
+		}
+		to = t
+	}
+	from := to.Add(-defaultRange)
+	if value := c.Query("from"); value != "" {
+		t, err := parseTimeParam(value, loc)
+		if err != nil {
+			response.BadRequest(c, "Invalid from, expected RFC 3339 or YYYY-MM-DD")
+			return
+		}
+		from = t
+	}
+
+	var toolIDs []uint
+	for _, value := range queryList(c, "tool_id") {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			response.BadRequest(c, "Invalid tool_id")
+			return
+		}
+		toolIDs = append(toolIDs, uint(id))
+	}
+
+	series, err := statsService.GetUsageSeries(services.UsageSeriesOptions{
+		ToolIDs:  toolIDs,
+		Bucket:   bucket,
+		From:     from,
+		To:       to,
+		Location: loc,
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"series": series,
+	})
+}
+
+// GetVisitors gets unique visitors per UTC day, across all tools or of the tool
+// given by tool_id. Without a range the last 30 days are returned.
+func GetVisitors(c *gin.Context) {
+	from, to, ok := parseDayRange(c, 30)
+	if !ok {
+		return
+	}
+
+	var toolID uint
+	if value := c.Query("tool_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || id == 0 {
+			response.BadRequest(c, "Invalid tool_id")
+			return
+		}
+		toolID = uint(id)
+	}
+
+	visitors, err := statsService.GetVisitorSeries(from, to, toolID)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"visitors": visitors,
+	})
+}
+
+// GetSearchCTR gets searches, clicks, click-through rate and conversion per
+// search term, by default over the last 30 days
+func GetSearchCTR(c *gin.Context) {
+	opts, ok := parseSearchStatsOptions(c)
+	if !ok {
+		return
+	}
+
+	report, err := statsService.GetSearchCTR(opts)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"search": report,
+	})
+}
+
+// GetTopQueries gets the most searched queries
+func GetTopQueries(c *gin.Context) {
+	opts, ok := parseSearchStatsOptions(c)
+	if !ok {
+		return
+	}
+
+	queries, err := statsService.GetTopQueries(opts)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"search": queries,
+	})
+}
+
+// GetZeroResultQueries gets the most searched queries that found no tools
+func GetZeroResultQueries(c *gin.Context) {
+	opts, ok := parseSearchStatsOptions(c)
+	if !ok {
+		return
+	}
+
+	queries, err := statsService.GetZeroResultQueries(opts)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"search": queries,
+	})
+}
+
+// GetSearchLocales gets searches per UI locale with their top queries
+func GetSearchLocales(c *gin.Context) {
+	opts, ok := parseSearchStatsOptions(c)
+	if !ok {
+		return
+	}
+
+	locales, err := statsService.GetSearchLocales(opts)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"search": locales,
+	})
+}
+
+// parseSearchStatsOptions parses the range, locale, source and limit query
+// parameters of the search analytics endpoints. The range defaults to the
+// last 30 days.
+func parseSearchStatsOptions(c *gin.Context) (services.SearchStatsOptions, bool) {
+	from, to, ok := parseDayRange(c, 30)
+	if !ok {
+		return services.SearchStatsOptions{}, false
+	}
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return services.SearchStatsOptions{}, false
+	}
+
+	return services.SearchStatsOptions{
+		From:   from,
+		To:     to,
+		Locale: c.Query("locale"),
+		Source: c.Query("source"),
+		Limit:  limit,
+	}, true
+}
+
+// GetToolSources gets the top source pages and UI locales of a tool's clicks,
+// by default over the last 30 days
+func GetToolSources(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+	from, to, ok := parseDayRange(c, 30)
+	if !ok {
+		return
+	}
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	sources, err := statsService.GetToolSources(id, from, to, limit)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"sources": sources,
+	})
+}
+
+// parseDayRange parses the from and to query parameters, defaulting to the
+// given number of days up to now. It responds with 400 and returns false when
+// either is invalid.
+func parseDayRange(c *gin.Context, days int) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		t, err := parseTimeParam(value, time.UTC)
+		if err != nil {
+			response.BadRequest(c, "Invalid to, expected RFC 3339 or YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -days)
+	if value := c.Query("from"); value != "" {
+		t, err := parseTimeParam(value, time.UTC)
+		if err != nil {
+			response.BadRequest(c, "Invalid from, expected RFC 3339 or YYYY-MM-DD")
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	return from, to, true
+}
+
+// CompactUsage rolls up raw usage now. With since, hours from that time on are
+// recomputed from the raw rows that are still kept.
+func CompactUsage(c *gin.Context) {
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		t, err := parseTimeParam(value, time.UTC)
+		if err != nil {
+			response.BadRequest(c, "Invalid since, expected RFC 3339 or YYYY-MM-DD")
+			return
+		}
+		since = t
+	}
+
+	summary, err := usageRollup.Compact(since)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"rollup": summary,
+	})
+}
+
+// parseTimezone loads the IANA timezone named by the tz query parameter, UTC by default
+func parseTimezone(c *gin.Context) (*time.Location, bool) {
+	name := c.DefaultQuery("tz", "UTC")
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		response.BadRequest(c, "Invalid tz, expected an IANA timezone such as Asia/Shanghai")
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseTimeParam parses an RFC 3339 timestamp, or a date or local date and time in loc
+func parseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", value, loc); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tagService *services.TagService
+
+// TagRequest is the request body for creating a tag
+type TagRequest struct {
+	Slug string `json:"slug" binding:"omitempty,max=100"`
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// TagUpdateRequest is the request body for partially updating a tag
+type TagUpdateRequest struct {
+	Slug *string `json:"slug" binding:"omitempty,min=1,max=100"`
+	Name *string `json:"name" binding:"omitempty,min=1,max=100"`
+}
+
+// GetTags lists all tags with their active tool counts
+func GetTags(c *gin.Context) {
+	tags, err := tagService.ListTags()
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"tags": tags,
+	})
+}
+
+// CreateTag creates a new tag
+func CreateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	tag, err := tagService.CreateTag(req.Name, req.Slug)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tag created successfully", gin.H{
+		"tag": tag,
+	})
+}
+
+// UpdateTag updates an existing tag
+func UpdateTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid tag ID")
+	if !ok {
+		return
+	}
+
+	var req TagUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Slug != nil {
+		updates["slug"] = *req.Slug
+	}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if len(updates) == 0 {
+		response.BadRequest(c, "No fields to update")
+		return
+	}
+
+	tag, err := tagService.UpdateTag(id, updates)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tag updated successfully", gin.H{
+		"tag": tag,
+	})
+}
+
+// DeleteTag deletes a tag and removes it from all tools
+func DeleteTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "Invalid tag ID")
+	if !ok {
+		return
+	}
+
+	if err := tagService.DeleteTag(id); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tag deleted successfully", nil)
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"tion.work/backend/internal/middleware"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var toolService *services.ToolService
+
+// ToolRequest is the request body for creating a tool
+type ToolRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+	CategoryID  uint   `json:"category_id" binding:"required"`
+	TagIDs      []uint `json:"tag_ids"`
+	Icon        string `json:"icon" binding:"max=50"`
+	URL         string `json:"url" binding:"required,uri,max=500"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// ToolUpdateRequest is the request body for partially updating a tool
+type ToolUpdateRequest struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+	CategoryID  *uint   `json:"category_id" binding:"omitempty,min=1"`
+	TagIDs      *[]uint `json:"tag_ids"`
+	Icon        *string `json:"icon" binding:"omitempty,max=50"`
+	URL         *string `json:"url" binding:"omitempty,uri,max=500"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+// toModel converts the request into a tool model
+func (r *ToolRequest) toModel() *models.Tool {
+	tool := &models.Tool{
+		Name:        r.Name,
+		Description: r.Description,
+		CategoryID:  &r.CategoryID,
+		Icon:        r.Icon,
+		URL:         r.URL,
+		IsActive:    true,
+	}
+	if r.IsActive != nil {
+		tool.IsActive = *r.IsActive
+	}
+	return tool
+}
+
+// toUpdates converts the request into a column update map
+func (r *ToolUpdateRequest) toUpdates() map[string]interface{} {
+	updates := make(map[string]interface{})
+	if r.Name != nil {
+		updates["name"] = *r.Name
+	}
+	if r.Description != nil {
+		updates["description"] = *r.Description
+	}
+	if r.CategoryID != nil {
+		updates["category_id"] = *r.CategoryID
+	}
+	if r.Icon != nil {
+		updates["icon"] = *r.Icon
+	}
+	if r.URL != nil {
+		updates["url"] = *r.URL
+	}
+	if r.IsActive != nil {
+		updates["is_active"] = *r.IsActive
+	}
+	return updates
+}
+
+// GetTools gets a page of active tools in the negotiated locale
+func GetTools(c *gin.Context) {
+	listTools(c, false, middleware.GetLocales(c))
+}
+
+// GetAdminTools gets a page of tools in their original text, optionally including inactive ones
+func GetAdminTools(c *gin.Context) {
+	includeInactive, _ := strconv.ParseBool(c.Query("include_inactive"))
+	listTools(c, includeInactive, nil)
+}
+
+// GetTool gets a specific tool by ID
+func GetTool(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	tool, err := toolService.GetToolByID(id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	if err := translationService.LocalizeTool(tool, middleware.GetLocales(c)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+	if tool.Locale != "" {
+		c.Header("Content-Language", tool.Locale)
+	}
+
+	response.Success(c, gin.H{
+		"tool": tool,
+	})
+}
+
+// CreateTool creates a new tool
+func CreateTool(c *gin.Context) {
+	var req ToolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	tool := req.toModel()
+	if err := toolService.CreateTool(tool, req.TagIDs, middleware.GetActor(c)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tool created successfully", gin.H{
+		"tool": tool,
+	})
+}
+
+// UpdateTool updates an existing tool
+func UpdateTool(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	var req ToolUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	updates := req.toUpdates()
+	if len(updates) == 0 && req.TagIDs == nil {
+		response.BadRequest(c, "No fields to update")
+		return
+	}
+
+	var tagIDs []uint
+	if req.TagIDs != nil {
+		tagIDs = append([]uint{}, *req.TagIDs...)
+	}
+
+	tool, err := toolService.UpdateTool(id, updates, tagIDs, middleware.GetActor(c))
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tool updated successfully", gin.H{
+		"tool": tool,
+	})
+}
+
+// DeleteTool deletes a tool
+func DeleteTool(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	if err := toolService.DeleteTool(id, middleware.GetActor(c)); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Tool deleted successfully", nil)
+}
+
+// listTools binds list query parameters and responds with a page of tools
+// localized along the given locale chain
+func listTools(c *gin.Context, includeInactive bool, locales []string) {
+	limit, err := queryInt(c, "limit", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid limit")
+		return
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		response.BadRequest(c, "Invalid offset")
+		return
+	}
+
+	tagMode := c.DefaultQuery("tag_mode", "any")
+	if tagMode != "any" && tagMode != "all" {
+		response.BadRequest(c, "Invalid tag_mode, expected any or all")
+		return
+	}
+
+	page, err := toolService.ListTools(services.ToolListOptions{
+		Category:        c.Query("category"),
+		Tags:            queryList(c, "tags"),
+		MatchAllTags:    tagMode == "all",
+		Sort:            c.Query("sort"),
+		Limit:           limit,
+		Offset:          offset,
+		Cursor:          c.Query("cursor"),
+		IncludeInactive: includeInactive,
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	if err := translationService.LocalizeTools(page.Tools, locales); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, page)
+}
+
+// parseToolID parses the :id path parameter, responding with 400 when invalid
+func parseToolID(c *gin.Context) (uint, bool) {
+	return parseIDParam(c, "id", "Invalid tool ID")
+}
+
+// parseIDParam parses a numeric path parameter, responding with 400 and the given message when invalid
+func parseIDParam(c *gin.Context, key, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(key), 10, 64)
+	if err != nil || id == 0 {
+		response.BadRequest(c, message)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryList reads a comma separated query parameter, skipping empty items
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range strings.Split(c.Query(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// queryInt reads an integer query parameter with a default value
+func queryInt(c *gin.Context, key string, defaultValue int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+var translationService *services.TranslationService
+
+// TranslationRequest is the request body for setting a tool translation
+type TranslationRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// GetToolTranslations lists all translations of a tool
+func GetToolTranslations(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	translations, err := translationService.GetTranslations(id)
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"translations": translations,
+	})
+}
+
+// UpsertToolTranslation creates or replaces a tool translation for a locale
+func UpsertToolTranslation(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	var req TranslationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+
+	translation, err := translationService.UpsertTranslation(id, c.Param("locale"), services.TranslationInput{
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Translation saved successfully", gin.H{
+		"translation": translation,
+	})
+}
+
+// DeleteToolTranslation deletes a tool translation for a locale
+func DeleteToolTranslation(c *gin.Context) {
+	id, ok := parseToolID(c)
+	if !ok {
+		return
+	}
+
+	if err := translationService.DeleteTranslation(id, c.Param("locale")); err != nil {
+		handleServiceError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Translation deleted successfully", nil)
+}
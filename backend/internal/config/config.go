@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,9 +20,81 @@ type Config struct {
 	// Redis configuration
 	RedisURL string
 
+	// Rate limit configuration. Limits are [algorithm:]rate/period[:burst],
+	// empty or "off" disables the limit of a route group.
+	RateLimitStore      string // memory or redis
+	RateLimitMemorySize int    // clients tracked by the memory store
+	RateLimitPublic     string // public catalog and stats reads, per IP
+	RateLimitTracking   string // usage and search events, per IP
+	RateLimitAuth       string // sign in and out, per IP
+	RateLimitAdmin      string // admin API, per user or API key
+	RateLimitAssistant  string // AI development assistant API, per user or API key
+
+	// AI agent run quotas of the development assistant. Zero limits and
+	// budgets are unlimited; budgets are per user or API key per UTC day.
+	AgentMaxConcurrent           int
+	AgentMaxConcurrentPerUser    int
+	AgentMaxConcurrentPerProject int
+	AgentQueueSize               int           // runs waiting for a slot
+	AgentQueueTimeout            time.Duration // how long a run waits for a slot
+	AgentDailyRuns               int
+	AgentDailyMinutes            int // wall-clock minutes
+
 	// API configuration
 	APIKey string
 
+	// Session configuration
+	SessionTTL          time.Duration
+	SessionCookieSecure bool // send the session cookie over HTTPS only
+
+	// Localization configuration
+	DefaultLocale string
+
+	// Link checker configuration
+	LinkCheckInterval         time.Duration // 0 disables scheduled checks
+	LinkCheckTimeout          time.Duration
+	LinkCheckConcurrency      int
+	LinkCheckFailureThreshold int
+	LinkCheckRetentionDays    int
+
+	// Usage rollup configuration
+	UsageRollupInterval time.Duration // 0 disables scheduled compaction
+	UsageRollupDelay    time.Duration
+	UsageRetentionDays  int // 0 keeps raw usage rows forever
+
+	// Usage ingestion configuration
+	UsageIngestQueueSize      int
+	UsageIngestBatchSize      int
+	UsageIngestFlushInterval  time.Duration
+	UsageIngestEnqueueTimeout time.Duration // 0 drops immediately when the queue is full
+
+	// Live stats configuration
+	LiveMaxClients        int
+	LiveHeartbeatInterval time.Duration
+
+	// Tool ranking configuration
+	ToolScoreInterval  time.Duration // 0 disables scheduled score refreshes
+	PopularityHalfLife time.Duration
+	TrendingWindow     time.Duration
+	TrendingBaseline   time.Duration
+
+	// Alerting configuration
+	AlertEvaluationInterval time.Duration // 0 disables scheduled evaluation
+	AlertRulesFile          string        // YAML rules, the default rules when empty
+	AlertWebhookURL         string
+	AlertSMTPAddr           string // host:port, email alerts are disabled when empty
+	AlertSMTPUsername       string
+	AlertSMTPPassword       string
+	AlertEmailFrom          string
+	AlertEmailTo            []string
+
+	// Logging configuration
+	LogLevel  string // panic, fatal, error, warn, info, debug or trace
+	LogFormat string // json or text
+
+	// Metrics configuration
+	MetricsToken string // bearer token required by /metrics, public when empty
+
 	// Service configuration
 	ServiceName string
 	Version     string
@@ -35,13 +109,68 @@ func InitConfig() error {
 	}
 
 	AppConfig = &Config{
-		Port:        getEnv("PORT", "8080"),
-		Mode:        getEnv("GIN_MODE", "debug"),
-		DatabaseURL: getEnv("DATABASE_URL", ""),
-		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379/0"),
-		APIKey:      getEnv("API_KEY", ""),
-		ServiceName: getEnv("SERVICE_NAME", "Tion Backend API"),
-		Version:     getEnv("VERSION", "1.0.0"),
+		Port:          getEnv("PORT", "8080"),
+		Mode:          getEnv("GIN_MODE", "debug"),
+		DatabaseURL:   getEnv("DATABASE_URL", ""),
+		RedisURL:      getEnv("REDIS_URL", "redis://localhost:6379/0"),
+		APIKey:        getEnv("API_KEY", ""),
+		DefaultLocale: getEnv("DEFAULT_LOCALE", "en"),
+		LogLevel:      getEnv("LOG_LEVEL", "info"),
+		LogFormat:     getEnv("LOG_FORMAT", "json"),
+		MetricsToken:  getEnv("METRICS_TOKEN", ""),
+		ServiceName:   getEnv("SERVICE_NAME", "Tion Backend API"),
+		Version:       getEnv("VERSION", "1.0.0"),
+
+		RateLimitStore:      getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitMemorySize: getEnvInt("RATE_LIMIT_MEMORY_SIZE", 10000),
+		RateLimitPublic:     getEnv("RATE_LIMIT_PUBLIC", "token_bucket:300/1m:100"),
+		RateLimitTracking:   getEnv("RATE_LIMIT_TRACKING", "token_bucket:120/1m:30"),
+		RateLimitAuth:       getEnv("RATE_LIMIT_AUTH", "sliding_window:10/1m"),
+		RateLimitAdmin:      getEnv("RATE_LIMIT_ADMIN", "token_bucket:600/1m:120"),
+		RateLimitAssistant:  getEnv("RATE_LIMIT_ASSISTANT", "token_bucket:120/1m:30"),
+
+		AgentMaxConcurrent:           getEnvInt("AGENT_MAX_CONCURRENT", 4),
+		AgentMaxConcurrentPerUser:    getEnvInt("AGENT_MAX_CONCURRENT_PER_USER", 2),
+		AgentMaxConcurrentPerProject: getEnvInt("AGENT_MAX_CONCURRENT_PER_PROJECT", 1),
+		AgentQueueSize:               getEnvInt("AGENT_QUEUE_SIZE", 20),
+		AgentQueueTimeout:            getEnvDuration("AGENT_QUEUE_TIMEOUT", 10*time.Minute),
+		AgentDailyRuns:               getEnvInt("AGENT_DAILY_RUNS", 100),
+		AgentDailyMinutes:            getEnvInt("AGENT_DAILY_MINUTES", 240),
+
+		SessionTTL:          getEnvDuration("SESSION_TTL", 7*24*time.Hour),
+		SessionCookieSecure: getEnvBool("SESSION_COOKIE_SECURE", true),
+
+		LinkCheckInterval:         getEnvDuration("LINK_CHECK_INTERVAL", 24*time.Hour),
+		LinkCheckTimeout:          getEnvDuration("LINK_CHECK_TIMEOUT", 10*time.Second),
+		LinkCheckConcurrency:      getEnvInt("LINK_CHECK_CONCURRENCY", 4),
+		LinkCheckFailureThreshold: getEnvInt("LINK_CHECK_FAILURE_THRESHOLD", 3),
+		LinkCheckRetentionDays:    getEnvInt("LINK_CHECK_RETENTION_DAYS", 30),
+
+		UsageRollupInterval: getEnvDuration("USAGE_ROLLUP_INTERVAL", 5*time.Minute),
+		UsageRollupDelay:    getEnvDuration("USAGE_ROLLUP_DELAY", 2*time.Minute),
+		UsageRetentionDays:  getEnvInt("USAGE_RETENTION_DAYS", 90),
+
+		UsageIngestQueueSize:      getEnvInt("USAGE_INGEST_QUEUE_SIZE", 10000),
+		UsageIngestBatchSize:      getEnvInt("USAGE_INGEST_BATCH_SIZE", 500),
+		UsageIngestFlushInterval:  getEnvDuration("USAGE_INGEST_FLUSH_INTERVAL", time.Second),
+		UsageIngestEnqueueTimeout: getEnvDuration("USAGE_INGEST_ENQUEUE_TIMEOUT", 50*time.Millisecond),
+
+		LiveMaxClients:        getEnvInt("LIVE_MAX_CLIENTS", 100),
+		LiveHeartbeatInterval: getEnvDuration("LIVE_HEARTBEAT_INTERVAL", 15*time.Second),
+
+		ToolScoreInterval:  getEnvDuration("TOOL_SCORE_INTERVAL", 15*time.Minute),
+		PopularityHalfLife: getEnvDuration("POPULARITY_HALF_LIFE", 7*24*time.Hour),
+		TrendingWindow:     getEnvDuration("TRENDING_WINDOW", 24*time.Hour),
+		TrendingBaseline:   getEnvDuration("TRENDING_BASELINE", 7*24*time.Hour),
+
+		AlertEvaluationInterval: getEnvDuration("ALERT_EVALUATION_INTERVAL", time.Minute),
+		AlertRulesFile:          getEnv("ALERT_RULES_FILE", ""),
+		AlertWebhookURL:         getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertSMTPAddr:           getEnv("ALERT_SMTP_ADDR", ""),
+		AlertSMTPUsername:       getEnv("ALERT_SMTP_USERNAME", ""),
+		AlertSMTPPassword:       getEnv("ALERT_SMTP_PASSWORD", ""),
+		AlertEmailFrom:          getEnv("ALERT_EMAIL_FROM", "alerts@tion.work"),
+		AlertEmailTo:            getEnvList("ALERT_EMAIL_TO"),
 	}
 
 	return nil
@@ -62,3 +191,31 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -1,8 +1,8 @@
 package database
 
 import (
-	"tion-backend/internal/config"
-	"tion-backend/internal/models"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -12,36 +12,100 @@ import (
 
 var DB *gorm.DB
 
-func InitDatabase() error {
-	var err error
+// dbLogger logs SQL statements, replaceable with SetLogger before InitDatabase
+var dbLogger = logger.Default.LogMode(logger.Info)
+
+// SetLogger replaces the SQL logger used by InitDatabase
+func SetLogger(l logger.Interface) {
+	dbLogger = l
+}
 
-	// Use PostgreSQL if DATABASE_URL is provided, otherwise use SQLite
+// InitDatabase connects to PostgreSQL when DATABASE_URL is set, otherwise to
+// the SQLite file tion.db, and prepares the schema
+func InitDatabase() error {
+	dialector := sqlite.Open("tion.db")
 	if config.AppConfig.DatabaseURL != "" {
-		DB, err = gorm.Open(postgres.Open(config.AppConfig.DatabaseURL), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
-	} else {
-		DB, err = gorm.Open(sqlite.Open("tion.db"), &gorm.Config{
-			Logger: logger.Default.LogMode(logger.Info),
-		})
+		dialector = postgres.Open(config.AppConfig.DatabaseURL)
 	}
 
+	db, err := Connect(dialector)
 	if err != nil {
 		return err
 	}
+	DB = db
+	return nil
+}
+
+// Connect opens a database, observes its queries in the metrics registry and
+// migrates the schema. Tests use it with a temporary SQLite database.
+func Connect(dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: dbLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Observe query durations in the metrics registry
+	if err := db.Use(metricsPlugin{}); err != nil {
+		return nil, err
+	}
+
+	// Convert tables whose layout AutoMigrate cannot change
+	if err := migrateLegacyAPIKeys(db); err != nil {
+		return nil, err
+	}
 
 	// Auto migrate the schema
-	if err := DB.AutoMigrate(
+	if err := db.AutoMigrate(
+		&models.Category{},
+		&models.CategoryTranslation{},
+		&models.Tag{},
 		&models.Tool{},
+		&models.ToolTranslation{},
+		&models.ToolLinkCheck{},
+		&models.ToolRevision{},
 		&models.ToolUsage{},
+		&models.ToolUsageHourly{},
+		&models.ToolUsageDaily{},
+		&models.VisitorsDaily{},
+		&models.SearchEvent{},
+		&models.ToolScore{},
+		&models.Alert{},
+		&models.VisitorSalt{},
+		&models.RollupWatermark{},
 		&models.APIKey{},
+		&models.User{},
+		&models.Session{},
+		&models.ProjectGrant{},
+		&models.AuditLog{},
+		&models.AuditChainHead{},
+		&models.AgentRun{},
 	); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	// Tool names are unique among the tools that are not deleted
+	if err := db.Exec(toolNameIndex).Error; err != nil {
+		return nil, err
+	}
+
+	// Apply data migrations
+	if err := runMigrations(db); err != nil {
+		return nil, err
+	}
+
+	// Prepare the full-text search index
+	if err := setupSearch(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
 }
 
+// toolNameIndex is a partial unique index, which both PostgreSQL and SQLite support
+const toolNameIndex = `CREATE UNIQUE INDEX IF NOT EXISTS idx_tools_name ON tools (name) WHERE deleted_at IS NULL`
+
 func GetDB() *gorm.DB {
 	return DB
 }
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"tion.work/backend/pkg/metrics"
+
+	"gorm.io/gorm"
+)
+
+var (
+	dbQueryDuration = metrics.Default.NewHistogramVec("db_query_duration_seconds",
+		"Duration of database queries by operation and table.", nil, "operation", "table")
+	dbQueryErrors = metrics.Default.NewCounterVec("db_query_errors_total",
+		"Failed database queries by operation and table, without record not found.", "operation", "table")
+)
+
+// queryStartKey holds the start time of a statement in its instance settings
+const queryStartKey = "metrics:query_start"
+
+// metricsPlugin observes the duration and errors of every statement GORM runs
+type metricsPlugin struct{}
+
+func (metricsPlugin) Name() string {
+	return "metrics"
+}
+
+func (metricsPlugin) Initialize(db *gorm.DB) error {
+	type register func(name string, fn func(*gorm.DB)) error
+	callbacks := db.Callback()
+	processors := []struct {
+		operation string
+		before    register
+		after     register
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+
+	for _, p := range processors {
+		if err := p.before("metrics:before_"+p.operation, startQuery); err != nil {
+			return err
+		}
+		if err := p.after("metrics:after_"+p.operation, observeQuery(p.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func observeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		dbQueryDuration.Observe(time.Since(start).Seconds(), operation, table)
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			dbQueryErrors.Inc(operation, table)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package database
+
+import (
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// runMigrations applies data migrations that AutoMigrate cannot express
+func runMigrations(db *gorm.DB) error {
+	if err := migrateLegacyCategories(db); err != nil {
+		return err
+	}
+	if err := anonymizeToolUsage(db); err != nil {
+		return err
+	}
+	if err := backfillToolRevisions(db); err != nil {
+		return err
+	}
+	return chainAuditLog(db)
+}
+
+// chainAuditLog creates the head of the audit log chain and links the entries
+// written before the log was hash chained, oldest first
+func chainAuditLog(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var head models.AuditChainHead
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name = ?", models.AuditChain).Limit(1).Find(&head).Error; err != nil {
+			return err
+		}
+		if head.Name == "" {
+			head = models.AuditChainHead{Name: models.AuditChain}
+			if err := tx.Create(&head).Error; err != nil {
+				return err
+			}
+		}
+
+		lastID := head.LastID
+		for {
+			var entries []models.AuditLog
+			if err := tx.Where("id > ?", head.LastID).Order("id").Limit(500).Find(&entries).Error; err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				break
+			}
+			for i := range entries {
+				entry := &entries[i]
+				entry.PrevHash = head.Hash
+				hash, err := entry.ComputeHash()
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(entry).UpdateColumns(map[string]interface{}{
+					"prev_hash": entry.PrevHash,
+					"hash":      hash,
+				}).Error; err != nil {
+					return err
+				}
+				head.LastID, head.Hash = entry.ID, hash
+			}
+		}
+		if head.LastID == lastID {
+			return nil
+		}
+		return tx.Model(&head).Updates(map[string]interface{}{
+			"last_id": head.LastID,
+			"hash":    head.Hash,
+		}).Error
+	})
+}
+
+// anonymizeToolUsage replaces the IP address and user agent of usage rows
+// recorded before visitor hashing with an anonymous identity, deletes bot
+// traffic and drops the raw columns. Every day gets a throwaway salt, so the
+// identities stay comparable within a day but cannot be traced back.
+func anonymizeToolUsage(db *gorm.DB) error {
+	if !db.Migrator().HasColumn("tool_usages", "ip_address") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		salts := make(map[string][]byte)
+		var lastID uint
+		for {
+			var rows []struct {
+				ID        uint
+				IPAddress string
+				UserAgent string
+				CreatedAt time.Time
+			}
+			if err := tx.Table("tool_usages").
+				Select("id, ip_address, user_agent, created_at").
+				Where("id > ?", lastID).Order("id").Limit(500).
+				Scan(&rows).Error; err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				break
+			}
+
+			var bots []uint
+			for _, row := range rows {
+				lastID = row.ID
+				agent := utils.ParseUserAgent(row.UserAgent)
+				if agent.Bot {
+					bots = append(bots, row.ID)
+					continue
+				}
+
+				day := row.CreatedAt.UTC().Format("2006-01-02")
+				salt, ok := salts[day]
+				if !ok {
+					var err error
+					if salt, err = utils.NewVisitorSalt(); err != nil {
+						return err
+					}
+					salts[day] = salt
+				}
+
+				if err := tx.Table("tool_usages").Where("id = ?", row.ID).Updates(map[string]interface{}{
+					"visitor_hash": utils.HashVisitor(salt, row.IPAddress, row.UserAgent),
+					"browser":      agent.Browser,
+					"os":           agent.OS,
+					"device_class": agent.Device,
+				}).Error; err != nil {
+					return err
+				}
+			}
+
+			if len(bots) > 0 {
+				if err := tx.Table("tool_usages").Where("id IN ?", bots).Delete(nil).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := dropColumn(tx, "tool_usages", "ip_address"); err != nil {
+			return err
+		}
+		return dropColumn(tx, "tool_usages", "user_agent")
+	})
+}
+
+// legacyAPIKeyPrefix starts the prefix of keys migrated from the plain layout
+const legacyAPIKeyPrefix = "legacy_"
+
+// migrateLegacyAPIKeys replaces the API key table that stored plain keys with
+// the hashed layout. Legacy keys keep working with the admin scope, revoked
+// when they were inactive.
+func migrateLegacyAPIKeys(db *gorm.DB) error {
+	// The SQLite migrator matches column names loosely, so look for the
+	// hashed layout instead of the short "key" column
+	if !db.Migrator().HasTable("api_keys") || db.Migrator().HasColumn("api_keys", "key_hash") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			Key         string
+			Name        string
+			Description string
+			IsActive    bool
+			CreatedAt   time.Time
+			UpdatedAt   time.Time
+			DeletedAt   gorm.DeletedAt
+		}
+		if err := tx.Table("api_keys").
+			Select("key, name, description, is_active, created_at, updated_at, deleted_at").
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Migrator().DropTable("api_keys"); err != nil {
+			return err
+		}
+		if err := tx.Migrator().CreateTable(&models.APIKey{}); err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			// Legacy keys have no public part, so they are identified by the
+			// start of their hash rather than the start of the secret
+			hash := utils.HashAPIKey(row.Key)
+			key := models.APIKey{
+				Name:        row.Name,
+				Description: row.Description,
+				Prefix:      legacyAPIKeyPrefix + hash[:8],
+				KeyHash:     hash,
+				Scopes:      models.ScopeList{models.ScopeAdmin},
+				CreatedBy:   "migration",
+				CreatedAt:   row.CreatedAt,
+				UpdatedAt:   row.UpdatedAt,
+				DeletedAt:   row.DeletedAt,
+			}
+			if !row.IsActive {
+				key.RevokedAt = &row.UpdatedAt
+			}
+			if err := tx.Create(&key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// backfillToolRevisions writes a baseline revision for tools created before
+// revision history existed, so that their first edit can be diffed and undone
+func backfillToolRevisions(db *gorm.DB) error {
+	var tools []models.Tool
+	if err := db.Unscoped().Preload("Tags").
+		Where("id NOT IN (?)", db.Model(&models.ToolRevision{}).Select("tool_id")).
+		Order("id").Find(&tools).Error; err != nil {
+		return err
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+
+	revisions := make([]models.ToolRevision, 0, len(tools))
+	for i := range tools {
+		revisions = append(revisions, models.ToolRevision{
+			ToolID:   tools[i].ID,
+			Revision: 1,
+			Action:   models.ToolRevisionBaseline,
+			Actor:    "system",
+			Snapshot: models.NewToolSnapshot(&tools[i]),
+		})
+	}
+	return db.CreateInBatches(revisions, 100).Error
+}
+
+// migrateLegacyCategories converts the free-text tools.category column into
+// Category rows referenced by tools.category_id, moves localized category text
+// from tool translations into category translations, and drops the old columns.
+func migrateLegacyCategories(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasColumn("tools", "category") {
+		return nil
+	}
+
+	// The search index depends on the old column and is rebuilt by setupSearch
+	if err := dropSearchIndex(db); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var names []string
+		if err := tx.Table("tools").
+			Where("category IS NOT NULL AND category <> ''").
+			Distinct("category").
+			Pluck("category", &names).Error; err != nil {
+			return err
+		}
+
+		for i, name := range names {
+			slug := utils.Slugify(name)
+			if slug == "" {
+				continue
+			}
+
+			category := models.Category{Slug: slug, Name: name, SortOrder: i}
+			if err := tx.Where("slug = ?", slug).FirstOrCreate(&category).Error; err != nil {
+				return err
+			}
+
+			if err := tx.Table("tools").
+				Where("category = ? AND category_id IS NULL", name).
+				Update("category_id", category.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if tx.Migrator().HasColumn("tool_translations", "category") {
+			if err := migrateTranslatedCategories(tx); err != nil {
+				return err
+			}
+			if err := dropColumn(tx, "tool_translations", "category"); err != nil {
+				return err
+			}
+		}
+
+		return dropColumn(tx, "tools", "category")
+	})
+}
+
+// migrateTranslatedCategories copies the category text of tool translations into
+// category translations, keeping the first value seen for each category and locale
+func migrateTranslatedCategories(tx *gorm.DB) error {
+	var rows []struct {
+		CategoryID uint
+		Locale     string
+		Category   string
+	}
+	if err := tx.Table("tool_translations").
+		Select("tools.category_id AS category_id, tool_translations.locale AS locale, tool_translations.category AS category").
+		Joins("JOIN tools ON tools.id = tool_translations.tool_id").
+		Where("tools.category_id IS NOT NULL AND tool_translations.category <> ''").
+		Order("tool_translations.id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		translation := models.CategoryTranslation{
+			CategoryID: row.CategoryID,
+			Locale:     row.Locale,
+			Name:       row.Category,
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&translation).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumn drops a column by table name. The SQLite migrator can only drop
+// columns of registered models, so plain DDL is used instead (SQLite 3.35+).
+func dropColumn(tx *gorm.DB, table, column string) error {
+	return tx.Exec("ALTER TABLE " + table + " DROP COLUMN " + column).Error
+}
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// legacyTool is the tools schema from before categories became a table
+type legacyTool struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"not null"`
+	Description string
+	Category    string
+	Icon        string
+	URL         string
+	IsActive    bool `gorm:"default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyTool) TableName() string { return "tools" }
+
+// legacyToolTranslation is the tool_translations schema from the same time
+type legacyToolTranslation struct {
+	ID          uint   `gorm:"primaryKey"`
+	ToolID      uint   `gorm:"not null;uniqueIndex:idx_tool_translations_tool_locale"`
+	Locale      string `gorm:"size:35;not null;uniqueIndex:idx_tool_translations_tool_locale"`
+	Name        string `gorm:"not null"`
+	Description string
+	Category    string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (legacyToolTranslation) TableName() string { return "tool_translations" }
+
+// legacyToolUsage is the tool_usages schema from before visitor hashing
+type legacyToolUsage struct {
+	ID        uint `gorm:"primaryKey"`
+	ToolID    uint `gorm:"not null;index"`
+	IPAddress string
+	UserAgent string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (legacyToolUsage) TableName() string { return "tool_usages" }
+
+// legacyAPIKey is the api_keys schema from before keys were hashed
+type legacyAPIKey struct {
+	ID          uint   `gorm:"primaryKey"`
+	Key         string `gorm:"uniqueIndex;not null"`
+	Name        string
+	Description string
+	IsActive    bool `gorm:"default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyAPIKey) TableName() string { return "api_keys" }
+
+func TestMigrateLegacyCategories(t *testing.T) {
+	SetLogger(logger.Discard)
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.AutoMigrate(&legacyTool{}, &legacyToolTranslation{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Create([]legacyTool{
+		{ID: 1, Name: "Formatter", Category: "Text Tools"},
+		{ID: 2, Name: "Counter", Category: "text  tools!"},
+		{ID: 3, Name: "Converter", Category: "文本 工具"},
+		{ID: 4, Name: "Timer", Category: "!!!"},
+		{ID: 5, Name: "Clock"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Create([]legacyToolTranslation{
+		{ID: 1, ToolID: 1, Locale: "zh", Name: "格式化", Category: "文本"},
+		{ID: 2, ToolID: 2, Locale: "zh", Name: "计数", Category: "文字"},
+		{ID: 3, ToolID: 3, Locale: "en", Name: "Converter", Category: "Text"},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := legacy.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	db := connectTest(t, dsn)
+
+	var categories []models.Category
+	if err := db.Order("slug").Find(&categories).Error; err != nil {
+		t.Fatal(err)
+	}
+	slugs := make(map[string]uint)
+	for _, category := range categories {
+		slugs[category.Slug] = category.ID
+	}
+	// Names that only differ in case and punctuation share a category, and
+	// CJK names keep their characters
+	if len(slugs) != 2 || slugs["text-tools"] == 0 || slugs["文本-工具"] == 0 {
+		t.Fatalf("categories %+v, want text-tools and 文本-工具", categories)
+	}
+
+	wantCategory := map[uint]uint{1: slugs["text-tools"], 2: slugs["text-tools"], 3: slugs["文本-工具"], 4: 0, 5: 0}
+	var tools []models.Tool
+	if err := db.Find(&tools).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools {
+		var got uint
+		if tool.CategoryID != nil {
+			got = *tool.CategoryID
+		}
+		if got != wantCategory[tool.ID] {
+			t.Errorf("tool %d in category %d, want %d", tool.ID, got, wantCategory[tool.ID])
+		}
+	}
+
+	// The first translation seen wins for a merged category
+	var translations []models.CategoryTranslation
+	if err := db.Order("category_id, locale").Find(&translations).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []models.CategoryTranslation{
+		{CategoryID: slugs["text-tools"], Locale: "zh", Name: "文本"},
+		{CategoryID: slugs["文本-工具"], Locale: "en", Name: "Text"},
+	}
+	if slugs["文本-工具"] < slugs["text-tools"] {
+		want[0], want[1] = want[1], want[0]
+	}
+	if len(translations) != len(want) {
+		t.Fatalf("category translations %+v, want %+v", translations, want)
+	}
+	for i, translation := range translations {
+		if translation.CategoryID != want[i].CategoryID || translation.Locale != want[i].Locale || translation.Name != want[i].Name {
+			t.Errorf("category translation %+v, want %+v", translation, want[i])
+		}
+	}
+
+	for _, table := range []string{"tools", "tool_translations"} {
+		if db.Migrator().HasColumn(table, "category") {
+			t.Errorf("%s.category was not dropped", table)
+		}
+	}
+
+	// Connecting again leaves the migrated data alone. The migrator rebuilds
+	// the tools table this time, which the search triggers must survive.
+	db = connectTest(t, dsn)
+	var count int64
+	if err := db.Model(&models.Category{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("%d categories after reconnecting, want 2", count)
+	}
+	// Existing tools get exactly one baseline revision
+	if err := db.Model(&models.ToolRevision{}).Where("action = ? AND revision = 1", models.ToolRevisionBaseline).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 {
+		t.Errorf("%d baseline revisions after reconnecting, want 5", count)
+	}
+	if hasFTS5(db) {
+		var matches int64
+		db.Raw(`SELECT count(*) FROM tools_fts WHERE tools_fts MATCH 'text'`).Scan(&matches)
+		if !hasSQLiteSearchIndex(db) || matches != 2 {
+			t.Errorf("search index complete %v with %d tools in text-tools, want 2", hasSQLiteSearchIndex(db), matches)
+		}
+	}
+}
+
+func TestAnonymizeToolUsage(t *testing.T) {
+	SetLogger(logger.Discard)
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.AutoMigrate(&legacyToolUsage{}); err != nil {
+		t.Fatal(err)
+	}
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+	day := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if err := legacy.Create([]legacyToolUsage{
+		{ID: 1, ToolID: 1, IPAddress: "203.0.113.7", UserAgent: firefox, CreatedAt: day},
+		{ID: 2, ToolID: 2, IPAddress: "203.0.113.7", UserAgent: firefox, CreatedAt: day.Add(time.Hour)},
+		{ID: 3, ToolID: 1, IPAddress: "203.0.113.7", UserAgent: firefox, CreatedAt: day.AddDate(0, 0, 1)},
+		{ID: 4, ToolID: 1, IPAddress: "203.0.113.8", UserAgent: firefox, CreatedAt: day},
+		{ID: 5, ToolID: 1, IPAddress: "66.249.66.1", UserAgent: "Googlebot/2.1 (+http://www.google.com/bot.html)", CreatedAt: day},
+		{ID: 6, ToolID: 1, IPAddress: "203.0.113.9", CreatedAt: day},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := legacy.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	db := connectTest(t, dsn)
+	var usages []models.ToolUsage
+	if err := db.Order("id").Find(&usages).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) != 4 {
+		t.Fatalf("%d usage rows, want the 4 rows that are not bots", len(usages))
+	}
+	hashes := make(map[uint]string)
+	for _, usage := range usages {
+		if len(usage.VisitorHash) != 32 || usage.Browser != "Firefox" || usage.OS != "Linux" {
+			t.Errorf("usage %+v, want a visitor hash of Firefox on Linux", usage)
+		}
+		hashes[usage.ID] = usage.VisitorHash
+	}
+	if hashes[1] != hashes[2] {
+		t.Error("the same visitor on the same day got two identities")
+	}
+	if hashes[3] == hashes[1] {
+		t.Error("the same visitor on another day got the same identity")
+	}
+	if hashes[4] == hashes[1] {
+		t.Error("two visitors got the same identity")
+	}
+	for _, column := range []string{"ip_address", "user_agent"} {
+		if db.Migrator().HasColumn("tool_usages", column) {
+			t.Errorf("tool_usages.%s was not dropped", column)
+		}
+	}
+	// The salts were thrown away rather than kept for the day
+	var salts int64
+	if err := db.Model(&models.VisitorSalt{}).Count(&salts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if salts != 0 {
+		t.Errorf("%d visitor salts stored by the migration", salts)
+	}
+
+	// Connecting again leaves the anonymized rows alone
+	db = connectTest(t, dsn)
+	var again []models.ToolUsage
+	if err := db.Order("id").Find(&again).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != len(usages) || again[0].VisitorHash != hashes[1] {
+		t.Errorf("reconnecting changed the usage rows to %+v", again)
+	}
+}
+
+func TestMigrateLegacyAPIKeys(t *testing.T) {
+	SetLogger(logger.Discard)
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.AutoMigrate(&legacyAPIKey{}); err != nil {
+		t.Fatal(err)
+	}
+	deactivated := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	if err := legacy.Create([]legacyAPIKey{
+		{ID: 1, Key: "plain-active-key", Name: "deploy", Description: "CI deploys", IsActive: true},
+		{ID: 2, Key: "plain-inactive-key", Name: "old", IsActive: true, UpdatedAt: deactivated},
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// default:true turns a false IsActive into true on create
+	if err := legacy.Model(&legacyAPIKey{}).Where("id = 2").UpdateColumn("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := legacy.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	db := connectTest(t, dsn)
+	if db.Migrator().HasColumn(&models.APIKey{}, "is_active") {
+		t.Error("api_keys.is_active was not dropped")
+	}
+	var keys []models.APIKey
+	if err := db.Order("name").Find(&keys).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("%d API keys, want 2", len(keys))
+	}
+	plain := map[string]string{"deploy": "plain-active-key", "old": "plain-inactive-key"}
+	for _, key := range keys {
+		hash := utils.HashAPIKey(plain[key.Name])
+		if key.KeyHash != hash || key.Prefix != "legacy_"+hash[:8] {
+			t.Errorf("key %s with hash %s and prefix %s, want the hash of its plain key", key.Name, key.KeyHash, key.Prefix)
+		}
+		if !key.Scopes.Allows(models.ScopeAdmin) || key.CreatedBy != "migration" {
+			t.Errorf("key %s with scopes %v created by %s, want admin from the migration", key.Name, key.Scopes, key.CreatedBy)
+		}
+	}
+	if deploy := keys[0]; deploy.RevokedAt != nil || deploy.Description != "CI deploys" {
+		t.Errorf("active key %+v, want it kept with its description", deploy)
+	}
+	if old := keys[1]; old.RevokedAt == nil || !old.RevokedAt.Equal(deactivated) {
+		t.Errorf("inactive key revoked at %v, want %v", old.RevokedAt, deactivated)
+	}
+
+	// Connecting again leaves the migrated keys alone
+	db = connectTest(t, dsn)
+	var again []models.APIKey
+	if err := db.Order("name").Find(&again).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 2 || again[0].KeyHash != keys[0].KeyHash || again[0].ID != keys[0].ID {
+		t.Errorf("reconnecting changed the keys to %+v", again)
+	}
+}
+
+// connectTest connects to a SQLite file and closes it when the test ends
+func connectTest(t *testing.T, dsn string) *gorm.DB {
+	t.Helper()
+	db, err := Connect(sqlite.Open(dsn))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
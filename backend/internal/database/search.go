@@ -0,0 +1,181 @@
+package database
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Full-text search backends
+const (
+	SearchBackendPostgres = "postgres"
+	SearchBackendFTS5     = "fts5"
+	SearchBackendLike     = "like"
+)
+
+var searchBackend = SearchBackendLike
+
+// postgresSearchStatements maintain a weighted tsvector column on tools with a GIN
+// index. The category name lives in another table, so triggers keep it in sync.
+var postgresSearchStatements = []string{
+	`ALTER TABLE tools ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION tools_search_vector(tool_name text, tool_description text, tool_category_id bigint)
+	RETURNS tsvector AS $$
+		SELECT setweight(to_tsvector('simple', coalesce(tool_name, '')), 'A') ||
+			setweight(to_tsvector('simple', coalesce((SELECT name FROM categories WHERE id = tool_category_id), '')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(tool_description, '')), 'C')
+	$$ LANGUAGE sql STABLE`,
+	`CREATE OR REPLACE FUNCTION tools_search_vector_trigger() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector := tools_search_vector(NEW.name, NEW.description, NEW.category_id);
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS tools_search_vector_update ON tools`,
+	`CREATE TRIGGER tools_search_vector_update BEFORE INSERT OR UPDATE ON tools
+		FOR EACH ROW EXECUTE FUNCTION tools_search_vector_trigger()`,
+	`CREATE OR REPLACE FUNCTION categories_search_vector_trigger() RETURNS trigger AS $$
+	BEGIN
+		UPDATE tools SET search_vector = tools_search_vector(name, description, category_id)
+		WHERE category_id = NEW.id;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS categories_search_vector_update ON categories`,
+	`CREATE TRIGGER categories_search_vector_update AFTER UPDATE OF name ON categories
+		FOR EACH ROW EXECUTE FUNCTION categories_search_vector_trigger()`,
+	`CREATE INDEX IF NOT EXISTS idx_tools_search_vector ON tools USING GIN (search_vector)`,
+}
+
+// postgresBackfillStatement fills the search vector of existing tools once the
+// column is added; triggers keep it current afterwards
+const postgresBackfillStatement = `UPDATE tools SET search_vector = tools_search_vector(name, description, category_id)`
+
+// sqliteSearchObjects are the FTS5 table and triggers sqliteSearchStatements create
+var sqliteSearchObjects = []string{"tools_fts", "tools_fts_ai", "tools_fts_ad", "tools_fts_au", "categories_fts_au"}
+
+// sqliteSearchStatements create an FTS5 table keyed by tool ID and the triggers
+// that keep it in sync with tools and category names. The table keeps the category
+// ID, so the categories trigger does not read tools: SQLite rejects renaming a
+// table that a trigger on another table refers to, which the migrator does when
+// it rebuilds tools.
+var sqliteSearchStatements = []string{
+	`CREATE VIRTUAL TABLE tools_fts USING fts5(name, description, category, category_id UNINDEXED, tokenize='unicode61')`,
+	`CREATE TRIGGER tools_fts_ai AFTER INSERT ON tools BEGIN
+		INSERT INTO tools_fts(rowid, name, description, category, category_id)
+		VALUES (new.id, new.name, new.description,
+			coalesce((SELECT name FROM categories WHERE id = new.category_id), ''), new.category_id);
+	END`,
+	`CREATE TRIGGER tools_fts_ad AFTER DELETE ON tools BEGIN
+		DELETE FROM tools_fts WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER tools_fts_au AFTER UPDATE ON tools BEGIN
+		DELETE FROM tools_fts WHERE rowid = old.id;
+		INSERT INTO tools_fts(rowid, name, description, category, category_id)
+		VALUES (new.id, new.name, new.description,
+			coalesce((SELECT name FROM categories WHERE id = new.category_id), ''), new.category_id);
+	END`,
+	`CREATE TRIGGER categories_fts_au AFTER UPDATE OF name ON categories BEGIN
+		UPDATE tools_fts SET category = new.name WHERE category_id = new.id;
+	END`,
+	`INSERT INTO tools_fts(rowid, name, description, category, category_id)
+		SELECT tools.id, tools.name, tools.description, coalesce(categories.name, ''), tools.category_id
+		FROM tools LEFT JOIN categories ON categories.id = tools.category_id`,
+}
+
+// sqliteDropStatements remove the FTS5 table and its triggers
+var sqliteDropStatements = []string{
+	`DROP TRIGGER IF EXISTS tools_fts_ai`,
+	`DROP TRIGGER IF EXISTS tools_fts_ad`,
+	`DROP TRIGGER IF EXISTS tools_fts_au`,
+	`DROP TRIGGER IF EXISTS categories_fts_au`,
+	`DROP TABLE IF EXISTS tools_fts`,
+}
+
+// setupSearch prepares the full-text index for the current dialect. The index
+// is only filled when it is missing, for example on the first start or after a
+// migration dropped it; triggers keep it in sync afterwards.
+// SQLite builds without FTS5 (the sqlite_fts5 build tag) fall back to LIKE matching.
+func setupSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		exists := db.Migrator().HasColumn("tools", "search_vector")
+		if err := execAll(db, postgresSearchStatements); err != nil {
+			return err
+		}
+		if !exists {
+			if err := db.Exec(postgresBackfillStatement).Error; err != nil {
+				return err
+			}
+		}
+		searchBackend = SearchBackendPostgres
+	case "sqlite":
+		if !hasFTS5(db) {
+			if err := dropSearchIndex(db); err != nil {
+				return err
+			}
+			searchBackend = SearchBackendLike
+			return nil
+		}
+		if !hasSQLiteSearchIndex(db) {
+			// Remove what is left of a partial index before creating it again
+			if err := dropSearchIndex(db); err != nil {
+				return err
+			}
+			if err := execAll(db, sqliteSearchStatements); err != nil {
+				return err
+			}
+		}
+		searchBackend = SearchBackendFTS5
+	default:
+		searchBackend = SearchBackendLike
+	}
+	return nil
+}
+
+// dropSearchIndex removes the full-text index so the columns it reads can change
+func dropSearchIndex(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return db.Exec(`ALTER TABLE tools DROP COLUMN IF EXISTS search_vector`).Error
+	case "sqlite":
+		if err := execAll(db, sqliteDropStatements); err != nil && !isMissingFTS5(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSearchBackend returns the full-text search backend in use
+func GetSearchBackend() string {
+	return searchBackend
+}
+
+// hasFTS5 reports whether the SQLite library was compiled with FTS5
+func hasFTS5(db *gorm.DB) bool {
+	var enabled int
+	if err := db.Raw(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled).Error; err != nil {
+		return false
+	}
+	return enabled == 1
+}
+
+// hasSQLiteSearchIndex reports whether the FTS5 table and all its triggers exist
+func hasSQLiteSearchIndex(db *gorm.DB) bool {
+	var count int64
+	err := db.Raw(`SELECT count(*) FROM sqlite_master WHERE name IN ?`, sqliteSearchObjects).Scan(&count).Error
+	return err == nil && count == int64(len(sqliteSearchObjects))
+}
+
+func isMissingFTS5(err error) bool {
+	return strings.Contains(err.Error(), "no such module: fts5")
+}
+
+func execAll(db *gorm.DB, statements []string) error {
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
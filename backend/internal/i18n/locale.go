@@ -0,0 +1,105 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// localePattern matches BCP 47 style tags such as "en", "zh-TW" or "zh-Hant-HK"
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// Normalize canonicalizes a locale tag: "zh_tw" becomes "zh-TW", "zh-hant" becomes "zh-Hant".
+// It returns an empty string when the tag is not a valid locale.
+func Normalize(tag string) string {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if !localePattern.MatchString(tag) {
+		return ""
+	}
+
+	parts := strings.Split(tag, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		switch len(parts[i]) {
+		case 2:
+			parts[i] = strings.ToUpper(parts[i])
+		case 4:
+			parts[i] = strings.ToUpper(parts[i][:1]) + strings.ToLower(parts[i][1:])
+		default:
+			parts[i] = strings.ToLower(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header ordered by quality
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var entries []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := Normalize(fields[0])
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		entries = append(entries, weighted{tag: tag, quality: quality})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].quality > entries[j].quality
+	})
+
+	locales := make([]string, len(entries))
+	for i, entry := range entries {
+		locales[i] = entry.tag
+	}
+	return locales
+}
+
+// FallbackChain expands preferred locales into an ordered lookup chain.
+// Each locale is followed by its parents, and the default locale comes last:
+// ["zh-Hant-TW"] with default "en" becomes ["zh-Hant-TW", "zh-Hant", "zh", "en"].
+func FallbackChain(preferred []string, defaultLocale string) []string {
+	seen := make(map[string]bool)
+	var chain []string
+
+	add := func(tag string) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			chain = append(chain, tag)
+		}
+	}
+
+	for _, tag := range preferred {
+		tag = Normalize(tag)
+		for tag != "" {
+			add(tag)
+			idx := strings.LastIndex(tag, "-")
+			if idx < 0 {
+				break
+			}
+			tag = tag[:idx]
+		}
+	}
+	add(Normalize(defaultLocale))
+
+	return chain
+}
@@ -0,0 +1,69 @@
+package i18n
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"en":         "en",
+		"EN":         "en",
+		"zh_tw":      "zh-TW",
+		"zh-hant":    "zh-Hant",
+		"zh-hant-hk": "zh-Hant-HK",
+		" pt-br ":    "pt-BR",
+		"es-419":     "es-419",
+		"":           "",
+		"*":          "",
+		"e":          "",
+		"en-":        "",
+		"../en":      "",
+	}
+	for tag, want := range tests {
+		if got := Normalize(tag); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"fr", []string{"fr"}},
+		{"fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de"}},
+		// Ordered by quality, keeping the header order for equal qualities
+		{"en;q=0.5, zh-tw, ja;q=0.8, zh", []string{"zh-TW", "zh", "ja", "en"}},
+		// Rejected languages and malformed qualities
+		{"de;q=0, en;q=abc", []string{"en"}},
+		{"en ; q=0.3 , it", []string{"it", "en"}},
+	}
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackChain(t *testing.T) {
+	tests := []struct {
+		preferred     []string
+		defaultLocale string
+		want          []string
+	}{
+		{nil, "en", []string{"en"}},
+		{[]string{"zh-Hant-TW"}, "en", []string{"zh-Hant-TW", "zh-Hant", "zh", "en"}},
+		{[]string{"fr-CA", "en-GB"}, "en", []string{"fr-CA", "fr", "en-GB", "en"}},
+		// Duplicates and invalid tags are dropped
+		{[]string{"de", "de-AT", "not a tag"}, "de", []string{"de", "de-AT"}},
+		{[]string{"zh_cn"}, "", []string{"zh-CN", "zh"}},
+	}
+	for _, tt := range tests {
+		if got := FallbackChain(tt.preferred, tt.defaultLocale); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("FallbackChain(%q, %q) = %v, want %v", tt.preferred, tt.defaultLocale, got, tt.want)
+		}
+	}
+}
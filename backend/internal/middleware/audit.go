@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID of a request, taken from the client when it
+// sends a usable one and returned in the response
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the context key holding the ID of the request
+const RequestIDKey = "request_id"
+
+// ProjectKey is the context key holding the workspace project a request acts on
+const ProjectKey = "project"
+
+// requestIDPattern limits client request IDs to what is safe to store and log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestIDMiddleware gives every request an ID and a logger that adds the
+// ID to every entry. The logger travels in the request context, so services
+// called with c.Request.Context() log with the same ID.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// Logger returns the logger of the request, which adds its request ID
+func Logger(c *gin.Context) *logrus.Entry {
+	return logging.FromContext(c.Request.Context())
+}
+
+// GetRequestID returns the ID of the request, empty without RequestIDMiddleware
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// AuditMiddleware writes an audit log entry for every request that may change
+// something, whether it succeeded, failed or was denied. It must come before
+// AuthMiddleware so that rejected requests are recorded too.
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		RecordAudit(c, &models.AuditLog{
+			Action:     models.AuditRequest,
+			TargetType: "route",
+			TargetID:   route,
+			Outcome:    auditOutcome(c.Writer.Status()),
+			Details: models.AuditDetails{
+				"method":      c.Request.Method,
+				"path":        c.Request.URL.Path,
+				"status":      c.Writer.Status(),
+				"duration_ms": time.Since(start).Milliseconds(),
+			},
+		})
+	}
+}
+
+// RecordAudit writes an audit log entry for the request, filling in who made
+// it, the request ID, the client IP and the project it acts on. Failures are
+// logged, they do not change the response.
+func RecordAudit(c *gin.Context, entry *models.AuditLog) {
+	if entry.Actor == "" {
+		entry.Actor = GetActor(c)
+	}
+	if entry.Project == "" {
+		entry.Project = c.GetString(ProjectKey)
+	}
+	entry.RequestID = GetRequestID(c)
+	entry.IP = c.ClientIP()
+
+	if err := auditService.Record(entry); err != nil {
+		Logger(c).Errorf("Failed to write audit log entry %s: %v", entry.Action, err)
+	}
+}
+
+// AuditOutcome returns the audit outcome of an operation
+func AuditOutcome(err error) string {
+	if err != nil {
+		return models.AuditOutcomeFailure
+	}
+	return models.AuditOutcomeSuccess
+}
+
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return models.AuditOutcomeDenied
+	case status >= http.StatusBadRequest:
+		return models.AuditOutcomeFailure
+	}
+	return models.AuditOutcomeSuccess
+}
@@ -1,66 +1,430 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
-	"tion-backend/internal/config"
-	"tion-backend/internal/response"
+	"strconv"
+	"strings"
+	"time"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/i18n"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/ratelimit"
+	"tion.work/backend/internal/response"
+	"tion.work/backend/internal/services"
+	"tion.work/backend/pkg/metrics"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	apiKeyService        *services.APIKeyService
+	userService          *services.UserService
+	projectAccessService *services.ProjectAccessService
+	auditService         *services.AuditService
 )
 
 // InitMiddleware initializes middleware
 func InitMiddleware() {
-	// Initialize any middleware dependencies here
+	apiKeyService = services.NewAPIKeyService()
+	userService = services.NewUserService(config.AppConfig.SessionTTL)
+	projectAccessService = services.NewProjectAccessService()
+	auditService = services.NewAuditService()
 }
 
-// APIKeyMiddleware validates API key
-func APIKeyMiddleware() gin.HandlerFunc {
+// SessionCookie is the name of the cookie holding the session token
+const SessionCookie = "tion_session"
+
+// AuthMiddleware authenticates the request and records who made it and which
+// scopes they hold. It accepts an API key in the X-API-Key header, or a
+// session token as a bearer token or in the session cookie. The API_KEY
+// configured for bootstrapping, when set, is accepted with the admin scope.
+func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		apiKey := c.GetHeader("X-API-Key")
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
+		if token := SessionToken(c); token != "" {
+			authenticateSession(c, token)
+			return
+		}
+
+		response.Unauthorized(c, "Authentication required, sign in or send an API key")
+		c.Abort()
+	}
+}
+
+// authenticateAPIKey checks the bootstrap key and the stored API keys
+func authenticateAPIKey(c *gin.Context, apiKey string) {
+	if bootstrap := config.AppConfig.APIKey; bootstrap != "" &&
+		subtle.ConstantTimeCompare([]byte(apiKey), []byte(bootstrap)) == 1 {
+		c.Set(ActorKey, apiKeyActor(apiKey))
+		c.Set(ScopesKey, models.ScopeList{models.ScopeAdmin})
+		c.Next()
+		return
+	}
+
+	key, err := apiKeyService.Authenticate(apiKey, c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAPIKeyInvalid),
+			errors.Is(err, services.ErrAPIKeyExpired),
+			errors.Is(err, services.ErrAPIKeyRevoked):
+			response.Unauthorized(c, err.Error())
+		default:
+			Logger(c).Errorf("API key authentication failed: %v", err)
+			response.InternalError(c, "Internal server error")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set(ActorKey, "api-key:"+key.Prefix)
+	c.Set(ScopesKey, key.Scopes)
+	c.Set(APIKeyIDKey, key.ID)
+	c.Next()
+}
+
+// authenticateSession grants the scopes of the role of the signed-in user
+func authenticateSession(c *gin.Context, token string) {
+	user, err := userService.Authenticate(token, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, services.ErrSessionInvalid) {
+			ClearSessionCookie(c)
+			response.Unauthorized(c, err.Error())
+		} else {
+			Logger(c).Errorf("Session authentication failed: %v", err)
+			response.InternalError(c, "Internal server error")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set(ActorKey, services.UserActor(user))
+	c.Set(ScopesKey, models.RoleScopes[user.Role])
+	c.Set(UserIDKey, user.ID)
+	c.Next()
+}
+
+// SessionToken returns the session token of the request, from a bearer
+// Authorization header or the session cookie
+func SessionToken(c *gin.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if token, err := c.Cookie(SessionCookie); err == nil {
+		return token
+	}
+	return ""
+}
+
+// SetSessionCookie stores a session token in an HTTP-only cookie that is not
+// sent with cross-site requests
+func SetSessionCookie(c *gin.Context, token string, expiresAt time.Time) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		Secure:   config.AppConfig.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
 
-		if apiKey == "" {
-			response.Unauthorized(c, "API key is required")
+// ClearSessionCookie removes the session cookie
+func ClearSessionCookie(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   config.AppConfig.SessionCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// RequireScope rejects requests whose API key or user role does not grant the
+// scope. It must follow AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScope(c, scope) {
+			response.Forbidden(c, "Missing the "+scope+" permission")
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		// Check if API key matches configured key
-		if config.AppConfig.APIKey != "" && apiKey != config.AppConfig.APIKey {
-			response.Unauthorized(c, "Invalid API key")
+// HasScope reports whether the API key or user role of the request grants
+// the scope
+func HasScope(c *gin.Context, scope string) bool {
+	scopes, _ := c.Get(ScopesKey)
+	list, ok := scopes.(models.ScopeList)
+	return ok && list.Allows(scope)
+}
+
+// AuthorizeProject checks that the caller holds right on a workspace project,
+// answering 403 when it does not, and records the project for the audit log.
+// It must follow AuthMiddleware.
+func AuthorizeProject(c *gin.Context, project, right string) bool {
+	c.Set(ProjectKey, project)
+	rights, err := ProjectRights(c, []string{project})
+	if err != nil {
+		Logger(c).Errorf("Project authorization failed: %v", err)
+		response.InternalError(c, "Internal server error")
+		c.Abort()
+		return false
+	}
+	if !rights[project].Has(right) {
+		response.Forbidden(c, fmt.Sprintf("Missing the %s right on project %s", right, project))
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// ProjectRights gets the rights the caller holds on each of the projects.
+// Holders of the admin scope have every right on every project; other
+// callers only have the rights granted to their user or stored API key.
+func ProjectRights(c *gin.Context, projects []string) (map[string]models.ProjectRightList, error) {
+	rights := make(map[string]models.ProjectRightList, len(projects))
+	scopes, _ := c.Get(ScopesKey)
+	if list, ok := scopes.(models.ScopeList); ok && list.Allows(models.ScopeAdmin) {
+		for _, project := range projects {
+			rights[project] = models.ProjectRights
+		}
+		return rights, nil
+	}
+
+	var subject services.ProjectSubject
+	if id := c.GetUint(UserIDKey); id != 0 {
+		subject = services.ProjectSubject{Type: models.GrantSubjectUser, ID: id}
+	} else if id := c.GetUint(APIKeyIDKey); id != 0 {
+		subject = services.ProjectSubject{Type: models.GrantSubjectAPIKey, ID: id}
+	} else {
+		return rights, nil
+	}
+
+	granted, err := projectAccessService.RightsByProject(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		list := append(models.ProjectRightList{}, granted[project]...)
+		if list = append(list, granted[models.AllProjects]...); len(list) > 0 {
+			rights[project] = list
+		}
+	}
+	return rights, nil
+}
+
+// ScopesKey is the context key holding the scopes of the authenticated API key or user
+const ScopesKey = "scopes"
+
+// UserIDKey is the context key holding the ID of the signed-in user
+const UserIDKey = "user_id"
+
+// APIKeyIDKey is the context key holding the ID of the authenticated stored API key
+const APIKeyIDKey = "api_key_id"
+
+// ActorKey is the context key identifying who made an authenticated request
+const ActorKey = "actor"
+
+// GetActor returns who made the request, as recorded in revisions
+func GetActor(c *gin.Context) string {
+	if actor := c.GetString(ActorKey); actor != "" {
+		return actor
+	}
+	return "anonymous"
+}
+
+// apiKeyActor identifies the bootstrap API key by a short fingerprint so that
+// the key itself is never stored
+func apiKeyActor(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "api-key:" + hex.EncodeToString(sum[:6])
+}
+
+// LocalesKey is the context key holding the negotiated locale fallback chain
+const LocalesKey = "locales"
+
+// LocaleMiddleware negotiates the response locale from the ?lang= parameter
+// or the Accept-Language header and stores the fallback chain in the context
+func LocaleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var preferred []string
+		if lang := c.Query("lang"); lang != "" {
+			preferred = strings.Split(lang, ",")
+		} else {
+			preferred = i18n.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+		}
+
+		c.Set(LocalesKey, i18n.FallbackChain(preferred, config.AppConfig.DefaultLocale))
+		c.Header("Vary", "Accept-Language")
+
+		c.Next()
+	}
+}
+
+// GetLocales returns the negotiated locale fallback chain for the request
+func GetLocales(c *gin.Context) []string {
+	if locales, ok := c.Get(LocalesKey); ok {
+		return locales.([]string)
+	}
+	return i18n.FallbackChain(nil, config.AppConfig.DefaultLocale)
+}
+
+// RateLimitMiddleware limits the requests of each client to a route group.
+// Clients are the signed-in user or API key when it follows AuthMiddleware,
+// and the client IP otherwise. Every response carries the RateLimit-* headers,
+// and rejected requests are answered with 429 and Retry-After. A nil limiter
+// disables limiting. When the store fails the request is let through.
+func RateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := c.GetString(ActorKey)
+		if key == "" {
+			key = "ip:" + c.ClientIP()
+		}
+		result, err := limiter.Allow(key)
+		if err != nil {
+			Logger(c).Errorf("Rate limit %s failed, allowing the request: %v", limiter.Name(), err)
+			c.Next()
+			return
+		}
+
+		limit := limiter.Limit()
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Rate, ceilSeconds(limit.Period)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			response.Error(c, http.StatusTooManyRequests, "Too many requests, retry later")
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
+
+// ceilSeconds rounds a duration up to whole seconds, as rate limit headers use
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// RequestRecorder counts finished requests
+type RequestRecorder interface {
+	Record(status int, latency time.Duration)
+}
+
+// RequestMetricsMiddleware records the status and latency of every request.
+// Event streams are left out, their latency is the length of the connection.
+func RequestMetricsMiddleware(recorder RequestRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		recorder.Record(c.Writer.Status(), time.Since(start))
+	}
+}
 
+var (
+	httpRequests = metrics.Default.NewCounterVec("http_requests_total",
+		"HTTP requests by method, route and status.", "method", "route", "status")
+	httpRequestDuration = metrics.Default.NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests by method and route, without event streams.", nil, "method", "route")
+)
+
+// MetricsMiddleware counts every request and observes its latency in the
+// metrics registry. Requests are labelled with their route pattern, so that
+// path parameters do not create new series; unknown paths share the
+// "unmatched" route. Event streams are counted but their latency is left out.
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
 		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.Inc(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		if strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		httpRequestDuration.Observe(time.Since(start).Seconds(), c.Request.Method, route)
 	}
 }
 
-// RateLimitMiddleware implements rate limiting
-func RateLimitMiddleware() gin.HandlerFunc {
+// MetricsAuthMiddleware requires METRICS_TOKEN as a bearer token when it is
+// set, so that scrapers do not need an API key. /metrics is public otherwise.
+func MetricsAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Implement rate limiting logic here
-		// For now, just pass through
+		token := config.AppConfig.MetricsToken
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			response.Unauthorized(c, "Metrics require the metrics token")
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
 
-// LoggingMiddleware logs requests
+// LoggingMiddleware logs every request with the logger of the request, so the
+// entry carries its request ID. It must follow RequestIDMiddleware. The query
+// string is left out, it may hold tokens.
 func LoggingMiddleware() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		// Custom log format
-		return fmt.Sprintf("%s - [%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.ClientIP,
-			param.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ErrorMessage,
-		)
-	})
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		entry := Logger(c).WithFields(logrus.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency_ms": time.Since(start).Milliseconds(),
+			"ip":         c.ClientIP(),
+			"actor":      GetActor(c),
+			"user_agent": c.Request.UserAgent(),
+		})
+		if len(c.Errors) > 0 {
+			entry = entry.WithField("error", c.Errors.String())
+		}
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request failed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request rejected")
+		default:
+			entry.Info("Request served")
+		}
+	}
 }
 
 // CORSMiddleware handles CORS
@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/config"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm/logger"
+)
+
+const testBootstrapKey = "bootstrap-key"
+
+// setupAuth migrates a fresh database and initializes the services the
+// middleware authenticates with
+func setupAuth(t *testing.T) {
+	t.Helper()
+	database.SetLogger(logger.Discard)
+	db, err := database.Connect(sqlite.Open(filepath.Join(t.TempDir(), "test.db")))
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	database.DB = db
+
+	config.AppConfig = &config.Config{APIKey: testBootstrapKey, SessionTTL: time.Hour}
+	InitMiddleware()
+}
+
+// newAuthRouter serves GET /stats behind AuthMiddleware and the stats:read scope
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/stats", AuthMiddleware(), RequireScope(models.ScopeStatsRead), func(c *gin.Context) {
+		c.String(http.StatusOK, GetActor(c))
+	})
+	return router
+}
+
+func TestLocaleMiddleware(t *testing.T) {
+	config.AppConfig = &config.Config{DefaultLocale: "en"}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/tools", LocaleMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Join(GetLocales(c), ","))
+	})
+
+	tests := []struct {
+		name, target, acceptLanguage string
+		want                         string
+	}{
+		{"default", "/tools", "", "en"},
+		{"header", "/tools", "zh-TW,zh;q=0.9,en;q=0.5", "zh-TW,zh,en"},
+		{"parameter wins over the header", "/tools?lang=ja", "zh-TW", "ja,en"},
+		{"parameter list", "/tools?lang=pt_br,es", "", "pt-BR,pt,es,en"},
+		{"invalid parameter", "/tools?lang=%2A", "fr", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Body.String(); got != tt.want {
+				t.Errorf("locales %q, want %q", got, tt.want)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept-Language" {
+				t.Errorf("Vary %q, want Accept-Language", vary)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	setupAuth(t)
+	router := newAuthRouter()
+
+	keys := services.NewAPIKeyService()
+	create := func(name string, scopes ...string) (*models.APIKey, string) {
+		t.Helper()
+		created, err := keys.CreateAPIKey(services.APIKeyInput{Name: name, Scopes: scopes}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &created.APIKey.APIKey, created.Key
+	}
+	statsKey, statsPlain := create("stats", models.ScopeStatsRead)
+	_, toolsPlain := create("tools", models.ScopeToolsWrite)
+	_, adminPlain := create("admin", models.ScopeAdmin)
+	revokedKey, revokedPlain := create("revoked", models.ScopeAdmin)
+	if _, err := keys.RevokeAPIKey(revokedKey.ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	rotatedKey, rotatedPlain := create("rotated", models.ScopeStatsRead)
+	replacement, err := keys.RotateAPIKey(rotatedKey.ID, time.Hour, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredKey, expiredPlain := create("expired", models.ScopeAdmin)
+	if err := database.DB.Model(expiredKey).Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	users := services.NewUserService(time.Hour)
+	if _, err := users.CreateUser(services.UserInput{Username: "viewer", Password: "correct horse", Role: models.RoleViewer}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	login, err := users.Login("viewer", "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		bearer       string
+		cookie       string
+		status       int
+		actor        string
+		clearsCookie bool
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "bootstrap key", apiKey: testBootstrapKey, status: http.StatusOK},
+		{name: "unknown key", apiKey: "tion_unknown", status: http.StatusUnauthorized},
+		{name: "scoped key", apiKey: statsPlain, status: http.StatusOK, actor: "api-key:" + statsKey.Prefix},
+		{name: "admin key", apiKey: adminPlain, status: http.StatusOK},
+		{name: "key without the scope", apiKey: toolsPlain, status: http.StatusForbidden},
+		{name: "revoked key", apiKey: revokedPlain, status: http.StatusUnauthorized},
+		{name: "expired key", apiKey: expiredPlain, status: http.StatusUnauthorized},
+		{name: "rotated key in its grace period", apiKey: rotatedPlain, status: http.StatusOK, actor: "api-key:" + rotatedKey.Prefix},
+		{name: "replacement key", apiKey: replacement.Key, status: http.StatusOK, actor: "api-key:" + replacement.APIKey.Prefix},
+		{name: "bearer session", bearer: login.Token, status: http.StatusOK, actor: "user:viewer"},
+		{name: "cookie session", cookie: login.Token, status: http.StatusOK, actor: "user:viewer"},
+		{name: "invalid session", cookie: "expired", status: http.StatusUnauthorized, clearsCookie: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.actor != "" && w.Body.String() != tt.actor {
+				t.Errorf("actor %q, want %q", w.Body.String(), tt.actor)
+			}
+			if tt.clearsCookie {
+				cookies := w.Result().Cookies()
+				if len(cookies) != 1 || cookies[0].Name != SessionCookie || cookies[0].MaxAge >= 0 {
+					t.Errorf("cookies %v, want the session cookie cleared", cookies)
+				}
+			}
+		})
+	}
+
+	// Without a bootstrap key configured, the old value is just an unknown key
+	config.AppConfig.APIKey = ""
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("X-API-Key", testBootstrapKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bootstrap key without API_KEY: status %d, want 401", w.Code)
+	}
+}
+
+func TestAuthorizeProject(t *testing.T) {
+	setupAuth(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/projects/:project/:right", AuthMiddleware(), func(c *gin.Context) {
+		if AuthorizeProject(c, c.Param("project"), c.Param("right")) {
+			c.String(http.StatusOK, "ok")
+		}
+	})
+
+	users := services.NewUserService(time.Hour)
+	user, err := users.CreateUser(services.UserInput{Username: "dev", Password: "correct horse", Role: models.RoleDeveloper}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login, err := users.Login("dev", "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := services.NewAPIKeyService()
+	deployKey, err := keys.CreateAPIKey(services.APIKeyInput{Name: "deploy", Scopes: []string{models.ScopeDeploy}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKey, err := keys.CreateAPIKey(services.APIKeyInput{Name: "admin", Scopes: []string{models.ScopeAdmin}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	access := services.NewProjectAccessService()
+	for _, grant := range []struct {
+		project string
+		input   services.ProjectGrantInput
+	}{
+		{"site", services.ProjectGrantInput{SubjectType: models.GrantSubjectUser, SubjectID: user.ID, Rights: []string{models.ProjectRightCommit}}},
+		{models.AllProjects, services.ProjectGrantInput{SubjectType: models.GrantSubjectAPIKey, SubjectID: deployKey.APIKey.ID, Rights: []string{models.ProjectRightDeploy}}},
+	} {
+		if _, err := access.SetGrant(grant.project, grant.input, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		bearer string
+		target string
+		status int
+	}{
+		{name: "granted right", bearer: login.Token, target: "/projects/site/commit", status: http.StatusOK},
+		{name: "read comes with any right", bearer: login.Token, target: "/projects/site/read", status: http.StatusOK},
+		{name: "right not granted", bearer: login.Token, target: "/projects/site/push", status: http.StatusForbidden},
+		{name: "ungranted project", bearer: login.Token, target: "/projects/blog/read", status: http.StatusForbidden},
+		{name: "grant on every project", apiKey: deployKey.Key, target: "/projects/blog/deploy", status: http.StatusOK},
+		{name: "key right not granted", apiKey: deployKey.Key, target: "/projects/blog/commit", status: http.StatusForbidden},
+		{name: "admin key", apiKey: adminKey.Key, target: "/projects/blog/push", status: http.StatusOK},
+		{name: "bootstrap key", apiKey: testBootstrapKey, target: "/projects/blog/deploy", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,6 +1,13 @@
 package models
 
 import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,36 +15,647 @@ import (
 
 // Tool represents a tool in the system
 type Tool struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	CategoryID  *uint          `json:"category_id" gorm:"index"`
+	Category    *Category      `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	Tags        []Tag          `json:"tags,omitempty" gorm:"many2many:tool_tags"`
+	Icon        string         `json:"icon"`
+	URL         string         `json:"url"`
+	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// LinkFailures counts consecutive failed link checks, LinkFlagged is set once
+	// it reaches the failure threshold and cleared by the next successful check
+	LinkFailures int  `json:"link_failures" gorm:"default:0"`
+	LinkFlagged  bool `json:"link_flagged" gorm:"default:false;index"`
+
+	// Locale is the locale the text fields were resolved in, set when localizing responses
+	Locale       string            `json:"locale,omitempty" gorm:"-"`
+	Translations []ToolTranslation `json:"translations,omitempty" gorm:"foreignKey:ToolID;constraint:OnDelete:CASCADE"`
+}
+
+// ToolTranslation holds the localized text of a tool for one locale
+type ToolTranslation struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
+	ToolID      uint      `json:"tool_id" gorm:"not null;uniqueIndex:idx_tool_translations_tool_locale"`
+	Locale      string    `json:"locale" gorm:"size:35;not null;uniqueIndex:idx_tool_translations_tool_locale"`
 	Name        string    `json:"name" gorm:"not null"`
 	Description string    `json:"description"`
-	Category    string    `json:"category"`
-	Icon        string    `json:"icon"`
-	URL         string    `json:"url"`
-	IsActive    bool      `json:"is_active" gorm:"default:true"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
-// ToolUsage represents usage statistics for tools
-type ToolUsage struct {
+// Category groups tools and may be nested under a parent category
+type Category struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Slug      string    `json:"slug" gorm:"size:100;uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"not null"`
+	Icon      string    `json:"icon"`
+	SortOrder int       `json:"sort_order" gorm:"default:0"`
+	ParentID  *uint     `json:"parent_id" gorm:"index"`
+	Parent    *Category `json:"-" gorm:"foreignKey:ParentID"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Locale is the locale the name was resolved in, set when localizing responses
+	Locale       string                `json:"locale,omitempty" gorm:"-"`
+	Translations []CategoryTranslation `json:"translations,omitempty" gorm:"foreignKey:CategoryID;constraint:OnDelete:CASCADE"`
+}
+
+// CategoryTranslation holds the localized name of a category for one locale
+type CategoryTranslation struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CategoryID uint      `json:"category_id" gorm:"not null;uniqueIndex:idx_category_translations_category_locale"`
+	Locale     string    `json:"locale" gorm:"size:35;not null;uniqueIndex:idx_category_translations_category_locale"`
+	Name       string    `json:"name" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Tag is a free-form label that can be attached to many tools
+type Tag struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
-	ToolID    uint      `json:"tool_id" gorm:"not null"`
-	Tool      Tool      `json:"tool" gorm:"foreignKey:ToolID"`
-	IPAddress string    `json:"ip_address"`
-	UserAgent string    `json:"user_agent"`
+	Slug      string    `json:"slug" gorm:"size:100;uniqueIndex;not null"`
+	Name      string    `json:"name" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// APIKey represents API keys for authentication
-type APIKey struct {
+// ToolLinkCheck is the result of probing a tool URL
+type ToolLinkCheck struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	ToolID       uint       `json:"tool_id" gorm:"not null;index"`
+	URL          string     `json:"url"`
+	FinalURL     string     `json:"final_url"`
+	Method       string     `json:"method"`
+	StatusCode   int        `json:"status_code"`
+	OK           bool       `json:"ok"`
+	Error        string     `json:"error,omitempty"`
+	LatencyMs    int64      `json:"latency_ms"`
+	Redirects    int        `json:"redirects"`
+	TLSExpiresAt *time.Time `json:"tls_expires_at,omitempty"`
+	CheckedAt    time.Time  `json:"checked_at" gorm:"index"`
+}
+
+// ErrRevisionImmutable is returned when trying to change or delete a tool revision
+var ErrRevisionImmutable = errors.New("tool revisions are immutable")
+
+// Tool revision actions
+const (
+	ToolRevisionBaseline = "baseline"
+	ToolRevisionCreate   = "create"
+	ToolRevisionUpdate   = "update"
+	ToolRevisionDelete   = "delete"
+	ToolRevisionRestore  = "restore"
+)
+
+// ToolRevision is an immutable snapshot of a tool written on every create,
+// update, delete and restore
+type ToolRevision struct {
+	ID           uint         `json:"id" gorm:"primaryKey"`
+	ToolID       uint         `json:"tool_id" gorm:"not null;uniqueIndex:idx_tool_revisions_tool_revision"`
+	Revision     int          `json:"revision" gorm:"not null;uniqueIndex:idx_tool_revisions_tool_revision"`
+	Action       string       `json:"action" gorm:"size:20;not null"`
+	Actor        string       `json:"actor" gorm:"size:100"`
+	RestoredFrom *int         `json:"restored_from,omitempty"`
+	Snapshot     ToolSnapshot `json:"snapshot" gorm:"type:text;not null"`
+	CreatedAt    time.Time    `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate keeps revisions immutable
+func (r *ToolRevision) BeforeUpdate(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
+
+// BeforeDelete keeps revisions immutable
+func (r *ToolRevision) BeforeDelete(tx *gorm.DB) error {
+	return ErrRevisionImmutable
+}
+
+// ToolSnapshot is the editable state of a tool, stored as JSON
+type ToolSnapshot struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CategoryID  *uint  `json:"category_id"`
+	Icon        string `json:"icon"`
+	URL         string `json:"url"`
+	IsActive    bool   `json:"is_active"`
+	TagIDs      []uint `json:"tag_ids"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// NewToolSnapshot captures a tool with its tags loaded
+func NewToolSnapshot(tool *Tool) ToolSnapshot {
+	tagIDs := make([]uint, 0, len(tool.Tags))
+	for _, tag := range tool.Tags {
+		tagIDs = append(tagIDs, tag.ID)
+	}
+	return ToolSnapshot{
+		Name:        tool.Name,
+		Description: tool.Description,
+		CategoryID:  tool.CategoryID,
+		Icon:        tool.Icon,
+		URL:         tool.URL,
+		IsActive:    tool.IsActive,
+		TagIDs:      tagIDs,
+		Deleted:     tool.DeletedAt.Valid,
+	}
+}
+
+// Value implements driver.Valuer
+func (s ToolSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (s *ToolSnapshot) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case []byte:
+		return json.Unmarshal(v, s)
+	}
+	return fmt.Errorf("cannot scan %T into ToolSnapshot", value)
+}
+
+// ToolUsage records one use of a tool. The visitor is identified by a salted
+// hash of their IP address and user agent that changes every day, so no
+// personal data is stored.
+type ToolUsage struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
-	Key         string    `json:"key" gorm:"uniqueIndex;not null"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ToolID      uint      `json:"tool_id" gorm:"not null;index"`
+	Tool        Tool      `json:"tool" gorm:"foreignKey:ToolID"`
+	VisitorHash string    `json:"visitor_hash" gorm:"size:32;index"`
+	Browser     string    `json:"browser" gorm:"size:50"`
+	OS          string    `json:"os" gorm:"size:50"`
+	DeviceClass string    `json:"device_class" gorm:"size:20"`
+	Source      string    `json:"source" gorm:"size:255;index"`       // page the click came from, e.g. "/category/defi"
+	SearchQuery string    `json:"search_query" gorm:"size:100;index"` // normalized query when clicked from search results
+	Locale      string    `json:"locale" gorm:"size:35"`              // UI locale of the visitor
+	Position    int       `json:"position" gorm:"not null;default:0"` // 1-based card position, 0 if unknown
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// VisitorSalt is the secret salt of the visitor hashes of one UTC day. Salts of
+// past days are deleted, which makes their hashes anonymous.
+type VisitorSalt struct {
+	Day       string `gorm:"primaryKey;size:10"` // YYYY-MM-DD
+	Salt      string `gorm:"not null"`           // hex encoded
+	CreatedAt time.Time
+}
+
+// ToolUsageHourly is the number of uses of a tool in the UTC hour starting at Hour
+type ToolUsageHourly struct {
+	ToolID uint      `json:"tool_id" gorm:"primaryKey;autoIncrement:false"`
+	Hour   time.Time `json:"hour" gorm:"primaryKey;index"`
+	Count  int64     `json:"count" gorm:"not null"`
+}
+
+func (ToolUsageHourly) TableName() string {
+	return "tool_usage_hourly"
+}
+
+// ToolUsageDaily is the number of uses and unique visitors of a tool in the UTC
+// day starting at Day
+type ToolUsageDaily struct {
+	ToolID   uint      `json:"tool_id" gorm:"primaryKey;autoIncrement:false"`
+	Day      time.Time `json:"day" gorm:"primaryKey;index"`
+	Count    int64     `json:"count" gorm:"not null"`
+	Visitors int64     `json:"visitors" gorm:"not null;default:0"`
+}
+
+func (ToolUsageDaily) TableName() string {
+	return "tool_usage_daily"
+}
+
+// VisitorsDaily is the number of unique visitors across all tools in the UTC day starting at Day
+type VisitorsDaily struct {
+	Day      time.Time `json:"day" gorm:"primaryKey"`
+	Visitors int64     `json:"visitors" gorm:"not null"`
+}
+
+func (VisitorsDaily) TableName() string {
+	return "visitors_daily"
+}
+
+// Search event sources
+const (
+	SearchSourceAPI    = "api"    // searched through GET /api/tools/search
+	SearchSourceClient = "client" // searched in the frontend and reported to the backend
+)
+
+// SearchEvent is a single search, recorded under the same anonymous visitor
+// identity as tool usage so that searches can be matched with the clicks they led to
+type SearchEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Query       string    `json:"query" gorm:"size:100;not null;index"` // normalized
+	Locale      string    `json:"locale" gorm:"size:35;index"`
+	Results     int       `json:"results" gorm:"not null"`
+	Source      string    `json:"source" gorm:"size:20;not null"`
+	VisitorHash string    `json:"visitor_hash" gorm:"size:32"`
+	CreatedAt   time.Time `json:"created_at" gorm:"index"`
+}
+
+// RollupWatermark records up to which time raw rows have been aggregated into a rollup
+type RollupWatermark struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:50"`
+	Watermark time.Time `json:"watermark" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Permission scopes, granted to API keys directly and to users through their role
+const (
+	ScopeToolsRead     = "tools:read"     // admin views of tools, revisions, translations, links and the catalog
+	ScopeToolsWrite    = "tools:write"    // edit tools, categories, tags and translations; implies tools:read
+	ScopeStatsRead     = "stats:read"     // usage, search and alert reports, and the dev server monitor
+	ScopeProjectsRead  = "projects:read"  // list projects, their status and logs on the dev server
+	ScopeProjectsWrite = "projects:write" // run the AI assistant, commands, installs and builds; implies projects:read
+	ScopeGitRead       = "git:read"       // branches and diffs of projects
+	ScopeGitWrite      = "git:write"      // commit, push, branch and reset; implies git:read
+	ScopeDeploy        = "deploy"         // deploy to Netlify and read deploy status
+	ScopeAdmin         = "admin"          // everything, including maintenance jobs and user management
+)
+
+// Scopes lists every permission scope
+var Scopes = []string{
+	ScopeToolsRead, ScopeToolsWrite, ScopeStatsRead,
+	ScopeProjectsRead, ScopeProjectsWrite, ScopeGitRead, ScopeGitWrite, ScopeDeploy,
+	ScopeAdmin,
+}
+
+// impliedScopes maps write scopes to the read scope they include
+var impliedScopes = map[string]string{
+	ScopeToolsWrite:    ScopeToolsRead,
+	ScopeProjectsWrite: ScopeProjectsRead,
+	ScopeGitWrite:      ScopeGitRead,
+}
+
+// ScopeList is a set of permission scopes, stored space separated
+type ScopeList []string
+
+// Allows reports whether the scopes grant scope, directly or through a wider scope
+func (l ScopeList) Allows(scope string) bool {
+	for _, granted := range l {
+		if granted == scope || granted == ScopeAdmin || impliedScopes[granted] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer
+func (l ScopeList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+// Scan implements sql.Scanner
+func (l *ScopeList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*l = strings.Fields(v)
+	case []byte:
+		*l = strings.Fields(string(v))
+	case nil:
+		*l = nil
+	default:
+		return fmt.Errorf("cannot scan %T into ScopeList", value)
+	}
+	return nil
+}
+
+// APIKey is a server-generated API key. Only its hash is stored; the prefix
+// identifies it in listings.
+type APIKey struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:100;not null"`
+	Description string         `json:"description"`
+	Prefix      string         `json:"prefix" gorm:"size:20;not null;index"`
+	KeyHash     string         `json:"-" gorm:"size:64;not null;uniqueIndex"` // hex SHA-256 of the key
+	Scopes      ScopeList      `json:"scopes" gorm:"type:text;not null"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time     `json:"revoked_at,omitempty"`
+	LastUsedAt  *time.Time     `json:"last_used_at,omitempty"`
+	LastUsedIP  string         `json:"last_used_ip,omitempty" gorm:"size:45"`
+	CreatedBy   string         `json:"created_by" gorm:"size:100"`
+	RotatedFrom *uint          `json:"rotated_from,omitempty"` // key this one replaced
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 }
+
+// User roles, from least to most privileged
+const (
+	RoleViewer    = "viewer"    // read tools, stats, projects and git history
+	RoleEditor    = "editor"    // viewer, and edit the tool catalog
+	RoleDeveloper = "developer" // editor, and run the AI assistant, change git and deploy
+	RoleAdmin     = "admin"     // everything
+)
+
+// Roles lists every user role
+var Roles = []string{RoleViewer, RoleEditor, RoleDeveloper, RoleAdmin}
+
+// RoleScopes maps each role to the scopes it grants
+var RoleScopes = map[string]ScopeList{
+	RoleViewer:    {ScopeToolsRead, ScopeStatsRead, ScopeProjectsRead, ScopeGitRead},
+	RoleEditor:    {ScopeToolsWrite, ScopeStatsRead, ScopeProjectsRead, ScopeGitRead},
+	RoleDeveloper: {ScopeToolsWrite, ScopeStatsRead, ScopeProjectsWrite, ScopeGitWrite, ScopeDeploy},
+	RoleAdmin:     {ScopeAdmin},
+}
+
+// User is a person who signs in with a password
+type User struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Username     string     `json:"username" gorm:"size:50;not null;uniqueIndex"`
+	Email        string     `json:"email,omitempty" gorm:"size:255"`
+	PasswordHash string     `json:"-" gorm:"size:100;not null"` // bcrypt
+	Role         string     `json:"role" gorm:"size:20;not null"`
+	Disabled     bool       `json:"disabled" gorm:"not null;default:false"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	CreatedBy    string     `json:"created_by" gorm:"size:100"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Session is a signed-in user. Only the hash of its token is stored.
+type Session struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	User       User      `json:"-"`
+	TokenHash  string    `json:"-" gorm:"size:64;not null;uniqueIndex"` // hex SHA-256 of the token
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null;index"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IP         string    `json:"ip" gorm:"size:45"`
+	UserAgent  string    `json:"user_agent" gorm:"size:255"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Project rights, granted per workspace project to users and API keys. Every
+// right includes read.
+const (
+	ProjectRightRead   = "read"   // view the project, its status, logs, branches and diffs
+	ProjectRightAgent  = "agent"  // run the AI assistant, commands, installs and builds
+	ProjectRightCommit = "commit" // commit, create and switch branches, reset changes
+	ProjectRightPush   = "push"   // push to the remote
+	ProjectRightDeploy = "deploy" // deploy to Netlify
+)
+
+// ProjectRights lists every project right
+var ProjectRights = []string{ProjectRightRead, ProjectRightAgent, ProjectRightCommit, ProjectRightPush, ProjectRightDeploy}
+
+// AllProjects is the project name of a grant that applies to every project
+const AllProjects = "*"
+
+// Project grant subjects
+const (
+	GrantSubjectUser   = "user"
+	GrantSubjectAPIKey = "api_key"
+)
+
+// ProjectRightList is a set of project rights, stored space separated
+type ProjectRightList []string
+
+// Has reports whether the rights include right
+func (l ProjectRightList) Has(right string) bool {
+	for _, granted := range l {
+		if granted == right || right == ProjectRightRead {
+			return true
+		}
+	}
+	return false
+}
+
+// Value implements driver.Valuer
+func (l ProjectRightList) Value() (driver.Value, error) {
+	return strings.Join(l, " "), nil
+}
+
+// Scan implements sql.Scanner
+func (l *ProjectRightList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*l = strings.Fields(v)
+	case []byte:
+		*l = strings.Fields(string(v))
+	case nil:
+		*l = nil
+	default:
+		return fmt.Errorf("cannot scan %T into ProjectRightList", value)
+	}
+	return nil
+}
+
+// ProjectGrant gives a user or API key rights on a workspace project, or on
+// every project when Project is AllProjects
+type ProjectGrant struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	Project     string           `json:"project" gorm:"size:100;not null;uniqueIndex:idx_project_grants_subject"`
+	SubjectType string           `json:"subject_type" gorm:"size:20;not null;uniqueIndex:idx_project_grants_subject"`
+	SubjectID   uint             `json:"subject_id" gorm:"not null;uniqueIndex:idx_project_grants_subject"`
+	Rights      ProjectRightList `json:"rights" gorm:"type:text;not null"`
+	GrantedBy   string           `json:"granted_by" gorm:"size:100"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// ToolScore holds the ranking signals of a tool, recomputed on a schedule from its usage
+type ToolScore struct {
+	ToolID       uint      `json:"tool_id" gorm:"primaryKey;autoIncrement:false"`
+	Popularity   float64   `json:"popularity" gorm:"not null;index"` // uses weighted by age with exponential decay
+	Trending     float64   `json:"trending" gorm:"not null;index"`   // growth of the recent window over the baseline
+	RecentUses   int64     `json:"recent_uses" gorm:"not null"`      // uses in the trending window
+	ExpectedUses float64   `json:"expected_uses" gorm:"not null"`    // baseline uses scaled to the trending window
+	Delta        float64   `json:"delta" gorm:"not null"`            // RecentUses - ExpectedUses
+	ComputedAt   time.Time `json:"computed_at"`
+}
+
+// Alert states
+const (
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+// Alert records an alert rule whose condition held. While it is firing,
+// further evaluations that still match update it instead of opening another
+// alert with the same fingerprint.
+type Alert struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Fingerprint string     `json:"fingerprint" gorm:"size:150;not null;index"` // rule name, plus the tool for per-tool rules
+	Rule        string     `json:"rule" gorm:"size:100;not null"`
+	ToolID      uint       `json:"tool_id,omitempty" gorm:"index"`
+	Metric      string     `json:"metric" gorm:"size:50;not null"`
+	Condition   string     `json:"condition" gorm:"size:20;not null"`
+	Severity    string     `json:"severity" gorm:"size:20;not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;index"`
+	Value       float64    `json:"value"`     // value compared by the rule when it last matched
+	Threshold   float64    `json:"threshold"` // value of the rule
+	Message     string     `json:"message"`
+	Matches     int        `json:"matches"` // evaluations that matched while firing
+	NotifyError string     `json:"notify_error,omitempty"`
+	FiredAt     time.Time  `json:"fired_at" gorm:"index"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Audit actions
+const (
+	AuditAPIKeyCreate  = "api_key.create"
+	AuditAPIKeyUpdate  = "api_key.update"
+	AuditAPIKeyRotate  = "api_key.rotate"
+	AuditAPIKeyRevoke  = "api_key.revoke"
+	AuditUserCreate    = "user.create"
+	AuditUserUpdate    = "user.update"
+	AuditUserLogin     = "user.login"
+	AuditProjectGrant  = "project.grant"
+	AuditProjectRevoke = "project.revoke"
+	AuditProjectDeploy = "project.deploy"
+	AuditGitCommit     = "git.commit"
+	AuditGitPush       = "git.push"
+	AuditGitBranch     = "git.branch"
+	AuditGitSwitch     = "git.switch"
+	AuditGitReset      = "git.reset"
+	AuditRequest       = "request" // a mutating request, recorded by the audit middleware
+)
+
+// Tool edits are audited as "tool." followed by the revision action
+const AuditToolPrefix = "tool."
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeDenied  = "denied" // the request was not authenticated or not permitted
+)
+
+// AuditDetails describe an audited change, stored as JSON
+type AuditDetails map[string]interface{}
+
+// Value implements driver.Valuer
+func (d AuditDetails) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (d *AuditDetails) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	case []byte:
+		return json.Unmarshal(v, d)
+	case nil:
+		*d = nil
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into AuditDetails", value)
+}
+
+// AuditLog records an administrative action. Details never contain secrets.
+// Every entry holds the hash of the entry before it, so changing, removing or
+// reordering entries breaks the chain.
+type AuditLog struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	Actor      string       `json:"actor" gorm:"size:100;not null;index"`
+	Action     string       `json:"action" gorm:"size:50;not null;index"`
+	TargetType string       `json:"target_type" gorm:"size:50;not null;index:idx_audit_logs_target"`
+	TargetID   string       `json:"target_id" gorm:"size:255;index:idx_audit_logs_target"`
+	Project    string       `json:"project,omitempty" gorm:"size:100;index"`
+	RequestID  string       `json:"request_id,omitempty" gorm:"size:64;index"`
+	IP         string       `json:"ip,omitempty" gorm:"size:45"`
+	Outcome    string       `json:"outcome" gorm:"size:20;not null;default:success"`
+	Details    AuditDetails `json:"details" gorm:"type:text"`
+	PrevHash   string       `json:"prev_hash" gorm:"size:64"`
+	Hash       string       `json:"hash" gorm:"size:64;index"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"index"`
+}
+
+// ComputeHash returns the hex SHA-256 of the entry and the hash of the entry
+// before it. The ID is left out, it is assigned after the hash is computed.
+func (l *AuditLog) ComputeHash() (string, error) {
+	details := l.Details
+	if details == nil {
+		details = AuditDetails{}
+	}
+	data, err := json.Marshal(struct {
+		PrevHash   string       `json:"prev_hash"`
+		Actor      string       `json:"actor"`
+		Action     string       `json:"action"`
+		TargetType string       `json:"target_type"`
+		TargetID   string       `json:"target_id"`
+		Project    string       `json:"project"`
+		RequestID  string       `json:"request_id"`
+		IP         string       `json:"ip"`
+		Outcome    string       `json:"outcome"`
+		Details    AuditDetails `json:"details"`
+		CreatedAt  string       `json:"created_at"`
+	}{
+		PrevHash:   l.PrevHash,
+		Actor:      l.Actor,
+		Action:     l.Action,
+		TargetType: l.TargetType,
+		TargetID:   l.TargetID,
+		Project:    l.Project,
+		RequestID:  l.RequestID,
+		IP:         l.IP,
+		Outcome:    l.Outcome,
+		Details:    details,
+		CreatedAt:  l.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditChain names the chain head of the audit log
+const AuditChain = "audit_log"
+
+// AuditChainHead is the last entry of the audit log chain. Writers lock it to
+// append entries one at a time, and it shows when entries were cut off the end.
+type AuditChainHead struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:50"`
+	LastID    uint      `json:"last_id"`
+	Hash      string    `json:"hash" gorm:"size:64"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Agent run statuses
+const (
+	AgentRunRunning     = "running"
+	AgentRunSucceeded   = "succeeded"
+	AgentRunFailed      = "failed"
+	AgentRunRejected    = "rejected"    // refused by a budget or the queue, never started
+	AgentRunInterrupted = "interrupted" // the server stopped while it was running
+)
+
+// AgentRun is a run of the AI development assistant. Runs count against the
+// daily budgets of the actor that started them.
+type AgentRun struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Actor      string     `json:"actor" gorm:"size:100;not null;index:idx_agent_runs_actor_day"`
+	Day        string     `json:"day" gorm:"size:10;not null;index:idx_agent_runs_actor_day;index"` // UTC date the run was requested
+	Project    string     `json:"project" gorm:"size:100;not null;index"`
+	Kind       string     `json:"kind" gorm:"size:20;not null"` // chat, review or analyze
+	RequestID  string     `json:"request_id" gorm:"size:64"`
+	Status     string     `json:"status" gorm:"size:20;not null;index"`
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	WaitMs     int64      `json:"wait_ms"`     // time spent queued for a slot
+	DurationMs int64      `json:"duration_ms"` // wall-clock time of the agent process
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
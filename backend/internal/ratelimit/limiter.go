@@ -0,0 +1,255 @@
+// Package ratelimit limits how often a client may call a group of routes.
+// Limiters keep their state in a Store, in memory for a single server or in
+// Redis when several servers share the limits.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate limit algorithms
+const (
+	// TokenBucket allows bursts up to the bucket size, refilled at the rate
+	TokenBucket = "token_bucket"
+	// SlidingWindow allows the rate within any period, weighting the count of
+	// the previous fixed window by how much of it still overlaps
+	SlidingWindow = "sliding_window"
+)
+
+// Limit allows Rate requests per Period. Burst is the token bucket size,
+// Rate when zero.
+type Limit struct {
+	Algorithm string
+	Rate      int
+	Period    time.Duration
+	Burst     int
+}
+
+// ParseLimit parses a limit of the form [algorithm:]rate/period[:burst], such
+// as 120/1m, sliding_window:10/1m or token_bucket:300/1m:50. The algorithm is
+// a token bucket by default. Empty and "off" disable the limit and give nil.
+func ParseLimit(spec string) (*Limit, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return nil, nil
+	}
+
+	limit := &Limit{Algorithm: TokenBucket}
+	parts := strings.Split(spec, ":")
+	if parts[0] == TokenBucket || parts[0] == SlidingWindow {
+		limit.Algorithm = parts[0]
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || len(parts) > 2 {
+		return nil, fmt.Errorf("invalid limit %q, expected [algorithm:]rate/period[:burst]", spec)
+	}
+
+	rate, period, ok := strings.Cut(parts[0], "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid limit %q, expected rate/period", spec)
+	}
+	var err error
+	if limit.Rate, err = strconv.Atoi(rate); err != nil || limit.Rate <= 0 {
+		return nil, fmt.Errorf("invalid rate %q, expected a positive number", rate)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return nil, fmt.Errorf("invalid period %q, expected a positive duration", period)
+	}
+	if len(parts) == 2 {
+		if limit.Algorithm != TokenBucket {
+			return nil, fmt.Errorf("invalid limit %q, only token buckets have a burst", spec)
+		}
+		if limit.Burst, err = strconv.Atoi(parts[1]); err != nil || limit.Burst <= 0 {
+			return nil, fmt.Errorf("invalid burst %q, expected a positive number", parts[1])
+		}
+	}
+	return limit, nil
+}
+
+// String formats the limit as it is parsed
+func (l Limit) String() string {
+	if l.Algorithm == TokenBucket && l.Burst > 0 {
+		return fmt.Sprintf("%s:%d/%s:%d", l.Algorithm, l.Rate, l.Period, l.Burst)
+	}
+	return fmt.Sprintf("%s:%d/%s", l.Algorithm, l.Rate, l.Period)
+}
+
+// Quota is the number of requests the limit allows at once
+func (l Limit) Quota() int {
+	if l.Algorithm == TokenBucket && l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result is the outcome of a request against a limit
+type Result struct {
+	Allowed    bool
+	Limit      int           // requests allowed at once
+	Remaining  int           // requests left after this one
+	Reset      time.Duration // until the full quota is available again
+	RetryAfter time.Duration // until the next request is allowed, zero when allowed
+}
+
+// Limiter applies a limit to the requests of each client
+type Limiter struct {
+	name  string
+	limit Limit
+	store Store
+	now   func() time.Time
+}
+
+// NewLimiter creates a limiter whose state is kept in store under its name
+func NewLimiter(name string, limit Limit, store Store) *Limiter {
+	return &Limiter{
+		name:  name,
+		limit: limit,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// New creates the limiter of a route group from its configured limit. It
+// returns nil when the limit is disabled.
+func New(name, spec string, store Store) (*Limiter, error) {
+	limit, err := ParseLimit(spec)
+	if err != nil {
+		return nil, fmt.Errorf("rate limit %s: %w", name, err)
+	}
+	if limit == nil {
+		return nil, nil
+	}
+	return NewLimiter(name, *limit, store), nil
+}
+
+// Name identifies the limiter in keys and logs
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+// Limit returns the limit the limiter applies
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow counts a request of the client identified by key, if the limit
+// allows it
+func (l *Limiter) Allow(key string) (Result, error) {
+	now := l.now()
+	var result Result
+	err := l.store.Update("ratelimit:"+l.name+":"+key, l.ttl(), func(state []byte) []byte {
+		switch l.limit.Algorithm {
+		case SlidingWindow:
+			state, result = l.slidingWindow(state, now)
+		default:
+			state, result = l.tokenBucket(state, now)
+		}
+		return state
+	})
+	return result, err
+}
+
+// ttl is how long the state of an idle client is kept. After that the client
+// has its full quota again, so the state is no longer needed.
+func (l *Limiter) ttl() time.Duration {
+	if l.limit.Algorithm == SlidingWindow {
+		return 2 * l.limit.Period
+	}
+	return l.limit.Period * time.Duration(l.limit.Quota()) / time.Duration(l.limit.Rate)
+}
+
+// tokenBucket refills the bucket stored as "tokens:updated unix nanoseconds"
+// and takes a token for the request
+func (l *Limiter) tokenBucket(state []byte, now time.Time) ([]byte, Result) {
+	capacity := float64(l.limit.Quota())
+	perToken := float64(l.limit.Period) / float64(l.limit.Rate)
+
+	tokens := capacity
+	if fields := strings.Split(string(state), ":"); len(fields) == 2 {
+		stored, err1 := strconv.ParseFloat(fields[0], 64)
+		updated, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 == nil && err2 == nil {
+			elapsed := now.Sub(time.Unix(0, updated))
+			tokens = math.Min(capacity, stored+math.Max(0, float64(elapsed))/perToken)
+		}
+	}
+
+	result := Result{Limit: int(capacity)}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((capacity - tokens) * perToken)
+
+	return []byte(strconv.FormatFloat(tokens, 'f', -1, 64) + ":" + strconv.FormatInt(now.UnixNano(), 10)), result
+}
+
+// slidingWindow counts the request in the current fixed window, stored as
+// "window start unix nanoseconds:current count:previous count", when the
+// estimated count over the last period is below the rate
+func (l *Limiter) slidingWindow(state []byte, now time.Time) ([]byte, Result) {
+	period := l.limit.Period
+	start := now.Truncate(period)
+
+	var current, previous int
+	if fields := strings.Split(string(state), ":"); len(fields) == 3 {
+		stored, err1 := strconv.ParseInt(fields[0], 10, 64)
+		cur, err2 := strconv.Atoi(fields[1])
+		prev, err3 := strconv.Atoi(fields[2])
+		if err1 == nil && err2 == nil && err3 == nil {
+			switch storedStart := time.Unix(0, stored); {
+			case storedStart.Equal(start):
+				current, previous = cur, prev
+			case storedStart.Add(period).Equal(start):
+				previous = cur
+			}
+		}
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(period)
+	estimate := float64(previous)*weight + float64(current)
+
+	rate := l.limit.Rate
+	result := Result{Limit: rate}
+	if estimate+1 <= float64(rate) {
+		current++
+		estimate++
+		result.Allowed = true
+	} else {
+		result.RetryAfter = l.slidingRetryAfter(current, previous, elapsed)
+	}
+	result.Remaining = int(math.Max(0, math.Floor(float64(rate)-estimate)))
+	// The full quota is back once every counted request has slid out
+	switch {
+	case current > 0:
+		result.Reset = 2*period - elapsed
+	case previous > 0:
+		result.Reset = period - elapsed
+	}
+
+	return []byte(fmt.Sprintf("%d:%d:%d", start.UnixNano(), current, previous)), result
+}
+
+// slidingRetryAfter is how long until the estimated count leaves room for one
+// more request, as the previous window slides out
+func (l *Limiter) slidingRetryAfter(current, previous int, elapsed time.Duration) time.Duration {
+	period := float64(l.limit.Period)
+	room := float64(l.limit.Rate - 1)
+
+	if current <= l.limit.Rate-1 && previous > 0 {
+		// Still in this window: previous * (1 - t/period) + current <= room
+		t := period * (1 - (room-float64(current))/float64(previous))
+		return time.Duration(math.Max(0, t-float64(elapsed)))
+	}
+	// In the next window, the current window is the previous one
+	t := period * (1 - room/float64(current))
+	return time.Duration(period-float64(elapsed)) + time.Duration(math.Max(0, t))
+}
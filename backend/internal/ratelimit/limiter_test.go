@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    *Limit
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "off", want: nil},
+		{spec: "120/1m", want: &Limit{Algorithm: TokenBucket, Rate: 120, Period: time.Minute}},
+		{spec: "token_bucket:300/1m:50", want: &Limit{Algorithm: TokenBucket, Rate: 300, Period: time.Minute, Burst: 50}},
+		{spec: "sliding_window:10/1m", want: &Limit{Algorithm: SlidingWindow, Rate: 10, Period: time.Minute}},
+		{spec: "sliding_window:10/1m:5", wantErr: true},
+		{spec: "0/1m", wantErr: true},
+		{spec: "10/soon", wantErr: true},
+		{spec: "10", wantErr: true},
+		{spec: "10/1m:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, want error %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+// testStores creates one store of each kind, the Redis store against an
+// in-process stand-in
+func testStores(t *testing.T) map[string]Store {
+	server := newRESPServer(t)
+	return map[string]Store{
+		StoreMemory: NewMemoryStore(0),
+		StoreRedis:  newTestRedisStore(t, "redis://"+server.addr),
+	}
+}
+
+// clock is a settable time source for limiters
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(limit Limit, store Store, c *clock) *Limiter {
+	limiter := NewLimiter("test", limit, store)
+	limiter.now = c.Now
+	return limiter
+}
+
+// allow makes a request and checks whether it is allowed with the remaining quota
+func allow(t *testing.T, limiter *Limiter, key string, wantAllowed bool, wantRemaining int) Result {
+	t.Helper()
+	result, err := limiter.Allow(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed != wantAllowed || result.Remaining != wantRemaining {
+		t.Fatalf("Allow(%s) = allowed %v, remaining %d, want %v and %d", key, result.Allowed, result.Remaining, wantAllowed, wantRemaining)
+	}
+	return result
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{now: time.Unix(1700000000, 0)}
+			// 2 per second with bursts of 3
+			limiter := newTestLimiter(Limit{Algorithm: TokenBucket, Rate: 2, Period: time.Second, Burst: 3}, store, c)
+
+			allow(t, limiter, "a", true, 2)
+			allow(t, limiter, "a", true, 1)
+			result := allow(t, limiter, "a", true, 0)
+			if result.Limit != 3 || result.Reset != 1500*time.Millisecond {
+				t.Errorf("limit %d, reset %s, want 3 and 1.5s", result.Limit, result.Reset)
+			}
+			result = allow(t, limiter, "a", false, 0)
+			if result.RetryAfter != 500*time.Millisecond {
+				t.Errorf("retry after %s, want 500ms", result.RetryAfter)
+			}
+
+			// Other clients have their own bucket
+			allow(t, limiter, "b", true, 2)
+
+			c.Advance(500 * time.Millisecond)
+			allow(t, limiter, "a", true, 0)
+			allow(t, limiter, "a", false, 0)
+
+			// A full refill does not exceed the burst
+			c.Advance(10 * time.Second)
+			allow(t, limiter, "a", true, 2)
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			c := &clock{now: time.Unix(1700000000, 0).Truncate(time.Minute)}
+			limiter := newTestLimiter(Limit{Algorithm: SlidingWindow, Rate: 3, Period: time.Minute}, store, c)
+
+			allow(t, limiter, "a", true, 2)
+			allow(t, limiter, "a", true, 1)
+			allow(t, limiter, "a", true, 0)
+			result := allow(t, limiter, "a", false, 0)
+			// A third into the next window the previous one weighs 2
+			if result.RetryAfter != 80*time.Second {
+				t.Errorf("retry after %s, want 1m20s", result.RetryAfter)
+			}
+			allow(t, limiter, "b", true, 2)
+
+			// Half way into the next window, 3 * 0.5 + 1 leaves room for one more
+			c.Advance(90 * time.Second)
+			result = allow(t, limiter, "a", true, 0)
+			if result.Reset != 90*time.Second {
+				t.Errorf("reset %s, want 1m30s", result.Reset)
+			}
+			allow(t, limiter, "a", false, 0)
+
+			// Two windows later nothing is counted
+			c.Advance(2 * time.Minute)
+			allow(t, limiter, "a", true, 2)
+		})
+	}
+}
+
+func TestMemoryStoreEvictsAndExpires(t *testing.T) {
+	store := NewMemoryStore(2)
+	c := &clock{now: time.Unix(1700000000, 0)}
+	store.now = c.Now
+
+	read := func(key string) []byte {
+		var state []byte
+		store.Update(key, time.Minute, func(s []byte) []byte {
+			state = s
+			return []byte(key)
+		})
+		return state
+	}
+
+	read("a")
+	read("b")
+	read("a") // a is now the most recently used
+	read("c") // evicts b
+	if state := read("b"); state != nil {
+		t.Errorf("evicted key kept state %q", state)
+	}
+	if state := read("c"); string(state) != "c" {
+		t.Errorf("state of c = %q, want c", state)
+	}
+
+	c.Advance(2 * time.Minute)
+	if state := read("c"); state != nil {
+		t.Errorf("expired key kept state %q", state)
+	}
+}
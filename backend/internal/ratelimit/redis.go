@@ -0,0 +1,320 @@
+package ratelimit
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRedisPoolSize = 10
+	defaultRedisTimeout  = 2 * time.Second
+
+	// redisUpdateAttempts bounds the optimistic retries of an update whose key
+	// another server changed at the same time
+	redisUpdateAttempts = 5
+
+	// redisKeyLocks is the number of locks that serialize updates of the same
+	// key within this process, so that retries are only needed across servers
+	redisKeyLocks = 64
+)
+
+// ErrRedisConflict is returned when an update keeps losing to concurrent
+// updates of the same key
+var ErrRedisConflict = errors.New("rate limit state changed concurrently too often")
+
+// RedisOptions tunes a Redis store
+type RedisOptions struct {
+	// PoolSize is the number of idle connections kept, 10 by default
+	PoolSize int
+	// Timeout bounds connecting and every round trip, 2s by default
+	Timeout time.Duration
+	// Dial opens a connection instead of dialing the address of the URL, for
+	// example to an in-process server speaking the Redis protocol
+	Dial func() (net.Conn, error)
+}
+
+// RedisStore keeps state in Redis, so that servers share their limits. It
+// speaks the Redis protocol itself and only needs the WATCH, GET, MULTI, SET
+// and EXEC commands, plus AUTH and SELECT when the URL asks for them.
+type RedisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	timeout  time.Duration
+	dial     func() (net.Conn, error)
+	pool     chan *redisConn
+	locks    [redisKeyLocks]sync.Mutex
+}
+
+// NewRedisStore creates a store for a redis:// or rediss:// URL of the form
+// redis://[[username]:password@]host[:port][/db]. Connections are opened
+// when first needed.
+func NewRedisStore(rawURL string, options RedisOptions) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid Redis URL scheme %q, expected redis or rediss", u.Scheme)
+	}
+
+	s := &RedisStore{
+		addr:    u.Host,
+		timeout: options.Timeout,
+		dial:    options.Dial,
+	}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil || s.db < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	if u.Scheme == "rediss" {
+		s.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultRedisTimeout
+	}
+	poolSize := options.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultRedisPoolSize
+	}
+	s.pool = make(chan *redisConn, poolSize)
+	return s, nil
+}
+
+// Update implements Store. The key is watched while the new state is
+// computed, and the update is retried when another client changed it.
+func (s *RedisStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	lock := &s.locks[hash.Sum32()%redisKeyLocks]
+	lock.Lock()
+	defer lock.Unlock()
+
+	conn, err := s.get()
+	if err != nil {
+		return err
+	}
+
+	ttlMillis := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if ttl < time.Millisecond {
+		ttlMillis = "1"
+	}
+	for attempt := 0; attempt < redisUpdateAttempts; attempt++ {
+		replies, err := conn.pipeline([][]string{
+			{"WATCH", key},
+			{"GET", key},
+		})
+		if err != nil {
+			conn.close()
+			return err
+		}
+		var state []byte
+		if value, ok := replies[1].([]byte); ok {
+			state = value
+		}
+
+		replies, err = conn.pipeline([][]string{
+			{"MULTI"},
+			{"SET", key, string(fn(state)), "PX", ttlMillis},
+			{"EXEC"},
+		})
+		if err != nil {
+			conn.close()
+			return err
+		}
+		// EXEC answers with a nil array when the watched key changed
+		if replies[2] != nil {
+			s.put(conn)
+			return nil
+		}
+	}
+	s.put(conn)
+	return ErrRedisConflict
+}
+
+// get takes an idle connection from the pool or opens a new one
+func (s *RedisStore) get() (*redisConn, error) {
+	select {
+	case conn := <-s.pool:
+		return conn, nil
+	default:
+	}
+
+	var netConn net.Conn
+	var err error
+	switch {
+	case s.dial != nil:
+		netConn, err = s.dial()
+	case s.tls != nil:
+		netConn, err = tls.DialWithDialer(&net.Dialer{Timeout: s.timeout}, "tcp", s.addr, s.tls)
+	default:
+		netConn, err = net.DialTimeout("tcp", s.addr, s.timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to Redis: %w", err)
+	}
+
+	conn := &redisConn{
+		conn:    netConn,
+		reader:  bufio.NewReader(netConn),
+		writer:  bufio.NewWriter(netConn),
+		timeout: s.timeout,
+	}
+	var setup [][]string
+	switch {
+	case s.username != "" && s.password != "":
+		setup = append(setup, []string{"AUTH", s.username, s.password})
+	case s.password != "":
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+	if len(setup) > 0 {
+		if _, err := conn.pipeline(setup); err != nil {
+			conn.close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool, closing it when the pool is full
+func (s *RedisStore) put(conn *redisConn) {
+	select {
+	case s.pool <- conn:
+	default:
+		conn.close()
+	}
+}
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection speaking RESP, the Redis serialization protocol
+type redisConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	timeout time.Duration
+}
+
+// pipeline sends commands in one write and reads their replies. An error
+// reply to any command is returned as a RedisError after reading them all.
+func (c *redisConn) pipeline(commands [][]string) ([]interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	for _, args := range commands {
+		fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	var replyErr error
+	for i := range commands {
+		reply, err := c.readReply()
+		if err != nil {
+			return nil, err
+		}
+		if redisErr, ok := reply.(RedisError); ok {
+			if replyErr == nil {
+				replyErr = redisErr
+			}
+			reply = nil
+		}
+		replies[i] = reply
+	}
+	return replies, replyErr
+}
+
+// readReply reads a simple string, error, integer, bulk string or array.
+// Nil bulk strings and arrays are returned as nil.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\r\n"), nil
+}
+
+func (c *redisConn) close() {
+	c.conn.Close()
+}
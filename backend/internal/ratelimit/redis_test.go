@@ -0,0 +1,286 @@
+package ratelimit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respServer is an in-process stand-in for Redis that understands the
+// commands RedisStore sends. Every SET bumps the version of its key, so that
+// EXEC aborts when a watched key changed.
+type respServer struct {
+	addr string
+
+	mu       sync.Mutex
+	values   map[string]string
+	versions map[string]int
+	commands []string // names of the commands received, in order
+	execs    int
+
+	// beforeExec runs before every EXEC, for example to change a watched key
+	// the way another server would
+	beforeExec func(s *respServer, exec int)
+}
+
+func newRESPServer(t *testing.T) *respServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &respServer{
+		addr:     listener.Addr().String(),
+		values:   make(map[string]string),
+		versions: make(map[string]int),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// set writes a key like a client outside the store would
+func (s *respServer) set(key, value string) {
+	s.values[key] = value
+	s.versions[key]++
+}
+
+func (s *respServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	watched := make(map[string]int)
+	var queued [][]string
+	multi := false
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+
+		s.mu.Lock()
+		s.commands = append(s.commands, name)
+		var reply string
+		switch {
+		case name == "AUTH" || name == "SELECT":
+			reply = "+OK\r\n"
+		case name == "WATCH":
+			for _, key := range args[1:] {
+				watched[key] = s.versions[key]
+			}
+			reply = "+OK\r\n"
+		case name == "MULTI":
+			multi, queued = true, nil
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			s.execs++
+			if s.beforeExec != nil {
+				s.beforeExec(s, s.execs)
+			}
+			aborted := false
+			for key, version := range watched {
+				if s.versions[key] != version {
+					aborted = true
+				}
+			}
+			if aborted {
+				reply = "*-1\r\n"
+			} else {
+				reply = fmt.Sprintf("*%d\r\n", len(queued))
+				for _, command := range queued {
+					reply += s.execute(command)
+				}
+			}
+			multi, queued, watched = false, nil, make(map[string]int)
+		case multi:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			reply = s.execute(args)
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// execute runs GET or SET and returns the reply
+func (s *respServer) execute(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.set(args[1], args[2])
+		return "+OK\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func (s *respServer) setBeforeExec(fn func(s *respServer, exec int)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beforeExec = fn
+}
+
+func (s *respServer) value(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+func (s *respServer) stats() (commands []string, execs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...), s.execs
+}
+
+func newTestRedisStore(t *testing.T, rawURL string) *RedisStore {
+	t.Helper()
+	store, err := NewRedisStore(rawURL, RedisOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestRedisStoreUpdate(t *testing.T) {
+	server := newRESPServer(t)
+	store := newTestRedisStore(t, "redis://"+server.addr)
+
+	for i, want := range []string{"1", "2"} {
+		var seen []byte
+		err := store.Update("counter", time.Minute, func(state []byte) []byte {
+			seen = state
+			n, _ := strconv.Atoi(string(state))
+			return []byte(strconv.Itoa(n + 1))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && seen != nil {
+			t.Errorf("state of a new key = %q, want nil", seen)
+		}
+		if got := server.value("counter"); got != want {
+			t.Errorf("update %d stored %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestRedisStoreAuthAndSelect(t *testing.T) {
+	server := newRESPServer(t)
+	store := newTestRedisStore(t, "redis://limiter:secret@"+server.addr+"/2")
+
+	if err := store.Update("key", time.Minute, func([]byte) []byte { return []byte("x") }); err != nil {
+		t.Fatal(err)
+	}
+	commands, _ := server.stats()
+	if len(commands) < 2 || commands[0] != "AUTH" || commands[1] != "SELECT" {
+		t.Errorf("commands %v, want AUTH and SELECT first", commands)
+	}
+}
+
+func TestRedisStoreRetriesAbortedExec(t *testing.T) {
+	server := newRESPServer(t)
+	store := newTestRedisStore(t, "redis://"+server.addr)
+
+	// Another server changes the key between the first GET and EXEC
+	server.setBeforeExec(func(s *respServer, exec int) {
+		if exec == 1 {
+			s.set("counter", "10")
+		}
+	})
+
+	calls := 0
+	var last []byte
+	err := store.Update("counter", time.Minute, func(state []byte) []byte {
+		calls++
+		last = state
+		n, _ := strconv.Atoi(string(state))
+		return []byte(strconv.Itoa(n + 1))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, execs := server.stats(); calls != 2 || execs != 2 {
+		t.Errorf("fn called %d times over %d EXECs, want 2 and 2", calls, execs)
+	}
+	if string(last) != "10" {
+		t.Errorf("retry saw state %q, want the concurrent write", last)
+	}
+	if got := server.value("counter"); got != "11" {
+		t.Errorf("stored %q, want 11", got)
+	}
+}
+
+func TestRedisStoreConflict(t *testing.T) {
+	server := newRESPServer(t)
+	store := newTestRedisStore(t, "redis://"+server.addr)
+	server.setBeforeExec(func(s *respServer, exec int) {
+		s.set("counter", strconv.Itoa(exec))
+	})
+
+	err := store.Update("counter", time.Minute, func(state []byte) []byte { return []byte("mine") })
+	if !errors.Is(err, ErrRedisConflict) {
+		t.Fatalf("Update = %v, want ErrRedisConflict", err)
+	}
+	if _, execs := server.stats(); execs != redisUpdateAttempts {
+		t.Errorf("%d EXECs, want %d", execs, redisUpdateAttempts)
+	}
+
+	// The connection is still usable once the conflicts stop
+	server.setBeforeExec(nil)
+	if err := store.Update("counter", time.Minute, func(state []byte) []byte { return []byte("mine") }); err != nil {
+		t.Fatal(err)
+	}
+}
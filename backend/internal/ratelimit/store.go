@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Store types
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Store keeps the state of limiters per client
+type Store interface {
+	// Update replaces the state of key with what fn returns and keeps it for
+	// ttl, as one atomic step. The state is nil for a new or expired key. fn
+	// may be called more than once and must not have side effects.
+	Update(key string, ttl time.Duration, fn func(state []byte) []byte) error
+}
+
+// NewStore creates the configured store: a memory store holding up to
+// memorySize keys, or a Redis store connected to redisURL
+func NewStore(kind, redisURL string, memorySize int) (Store, error) {
+	switch kind {
+	case StoreMemory, "":
+		return NewMemoryStore(memorySize), nil
+	case StoreRedis:
+		return NewRedisStore(redisURL, RedisOptions{})
+	}
+	return nil, fmt.Errorf("unknown rate limit store %q, expected %s or %s", kind, StoreMemory, StoreRedis)
+}
+
+// defaultMemorySize is the number of keys a memory store holds when no size is given
+const defaultMemorySize = 10000
+
+// MemoryStore keeps state in this process. When it is full the least recently
+// used key is evicted, which at worst gives that client a fresh quota.
+type MemoryStore struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // most recently used first
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type memoryItem struct {
+	key     string
+	state   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a memory store holding up to size keys
+func NewMemoryStore(size int) *MemoryStore {
+	if size <= 0 {
+		size = defaultMemorySize
+	}
+	return &MemoryStore{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Update implements Store
+func (s *MemoryStore) Update(key string, ttl time.Duration, fn func(state []byte) []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if element, ok := s.items[key]; ok {
+		item := element.Value.(*memoryItem)
+		var state []byte
+		if now.Before(item.expires) {
+			state = item.state
+		}
+		item.state = fn(state)
+		item.expires = now.Add(ttl)
+		s.order.MoveToFront(element)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(&memoryItem{
+		key:     key,
+		state:   fn(nil),
+		expires: now.Add(ttl),
+	})
+	for s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
@@ -0,0 +1,607 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// agentRunDayFormat is the layout of the UTC day a run counts against
+const agentRunDayFormat = "2006-01-02"
+
+// agentRunErrorSize bounds the error message stored with a run
+const agentRunErrorSize = 1000
+
+// AgentQuotaOptions configures an AgentQuotaService. Zero limits and budgets
+// are unlimited.
+type AgentQuotaOptions struct {
+	MaxConcurrent           int           // agent runs at once on this server
+	MaxConcurrentPerActor   int           // agent runs at once per user or API key
+	MaxConcurrentPerProject int           // agent runs at once per project
+	QueueSize               int           // runs waiting for a slot; 0 rejects runs when no slot is free
+	QueueTimeout            time.Duration // how long a run waits for a slot before it is rejected
+	DailyRuns               int           // runs per user or API key per UTC day
+	DailyMinutes            int           // wall-clock minutes of runs per user or API key per UTC day
+}
+
+// AgentRunRequest describes a run asking for a slot
+type AgentRunRequest struct {
+	Actor     string
+	Project   string
+	Kind      string
+	RequestID string
+}
+
+// Daily agent budgets
+const (
+	AgentBudgetRuns    = "runs"
+	AgentBudgetMinutes = "minutes"
+)
+
+// AgentBudgetError is returned when the actor has used up a daily budget
+type AgentBudgetError struct {
+	Budget   string    `json:"budget"`    // runs or minutes
+	Limit    int       `json:"limit"`     // the daily budget
+	Used     float64   `json:"used"`      // runs or minutes used today
+	ResetsAt time.Time `json:"resets_at"` // start of the next UTC day
+}
+
+func (e *AgentBudgetError) Error() string {
+	return fmt.Sprintf("daily agent %s budget exceeded: %s of %d %s used, resets at %s",
+		e.Budget, formatAgentUsage(e.Used), e.Limit, e.Budget, e.ResetsAt.Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrAgentBudgetExceeded) match
+func (e *AgentBudgetError) Is(target error) bool {
+	return target == ErrAgentBudgetExceeded
+}
+
+func formatAgentUsage(used float64) string {
+	if used == float64(int64(used)) {
+		return fmt.Sprintf("%d", int64(used))
+	}
+	return fmt.Sprintf("%.1f", used)
+}
+
+// AgentTicket is a run holding or waiting for a slot. Wait blocks until the
+// run may start; every ticket whose Wait succeeded must be finished.
+type AgentTicket struct {
+	service  *AgentQuotaService
+	req      AgentRunRequest
+	queuedAt time.Time
+	admitted chan struct{} // closed when the run gets a slot
+
+	// Guarded by service.mu
+	startedAt time.Time
+	running   bool
+	recorded  bool // the run row exists, so the database counts the run
+	position  int  // place in the queue when it was queued
+
+	run *models.AgentRun
+}
+
+// Position is the place of the run in the queue when it was queued, 1 for
+// the first run waiting, or 0 when it got a slot right away
+func (t *AgentTicket) Position() int {
+	return t.position
+}
+
+// AgentQuotaStatus reports the limits, the current runs and the usage of the
+// day for one actor
+type AgentQuotaStatus struct {
+	Actor          string    `json:"actor"`
+	Day            string    `json:"day"`
+	RunsUsed       int64     `json:"runs_used"`
+	MinutesUsed    float64   `json:"minutes_used"`
+	DailyRuns      int       `json:"daily_runs"`    // 0 is unlimited
+	DailyMinutes   int       `json:"daily_minutes"` // 0 is unlimited
+	ResetsAt       time.Time `json:"resets_at"`
+	Running        int       `json:"running"` // runs of the actor holding a slot
+	Queued         int       `json:"queued"`  // runs of the actor waiting for a slot
+	TotalRunning   int       `json:"total_running"`
+	TotalQueued    int       `json:"total_queued"`
+	MaxConcurrent  int       `json:"max_concurrent"`
+	MaxPerActor    int       `json:"max_concurrent_per_actor"`
+	MaxPerProject  int       `json:"max_concurrent_per_project"`
+	QueueSize      int       `json:"queue_size"`
+	QueueTimeoutMs int64     `json:"queue_timeout_ms"`
+}
+
+// AgentUsageFilter selects the runs of a usage report. Empty fields match
+// every run; days are UTC dates in YYYY-MM-DD form.
+type AgentUsageFilter struct {
+	Actor   string
+	Project string
+	From    string // inclusive
+	To      string // inclusive
+}
+
+// AgentUsage is the usage of one actor on one UTC day
+type AgentUsage struct {
+	Day         string  `json:"day"`
+	Actor       string  `json:"actor"`
+	Runs        int64   `json:"runs"` // runs that started, rejected runs excluded
+	Succeeded   int64   `json:"succeeded"`
+	Failed      int64   `json:"failed"`
+	Interrupted int64   `json:"interrupted"`
+	Rejected    int64   `json:"rejected"`
+	Minutes     float64 `json:"minutes"`
+	WaitMinutes float64 `json:"wait_minutes"`
+}
+
+// AgentQuotaService caps how many AI agent runs execute at once, globally,
+// per actor and per project, queueing the runs that do not fit, and enforces
+// daily run and minute budgets per actor. Slots are held in memory, so the
+// concurrency limits apply per server; runs and budgets are kept in the
+// database.
+//
+// Budget queries run outside mu, so that slow database calls of one actor do
+// not hold up the runs of others. Instead the runs of an actor are written
+// under a lock of that actor only, which budget checks hold as well.
+type AgentQuotaService struct {
+	db   *gorm.DB
+	opts AgentQuotaOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	queue      []*AgentTicket // oldest first
+	running    map[*AgentTicket]struct{}
+	perActor   map[string]int
+	perProject map[string]int
+
+	actorLocksMu sync.Mutex
+	actorLocks   map[string]*agentActorLock
+}
+
+// agentActorLock serializes the budget checks and run records of one actor
+type agentActorLock struct {
+	mu   sync.Mutex
+	refs int // callers holding or waiting for the lock
+}
+
+func NewAgentQuotaService(opts AgentQuotaOptions) *AgentQuotaService {
+	if opts.QueueSize < 0 {
+		opts.QueueSize = 0
+	}
+	return &AgentQuotaService{
+		db:         database.GetDB(),
+		opts:       opts,
+		now:        time.Now,
+		running:    make(map[*AgentTicket]struct{}),
+		perActor:   make(map[string]int),
+		perProject: make(map[string]int),
+		actorLocks: make(map[string]*agentActorLock),
+	}
+}
+
+// Options returns the limits the service enforces
+func (s *AgentQuotaService) Options() AgentQuotaOptions {
+	return s.opts
+}
+
+// Enqueue checks the daily budgets of the actor and takes a slot for the run,
+// or a place in the queue when no slot is free. Runs refused by a budget or a
+// full queue are recorded as rejected.
+func (s *AgentQuotaService) Enqueue(req AgentRunRequest) (*AgentTicket, error) {
+	now := s.now()
+	ticket := &AgentTicket{
+		service:  s,
+		req:      req,
+		queuedAt: now,
+		admitted: make(chan struct{}),
+	}
+
+	// Runs queued at the same time by one actor must not together go over
+	// the budget, so their checks take turns
+	unlock := s.lockActor(req.Actor)
+	defer unlock()
+
+	var used agentUsedToday
+	var err error
+	if s.hasBudgets() {
+		used, err = s.usedToday(req.Actor, now)
+	}
+
+	s.mu.Lock()
+	if err == nil && s.hasBudgets() {
+		err = s.checkBudgetLocked(req.Actor, now, used)
+	}
+	switch {
+	case err != nil:
+	case s.fitsLocked(req):
+		s.admitLocked(ticket)
+	case len(s.queue) < s.opts.QueueSize:
+		ticket.position = len(s.queue) + 1
+		s.queue = append(s.queue, ticket)
+	default:
+		err = ErrAgentQueueFull
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		s.recordRejected(ticket, err)
+		return nil, err
+	}
+	return ticket, nil
+}
+
+// Wait blocks until the run has a slot, the queue timeout passes or ctx is
+// done, and records the start of the run. A run that did not start is
+// recorded as rejected and must not be finished.
+func (t *AgentTicket) Wait(ctx context.Context) error {
+	s := t.service
+
+	var timeout <-chan time.Time
+	if s.opts.QueueTimeout > 0 {
+		timer := time.NewTimer(s.opts.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-t.admitted:
+	case <-timeout:
+		err = ErrAgentQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		s.mu.Lock()
+		started := t.running
+		if !started {
+			s.removeQueuedLocked(t)
+		}
+		s.mu.Unlock()
+		if !started {
+			s.recordRejected(t, err)
+			return err
+		}
+	}
+
+	unlock := s.lockActor(t.req.Actor)
+	defer unlock()
+
+	run := &models.AgentRun{
+		Actor:     t.req.Actor,
+		Day:       t.queuedAt.UTC().Format(agentRunDayFormat),
+		Project:   t.req.Project,
+		Kind:      t.req.Kind,
+		RequestID: t.req.RequestID,
+		Status:    models.AgentRunRunning,
+		WaitMs:    t.startedAt.Sub(t.queuedAt).Milliseconds(),
+		StartedAt: &t.startedAt,
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		s.release(t)
+		return err
+	}
+	t.run = run
+
+	s.mu.Lock()
+	t.recorded = true
+	s.mu.Unlock()
+	return nil
+}
+
+// Finish releases the slot of the run, lets queued runs start and records how
+// the run ended
+func (t *AgentTicket) Finish(runErr error) {
+	s := t.service
+	unlock := s.lockActor(t.req.Actor)
+	defer unlock()
+
+	s.release(t)
+	if t.run == nil {
+		return
+	}
+
+	finishedAt := s.now()
+	updates := map[string]interface{}{
+		"status":      models.AgentRunSucceeded,
+		"duration_ms": finishedAt.Sub(t.startedAt).Milliseconds(),
+		"finished_at": finishedAt,
+	}
+	if runErr != nil {
+		updates["status"] = models.AgentRunFailed
+		updates["error"] = truncateAgentError(runErr)
+	}
+	if err := s.db.Model(t.run).Updates(updates).Error; err != nil {
+		logging.Errorf("Failed to record the end of agent run %d: %v", t.run.ID, err)
+	}
+}
+
+// Status reports the limits, current runs and today's usage of an actor
+func (s *AgentQuotaService) Status(actor string) (*AgentQuotaStatus, error) {
+	now := s.now()
+	used, err := s.usedToday(actor, now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addRunningLocked(actor, now, &used)
+	status := &AgentQuotaStatus{
+		Actor:          actor,
+		Day:            now.UTC().Format(agentRunDayFormat),
+		RunsUsed:       used.Runs,
+		MinutesUsed:    used.Duration.Minutes(),
+		DailyRuns:      s.opts.DailyRuns,
+		DailyMinutes:   s.opts.DailyMinutes,
+		ResetsAt:       nextAgentDay(now),
+		Running:        s.perActor[actor],
+		TotalRunning:   len(s.running),
+		TotalQueued:    len(s.queue),
+		MaxConcurrent:  s.opts.MaxConcurrent,
+		MaxPerActor:    s.opts.MaxConcurrentPerActor,
+		MaxPerProject:  s.opts.MaxConcurrentPerProject,
+		QueueSize:      s.opts.QueueSize,
+		QueueTimeoutMs: s.opts.QueueTimeout.Milliseconds(),
+	}
+	for _, queued := range s.queue {
+		if queued.req.Actor == actor {
+			status.Queued++
+		}
+	}
+	return status, nil
+}
+
+// Usage reports runs and minutes per actor and UTC day, newest day first
+func (s *AgentQuotaService) Usage(filter AgentUsageFilter) ([]AgentUsage, error) {
+	query := s.db.Model(&models.AgentRun{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if err := validateAgentDay("from", filter.From); err != nil {
+		return nil, err
+	}
+	if err := validateAgentDay("to", filter.To); err != nil {
+		return nil, err
+	}
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		return nil, newValidationError("to", "must not be before from")
+	}
+	if filter.From != "" {
+		query = query.Where("day >= ?", filter.From)
+	}
+	if filter.To != "" {
+		query = query.Where("day <= ?", filter.To)
+	}
+
+	usage := make([]AgentUsage, 0)
+	err := query.Select(`day, actor,
+		SUM(CASE WHEN status <> ? THEN 1 ELSE 0 END) AS runs,
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS succeeded,
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed,
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS interrupted,
+		SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS rejected,
+		SUM(duration_ms) / 60000.0 AS minutes,
+		SUM(wait_ms) / 60000.0 AS wait_minutes`,
+		models.AgentRunRejected, models.AgentRunSucceeded, models.AgentRunFailed,
+		models.AgentRunInterrupted, models.AgentRunRejected).
+		Group("day, actor").Order("day DESC, actor").Scan(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CloseInterruptedRuns marks the runs left running by a previous server
+// process as interrupted. It must be called before runs are started.
+func (s *AgentQuotaService) CloseInterruptedRuns() (int64, error) {
+	result := s.db.Model(&models.AgentRun{}).Where("status = ?", models.AgentRunRunning).
+		Updates(map[string]interface{}{
+			"status":      models.AgentRunInterrupted,
+			"finished_at": s.now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// agentUsedToday is how much of the daily budgets an actor has used
+type agentUsedToday struct {
+	Runs     int64
+	Duration time.Duration
+}
+
+func (s *AgentQuotaService) hasBudgets() bool {
+	return s.opts.DailyRuns > 0 || s.opts.DailyMinutes > 0
+}
+
+// checkBudgetLocked returns an AgentBudgetError when one more run would go
+// over a daily budget of the actor. used holds what the database recorded,
+// read under the lock of the actor.
+func (s *AgentQuotaService) checkBudgetLocked(actor string, now time.Time, used agentUsedToday) error {
+	s.addRunningLocked(actor, now, &used)
+	// Queued runs will start, so they count as used
+	for _, queued := range s.queue {
+		if queued.req.Actor == actor {
+			used.Runs++
+		}
+	}
+
+	minutes := used.Duration.Minutes()
+	switch {
+	case s.opts.DailyRuns > 0 && used.Runs >= int64(s.opts.DailyRuns):
+		return &AgentBudgetError{Budget: AgentBudgetRuns, Limit: s.opts.DailyRuns, Used: float64(used.Runs), ResetsAt: nextAgentDay(now)}
+	case s.opts.DailyMinutes > 0 && minutes >= float64(s.opts.DailyMinutes):
+		return &AgentBudgetError{Budget: AgentBudgetMinutes, Limit: s.opts.DailyMinutes, Used: minutes, ResetsAt: nextAgentDay(now)}
+	}
+	return nil
+}
+
+// usedToday reads the runs the database recorded for the actor today and the
+// minutes of those that finished. It must not be called with mu held.
+func (s *AgentQuotaService) usedToday(actor string, now time.Time) (agentUsedToday, error) {
+	var used struct {
+		Runs       int64
+		DurationMs int64
+	}
+	err := s.db.Model(&models.AgentRun{}).
+		Select("COUNT(*) AS runs, COALESCE(SUM(duration_ms), 0) AS duration_ms").
+		Where("actor = ? AND day = ? AND status <> ?", actor, now.UTC().Format(agentRunDayFormat), models.AgentRunRejected).
+		Scan(&used).Error
+	if err != nil {
+		return agentUsedToday{}, err
+	}
+	return agentUsedToday{Runs: used.Runs, Duration: time.Duration(used.DurationMs) * time.Millisecond}, nil
+}
+
+// addRunningLocked adds the runs of the actor holding a slot to used: their
+// time so far, and the runs themselves when they are not recorded yet
+func (s *AgentQuotaService) addRunningLocked(actor string, now time.Time, used *agentUsedToday) {
+	for ticket := range s.running {
+		if ticket.req.Actor != actor {
+			continue
+		}
+		used.Duration += now.Sub(ticket.startedAt)
+		if !ticket.recorded {
+			used.Runs++
+		}
+	}
+}
+
+// lockActor takes the lock of an actor and returns the function releasing it
+func (s *AgentQuotaService) lockActor(actor string) func() {
+	s.actorLocksMu.Lock()
+	lock, ok := s.actorLocks[actor]
+	if !ok {
+		lock = &agentActorLock{}
+		s.actorLocks[actor] = lock
+	}
+	lock.refs++
+	s.actorLocksMu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		s.actorLocksMu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(s.actorLocks, actor)
+		}
+		s.actorLocksMu.Unlock()
+	}
+}
+
+// fitsLocked reports whether a run may take a slot without going over a
+// concurrency limit
+func (s *AgentQuotaService) fitsLocked(req AgentRunRequest) bool {
+	switch {
+	case s.opts.MaxConcurrent > 0 && len(s.running) >= s.opts.MaxConcurrent:
+		return false
+	case s.opts.MaxConcurrentPerActor > 0 && s.perActor[req.Actor] >= s.opts.MaxConcurrentPerActor:
+		return false
+	case s.opts.MaxConcurrentPerProject > 0 && s.perProject[req.Project] >= s.opts.MaxConcurrentPerProject:
+		return false
+	}
+	return true
+}
+
+func (s *AgentQuotaService) admitLocked(ticket *AgentTicket) {
+	ticket.running = true
+	ticket.startedAt = s.now()
+	s.running[ticket] = struct{}{}
+	s.perActor[ticket.req.Actor]++
+	s.perProject[ticket.req.Project]++
+	close(ticket.admitted)
+}
+
+// release frees the slot of a run and starts the queued runs that now fit,
+// oldest first. A queued run blocked by its actor or project limit does not
+// hold back the runs behind it.
+func (s *AgentQuotaService) release(ticket *AgentTicket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.running[ticket]; !ok {
+		return
+	}
+	delete(s.running, ticket)
+	if s.perActor[ticket.req.Actor]--; s.perActor[ticket.req.Actor] <= 0 {
+		delete(s.perActor, ticket.req.Actor)
+	}
+	if s.perProject[ticket.req.Project]--; s.perProject[ticket.req.Project] <= 0 {
+		delete(s.perProject, ticket.req.Project)
+	}
+
+	waiting := s.queue[:0]
+	for _, queued := range s.queue {
+		if s.fitsLocked(queued.req) {
+			s.admitLocked(queued)
+		} else {
+			waiting = append(waiting, queued)
+		}
+	}
+	for i := len(waiting); i < len(s.queue); i++ {
+		s.queue[i] = nil
+	}
+	s.queue = waiting
+}
+
+func (s *AgentQuotaService) removeQueuedLocked(ticket *AgentTicket) {
+	for i, queued := range s.queue {
+		if queued == ticket {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// recordRejected records a run that never started, so that usage reports
+// show how often callers hit the limits
+func (s *AgentQuotaService) recordRejected(ticket *AgentTicket, reason error) {
+	run := &models.AgentRun{
+		Actor:     ticket.req.Actor,
+		Day:       ticket.queuedAt.UTC().Format(agentRunDayFormat),
+		Project:   ticket.req.Project,
+		Kind:      ticket.req.Kind,
+		RequestID: ticket.req.RequestID,
+		Status:    models.AgentRunRejected,
+		Error:     truncateAgentError(reason),
+		WaitMs:    s.now().Sub(ticket.queuedAt).Milliseconds(),
+	}
+	if err := s.db.Create(run).Error; err != nil {
+		logging.Errorf("Failed to record rejected agent run for %s: %v", ticket.req.Actor, err)
+	}
+}
+
+// validateAgentDay checks that a usage report bound is a date, when given
+func validateAgentDay(field, day string) error {
+	if day == "" {
+		return nil
+	}
+	if _, err := time.Parse(agentRunDayFormat, day); err != nil {
+		return newValidationError(field, "expected a date in YYYY-MM-DD form")
+	}
+	return nil
+}
+
+// truncateAgentError shortens an error message to fit the run record,
+// without cutting a character in half
+func truncateAgentError(err error) string {
+	message := err.Error()
+	if len(message) <= agentRunErrorSize {
+		return message
+	}
+	end := agentRunErrorSize
+	for end > 0 && !utf8.RuneStart(message[end]) {
+		end--
+	}
+	return message[:end]
+}
+
+// nextAgentDay returns the start of the UTC day after now, when budgets reset
+func nextAgentDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+// testClock is a settable time source shared with the quota service
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestQuota(t *testing.T, opts AgentQuotaOptions) (*AgentQuotaService, *testClock) {
+	t.Helper()
+	newTestDB(t)
+	// Noon keeps every run of a test on the same UTC day
+	clock := &testClock{now: time.Now().UTC().Truncate(24 * time.Hour).Add(12 * time.Hour)}
+	quota := NewAgentQuotaService(opts)
+	quota.now = clock.Now
+	return quota, clock
+}
+
+// startRun enqueues a run and waits for its slot
+func startRun(t *testing.T, quota *AgentQuotaService, actor, project string) *AgentTicket {
+	t.Helper()
+	ticket, err := quota.Enqueue(AgentRunRequest{Actor: actor, Project: project, Kind: "chat"})
+	if err != nil {
+		t.Fatalf("enqueue %s on %s: %v", actor, project, err)
+	}
+	if err := ticket.Wait(context.Background()); err != nil {
+		t.Fatalf("wait %s on %s: %v", actor, project, err)
+	}
+	return ticket
+}
+
+func enqueueRun(t *testing.T, quota *AgentQuotaService, actor, project string) *AgentTicket {
+	t.Helper()
+	ticket, err := quota.Enqueue(AgentRunRequest{Actor: actor, Project: project, Kind: "chat"})
+	if err != nil {
+		t.Fatalf("enqueue %s on %s: %v", actor, project, err)
+	}
+	return ticket
+}
+
+func isAdmitted(ticket *AgentTicket) bool {
+	select {
+	case <-ticket.admitted:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestAgentQuotaQueue(t *testing.T) {
+	quota, _ := newTestQuota(t, AgentQuotaOptions{MaxConcurrent: 1, QueueSize: 2, QueueTimeout: 5 * time.Second})
+
+	first := startRun(t, quota, "user:alice", "site")
+	if first.Position() != 0 {
+		t.Errorf("first run position %d, want 0", first.Position())
+	}
+	second := enqueueRun(t, quota, "user:bob", "site")
+	third := enqueueRun(t, quota, "user:carol", "site")
+	if second.Position() != 1 || third.Position() != 2 {
+		t.Errorf("queue positions %d and %d, want 1 and 2", second.Position(), third.Position())
+	}
+	if _, err := quota.Enqueue(AgentRunRequest{Actor: "user:dave", Project: "site"}); !errors.Is(err, ErrAgentQueueFull) {
+		t.Errorf("enqueue into a full queue = %v, want ErrAgentQueueFull", err)
+	}
+
+	// Slots are handed out oldest first
+	first.Finish(nil)
+	if !isAdmitted(second) || isAdmitted(third) {
+		t.Fatalf("after the first run: second admitted %v, third admitted %v", isAdmitted(second), isAdmitted(third))
+	}
+	if err := second.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	second.Finish(errors.New("agent crashed"))
+	if err := third.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	third.Finish(nil)
+
+	usage, err := quota.Usage(AgentUsageFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs, succeeded, failed, rejected int64
+	for _, row := range usage {
+		runs += row.Runs
+		succeeded += row.Succeeded
+		failed += row.Failed
+		rejected += row.Rejected
+	}
+	if runs != 3 || succeeded != 2 || failed != 1 || rejected != 1 {
+		t.Errorf("usage %d runs, %d succeeded, %d failed, %d rejected, want 3, 2, 1 and 1", runs, succeeded, failed, rejected)
+	}
+}
+
+func TestAgentQuotaLimitsDoNotBlockOthers(t *testing.T) {
+	quota, _ := newTestQuota(t, AgentQuotaOptions{
+		MaxConcurrent:           2,
+		MaxConcurrentPerActor:   1,
+		MaxConcurrentPerProject: 1,
+		QueueSize:               5,
+	})
+
+	alice := startRun(t, quota, "user:alice", "site")
+	aliceAgain := enqueueRun(t, quota, "user:alice", "docs") // over the limit of alice
+	bobSite := enqueueRun(t, quota, "user:bob", "site")      // over the limit of the project
+	bob := enqueueRun(t, quota, "user:bob", "docs")          // takes the second slot
+	if isAdmitted(aliceAgain) || isAdmitted(bobSite) || !isAdmitted(bob) {
+		t.Fatalf("admitted alice again %v, bob on site %v, bob on docs %v, want only bob on docs",
+			isAdmitted(aliceAgain), isAdmitted(bobSite), isAdmitted(bob))
+	}
+	if err := bob.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	carol := enqueueRun(t, quota, "user:carol", "blog") // over the global limit
+
+	// The runs at the head of the queue are still blocked by their actor
+	// and project, which does not hold back the run behind them
+	bob.Finish(nil)
+	if isAdmitted(aliceAgain) || isAdmitted(bobSite) || !isAdmitted(carol) {
+		t.Fatalf("after bob: admitted alice again %v, bob on site %v, carol %v, want only carol",
+			isAdmitted(aliceAgain), isAdmitted(bobSite), isAdmitted(carol))
+	}
+
+	alice.Finish(nil)
+	if !isAdmitted(aliceAgain) || isAdmitted(bobSite) {
+		t.Errorf("after alice: admitted alice again %v, bob on site %v, want alice again only (global limit)",
+			isAdmitted(aliceAgain), isAdmitted(bobSite))
+	}
+
+	status, err := quota.Status("user:bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Queued != 1 || status.TotalRunning != 2 || status.TotalQueued != 1 {
+		t.Errorf("status %+v, want bob queued once with 2 running", status)
+	}
+}
+
+func TestAgentQuotaQueueTimeout(t *testing.T) {
+	quota, _ := newTestQuota(t, AgentQuotaOptions{MaxConcurrent: 1, QueueSize: 2, QueueTimeout: 50 * time.Millisecond})
+
+	running := startRun(t, quota, "user:alice", "site")
+	defer running.Finish(nil)
+
+	timedOut := enqueueRun(t, quota, "user:bob", "site")
+	if err := timedOut.Wait(context.Background()); !errors.Is(err, ErrAgentQueueTimeout) {
+		t.Errorf("wait = %v, want ErrAgentQueueTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := enqueueRun(t, quota, "user:carol", "site")
+	if err := cancelled.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait = %v, want context.Canceled", err)
+	}
+
+	status, err := quota.Status("user:bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.TotalQueued != 0 {
+		t.Errorf("%d runs left in the queue, want none", status.TotalQueued)
+	}
+	var rejected int64
+	quota.db.Model(&models.AgentRun{}).Where("status = ?", models.AgentRunRejected).Count(&rejected)
+	if rejected != 2 {
+		t.Errorf("%d rejected runs recorded, want 2", rejected)
+	}
+}
+
+func TestAgentQuotaRunBudget(t *testing.T) {
+	quota, _ := newTestQuota(t, AgentQuotaOptions{MaxConcurrent: 1, QueueSize: 5, DailyRuns: 3})
+
+	startRun(t, quota, "user:alice", "site").Finish(nil)
+	running := startRun(t, quota, "user:alice", "site")
+	enqueueRun(t, quota, "user:alice", "site") // queued runs count against the budget
+
+	_, err := quota.Enqueue(AgentRunRequest{Actor: "user:alice", Project: "site"})
+	var budgetErr *AgentBudgetError
+	if !errors.As(err, &budgetErr) || !errors.Is(err, ErrAgentBudgetExceeded) {
+		t.Fatalf("enqueue over the budget = %v, want an AgentBudgetError", err)
+	}
+	if budgetErr.Budget != AgentBudgetRuns || budgetErr.Used != 3 || budgetErr.Limit != 3 {
+		t.Errorf("budget error %+v, want 3 of 3 runs used", budgetErr)
+	}
+
+	// Other actors have their own budget
+	if _, err := quota.Enqueue(AgentRunRequest{Actor: "user:bob", Project: "site"}); err != nil {
+		t.Errorf("enqueue for bob = %v", err)
+	}
+
+	status, err := quota.Status("user:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RunsUsed != 2 || status.Running != 1 || status.Queued != 1 {
+		t.Errorf("status %+v, want 2 runs used, 1 running and 1 queued", status)
+	}
+	running.Finish(nil)
+}
+
+func TestAgentQuotaMinuteBudget(t *testing.T) {
+	quota, clock := newTestQuota(t, AgentQuotaOptions{DailyMinutes: 2})
+
+	run := startRun(t, quota, "user:alice", "site")
+	clock.Advance(90 * time.Second)
+	run.Finish(nil)
+
+	// The time of a run still going counts before it is finished
+	run = startRun(t, quota, "user:alice", "site")
+	clock.Advance(45 * time.Second)
+	_, err := quota.Enqueue(AgentRunRequest{Actor: "user:alice", Project: "site"})
+	var budgetErr *AgentBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Budget != AgentBudgetMinutes {
+		t.Fatalf("enqueue over the minute budget = %v, want a minutes AgentBudgetError", err)
+	}
+	if budgetErr.Used != 2.25 {
+		t.Errorf("used %g minutes, want 2.25", budgetErr.Used)
+	}
+	run.Finish(nil)
+
+	status, err := quota.Status("user:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RunsUsed != 2 || status.MinutesUsed != 2.25 {
+		t.Errorf("status %d runs and %g minutes, want 2 and 2.25", status.RunsUsed, status.MinutesUsed)
+	}
+
+	// The budget resets on the next UTC day
+	clock.Advance(24 * time.Hour)
+	startRun(t, quota, "user:alice", "site").Finish(nil)
+}
+
+func TestAgentQuotaConcurrentBudget(t *testing.T) {
+	quota, _ := newTestQuota(t, AgentQuotaOptions{QueueSize: 20, DailyRuns: 5})
+
+	// Runs enqueued at once by one actor must not together go over the budget
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var tickets []*AgentTicket
+	rejected := 0
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticket, err := quota.Enqueue(AgentRunRequest{Actor: "user:alice", Project: "site"})
+			if err == nil {
+				err = ticket.Wait(context.Background())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				rejected++
+				return
+			}
+			tickets = append(tickets, ticket)
+		}()
+	}
+	wg.Wait()
+
+	if len(tickets) != 5 || rejected != 7 {
+		t.Errorf("%d runs started and %d rejected, want 5 and 7", len(tickets), rejected)
+	}
+	for _, ticket := range tickets {
+		ticket.Finish(nil)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+)
+
+// Notifier names
+const (
+	NotifierWebhook = "webhook"
+	NotifierEmail   = "email"
+	NotifierLog     = "log"
+)
+
+// AlertNotification is sent when an alert fires or resolves
+type AlertNotification struct {
+	Status string       `json:"status"` // models.AlertStatusFiring or models.AlertStatusResolved
+	Alert  models.Alert `json:"alert"`
+	Test   bool         `json:"test,omitempty"` // sent to check the notifier configuration
+}
+
+// Notifier delivers alert notifications
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, notification AlertNotification) error
+}
+
+// notificationSubject summarizes a notification in a single line
+func notificationSubject(n AlertNotification) string {
+	prefix := ""
+	if n.Test {
+		prefix = "[test] "
+	}
+	return fmt.Sprintf("%s[%s] %s %s: %s", prefix, strings.ToUpper(n.Alert.Severity), n.Alert.Rule, n.Status, n.Alert.Message)
+}
+
+// WebhookNotifier posts notifications as JSON to a URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // injectable for tests
+}
+
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Name() string {
+	return NotifierWebhook
+}
+
+// Notify posts the notification and expects a 2xx response
+func (n *WebhookNotifier) Notify(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tion-alerts/1.0")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier sends notifications as plain text mail over SMTP. Without a
+// username it sends without authentication, as to a local relay.
+type EmailNotifier struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *EmailNotifier) Name() string {
+	return NotifierEmail
+}
+
+// Notify sends the notification to every recipient. STARTTLS is used when the
+// server offers it.
+func (n *EmailNotifier) Notify(ctx context.Context, notification AlertNotification) error {
+	var auth smtp.Auth
+	if n.Username != "" {
+		host := n.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", n.Username, n.Password, host)
+	}
+
+	alert := notification.Alert
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\r\n\r\n", alert.Message)
+	fmt.Fprintf(&body, "Rule:      %s\r\n", alert.Rule)
+	fmt.Fprintf(&body, "Status:    %s\r\n", notification.Status)
+	fmt.Fprintf(&body, "Severity:  %s\r\n", alert.Severity)
+	fmt.Fprintf(&body, "Metric:    %s (%s)\r\n", alert.Metric, alert.Condition)
+	fmt.Fprintf(&body, "Value:     %g (rule value %g)\r\n", alert.Value, alert.Threshold)
+	if alert.ToolID != 0 {
+		fmt.Fprintf(&body, "Tool:      %d\r\n", alert.ToolID)
+	}
+	fmt.Fprintf(&body, "Fired at:  %s\r\n", alert.FiredAt.UTC().Format(time.RFC3339))
+	if alert.ResolvedAt != nil {
+		fmt.Fprintf(&body, "Resolved:  %s\r\n", alert.ResolvedAt.UTC().Format(time.RFC3339))
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", notificationSubject(notification))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(body.String())
+
+	// net/smtp has no context support, so the send is abandoned rather than
+	// interrupted when the context ends first
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.Addr, auth, n.From, n.To, []byte(msg.String()))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogNotifier writes notifications to the application log
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string {
+	return NotifierLog
+}
+
+func (LogNotifier) Notify(ctx context.Context, notification AlertNotification) error {
+	if notification.Status == models.AlertStatusFiring && !notification.Test {
+		logging.Warnf("Alert %s", notificationSubject(notification))
+	} else {
+		logging.Infof("Alert %s", notificationSubject(notification))
+	}
+	return nil
+}
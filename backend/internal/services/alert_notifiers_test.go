@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+)
+
+// webhookReceiver records the notifications posted to it and answers with status
+type webhookReceiver struct {
+	mu            sync.Mutex
+	status        int
+	notifications []AlertNotification
+}
+
+func newWebhookReceiver(t *testing.T) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	receiver := &webhookReceiver{status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s with content type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var notification AlertNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.notifications = append(receiver.notifications, notification)
+		w.WriteHeader(receiver.status)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+func (r *webhookReceiver) received() []AlertNotification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]AlertNotification(nil), r.notifications...)
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+// newSMTPTestServer accepts mail on a local port, without TLS or
+// authentication, and passes the data of every message to the channel
+func newSMTPTestServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	return listener.Addr().String(), messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM"), strings.HasPrefix(command, "RCPT TO"):
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			messages <- data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func testNotification() AlertNotification {
+	return AlertNotification{
+		Status: models.AlertStatusFiring,
+		Alert: models.Alert{
+			Fingerprint: "links",
+			Rule:        "links",
+			Metric:      AlertMetricLinksFlagged,
+			Condition:   AlertConditionThreshold,
+			Severity:    AlertSeverityCritical,
+			Status:      models.AlertStatusFiring,
+			Value:       2,
+			Message:     "2 active tools have failing links (> 0)",
+			FiredAt:     time.Now(),
+		},
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	receiver, server := newWebhookReceiver(t)
+	notifier := NewWebhookNotifier(server.URL, time.Second)
+
+	if err := notifier.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	received := receiver.received()
+	if len(received) != 1 || received[0].Alert.Rule != "links" || received[0].Status != models.AlertStatusFiring {
+		t.Fatalf("received %+v, want the firing links alert", received)
+	}
+
+	receiver.setStatus(http.StatusInternalServerError)
+	if err := notifier.Notify(context.Background(), testNotification()); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Notify against a failing receiver = %v, want a status error", err)
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	addr, messages := newSMTPTestServer(t)
+	notifier := &EmailNotifier{Addr: addr, From: "alerts@tion.work", To: []string{"ops@tion.work", "dev@tion.work"}}
+
+	if err := notifier.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messages:
+		for _, want := range []string{
+			"To: ops@tion.work, dev@tion.work",
+			"Subject: [CRITICAL] links firing: 2 active tools have failing links (> 0)",
+			"Metric:    links_flagged (threshold)",
+		} {
+			if !strings.Contains(message, want) {
+				t.Errorf("message lacks %q:\n%s", want, message)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestAlertEngineFiresAndResolves(t *testing.T) {
+	// Failed notifications are logged
+	logging.Logger.SetOutput(io.Discard)
+
+	db := newTestDB(t)
+	receiver, server := newWebhookReceiver(t)
+	addr, messages := newSMTPTestServer(t)
+
+	engine, err := NewAlertEngine(AlertEngineOptions{
+		Rules: []AlertRule{{Name: "links", Metric: AlertMetricLinksFlagged, Operator: ">", Value: 0}},
+		Notifiers: []Notifier{
+			NewWebhookNotifier(server.URL, time.Second),
+			&EmailNotifier{Addr: addr, From: "alerts@tion.work", To: []string{"ops@tion.work"}},
+		},
+		NotifyTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tool := models.Tool{Name: "Broken", URL: "https://broken.example", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&tool).Update("link_flagged", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	evaluate := func() *AlertEvaluation {
+		t.Helper()
+		summary, err := engine.Evaluate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary
+	}
+	expectMail := func(subject string) {
+		t.Helper()
+		select {
+		case message := <-messages:
+			if !strings.Contains(message, subject) {
+				t.Errorf("mail lacks %q:\n%s", subject, message)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no mail with %q", subject)
+		}
+	}
+
+	if summary := evaluate(); summary.Fired != 1 || summary.Firing != 1 {
+		t.Fatalf("first evaluation %+v, want one fired alert", summary)
+	}
+	expectMail("links firing")
+
+	// A rule that keeps matching updates its alert without notifying again
+	if summary := evaluate(); summary.Fired != 0 || summary.Firing != 1 {
+		t.Fatalf("second evaluation %+v, want the alert still firing", summary)
+	}
+	var alert models.Alert
+	if err := db.Where("fingerprint = ?", "links").First(&alert).Error; err != nil {
+		t.Fatal(err)
+	}
+	if alert.Matches != 2 || alert.Status != models.AlertStatusFiring {
+		t.Errorf("alert %+v, want 2 matches while firing", alert)
+	}
+	if received := receiver.received(); len(received) != 1 {
+		t.Errorf("webhook got %d notifications, want 1", len(received))
+	}
+
+	// Failed deliveries are recorded on the alert
+	receiver.setStatus(http.StatusBadGateway)
+	if err := db.Model(&tool).Update("link_flagged", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if summary := evaluate(); summary.Resolved != 1 || summary.Firing != 0 {
+		t.Fatalf("third evaluation %+v, want the alert resolved", summary)
+	}
+	expectMail("links resolved")
+	received := receiver.received()
+	if len(received) != 2 || received[1].Status != models.AlertStatusResolved || received[1].Alert.ResolvedAt == nil {
+		t.Errorf("webhook got %+v, want a resolved notification last", received)
+	}
+	if err := db.First(&alert, alert.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if alert.Status != models.AlertStatusResolved || !strings.Contains(alert.NotifyError, "webhook") {
+		t.Errorf("alert status %s, notify error %q, want resolved with a webhook error", alert.Status, alert.NotifyError)
+	}
+
+	// The next match opens a new alert rather than reopening the resolved one
+	if err := db.Model(&tool).Update("link_flagged", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if summary := evaluate(); summary.Fired != 1 {
+		t.Fatalf("fourth evaluation %+v, want a new alert", summary)
+	}
+	expectMail("links firing")
+	var alerts int64
+	db.Model(&models.Alert{}).Count(&alerts)
+	if alerts != 2 {
+		t.Errorf("%d alerts recorded, want 2", alerts)
+	}
+}
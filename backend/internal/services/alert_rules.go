@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alert metrics
+const (
+	AlertMetricUsage        = "usage"         // tool uses, of one or every tool
+	AlertMetricRequests     = "requests"      // API requests
+	AlertMetricErrors       = "errors"        // API responses with a 5xx status
+	AlertMetricErrorRate    = "error_rate"    // errors per request, 0 to 1
+	AlertMetricLatency      = "latency_ms"    // average request latency
+	AlertMetricLinksFlagged = "links_flagged" // active tools whose link keeps failing
+)
+
+// Alert conditions, each comparing a different value with the rule value
+const (
+	AlertConditionThreshold = "threshold" // the metric over the window
+	AlertConditionChange    = "change"    // percentage change against the same window a week earlier
+	AlertConditionZScore    = "zscore"    // standard deviations from the mean of the preceding windows
+)
+
+// Alert severities
+const (
+	AlertSeverityInfo     = "info"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// defaultAlertHistory is the number of preceding windows z-score rules
+// compare with when the rule does not set it
+const defaultAlertHistory = 24
+
+// AlertRule fires an alert when the value its condition computes for a metric
+// compares with Value according to Operator
+type AlertRule struct {
+	Name      string        `json:"name" yaml:"name"`
+	Metric    string        `json:"metric" yaml:"metric"`
+	Condition string        `json:"condition" yaml:"condition"`
+	Operator  string        `json:"operator" yaml:"operator"` // >, >=, < or <=
+	Value     float64       `json:"value" yaml:"value"`
+	Window    time.Duration `json:"window" yaml:"window"`                   // whole hours for usage, whole minutes otherwise
+	History   int           `json:"history,omitempty" yaml:"history"`       // preceding windows of a z-score rule
+	MinVolume int64         `json:"min_volume,omitempty" yaml:"min_volume"` // uses or requests a window needs before the rule can match
+	ToolID    uint          `json:"tool_id,omitempty" yaml:"tool_id"`       // usage of a single tool
+	PerTool   bool          `json:"per_tool,omitempty" yaml:"per_tool"`     // evaluate the usage of every active tool separately
+	Severity  string        `json:"severity" yaml:"severity"`               // info, warning or critical
+	Notifiers []string      `json:"notifiers,omitempty" yaml:"notifiers"`   // notifier names, every notifier when empty
+}
+
+// alertRuleFile is the layout of an alert rules file
+type alertRuleFile struct {
+	Rules []AlertRule `yaml:"rules"`
+}
+
+// DefaultAlertRules are used when no rules file is configured
+func DefaultAlertRules() []AlertRule {
+	return []AlertRule{
+		{
+			Name:      "usage-spike",
+			Metric:    AlertMetricUsage,
+			Condition: AlertConditionZScore,
+			Operator:  ">",
+			Value:     4,
+			Window:    time.Hour,
+			History:   defaultAlertHistory,
+			MinVolume: 20,
+			Severity:  AlertSeverityWarning,
+		},
+		{
+			Name:      "error-rate",
+			Metric:    AlertMetricErrorRate,
+			Condition: AlertConditionThreshold,
+			Operator:  ">",
+			Value:     0.05,
+			Window:    5 * time.Minute,
+			MinVolume: 20,
+			Severity:  AlertSeverityCritical,
+		},
+		{
+			Name:      "broken-links",
+			Metric:    AlertMetricLinksFlagged,
+			Condition: AlertConditionThreshold,
+			Operator:  ">",
+			Value:     0,
+			Severity:  AlertSeverityWarning,
+		},
+	}
+}
+
+// LoadAlertRules reads the rules of a YAML (or JSON) rules file, or returns
+// the default rules when path is empty
+func LoadAlertRules(path string) ([]AlertRule, error) {
+	if path == "" {
+		return DefaultAlertRules(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %w", err)
+	}
+	var file alertRuleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+// validateAlertRules applies rule defaults and checks every rule, including
+// that it only names configured notifiers
+func validateAlertRules(rules []AlertRule, notifiers map[string]Notifier) error {
+	var problems ValidationErrors
+	seen := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		field := func(name string) string {
+			return fmt.Sprintf("rules[%d].%s", i, name)
+		}
+		invalid := func(name, format string, args ...interface{}) {
+			problems = append(problems, newValidationError(field(name), format, args...).(*ValidationError))
+		}
+
+		if rule.Name == "" {
+			invalid("name", "is required")
+		} else if seen[rule.Name] {
+			invalid("name", "%q is used by another rule", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if rule.Condition == "" {
+			rule.Condition = AlertConditionThreshold
+		}
+		if rule.Severity == "" {
+			rule.Severity = AlertSeverityWarning
+		}
+		if rule.Condition == AlertConditionZScore && rule.History == 0 {
+			rule.History = defaultAlertHistory
+		}
+
+		switch rule.Metric {
+		case AlertMetricUsage:
+			if rule.Window <= 0 || rule.Window%time.Hour != 0 {
+				invalid("window", "must be a whole number of hours for usage")
+			}
+			if rule.PerTool && rule.ToolID != 0 {
+				invalid("per_tool", "cannot be combined with tool_id")
+			}
+		case AlertMetricRequests, AlertMetricErrors, AlertMetricErrorRate, AlertMetricLatency:
+			if rule.Window <= 0 || rule.Window%time.Minute != 0 {
+				invalid("window", "must be a whole number of minutes")
+			}
+		case AlertMetricLinksFlagged:
+			if rule.Condition != AlertConditionThreshold {
+				invalid("condition", "only threshold is supported for %s", rule.Metric)
+			}
+		default:
+			invalid("metric", "unsupported metric %q", rule.Metric)
+		}
+		if rule.Metric != AlertMetricUsage && (rule.ToolID != 0 || rule.PerTool) {
+			invalid("tool_id", "only applies to usage")
+		}
+
+		switch rule.Condition {
+		case AlertConditionThreshold, AlertConditionChange:
+		case AlertConditionZScore:
+			if rule.History < 2 {
+				invalid("history", "must be at least 2")
+			}
+		default:
+			invalid("condition", "unsupported condition %q, expected threshold, change or zscore", rule.Condition)
+		}
+
+		if _, ok := compareOperators[rule.Operator]; !ok {
+			invalid("operator", "unsupported operator %q, expected >, >=, < or <=", rule.Operator)
+		}
+		switch rule.Severity {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+		default:
+			invalid("severity", "unsupported severity %q, expected info, warning or critical", rule.Severity)
+		}
+		if rule.MinVolume < 0 {
+			invalid("min_volume", "must not be negative")
+		}
+		for _, name := range rule.Notifiers {
+			if _, ok := notifiers[name]; !ok {
+				invalid("notifiers", "notifier %q is not configured", name)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// compareOperators maps rule operators to comparisons of a value with the rule value
+var compareOperators = map[string]func(value, limit float64) bool{
+	">":  func(value, limit float64) bool { return value > limit },
+	">=": func(value, limit float64) bool { return value >= limit },
+	"<":  func(value, limit float64) bool { return value < limit },
+	"<=": func(value, limit float64) bool { return value <= limit },
+}
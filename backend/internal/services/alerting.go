@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+)
+
+// alertChangeOffset is how far back change rules look for the window they compare with
+const alertChangeOffset = 7 * 24 * time.Hour
+
+// AlertEngineOptions configures an AlertEngine. Zero values fall back to defaults.
+type AlertEngineOptions struct {
+	Interval      time.Duration // 0 disables scheduled evaluation
+	Rules         []AlertRule
+	Notifiers     []Notifier
+	Metrics       *RequestMetrics // source of the request metrics, rules on them are skipped without it
+	NotifyTimeout time.Duration   // per notifier and notification
+}
+
+// AlertEvaluation reports the outcome of evaluating every alert rule
+type AlertEvaluation struct {
+	Rules       int           `json:"rules"`
+	Skipped     int           `json:"skipped"` // rules without enough data, their alerts are kept as they are
+	Fired       int           `json:"fired"`
+	Resolved    int           `json:"resolved"`
+	Firing      int           `json:"firing"`
+	EvaluatedAt time.Time     `json:"evaluated_at"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// AlertPage is a single page of alerts
+type AlertPage struct {
+	Alerts []models.Alert `json:"alerts"`
+	Total  int64          `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// NotifierResult is the outcome of sending a test notification
+type NotifierResult struct {
+	Notifier string `json:"notifier"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ruleResult is the value of a rule for one fingerprint
+type ruleResult struct {
+	fingerprint string
+	toolID      uint
+	value       float64 // value compared with the rule value
+	matched     bool
+	message     string
+}
+
+// AlertEngine periodically evaluates alert rules over the usage rollups, the
+// request metrics and the link checks. An alert is opened and notified when a
+// rule starts matching, kept while it matches and resolved, again with a
+// notification, once it no longer does.
+type AlertEngine struct {
+	db        *gorm.DB
+	stats     *StatsService
+	opts      AlertEngineOptions
+	notifiers map[string]Notifier
+	mu        sync.Mutex
+}
+
+// NewAlertEngine validates the rules against the configured notifiers
+func NewAlertEngine(opts AlertEngineOptions) (*AlertEngine, error) {
+	if opts.NotifyTimeout <= 0 {
+		opts.NotifyTimeout = 10 * time.Second
+	}
+
+	notifiers := make(map[string]Notifier, len(opts.Notifiers))
+	for _, notifier := range opts.Notifiers {
+		notifiers[notifier.Name()] = notifier
+	}
+	rules := append([]AlertRule(nil), opts.Rules...)
+	if err := validateAlertRules(rules, notifiers); err != nil {
+		return nil, err
+	}
+	opts.Rules = rules
+
+	return &AlertEngine{
+		db:        database.GetDB(),
+		stats:     NewStatsService(),
+		opts:      opts,
+		notifiers: notifiers,
+	}, nil
+}
+
+// Start evaluates the rules every interval until the context is cancelled.
+// It does nothing when the interval is not positive.
+func (e *AlertEngine) Start(ctx context.Context) {
+	if e.opts.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(e.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := e.Evaluate(ctx); err != nil {
+					logging.Errorf("Alert evaluation failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Rules returns the configured alert rules
+func (e *AlertEngine) Rules() []AlertRule {
+	return e.opts.Rules
+}
+
+// Evaluate evaluates every rule once, opening, updating and resolving alerts
+func (e *AlertEngine) Evaluate(ctx context.Context) (*AlertEvaluation, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	started := time.Now()
+	summary := &AlertEvaluation{Rules: len(e.opts.Rules), EvaluatedAt: started.UTC()}
+
+	var open []models.Alert
+	if err := e.db.Where("status = ?", models.AlertStatusFiring).Find(&open).Error; err != nil {
+		return nil, err
+	}
+	firing := make(map[string]*models.Alert, len(open))
+	for i := range open {
+		firing[open[i].Fingerprint] = &open[i]
+	}
+
+	seen := make(map[string]bool)
+	skipped := make(map[string]bool)
+	for _, rule := range e.opts.Rules {
+		results, ok, err := e.evaluateRule(rule, started)
+		if err != nil {
+			logging.Errorf("Failed to evaluate alert rule %s: %v", rule.Name, err)
+		}
+		if err != nil || !ok {
+			summary.Skipped++
+			skipped[rule.Name] = true
+			continue
+		}
+
+		for _, result := range results {
+			seen[result.fingerprint] = true
+			alert := firing[result.fingerprint]
+			switch {
+			case result.matched && alert == nil:
+				alert = &models.Alert{
+					Fingerprint: result.fingerprint,
+					Rule:        rule.Name,
+					ToolID:      result.toolID,
+					Metric:      rule.Metric,
+					Condition:   rule.Condition,
+					Severity:    rule.Severity,
+					Status:      models.AlertStatusFiring,
+					Value:       result.value,
+					Threshold:   rule.Value,
+					Message:     result.message,
+					Matches:     1,
+					FiredAt:     started,
+					LastSeenAt:  started,
+				}
+				if err := e.db.Create(alert).Error; err != nil {
+					return nil, err
+				}
+				firing[result.fingerprint] = alert
+				summary.Fired++
+				if err := e.notify(ctx, rule.Notifiers, alert); err != nil {
+					return nil, err
+				}
+			case result.matched:
+				if err := e.db.Model(alert).Updates(map[string]interface{}{
+					"value":        result.value,
+					"message":      result.message,
+					"matches":      gorm.Expr("matches + 1"),
+					"last_seen_at": started,
+				}).Error; err != nil {
+					return nil, err
+				}
+			case alert != nil:
+				if err := e.resolve(ctx, rule.Notifiers, alert, started); err != nil {
+					return nil, err
+				}
+				delete(firing, result.fingerprint)
+				summary.Resolved++
+			}
+		}
+	}
+
+	// Alerts of removed rules and deleted tools are no longer evaluated
+	for fingerprint, alert := range firing {
+		if seen[fingerprint] || skipped[alert.Rule] {
+			continue
+		}
+		if err := e.resolve(ctx, nil, alert, started); err != nil {
+			return nil, err
+		}
+		delete(firing, fingerprint)
+		summary.Resolved++
+	}
+
+	summary.Firing = len(firing)
+	summary.Duration = time.Since(started)
+	return summary, nil
+}
+
+// ListAlerts gets alerts, optionally only those with a status, newest first
+func (e *AlertEngine) ListAlerts(status string, limit, offset int) (*AlertPage, error) {
+	limit = clampLimit(limit)
+	if offset < 0 {
+		return nil, newValidationError("offset", "must not be negative")
+	}
+
+	query := e.db.Model(&models.Alert{})
+	switch status {
+	case "":
+	case models.AlertStatusFiring, models.AlertStatusResolved:
+		query = query.Where("status = ?", status)
+	default:
+		return nil, newValidationError("status", "must be %q or %q", models.AlertStatusFiring, models.AlertStatusResolved)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0)
+	if err := query.Order("fired_at DESC, id DESC").Offset(offset).Limit(limit).Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+
+	return &AlertPage{Alerts: alerts, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// TestNotifiers sends a test notification through one notifier, or through
+// every notifier when name is empty, and reports the outcome of each
+func (e *AlertEngine) TestNotifiers(ctx context.Context, name string) ([]NotifierResult, error) {
+	notifiers := e.opts.Notifiers
+	if name != "" {
+		notifier, ok := e.notifiers[name]
+		if !ok {
+			return nil, newValidationError("notifier", "notifier %q is not configured", name)
+		}
+		notifiers = []Notifier{notifier}
+	}
+
+	now := time.Now()
+	notification := AlertNotification{
+		Status: models.AlertStatusFiring,
+		Test:   true,
+		Alert: models.Alert{
+			Fingerprint: "test",
+			Rule:        "test",
+			Metric:      AlertMetricRequests,
+			Condition:   AlertConditionThreshold,
+			Severity:    AlertSeverityInfo,
+			Status:      models.AlertStatusFiring,
+			Message:     "Test notification, no action needed",
+			FiredAt:     now,
+			LastSeenAt:  now,
+		},
+	}
+
+	results := make([]NotifierResult, 0, len(notifiers))
+	for _, notifier := range notifiers {
+		result := NotifierResult{Notifier: notifier.Name(), OK: true}
+		if err := e.send(ctx, notifier, notification); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// resolve marks a firing alert as resolved and sends the notification
+func (e *AlertEngine) resolve(ctx context.Context, names []string, alert *models.Alert, now time.Time) error {
+	alert.Status = models.AlertStatusResolved
+	alert.ResolvedAt = &now
+	if err := e.db.Model(alert).Updates(map[string]interface{}{
+		"status":      alert.Status,
+		"resolved_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	return e.notify(ctx, names, alert)
+}
+
+// notify sends the state of an alert to the named notifiers, or to all of
+// them, and records delivery failures on the alert
+func (e *AlertEngine) notify(ctx context.Context, names []string, alert *models.Alert) error {
+	notifiers := e.opts.Notifiers
+	if len(names) > 0 {
+		notifiers = make([]Notifier, 0, len(names))
+		for _, name := range names {
+			notifiers = append(notifiers, e.notifiers[name])
+		}
+	}
+
+	notification := AlertNotification{Status: alert.Status, Alert: *alert}
+	var failures []string
+	for _, notifier := range notifiers {
+		if err := e.send(ctx, notifier, notification); err != nil {
+			logging.Errorf("Failed to send alert %s to %s: %v", alert.Fingerprint, notifier.Name(), err)
+			failures = append(failures, notifier.Name()+": "+err.Error())
+		}
+	}
+
+	notifyError := strings.Join(failures, "; ")
+	if notifyError == alert.NotifyError {
+		return nil
+	}
+	alert.NotifyError = notifyError
+	return e.db.Model(alert).Update("notify_error", notifyError).Error
+}
+
+// send delivers a notification within the notify timeout
+func (e *AlertEngine) send(ctx context.Context, notifier Notifier, notification AlertNotification) error {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.NotifyTimeout)
+	defer cancel()
+	return notifier.Notify(ctx, notification)
+}
+
+// evaluateRule computes the value of a rule for each of its fingerprints. It
+// reports false when the data the rule needs is not available.
+func (e *AlertEngine) evaluateRule(rule AlertRule, now time.Time) ([]ruleResult, bool, error) {
+	if rule.Metric == AlertMetricLinksFlagged {
+		var flagged int64
+		if err := e.db.Model(&models.Tool{}).
+			Where("is_active = ? AND link_flagged = ?", true, true).
+			Count(&flagged).Error; err != nil {
+			return nil, false, err
+		}
+		value := float64(flagged)
+		return []ruleResult{{
+			fingerprint: rule.Name,
+			value:       value,
+			matched:     compareOperators[rule.Operator](value, rule.Value),
+			message:     fmt.Sprintf("%d active tools have failing links (%s %g)", flagged, rule.Operator, rule.Value),
+		}}, true, nil
+	}
+
+	// Window starts, the current window first, then the week-old window of
+	// a change rule or the preceding windows of a z-score rule
+	var end time.Time
+	if rule.Metric == AlertMetricUsage {
+		// Rolled up usage is only available in whole hours
+		end = now.UTC().Truncate(time.Hour)
+	} else {
+		end = now.UTC().Truncate(time.Minute)
+	}
+	starts := []time.Time{end.Add(-rule.Window)}
+	switch rule.Condition {
+	case AlertConditionChange:
+		starts = append(starts, starts[0].Add(-alertChangeOffset))
+	case AlertConditionZScore:
+		for i := 1; i <= rule.History; i++ {
+			starts = append(starts, starts[0].Add(-time.Duration(i)*rule.Window))
+		}
+	}
+
+	var series map[uint][]float64
+	var volumes map[uint]int64
+	var err error
+	if rule.Metric == AlertMetricUsage {
+		series, volumes, err = e.usageSeries(rule, starts)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		var ok bool
+		if series, volumes, ok = e.requestSeries(rule, starts); !ok {
+			return nil, false, nil
+		}
+	}
+
+	results := make([]ruleResult, 0, len(series))
+	for toolID, values := range series {
+		result := ruleResult{fingerprint: rule.Name, toolID: toolID}
+		subject := rule.Metric
+		if toolID != 0 {
+			result.fingerprint = fmt.Sprintf("%s:tool=%d", rule.Name, toolID)
+			subject = fmt.Sprintf("%s of tool %d", rule.Metric, toolID)
+		}
+		current := values[0]
+		description := fmt.Sprintf("%s over %s is %s", subject, formatAlertWindow(rule.Window), formatAlertValue(current))
+
+		comparable := true
+		switch rule.Condition {
+		case AlertConditionThreshold:
+			result.value = current
+		case AlertConditionChange:
+			if values[1] == 0 {
+				// No change can be computed against an empty week-old window
+				comparable = false
+				description += ", nothing a week ago"
+				break
+			}
+			result.value = (current - values[1]) / values[1] * 100
+			description += fmt.Sprintf(", %+.1f%% against a week ago", result.value)
+		case AlertConditionZScore:
+			mean, std := meanStdDev(values[1:])
+			result.value = (current - mean) / math.Max(std, minAlertStdDev(rule.Metric))
+			description += fmt.Sprintf(", z-score %.2f against the previous %d windows", result.value, rule.History)
+		}
+		result.matched = comparable && volumes[toolID] >= rule.MinVolume &&
+			compareOperators[rule.Operator](result.value, rule.Value)
+		result.message = fmt.Sprintf("%s (%s %g)", description, rule.Operator, rule.Value)
+		results = append(results, result)
+	}
+	return results, true, nil
+}
+
+// usageSeries sums the usage of each window, per active tool for per-tool
+// rules and under tool 0 otherwise. Volumes are the uses of the current window.
+func (e *AlertEngine) usageSeries(rule AlertRule, starts []time.Time) (map[uint][]float64, map[uint]int64, error) {
+	var toolIDs []uint
+	if rule.ToolID != 0 {
+		toolIDs = []uint{rule.ToolID}
+	}
+
+	series := make(map[uint][]float64)
+	if rule.PerTool {
+		var active []uint
+		if err := e.db.Model(&models.Tool{}).Where("is_active = ?", true).Pluck("id", &active).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, id := range active {
+			series[id] = make([]float64, len(starts))
+		}
+	} else {
+		series[0] = make([]float64, len(starts))
+	}
+
+	from, to := starts[0], starts[0].Add(rule.Window)
+	for _, start := range starts {
+		from = minTime(from, start)
+	}
+	counts, err := e.stats.usageCounts(from, to, toolIDs, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	volumes := make(map[uint]int64)
+	for _, count := range counts {
+		key := uint(0)
+		if rule.PerTool {
+			key = count.ToolID
+		}
+		values, ok := series[key]
+		if !ok {
+			continue
+		}
+		slot := time.Unix(count.Start, 0)
+		for i, start := range starts {
+			if !slot.Before(start) && slot.Before(start.Add(rule.Window)) {
+				values[i] += float64(count.Count)
+				if i == 0 {
+					volumes[key] += count.Count
+				}
+			}
+		}
+	}
+	return series, volumes, nil
+}
+
+// requestSeries computes the request metric of each window. It reports false
+// when a window was not recorded.
+func (e *AlertEngine) requestSeries(rule AlertRule, starts []time.Time) (map[uint][]float64, map[uint]int64, bool) {
+	if e.opts.Metrics == nil {
+		return nil, nil, false
+	}
+
+	values := make([]float64, len(starts))
+	var volume int64
+	for i, start := range starts {
+		window, ok := e.opts.Metrics.Window(start, start.Add(rule.Window))
+		if !ok {
+			return nil, nil, false
+		}
+		if i == 0 {
+			volume = window.Requests
+		}
+
+		switch rule.Metric {
+		case AlertMetricRequests:
+			values[i] = float64(window.Requests)
+		case AlertMetricErrors:
+			values[i] = float64(window.Errors)
+		case AlertMetricErrorRate:
+			if window.Requests > 0 {
+				values[i] = float64(window.Errors) / float64(window.Requests)
+			}
+		case AlertMetricLatency:
+			if window.Requests > 0 {
+				values[i] = float64(window.LatencyMs) / float64(window.Requests)
+			}
+		}
+	}
+	return map[uint][]float64{0: values}, map[uint]int64{0: volume}, true
+}
+
+// minAlertStdDev is the smallest standard deviation z-scores divide by, so
+// that a flat history does not turn the slightest change into a spike
+func minAlertStdDev(metric string) float64 {
+	if metric == AlertMetricErrorRate {
+		return 0.01
+	}
+	return 1
+}
+
+// meanStdDev returns the mean and population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// formatAlertWindow formats a window without trailing zero units, e.g. 1h or 5m
+func formatAlertWindow(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatAlertValue formats a metric value for alert messages
+func formatAlertValue(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3g", value)
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyTouchInterval limits how often the last use of a key is written for
+	// requests from the same address
+	apiKeyTouchInterval = time.Minute
+
+	// DefaultRotationGrace is how long a rotated key keeps working by default
+	DefaultRotationGrace = 24 * time.Hour
+
+	// maxRotationGrace bounds the grace period of a rotation
+	maxRotationGrace = 30 * 24 * time.Hour
+
+	auditTargetAPIKey = "api_key"
+)
+
+// API key states
+const (
+	APIKeyActive   = "active"
+	APIKeyRotating = "rotating" // rotated, works until its revocation time
+	APIKeyExpired  = "expired"
+	APIKeyRevoked  = "revoked"
+)
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		db: database.GetDB(),
+	}
+}
+
+// APIKeyInput describes an API key to create
+type APIKeyInput struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// APIKeyUpdate changes the given fields of an API key
+type APIKeyUpdate struct {
+	Name         *string    `json:"name"`
+	Description  *string    `json:"description"`
+	Scopes       []string   `json:"scopes"` // nil keeps the scopes
+	ExpiresAt    *time.Time `json:"expires_at"`
+	NeverExpires bool       `json:"never_expires"` // removes the expiry
+}
+
+// APIKeyView is an API key as listed, with its masked form and state
+type APIKeyView struct {
+	models.APIKey
+	MaskedKey string `json:"masked_key"`
+	Status    string `json:"status"`
+}
+
+// CreatedAPIKey is a new API key with the plain key, which is not stored and
+// cannot be shown again
+type CreatedAPIKey struct {
+	APIKey APIKeyView `json:"api_key"`
+	Key    string     `json:"key"`
+}
+
+// ListAPIKeys gets the API keys, newest first. Revoked and expired keys are
+// left out unless includeInactive is set.
+func (s *APIKeyService) ListAPIKeys(includeInactive bool) ([]APIKeyView, error) {
+	query := s.db.Order("id DESC")
+	if !includeInactive {
+		now := time.Now()
+		query = query.Where("(revoked_at IS NULL OR revoked_at > ?) AND (expires_at IS NULL OR expires_at > ?)", now, now)
+	}
+
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	views := make([]APIKeyView, 0, len(keys))
+	for _, key := range keys {
+		views = append(views, newAPIKeyView(key))
+	}
+	return views, nil
+}
+
+// GetAPIKey gets an API key by ID
+func (s *APIKeyService) GetAPIKey(id uint) (*APIKeyView, error) {
+	key, err := s.findAPIKey(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	view := newAPIKeyView(*key)
+	return &view, nil
+}
+
+// CreateAPIKey generates and stores a key
+func (s *APIKeyService) CreateAPIKey(input APIKeyInput, actor string) (*CreatedAPIKey, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, newValidationError("name", "is required")
+	}
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, newValidationError("expires_at", "must be in the future")
+	}
+
+	plain, prefix, err := utils.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{
+		Name:        name,
+		Description: input.Description,
+		Prefix:      prefix,
+		KeyHash:     utils.HashAPIKey(plain),
+		Scopes:      scopes,
+		ExpiresAt:   input.ExpiresAt,
+		CreatedBy:   actor,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditAPIKeyCreate, auditTargetAPIKey, apiKeyTarget(key.ID), models.AuditDetails{
+			"name":       key.Name,
+			"prefix":     key.Prefix,
+			"scopes":     key.Scopes,
+			"expires_at": key.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: newAPIKeyView(*key), Key: plain}, nil
+}
+
+// UpdateAPIKey changes the name, description, scopes or expiry of a key that
+// has not been revoked
+func (s *APIKeyService) UpdateAPIKey(id uint, update APIKeyUpdate, actor string) (*APIKeyView, error) {
+	updates := make(map[string]interface{})
+	details := models.AuditDetails{}
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, newValidationError("name", "must not be empty")
+		}
+		updates["name"] = name
+	}
+	if update.Description != nil {
+		updates["description"] = *update.Description
+	}
+	if update.Scopes != nil {
+		scopes, err := normalizeScopes(update.Scopes)
+		if err != nil {
+			return nil, err
+		}
+		updates["scopes"] = scopes
+	}
+	switch {
+	case update.NeverExpires && update.ExpiresAt != nil:
+		return nil, newValidationError("expires_at", "cannot be combined with never_expires")
+	case update.NeverExpires:
+		updates["expires_at"] = nil
+	case update.ExpiresAt != nil:
+		if !update.ExpiresAt.After(time.Now()) {
+			return nil, newValidationError("expires_at", "must be in the future")
+		}
+		updates["expires_at"] = *update.ExpiresAt
+	}
+	if len(updates) == 0 {
+		return nil, newValidationError("update", "no fields to update")
+	}
+
+	var key *models.APIKey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if key, err = s.findAPIKey(tx, id); err != nil {
+			return err
+		}
+		if key.RevokedAt != nil && !key.RevokedAt.After(time.Now()) {
+			return ErrAPIKeyRevoked
+		}
+
+		for column, value := range updates {
+			details[column] = map[string]interface{}{"old": apiKeyColumn(key, column), "new": value}
+		}
+		if err := tx.Model(key).Updates(updates).Error; err != nil {
+			return err
+		}
+		if key, err = s.findAPIKey(tx, id); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditAPIKeyUpdate, auditTargetAPIKey, apiKeyTarget(id), details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	view := newAPIKeyView(*key)
+	return &view, nil
+}
+
+// RotateAPIKey replaces a key with a new one carrying the same name, scopes,
+// expiry and project grants. The old key keeps working for the grace period,
+// so that clients can switch over; a zero grace revokes it right away.
+func (s *APIKeyService) RotateAPIKey(id uint, grace time.Duration, actor string) (*CreatedAPIKey, error) {
+	if grace < 0 || grace > maxRotationGrace {
+		return nil, newValidationError("grace", "must be between 0 and %s", maxRotationGrace)
+	}
+
+	plain, prefix, err := utils.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var replacement *models.APIKey
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		old, err := s.findAPIKey(tx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if old.RevokedAt != nil && !old.RevokedAt.After(now) {
+			return ErrAPIKeyRevoked
+		}
+		if old.ExpiresAt != nil && !old.ExpiresAt.After(now) {
+			return ErrAPIKeyExpired
+		}
+
+		replacement = &models.APIKey{
+			Name:        old.Name,
+			Description: old.Description,
+			Prefix:      prefix,
+			KeyHash:     utils.HashAPIKey(plain),
+			Scopes:      old.Scopes,
+			ExpiresAt:   old.ExpiresAt,
+			CreatedBy:   actor,
+			RotatedFrom: &old.ID,
+		}
+		if err := tx.Create(replacement).Error; err != nil {
+			return err
+		}
+
+		// The new key takes over the project rights of the old one
+		var grants []models.ProjectGrant
+		if err := tx.Where("subject_type = ? AND subject_id = ?", models.GrantSubjectAPIKey, old.ID).Find(&grants).Error; err != nil {
+			return err
+		}
+		for _, grant := range grants {
+			grant.ID = 0
+			grant.SubjectID = replacement.ID
+			grant.GrantedBy = actor
+			if err := tx.Create(&grant).Error; err != nil {
+				return err
+			}
+		}
+
+		// A key already being rotated keeps the earlier cut-off
+		revokeAt := now.Add(grace)
+		if old.RevokedAt != nil && old.RevokedAt.Before(revokeAt) {
+			revokeAt = *old.RevokedAt
+		}
+		if err := tx.Model(old).Update("revoked_at", revokeAt).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditAPIKeyRotate, auditTargetAPIKey, apiKeyTarget(old.ID), models.AuditDetails{
+			"new_key_id":     replacement.ID,
+			"new_prefix":     replacement.Prefix,
+			"old_revoked_at": revokeAt,
+			"grace":          grace.String(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: newAPIKeyView(*replacement), Key: plain}, nil
+}
+
+// RevokeAPIKey revokes a key right away, also ending the grace period of a
+// rotated key. Revoking a revoked key keeps the original revocation time.
+func (s *APIKeyService) RevokeAPIKey(id uint, actor string) (*APIKeyView, error) {
+	var key *models.APIKey
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if key, err = s.findAPIKey(tx, id); err != nil {
+			return err
+		}
+		now := time.Now()
+		if key.RevokedAt != nil && !key.RevokedAt.After(now) {
+			return nil
+		}
+
+		key.RevokedAt = &now
+		if err := tx.Model(key).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditAPIKeyRevoke, auditTargetAPIKey, apiKeyTarget(id), models.AuditDetails{
+			"prefix": key.Prefix,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	view := newAPIKeyView(*key)
+	return &view, nil
+}
+
+// Authenticate looks up the key presented by a client and records its use
+// from ip. Unknown, expired and revoked keys are rejected.
+func (s *APIKeyService) Authenticate(plain, ip string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("key_hash = ?", utils.HashAPIKey(plain)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil && !key.RevokedAt.After(now) {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if key.LastUsedAt == nil || key.LastUsedIP != ip || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// UpdateColumns leaves updated_at to changes of the key itself
+		if err := s.db.Model(&key).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error; err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = ip
+	}
+	return &key, nil
+}
+
+func (s *APIKeyService) findAPIKey(tx *gorm.DB, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := tx.First(&key, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// newAPIKeyView masks a key and works out its state
+func newAPIKeyView(key models.APIKey) APIKeyView {
+	view := APIKeyView{APIKey: key, MaskedKey: key.Prefix + "_" + strings.Repeat("*", 8), Status: APIKeyActive}
+	now := time.Now()
+	switch {
+	case key.RevokedAt != nil && !key.RevokedAt.After(now):
+		view.Status = APIKeyRevoked
+	case key.ExpiresAt != nil && !key.ExpiresAt.After(now):
+		view.Status = APIKeyExpired
+	case key.RevokedAt != nil:
+		view.Status = APIKeyRotating
+	}
+	return view
+}
+
+// apiKeyColumn returns the current value of an updatable column for the audit log
+func apiKeyColumn(key *models.APIKey, column string) interface{} {
+	switch column {
+	case "name":
+		return key.Name
+	case "description":
+		return key.Description
+	case "scopes":
+		return key.Scopes
+	case "expires_at":
+		return key.ExpiresAt
+	}
+	return nil
+}
+
+func apiKeyTarget(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// normalizeScopes validates scopes and removes duplicates
+func normalizeScopes(scopes []string) (models.ScopeList, error) {
+	if len(scopes) == 0 {
+		return nil, newValidationError("scopes", "at least one scope is required")
+	}
+	list := make(models.ScopeList, 0, len(scopes))
+	for _, scope := range uniqueStrings(scopes) {
+		if !isScope(scope) {
+			return nil, newValidationError("scopes", "unknown scope %q, expected one of %s", scope, strings.Join(models.Scopes, ", "))
+		}
+		list = append(list, scope)
+	}
+	return list, nil
+}
+
+func isScope(scope string) bool {
+	for _, known := range models.Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
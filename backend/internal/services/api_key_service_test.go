@@ -0,0 +1,385 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService()
+
+	past := time.Now().Add(-time.Hour)
+	invalid := []struct {
+		input APIKeyInput
+		field string
+	}{
+		{APIKeyInput{Name: " ", Scopes: []string{models.ScopeAdmin}}, "name"},
+		{APIKeyInput{Name: "ci"}, "scopes"},
+		{APIKeyInput{Name: "ci", Scopes: []string{"tools:delete"}}, "scopes"},
+		{APIKeyInput{Name: "ci", Scopes: []string{models.ScopeAdmin}, ExpiresAt: &past}, "expires_at"},
+	}
+	for _, tt := range invalid {
+		if _, err := keys.CreateAPIKey(tt.input, "test"); !isValidationError(err, tt.field) {
+			t.Errorf("%+v = %v, want a %s validation error", tt.input, err, tt.field)
+		}
+	}
+
+	created, err := keys.CreateAPIKey(APIKeyInput{
+		Name:   " ci ",
+		Scopes: []string{models.ScopeStatsRead, models.ScopeToolsRead, models.ScopeStatsRead},
+	}, "api-key:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, plain := created.APIKey, created.Key
+	if !strings.HasPrefix(plain, key.Prefix+"_") || !strings.HasPrefix(key.Prefix, "tion_") {
+		t.Errorf("key %q with prefix %q, want tion_<id>_<secret>", plain, key.Prefix)
+	}
+	if key.Name != "ci" || fmt.Sprint(key.Scopes) != "[stats:read tools:read]" || key.CreatedBy != "api-key:admin" {
+		t.Errorf("created key %+v", key)
+	}
+	if key.Status != APIKeyActive || key.MaskedKey != key.Prefix+"_********" {
+		t.Errorf("created key %s shown as %s, want active and masked", key.Status, key.MaskedKey)
+	}
+
+	// Only the hash of the key is stored
+	var stored models.APIKey
+	if err := db.First(&stored, key.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.KeyHash != utils.HashAPIKey(plain) || strings.Contains(stored.KeyHash, plain) {
+		t.Errorf("stored hash %q is not the hash of the key", stored.KeyHash)
+	}
+	if fmt.Sprint(stored.Scopes) != "[stats:read tools:read]" {
+		t.Errorf("stored scopes %v", stored.Scopes)
+	}
+
+	entries := auditEntries(t, db)
+	if len(entries) != 1 || entries[0].Action != models.AuditAPIKeyCreate || entries[0].Actor != "api-key:admin" ||
+		entries[0].TargetID != fmt.Sprint(key.ID) || entries[0].Details["prefix"] != key.Prefix {
+		t.Errorf("audit log %+v, want the creation of key %d", entries, key.ID)
+	}
+	if fmt.Sprint(entries[0].Details) != fmt.Sprint(models.AuditDetails{"name": "ci", "prefix": key.Prefix, "scopes": []interface{}{"stats:read", "tools:read"}, "expires_at": nil}) {
+		t.Errorf("audit details %v, want the name, prefix, scopes and expiry only", entries[0].Details)
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService()
+	created, err := keys.CreateAPIKey(APIKeyInput{Name: "ci", Scopes: []string{models.ScopeToolsWrite}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, plain := created.APIKey, created.Key
+
+	for _, wrong := range []string{"", key.Prefix, plain + "x", utils.HashAPIKey(plain)} {
+		if _, err := keys.Authenticate(wrong, "203.0.113.1"); !errors.Is(err, ErrAPIKeyInvalid) {
+			t.Errorf("authenticate %q = %v, want ErrAPIKeyInvalid", wrong, err)
+		}
+	}
+
+	authenticated, err := keys.Authenticate(plain, "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated.ID != key.ID || !authenticated.Scopes.Allows(models.ScopeToolsRead) || authenticated.Scopes.Allows(models.ScopeStatsRead) {
+		t.Errorf("authenticated %+v, want key %d with tools scopes", authenticated, key.ID)
+	}
+	lastUsed := func() models.APIKey {
+		t.Helper()
+		var stored models.APIKey
+		if err := db.First(&stored, key.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+	first := lastUsed()
+	if first.LastUsedAt == nil || first.LastUsedIP != "203.0.113.1" {
+		t.Fatalf("last use %v from %q, want the first request", first.LastUsedAt, first.LastUsedIP)
+	}
+
+	// Repeated requests from the same address are written once a minute
+	if _, err := keys.Authenticate(plain, "203.0.113.1"); err != nil {
+		t.Fatal(err)
+	}
+	if again := lastUsed(); !again.LastUsedAt.Equal(*first.LastUsedAt) {
+		t.Errorf("last use moved to %v within a minute", again.LastUsedAt)
+	}
+	if _, err := keys.Authenticate(plain, "203.0.113.2"); err != nil {
+		t.Fatal(err)
+	}
+	if moved := lastUsed(); moved.LastUsedIP != "203.0.113.2" {
+		t.Errorf("last use from %q, want the new address", moved.LastUsedIP)
+	}
+
+	if err := db.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("expires_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(plain, "203.0.113.1"); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("authenticate expired key = %v, want ErrAPIKeyExpired", err)
+	}
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService()
+	created, err := keys.CreateAPIKey(APIKeyInput{Name: "ci", Scopes: []string{models.ScopeAdmin}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, plain := created.APIKey, created.Key
+
+	revoked, err := keys.RevokeAPIKey(key.ID, "user:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked.RevokedAt == nil || revoked.Status != APIKeyRevoked {
+		t.Fatalf("revoked key %s at %v, want revoked now", revoked.Status, revoked.RevokedAt)
+	}
+	if _, err := keys.Authenticate(plain, "203.0.113.1"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("authenticate revoked key = %v, want ErrAPIKeyRevoked", err)
+	}
+
+	// Revoking again keeps the original time
+	again, err := keys.RevokeAPIKey(key.ID, "user:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("revoked again at %v, want %v", again.RevokedAt, revoked.RevokedAt)
+	}
+	if got := auditActions(auditEntries(t, db)); got != "[api_key.create api_key.revoke]" {
+		t.Errorf("audit actions %s, want a single revocation", got)
+	}
+
+	if _, err := keys.RevokeAPIKey(key.ID+1, "user:admin"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("revoke missing key = %v, want ErrAPIKeyNotFound", err)
+	}
+	if _, err := keys.UpdateAPIKey(key.ID, APIKeyUpdate{Scopes: []string{models.ScopeStatsRead}}, "user:admin"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("update revoked key = %v, want ErrAPIKeyRevoked", err)
+	}
+	if _, err := keys.RotateAPIKey(key.ID, time.Hour, "user:admin"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("rotate revoked key = %v, want ErrAPIKeyRevoked", err)
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService()
+	expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	created, err := keys.CreateAPIKey(APIKeyInput{Name: "deploy", Scopes: []string{models.ScopeToolsWrite}, ExpiresAt: &expires}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := created.APIKey
+
+	for _, grace := range []time.Duration{-time.Second, 31 * 24 * time.Hour} {
+		if _, err := keys.RotateAPIKey(old.ID, grace, "test"); !isValidationError(err, "grace") {
+			t.Errorf("rotate with grace %s = %v, want a grace validation error", grace, err)
+		}
+	}
+
+	rotated, err := keys.RotateAPIKey(old.ID, time.Hour, "user:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement := rotated.APIKey
+	if rotated.Key == created.Key || replacement.Prefix == old.Prefix || replacement.RotatedFrom == nil || *replacement.RotatedFrom != old.ID {
+		t.Errorf("replacement %+v, want a new key rotated from %d", replacement, old.ID)
+	}
+	if replacement.Name != "deploy" || fmt.Sprint(replacement.Scopes) != "[tools:write]" || !replacement.ExpiresAt.Equal(expires) {
+		t.Errorf("replacement %+v, want the name, scopes and expiry of the old key", replacement)
+	}
+
+	// Both keys work during the grace period
+	for _, plain := range []string{created.Key, rotated.Key} {
+		if _, err := keys.Authenticate(plain, "203.0.113.1"); err != nil {
+			t.Errorf("authenticate during the grace period = %v", err)
+		}
+	}
+	view, err := keys.GetAPIKey(old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Status != APIKeyRotating || view.RevokedAt == nil || view.RevokedAt.Sub(time.Now()) < 59*time.Minute {
+		t.Errorf("old key %s until %v, want rotating for an hour", view.Status, view.RevokedAt)
+	}
+
+	// Rotating again cannot extend the grace period of the old key
+	if _, err := keys.RotateAPIKey(old.ID, 2*time.Hour, "user:admin"); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := keys.GetAPIKey(old.ID); err != nil || !again.RevokedAt.Equal(*view.RevokedAt) {
+		t.Errorf("old key revoked at %v after rotating again (%v), want %v", again.RevokedAt, err, view.RevokedAt)
+	}
+
+	// The grace period ends with revocation, or when it runs out
+	if _, err := keys.RevokeAPIKey(old.ID, "user:admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(created.Key, "203.0.113.1"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("authenticate the old key after revoking = %v, want ErrAPIKeyRevoked", err)
+	}
+	if _, err := keys.RotateAPIKey(replacement.ID, 0, "user:admin"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(rotated.Key, "203.0.113.1"); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Errorf("authenticate a key rotated without grace = %v, want ErrAPIKeyRevoked", err)
+	}
+
+	entries := auditEntries(t, db)
+	if got := auditActions(entries); got != "[api_key.create api_key.rotate api_key.rotate api_key.revoke api_key.rotate]" {
+		t.Errorf("audit actions %s", got)
+	}
+	rotation := entries[1]
+	if rotation.TargetID != fmt.Sprint(old.ID) || rotation.Details["new_prefix"] != replacement.Prefix || rotation.Details["grace"] != "1h0m0s" {
+		t.Errorf("rotation audit %+v", rotation)
+	}
+	for _, entry := range entries {
+		if details := fmt.Sprint(entry.Details); strings.Contains(details, created.Key) || strings.Contains(details, rotated.Key) {
+			t.Errorf("audit entry %d contains a plain key", entry.ID)
+		}
+	}
+}
+
+func TestUpdateAPIKey(t *testing.T) {
+	db := newTestDB(t)
+	keys := NewAPIKeyService()
+	expires := time.Now().Add(24 * time.Hour)
+	created, err := keys.CreateAPIKey(APIKeyInput{Name: "ci", Scopes: []string{models.ScopeToolsRead}, ExpiresAt: &expires}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.APIKey.ID
+
+	empty := ""
+	past := time.Now().Add(-time.Hour)
+	invalid := []struct {
+		update APIKeyUpdate
+		field  string
+	}{
+		{APIKeyUpdate{}, "update"},
+		{APIKeyUpdate{Name: &empty}, "name"},
+		{APIKeyUpdate{Scopes: []string{}}, "scopes"},
+		{APIKeyUpdate{ExpiresAt: &past}, "expires_at"},
+		{APIKeyUpdate{ExpiresAt: &expires, NeverExpires: true}, "expires_at"},
+	}
+	for _, tt := range invalid {
+		if _, err := keys.UpdateAPIKey(id, tt.update, "test"); !isValidationError(err, tt.field) {
+			t.Errorf("%+v = %v, want a %s validation error", tt.update, err, tt.field)
+		}
+	}
+
+	name := "deploy"
+	updated, err := keys.UpdateAPIKey(id, APIKeyUpdate{Name: &name, Scopes: []string{models.ScopeStatsRead}, NeverExpires: true}, "user:admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "deploy" || fmt.Sprint(updated.Scopes) != "[stats:read]" || updated.ExpiresAt != nil {
+		t.Errorf("updated key %+v", updated)
+	}
+	authenticated, err := keys.Authenticate(created.Key, "203.0.113.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authenticated.Scopes.Allows(models.ScopeToolsRead) {
+		t.Error("the key still has the scopes it had before the update")
+	}
+
+	entries := auditEntries(t, db)
+	if got := auditActions(entries); got != "[api_key.create api_key.update]" {
+		t.Fatalf("audit actions %s", got)
+	}
+	want := "map[expires_at:map[new:<nil> old:" + expires.UTC().Format(time.RFC3339Nano) + "] name:map[new:deploy old:ci] scopes:map[new:[stats:read] old:[tools:read]]]"
+	if got := fmt.Sprint(entries[1].Details); got != want {
+		t.Errorf("update audit details\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := keys.UpdateAPIKey(id+1, APIKeyUpdate{Name: &name}, "test"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("update missing key = %v, want ErrAPIKeyNotFound", err)
+	}
+}
+
+func TestListAPIKeys(t *testing.T) {
+	newTestDB(t)
+	keys := NewAPIKeyService()
+	var ids []uint
+	for _, name := range []string{"active", "revoked", "rotated"} {
+		created, err := keys.CreateAPIKey(APIKeyInput{Name: name, Scopes: []string{models.ScopeAdmin}}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, created.APIKey.ID)
+	}
+	if _, err := keys.RevokeAPIKey(ids[1], "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.RotateAPIKey(ids[2], time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	for includeInactive, want := range map[bool]string{
+		false: "[rotated:active rotated:rotating active:active]",
+		true:  "[rotated:active rotated:rotating revoked:revoked active:active]",
+	} {
+		views, err := keys.ListAPIKeys(includeInactive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, view := range views {
+			got = append(got, view.Name+":"+view.Status)
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("keys with inactive %v: %v, want %s", includeInactive, got, want)
+		}
+	}
+}
+
+// auditEntries gets the audit log, oldest first
+func auditEntries(t *testing.T, db *gorm.DB) []models.AuditLog {
+	t.Helper()
+	var entries []models.AuditLog
+	if err := db.Order("id").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func auditActions(entries []models.AuditLog) string {
+	actions := make([]string, len(entries))
+	for i, entry := range entries {
+		actions[i] = entry.Action
+	}
+	return fmt.Sprint(actions)
+}
+
+func TestScopeListAllows(t *testing.T) {
+	tests := []struct {
+		granted models.ScopeList
+		scope   string
+		want    bool
+	}{
+		{models.ScopeList{models.ScopeToolsRead}, models.ScopeToolsRead, true},
+		{models.ScopeList{models.ScopeToolsRead}, models.ScopeToolsWrite, false},
+		{models.ScopeList{models.ScopeToolsWrite}, models.ScopeToolsRead, true},
+		{models.ScopeList{models.ScopeToolsWrite}, models.ScopeStatsRead, false},
+		{models.ScopeList{models.ScopeStatsRead}, models.ScopeAdmin, false},
+		{models.ScopeList{models.ScopeAdmin}, models.ScopeStatsRead, true},
+		{nil, models.ScopeToolsRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.granted.Allows(tt.scope); got != tt.want {
+			t.Errorf("%v allows %s = %v, want %v", tt.granted, tt.scope, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"time"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// directSource labels clicks without a referrer
+	directSource = "(direct)"
+
+	// unknownLocale labels clicks without a UI locale
+	unknownLocale = "(unknown)"
+)
+
+// AttributionCount is the number of clicks attributed to a source or locale
+type AttributionCount struct {
+	Name   string  `json:"name"`
+	Clicks int64   `json:"clicks"`
+	Share  float64 `json:"share"`
+}
+
+// ToolSources breaks down the clicks of a tool by source page and UI locale
+type ToolSources struct {
+	ToolID       uint               `json:"tool_id"`
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	Clicks       int64              `json:"clicks"`
+	SearchClicks int64              `json:"search_clicks"`
+	AvgPosition  *float64           `json:"avg_position"`
+	Sources      []AttributionCount `json:"sources"`
+	Locales      []AttributionCount `json:"locales"`
+}
+
+// GetToolSources reports where the clicks of a tool came from over whole UTC
+// days: the top source pages and UI locales, read from raw usage rows
+func (s *StatsService) GetToolSources(toolID uint, from, to time.Time, limit int) (*ToolSources, error) {
+	if err := s.db.Select("id").First(&models.Tool{}, toolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrToolNotFound
+		}
+		return nil, err
+	}
+
+	from, end, err := utcDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	limit = clampLimit(limit)
+
+	clicks := func() *gorm.DB {
+		return s.db.Model(&models.ToolUsage{}).
+			Where("tool_id = ? AND created_at >= ? AND created_at < ?", toolID, timeArg(s.db, from), timeArg(s.db, end))
+	}
+
+	result := &ToolSources{ToolID: toolID, From: from, To: end}
+	var totals struct {
+		Clicks       int64
+		SearchClicks int64
+		AvgPosition  *float64
+	}
+	if err := clicks().
+		Select("COUNT(*) AS clicks, " +
+			"COALESCE(SUM(CASE WHEN search_query <> '' THEN 1 ELSE 0 END), 0) AS search_clicks, " +
+			"AVG(CASE WHEN position > 0 THEN position END) AS avg_position").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	result.Clicks = totals.Clicks
+	result.SearchClicks = totals.SearchClicks
+	result.AvgPosition = totals.AvgPosition
+
+	if result.Sources, err = countAttribution(clicks(), "source", directSource, result.Clicks, limit); err != nil {
+		return nil, err
+	}
+	if result.Locales, err = countAttribution(clicks(), "locale", unknownLocale, result.Clicks, limit); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// countAttribution counts the clicks of the query per value of column, most
+// clicked first, labelling empty values with blank
+func countAttribution(query *gorm.DB, column, blank string, total int64, limit int) ([]AttributionCount, error) {
+	var rows []struct {
+		Name   string
+		Clicks int64
+	}
+	if err := query.
+		Select(column + " AS name, COUNT(*) AS clicks").
+		Group(column).
+		Order("clicks DESC, name").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make([]AttributionCount, 0, len(rows))
+	for _, row := range rows {
+		count := AttributionCount{Name: row.Name, Clicks: row.Clicks}
+		if count.Name == "" {
+			count.Name = blank
+		}
+		if total > 0 {
+			count.Share = float64(row.Clicks) / float64(total)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// clampLimit applies the default and maximum page size to a result limit
+func clampLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
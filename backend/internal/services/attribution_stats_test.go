@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const testUserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+// recordClick stores a click on a tool made now in the given context
+func recordClick(t *testing.T, stats *StatsService, toolID uint, usageCtx UsageContext) {
+	t.Helper()
+	clickAs(t, stats, "203.0.113.7", toolID, usageCtx)
+}
+
+// clickAs stores a click of the visitor with the given IP address
+func clickAs(t *testing.T, stats *StatsService, ip string, toolID uint, usageCtx UsageContext) {
+	t.Helper()
+	usage, err := stats.NewToolUsage(toolID, ip, testUserAgent, usageCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.db.Create(usage).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// formatRatio formats an optional ratio with two decimals
+func formatRatio(ratio *float64) string {
+	if ratio == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *ratio)
+}
+
+func TestNewToolUsageContext(t *testing.T) {
+	newTestDB(t)
+	stats := NewStatsService()
+	usage, err := stats.NewToolUsage(1, "203.0.113.7", testUserAgent, UsageContext{
+		Source:   "https://www.Google.com/search?q=private",
+		Query:    "  JSON   Formatter ",
+		Locale:   "zh_cn",
+		Position: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Source != "www.google.com/search" || usage.SearchQuery != "json formatter" || usage.Locale != "zh-CN" || usage.Position != 3 {
+		t.Errorf("usage %+v, want a normalized context", usage)
+	}
+
+	if _, err := stats.NewToolUsage(1, "203.0.113.7", testUserAgent, UsageContext{Position: -1}); !isValidationError(err, "position") {
+		t.Errorf("negative position = %v, want a validation error", err)
+	}
+}
+
+func TestGetToolSources(t *testing.T) {
+	newTestDB(t)
+	tools := createTestTools(t, NewToolService(), "formatter", "encoder")
+	stats := NewStatsService()
+
+	recordClick(t, stats, tools[0].ID, UsageContext{Source: "/category/text/", Locale: "zh-CN", Query: "json", Position: 2})
+	recordClick(t, stats, tools[0].ID, UsageContext{Source: "/category/text?page=2", Locale: "zh-cn", Query: "json", Position: 4})
+	recordClick(t, stats, tools[0].ID, UsageContext{Source: "https://www.google.com/", Locale: "en"})
+	recordClick(t, stats, tools[0].ID, UsageContext{})
+	recordClick(t, stats, tools[1].ID, UsageContext{Source: "/category/text", Locale: "en"})
+
+	now := time.Now()
+	sources, err := stats.GetToolSources(tools[0].ID, now, now, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sources.Clicks != 4 || sources.SearchClicks != 2 || formatRatio(sources.AvgPosition) != "3.00" {
+		t.Errorf("%d clicks, %d from search at position %s, want 4, 2 and 3.00",
+			sources.Clicks, sources.SearchClicks, formatRatio(sources.AvgPosition))
+	}
+	format := func(counts []AttributionCount) []string {
+		var formatted []string
+		for _, count := range counts {
+			formatted = append(formatted, fmt.Sprintf("%s:%d:%.2f", count.Name, count.Clicks, count.Share))
+		}
+		return formatted
+	}
+	if got := format(sources.Sources); fmt.Sprint(got) != "[/category/text:2:0.50 (direct):1:0.25 www.google.com/:1:0.25]" {
+		t.Errorf("sources %v", got)
+	}
+	if got := format(sources.Locales); fmt.Sprint(got) != "[zh-CN:2:0.50 (unknown):1:0.25 en:1:0.25]" {
+		t.Errorf("locales %v", got)
+	}
+
+	sources, err = stats.GetToolSources(tools[0].ID, now, now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources.Sources) != 1 || len(sources.Locales) != 1 || sources.Clicks != 4 {
+		t.Errorf("limited to one source and locale: %+v", sources)
+	}
+
+	if _, err := stats.GetToolSources(tools[1].ID+1, now, now, 0); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("sources of a missing tool = %v, want ErrToolNotFound", err)
+	}
+	if _, err := stats.GetToolSources(tools[0].ID, now, now.AddDate(0, 0, -1), 0); !isValidationError(err, "from") {
+		t.Errorf("reversed range = %v, want a validation error", err)
+	}
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// auditBatchSize is the number of entries read at a time when exporting or
+// verifying the audit log
+const auditBatchSize = 500
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService() *AuditService {
+	return &AuditService{
+		db: database.GetDB(),
+	}
+}
+
+// AuditFilter selects audit log entries. Empty fields match every entry.
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Project    string
+	RequestID  string
+	Outcome    string
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	Limit      int
+	Offset     int
+}
+
+// AuditPage is a single page of audit log entries
+type AuditPage struct {
+	Entries []models.AuditLog `json:"entries"`
+	Total   int64             `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+}
+
+// AuditVerification is the result of checking the audit log chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int64  `json:"entries"`
+	BrokenID uint   `json:"broken_id,omitempty"` // first entry that does not match the chain
+	Reason   string `json:"reason,omitempty"`
+	Head     string `json:"head"`
+}
+
+// ListAuditLog gets audit log entries, newest first
+func (s *AuditService) ListAuditLog(filter AuditFilter) (*AuditPage, error) {
+	filter.Limit = clampLimit(filter.Limit)
+	if filter.Offset < 0 {
+		return nil, newValidationError("offset", "must not be negative")
+	}
+	query, err := s.filterAuditLog(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.AuditLog, 0)
+	if err := query.Order("id DESC").Offset(filter.Offset).Limit(filter.Limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return &AuditPage{Entries: entries, Total: total, Limit: filter.Limit, Offset: filter.Offset}, nil
+}
+
+// EachAuditLog calls fn with every entry matching the filter, oldest first.
+// Limit and offset are ignored.
+func (s *AuditService) EachAuditLog(filter AuditFilter, fn func(*models.AuditLog) error) error {
+	query, err := s.filterAuditLog(filter)
+	if err != nil {
+		return err
+	}
+
+	var lastID uint
+	for {
+		var entries []models.AuditLog
+		if err := query.Session(&gorm.Session{}).Where("id > ?", lastID).
+			Order("id").Limit(auditBatchSize).Find(&entries).Error; err != nil {
+			return err
+		}
+		for i := range entries {
+			if err := fn(&entries[i]); err != nil {
+				return err
+			}
+			lastID = entries[i].ID
+		}
+		if len(entries) < auditBatchSize {
+			return nil
+		}
+	}
+}
+
+// Record appends an entry to the audit log on its own
+func (s *AuditService) Record(entry *models.AuditLog) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return appendAudit(tx, entry)
+	})
+}
+
+// VerifyAuditChain recomputes the hash of every entry and checks that each
+// entry follows the one before it and that the last entry is the chain head
+func (s *AuditService) VerifyAuditChain() (*AuditVerification, error) {
+	var head models.AuditChainHead
+	if err := s.db.Where("name = ?", models.AuditChain).Limit(1).Find(&head).Error; err != nil {
+		return nil, err
+	}
+	result := &AuditVerification{Valid: true, Head: head.Hash}
+
+	var prev models.AuditLog
+	for {
+		var entries []models.AuditLog
+		// Entries appended after the head was read are left for the next check
+		if err := s.db.Where("id > ? AND id <= ?", prev.ID, head.LastID).
+			Order("id").Limit(auditBatchSize).Find(&entries).Error; err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			hash, err := entry.ComputeHash()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case entry.PrevHash != prev.Hash:
+				return result.broken(entry.ID, "entry does not follow the entry before it, entries were removed or reordered"), nil
+			case entry.Hash != hash:
+				return result.broken(entry.ID, "entry was modified after it was written"), nil
+			}
+			result.Entries++
+			prev = *entry
+		}
+		if len(entries) < auditBatchSize {
+			break
+		}
+	}
+
+	if prev.ID != head.LastID || prev.Hash != head.Hash {
+		return result.broken(prev.ID, "last entry is not the chain head, entries were removed from the end"), nil
+	}
+	return result, nil
+}
+
+func (v *AuditVerification) broken(id uint, reason string) *AuditVerification {
+	v.Valid = false
+	v.BrokenID = id
+	v.Reason = reason
+	return v
+}
+
+func (s *AuditService) filterAuditLog(filter AuditFilter) (*gorm.DB, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, newValidationError("to", "must be after from")
+	}
+
+	query := s.db.Model(&models.AuditLog{})
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Project != "" {
+		query = query.Where("project = ?", filter.Project)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	return query, nil
+}
+
+// recordAudit writes an audit log entry, inside the transaction of the change
+// it describes
+func recordAudit(tx *gorm.DB, actor, action, targetType, targetID string, details models.AuditDetails) error {
+	return appendAudit(tx, &models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Outcome:    models.AuditOutcomeSuccess,
+		Details:    details,
+	})
+}
+
+// appendAudit links an entry to the end of the audit log chain and writes it.
+// The chain head stays locked until the transaction ends, so entries are
+// appended one at a time.
+func appendAudit(tx *gorm.DB, entry *models.AuditLog) error {
+	if entry.Actor == "" {
+		entry.Actor = "anonymous"
+	}
+	if entry.Outcome == "" {
+		entry.Outcome = models.AuditOutcomeSuccess
+	}
+	// Details and time are stored the way they read back, so that the hash
+	// can be recomputed from the stored entry
+	details, err := normalizeAuditDetails(entry.Details)
+	if err != nil {
+		return err
+	}
+	entry.Details = details
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	var head models.AuditChainHead
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("name = ?", models.AuditChain).First(&head).Error; err != nil {
+		return fmt.Errorf("audit log chain head: %w", err)
+	}
+	entry.PrevHash = head.Hash
+	if entry.Hash, err = entry.ComputeHash(); err != nil {
+		return err
+	}
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	return tx.Model(&head).Updates(map[string]interface{}{
+		"last_id": entry.ID,
+		"hash":    entry.Hash,
+	}).Error
+}
+
+// normalizeAuditDetails converts details to the JSON types they are read back as
+func normalizeAuditDetails(details models.AuditDetails) (models.AuditDetails, error) {
+	if len(details) == 0 {
+		return models.AuditDetails{}, nil
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	normalized := models.AuditDetails{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// Audit log export formats
+const (
+	AuditFormatJSONL = "jsonl"
+	AuditFormatCSV   = "csv"
+)
+
+// auditCSVHeader names the columns of a CSV audit log export
+var auditCSVHeader = []string{
+	"id", "created_at", "actor", "action", "target_type", "target_id", "project",
+	"request_id", "ip", "outcome", "details", "prev_hash", "hash",
+}
+
+// ExportAuditLog writes every entry matching the filter to w, oldest first,
+// as JSON lines or CSV. The hashes are included so the export can be checked
+// against the chain.
+func (s *AuditService) ExportAuditLog(w io.Writer, filter AuditFilter, format string) error {
+	switch format {
+	case AuditFormatJSONL:
+		encoder := json.NewEncoder(w)
+		return s.EachAuditLog(filter, func(entry *models.AuditLog) error {
+			return encoder.Encode(entry)
+		})
+	case AuditFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(auditCSVHeader); err != nil {
+			return err
+		}
+		err := s.EachAuditLog(filter, func(entry *models.AuditLog) error {
+			details, err := json.Marshal(entry.Details)
+			if err != nil {
+				return err
+			}
+			return writer.Write([]string{
+				strconv.FormatUint(uint64(entry.ID), 10),
+				entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+				entry.Actor,
+				entry.Action,
+				entry.TargetType,
+				entry.TargetID,
+				entry.Project,
+				entry.RequestID,
+				entry.IP,
+				entry.Outcome,
+				string(details),
+				entry.PrevHash,
+				entry.Hash,
+			})
+		})
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return newValidationError("format", "unknown format %q, expected %s or %s", format, AuditFormatJSONL, AuditFormatCSV)
+}
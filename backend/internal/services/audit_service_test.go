@@ -0,0 +1,153 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordTestAudit appends entries for the targets 1 to n
+func recordTestAudit(t *testing.T, audit *AuditService, n int) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		err := audit.Record(&models.AuditLog{
+			Actor:      "user:alice",
+			Action:     models.AuditUserUpdate,
+			TargetType: auditTargetUser,
+			TargetID:   userTarget(uint(i)),
+			Details:    models.AuditDetails{"role": map[string]interface{}{"old": "viewer", "new": "editor"}, "n": i},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verifyAudit(t *testing.T, audit *AuditService) *AuditVerification {
+	t.Helper()
+	result, err := audit.VerifyAuditChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func(db *gorm.DB) error
+		brokenID uint
+		reason   string
+	}{
+		{name: "intact", tamper: func(db *gorm.DB) error { return nil }},
+		{
+			name: "modified",
+			tamper: func(db *gorm.DB) error {
+				return db.Model(&models.AuditLog{}).Where("id = ?", 2).Update("actor", "user:mallory").Error
+			},
+			brokenID: 2,
+			reason:   "modified",
+		},
+		{
+			name: "removed",
+			tamper: func(db *gorm.DB) error {
+				return db.Delete(&models.AuditLog{}, 2).Error
+			},
+			brokenID: 3,
+			reason:   "removed or reordered",
+		},
+		{
+			name: "removed from the end",
+			tamper: func(db *gorm.DB) error {
+				return db.Delete(&models.AuditLog{}, 3).Error
+			},
+			brokenID: 2,
+			reason:   "removed from the end",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			audit := NewAuditService()
+			recordTestAudit(t, audit, 3)
+			if err := tt.tamper(db); err != nil {
+				t.Fatal(err)
+			}
+
+			result := verifyAudit(t, audit)
+			if tt.brokenID == 0 {
+				if !result.Valid || result.Entries != 3 {
+					t.Errorf("verification %+v, want a valid chain of 3", result)
+				}
+				return
+			}
+			if result.Valid || result.BrokenID != tt.brokenID || !strings.Contains(result.Reason, tt.reason) {
+				t.Errorf("verification %+v, want broken at %d because %s", result, tt.brokenID, tt.reason)
+			}
+		})
+	}
+}
+
+func TestChainAuditLogBackfill(t *testing.T) {
+	db := newTestDB(t)
+	audit := NewAuditService()
+
+	// Entries written before the log was hash chained
+	for i := 0; i < 3; i++ {
+		entry := models.AuditLog{
+			Actor:      "api-key:legacy",
+			Action:     models.AuditAPIKeyCreate,
+			TargetType: "api_key",
+			TargetID:   userTarget(uint(i + 1)),
+			Outcome:    models.AuditOutcomeSuccess,
+			CreatedAt:  time.Now().UTC().Add(-time.Duration(3-i) * time.Hour),
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result := verifyAudit(t, audit); !result.Valid || result.Entries != 0 {
+		t.Fatalf("verification before the backfill %+v, want no chained entries", result)
+	}
+
+	// Migrating again links the unchained entries, oldest first
+	reopen := func() {
+		t.Helper()
+		reopened, err := database.Connect(sqlite.Open(db.Dialector.(*sqlite.Dialector).DSN))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB, err := reopened.DB()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlDB.Close()
+	}
+	reopen()
+
+	result := verifyAudit(t, audit)
+	if !result.Valid || result.Entries != 3 {
+		t.Fatalf("verification after the backfill %+v, want a valid chain of 3", result)
+	}
+	var first models.AuditLog
+	if err := db.First(&first).Error; err != nil {
+		t.Fatal(err)
+	}
+	if first.PrevHash != "" || first.Hash == "" {
+		t.Errorf("first entry prev hash %q, hash %q, want it to start the chain", first.PrevHash, first.Hash)
+	}
+
+	// New entries continue the chain and a further migration leaves it alone
+	recordTestAudit(t, audit, 1)
+	head := verifyAudit(t, audit).Head
+	reopen()
+	result = verifyAudit(t, audit)
+	if !result.Valid || result.Entries != 4 || result.Head != head {
+		t.Errorf("verification after migrating again %+v, want the same chain of 4", result)
+	}
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog file formats
+const (
+	CatalogFormatJSON = "json"
+	CatalogFormatYAML = "yaml"
+	CatalogFormatCSV  = "csv"
+)
+
+// csvTagSeparator separates tag slugs inside the CSV tags column
+const csvTagSeparator = "|"
+
+// csvColumns are the fixed CSV columns. Translations follow as "<column>.<locale>"
+// columns for name, description and category_name.
+var csvColumns = []string{"name", "description", "category", "category_name", "icon", "url", "is_active", "tags"}
+
+// ParseCatalogFormat validates a format name, accepting "yml" as an alias of yaml
+func ParseCatalogFormat(name string) (string, error) {
+	switch strings.ToLower(name) {
+	case CatalogFormatJSON:
+		return CatalogFormatJSON, nil
+	case CatalogFormatYAML, "yml":
+		return CatalogFormatYAML, nil
+	case CatalogFormatCSV:
+		return CatalogFormatCSV, nil
+	}
+	return "", newValidationError("format", "unsupported format %q, expected json, yaml or csv", name)
+}
+
+// CatalogFormatFromFilename detects the format from a file extension
+func CatalogFormatFromFilename(filename string) (string, error) {
+	return ParseCatalogFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// CatalogContentType returns the MIME type of a catalog format
+func CatalogContentType(format string) string {
+	switch format {
+	case CatalogFormatYAML:
+		return "application/yaml; charset=utf-8"
+	case CatalogFormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// EncodeCatalog writes the catalog in the given format
+func EncodeCatalog(w io.Writer, catalog *Catalog, format string) error {
+	switch format {
+	case CatalogFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(catalog)
+	case CatalogFormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(catalog); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case CatalogFormatCSV:
+		return encodeCatalogCSV(w, catalog)
+	}
+	return newValidationError("format", "unsupported format %q", format)
+}
+
+// DecodeCatalog reads a catalog in the given format. Unknown fields are rejected
+// so that typos in hand-edited files do not go unnoticed.
+func DecodeCatalog(r io.Reader, format string) (*Catalog, error) {
+	catalog := &Catalog{}
+	switch format {
+	case CatalogFormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(catalog); err != nil {
+			return nil, newValidationError("file", "%v", err)
+		}
+	case CatalogFormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(catalog); err != nil && !errors.Is(err, io.EOF) {
+			return nil, newValidationError("file", "%v", err)
+		}
+	case CatalogFormatCSV:
+		return decodeCatalogCSV(r)
+	default:
+		return nil, newValidationError("format", "unsupported format %q", format)
+	}
+	return catalog, nil
+}
+
+// encodeCatalogCSV writes one row per tool. Category icons, ordering and nesting
+// cannot be represented in CSV and are not exported.
+func encodeCatalogCSV(w io.Writer, catalog *Catalog) error {
+	categories := make(map[string]CatalogCategory, len(catalog.Categories))
+	for _, category := range catalog.Categories {
+		categories[category.Slug] = category
+	}
+
+	toolLocales := make(map[string]bool)
+	categoryLocales := make(map[string]bool)
+	for _, tool := range catalog.Tools {
+		for locale := range tool.Translations {
+			toolLocales[locale] = true
+		}
+		for locale := range categories[tool.Category].Translations {
+			categoryLocales[locale] = true
+		}
+	}
+
+	header := append([]string{}, csvColumns...)
+	for _, locale := range sortedKeys(toolLocales) {
+		header = append(header, "name."+locale, "description."+locale)
+	}
+	for _, locale := range sortedKeys(categoryLocales) {
+		header = append(header, "category_name."+locale)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, tool := range catalog.Tools {
+		category := categories[tool.Category]
+		isActive := tool.IsActive == nil || *tool.IsActive
+		row := []string{
+			tool.Name,
+			tool.Description,
+			tool.Category,
+			category.Name,
+			tool.Icon,
+			tool.URL,
+			strconv.FormatBool(isActive),
+			strings.Join(tool.Tags, csvTagSeparator),
+		}
+		for _, locale := range sortedKeys(toolLocales) {
+			text := tool.Translations[locale]
+			row = append(row, text.Name, text.Description)
+		}
+		for _, locale := range sortedKeys(categoryLocales) {
+			row = append(row, category.Translations[locale])
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// decodeCatalogCSV reads one tool per row and derives the categories from the
+// category columns. Rows of the same category must agree on its names.
+func decodeCatalogCSV(r io.Reader) (*Catalog, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return &Catalog{}, nil
+	}
+	if err != nil {
+		return nil, newValidationError("file", "%v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if _, duplicate := columns[name]; duplicate {
+			return nil, newValidationError("file", "column %q appears more than once", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"name", "category", "url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, newValidationError("file", "missing required column %q", required)
+		}
+	}
+	for name := range columns {
+		if !isCSVColumn(name) {
+			return nil, newValidationError("file", "unknown column %q", name)
+		}
+	}
+
+	catalog := &Catalog{}
+	categoryIndex := make(map[string]int)
+	var problems ValidationErrors
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, newValidationError("file", "%v", err)
+		}
+
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		tool := CatalogTool{
+			Name:        get("name"),
+			Description: get("description"),
+			Category:    get("category"),
+			Icon:        get("icon"),
+			URL:         get("url"),
+		}
+		if value := get("is_active"); value != "" {
+			isActive, err := strconv.ParseBool(value)
+			if err != nil {
+				problems = append(problems, &ValidationError{
+					Field:   fmt.Sprintf("line %d is_active", line),
+					Message: fmt.Sprintf("%q is not a boolean", value),
+				})
+			}
+			tool.IsActive = &isActive
+		}
+		for _, tag := range strings.Split(get("tags"), csvTagSeparator) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tool.Tags = append(tool.Tags, tag)
+			}
+		}
+
+		category := CatalogCategory{
+			Slug:         tool.Category,
+			Name:         get("category_name"),
+			Translations: make(map[string]string),
+			nameOnly:     true,
+		}
+		for column := range columns {
+			locale, ok := strings.CutPrefix(column, "name.")
+			if ok {
+				if name := get(column); name != "" {
+					if tool.Translations == nil {
+						tool.Translations = make(map[string]CatalogText)
+					}
+					tool.Translations[locale] = CatalogText{
+						Name:        name,
+						Description: get("description." + locale),
+					}
+				}
+				continue
+			}
+			if locale, ok := strings.CutPrefix(column, "category_name."); ok {
+				if name := get(column); name != "" {
+					category.Translations[locale] = name
+				}
+			}
+		}
+		catalog.Tools = append(catalog.Tools, tool)
+
+		if category.Slug == "" {
+			continue
+		}
+		i, seen := categoryIndex[category.Slug]
+		if !seen {
+			categoryIndex[category.Slug] = len(catalog.Categories)
+			catalog.Categories = append(catalog.Categories, category)
+			continue
+		}
+		if err := mergeCSVCategory(&catalog.Categories[i], category); err != nil {
+			problems = append(problems, &ValidationError{
+				Field:   fmt.Sprintf("line %d category", line),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return catalog, nil
+}
+
+// mergeCSVCategory fills names missing from an earlier row of the same category
+// and reports rows that disagree
+func mergeCSVCategory(existing *CatalogCategory, row CatalogCategory) error {
+	if row.Name != "" {
+		if existing.Name != "" && existing.Name != row.Name {
+			return fmt.Errorf("%q is named both %q and %q", row.Slug, existing.Name, row.Name)
+		}
+		existing.Name = row.Name
+	}
+	for locale, name := range row.Translations {
+		if current, ok := existing.Translations[locale]; ok && current != name {
+			return fmt.Errorf("%q is named both %q and %q in %s", row.Slug, current, name, locale)
+		}
+		existing.Translations[locale] = name
+	}
+	return nil
+}
+
+// isCSVColumn reports whether a header names a fixed or translation column
+func isCSVColumn(name string) bool {
+	for _, column := range csvColumns {
+		if name == column {
+			return true
+		}
+	}
+	for _, prefix := range []string{"name.", "description.", "category_name."} {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
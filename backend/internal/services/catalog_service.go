@@ -0,0 +1,789 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/i18n"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Import change actions
+const (
+	CatalogActionCreate = "create"
+	CatalogActionUpdate = "update"
+)
+
+// errCatalogDryRun rolls back the import transaction after a dry run
+var errCatalogDryRun = errors.New("catalog dry run")
+
+type CatalogService struct {
+	db *gorm.DB
+}
+
+func NewCatalogService() *CatalogService {
+	return &CatalogService{
+		db: database.GetDB(),
+	}
+}
+
+// Catalog is the portable representation of all categories, tags and tools.
+// Categories and tags are referenced by slug, tools are matched by name.
+type Catalog struct {
+	Categories []CatalogCategory `json:"categories" yaml:"categories"`
+	Tags       []CatalogTag      `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Tools      []CatalogTool     `json:"tools" yaml:"tools"`
+}
+
+// CatalogCategory is a category with its translated names keyed by locale
+type CatalogCategory struct {
+	Slug         string            `json:"slug" yaml:"slug"`
+	Name         string            `json:"name" yaml:"name"`
+	Icon         string            `json:"icon,omitempty" yaml:"icon,omitempty"`
+	SortOrder    int               `json:"sort_order" yaml:"sort_order"`
+	Parent       string            `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Translations map[string]string `json:"translations,omitempty" yaml:"translations,omitempty"`
+
+	// nameOnly marks categories derived from CSV rows, which only carry the name
+	// and its translations; other attributes of existing categories are kept
+	nameOnly bool
+}
+
+// CatalogTag is a tag definition
+type CatalogTag struct {
+	Slug string `json:"slug" yaml:"slug"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// CatalogTool is a tool with its category slug, tag slugs and translated text keyed by locale
+type CatalogTool struct {
+	Name         string                 `json:"name" yaml:"name"`
+	Description  string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Category     string                 `json:"category" yaml:"category"`
+	Tags         []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Icon         string                 `json:"icon,omitempty" yaml:"icon,omitempty"`
+	URL          string                 `json:"url" yaml:"url"`
+	IsActive     *bool                  `json:"is_active,omitempty" yaml:"is_active,omitempty"`
+	Translations map[string]CatalogText `json:"translations,omitempty" yaml:"translations,omitempty"`
+}
+
+// CatalogText is the localized text of a tool
+type CatalogText struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// CatalogFieldChange is a single field difference between the database and the catalog
+type CatalogFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// CatalogChange describes a record the import creates or updates
+type CatalogChange struct {
+	Kind   string               `json:"kind"` // category, tag or tool
+	Key    string               `json:"key"`  // slug or tool name
+	Action string               `json:"action"`
+	Fields []CatalogFieldChange `json:"fields,omitempty"`
+}
+
+// ImportReport summarizes the changes made, or that would be made, by an import
+type ImportReport struct {
+	DryRun    bool            `json:"dry_run"`
+	Created   int             `json:"created"`
+	Updated   int             `json:"updated"`
+	Unchanged int             `json:"unchanged"`
+	Changes   []CatalogChange `json:"changes"`
+}
+
+// Export gets the whole catalog, including inactive tools, in its original text
+func (s *CatalogService) Export() (*Catalog, error) {
+	var categories []models.Category
+	if err := s.db.Preload("Translations").Order("sort_order, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	slugs := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		slugs[category.ID] = category.Slug
+	}
+
+	var tags []models.Tag
+	if err := s.db.Order("slug").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var tools []models.Tool
+	if err := s.db.Preload("Tags").Preload("Translations").Order("id").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+
+	catalog := &Catalog{
+		Categories: make([]CatalogCategory, 0, len(categories)),
+		Tags:       make([]CatalogTag, 0, len(tags)),
+		Tools:      make([]CatalogTool, 0, len(tools)),
+	}
+
+	for _, category := range categories {
+		entry := CatalogCategory{
+			Slug:      category.Slug,
+			Name:      category.Name,
+			Icon:      category.Icon,
+			SortOrder: category.SortOrder,
+		}
+		if category.ParentID != nil {
+			entry.Parent = slugs[*category.ParentID]
+		}
+		if len(category.Translations) > 0 {
+			entry.Translations = make(map[string]string, len(category.Translations))
+			for _, translation := range category.Translations {
+				entry.Translations[translation.Locale] = translation.Name
+			}
+		}
+		catalog.Categories = append(catalog.Categories, entry)
+	}
+
+	for _, tag := range tags {
+		catalog.Tags = append(catalog.Tags, CatalogTag{Slug: tag.Slug, Name: tag.Name})
+	}
+
+	for _, tool := range tools {
+		isActive := tool.IsActive
+		entry := CatalogTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Icon:        tool.Icon,
+			URL:         tool.URL,
+			IsActive:    &isActive,
+		}
+		if tool.CategoryID != nil {
+			entry.Category = slugs[*tool.CategoryID]
+		}
+		for _, tag := range tool.Tags {
+			entry.Tags = append(entry.Tags, tag.Slug)
+		}
+		sort.Strings(entry.Tags)
+		if len(tool.Translations) > 0 {
+			entry.Translations = make(map[string]CatalogText, len(tool.Translations))
+			for _, translation := range tool.Translations {
+				entry.Translations[translation.Locale] = CatalogText{
+					Name:        translation.Name,
+					Description: translation.Description,
+				}
+			}
+		}
+		catalog.Tools = append(catalog.Tools, entry)
+	}
+
+	return catalog, nil
+}
+
+// Import upserts the catalog in a single transaction: categories and tags are
+// matched by slug and tools by name. Records missing from the catalog are left
+// untouched. Created and changed tools get a revision attributed to actor. With
+// dryRun the transaction is rolled back after computing the report.
+func (s *CatalogService) Import(catalog *Catalog, dryRun bool, actor string) (*ImportReport, error) {
+	if err := validateCatalog(catalog); err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: dryRun, Changes: []CatalogChange{}}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		importer := &catalogImporter{
+			tx:         tx,
+			report:     report,
+			actor:      actor,
+			categories: make(map[string]uint),
+			tags:       make(map[string]models.Tag),
+		}
+		if err := importer.importCategories(catalog.Categories); err != nil {
+			return err
+		}
+		if err := importer.importTags(catalog); err != nil {
+			return err
+		}
+		if err := importer.importTools(catalog.Tools); err != nil {
+			return err
+		}
+		if dryRun {
+			return errCatalogDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errCatalogDryRun) {
+		return nil, err
+	}
+	if !dryRun {
+		invalidateFuzzyCandidates()
+	}
+
+	return report, nil
+}
+
+// validateCatalog checks the whole catalog up front and reports every problem found.
+// Locales are normalized in place.
+func validateCatalog(catalog *Catalog) error {
+	var problems ValidationErrors
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, newValidationError(field, format, args...).(*ValidationError))
+	}
+
+	categorySlugs := make(map[string]bool, len(catalog.Categories))
+	for i := range catalog.Categories {
+		category := &catalog.Categories[i]
+		field := fmt.Sprintf("categories[%d]", i)
+		switch {
+		case category.Slug == "":
+			add(field+".slug", "is required")
+		case categorySlugs[category.Slug]:
+			add(field+".slug", "%q is listed more than once", category.Slug)
+		}
+		categorySlugs[category.Slug] = true
+		if category.Name == "" && !category.nameOnly {
+			add(field+".name", "is required")
+		}
+		if category.Parent != "" && category.Parent == category.Slug {
+			add(field+".parent", "a category cannot be nested under itself")
+		}
+
+		translations, ok := normalizeLocaleKeys(category.Translations)
+		if !ok {
+			add(field+".translations", "contains an invalid locale")
+		}
+		category.Translations = translations
+	}
+
+	tagSlugs := make(map[string]bool, len(catalog.Tags))
+	for i, tag := range catalog.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case tag.Slug == "":
+			add(field+".slug", "is required")
+		case tagSlugs[tag.Slug]:
+			add(field+".slug", "%q is listed more than once", tag.Slug)
+		}
+		tagSlugs[tag.Slug] = true
+	}
+
+	toolNames := make(map[string]bool, len(catalog.Tools))
+	for i := range catalog.Tools {
+		tool := &catalog.Tools[i]
+		field := fmt.Sprintf("tools[%d]", i)
+		switch {
+		case tool.Name == "":
+			add(field+".name", "is required")
+		case toolNames[tool.Name]:
+			add(field+".name", "%q is listed more than once", tool.Name)
+		}
+		toolNames[tool.Name] = true
+		if tool.Category == "" {
+			add(field+".category", "is required")
+		}
+		if tool.URL == "" {
+			add(field+".url", "is required")
+		} else if _, err := url.ParseRequestURI(tool.URL); err != nil {
+			add(field+".url", "%q is not a valid URL", tool.URL)
+		}
+		for _, tag := range tool.Tags {
+			if tag == "" {
+				add(field+".tags", "contains an empty slug")
+				break
+			}
+		}
+
+		translations := make(map[string]CatalogText, len(tool.Translations))
+		for locale, text := range tool.Translations {
+			normalized := i18n.Normalize(locale)
+			if normalized == "" {
+				add(field+".translations", "%q is not a valid locale", locale)
+				continue
+			}
+			if text.Name == "" {
+				add(field+".translations."+normalized+".name", "is required")
+			}
+			translations[normalized] = text
+		}
+		tool.Translations = translations
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// normalizeLocaleKeys normalizes the locale keys of a translation map
+func normalizeLocaleKeys(translations map[string]string) (map[string]string, bool) {
+	normalized := make(map[string]string, len(translations))
+	ok := true
+	for locale, value := range translations {
+		key := i18n.Normalize(locale)
+		if key == "" {
+			ok = false
+			continue
+		}
+		normalized[key] = value
+	}
+	return normalized, ok
+}
+
+// catalogImporter applies a validated catalog inside a transaction
+type catalogImporter struct {
+	tx         *gorm.DB
+	report     *ImportReport
+	actor      string
+	categories map[string]uint       // category IDs by slug
+	tags       map[string]models.Tag // tags by slug
+}
+
+// record adds a change to the report, counting records without changes as unchanged
+func (im *catalogImporter) record(kind, key, action string, fields []CatalogFieldChange) {
+	switch {
+	case action == CatalogActionCreate:
+		im.report.Created++
+	case len(fields) == 0:
+		im.report.Unchanged++
+		return
+	default:
+		im.report.Updated++
+	}
+	im.report.Changes = append(im.report.Changes, CatalogChange{
+		Kind:   kind,
+		Key:    key,
+		Action: action,
+		Fields: fields,
+	})
+}
+
+// importCategories upserts categories so that parents are written before their children
+func (im *catalogImporter) importCategories(categories []CatalogCategory) error {
+	bySlug := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		bySlug[category.Slug] = true
+	}
+
+	pending := categories
+	for len(pending) > 0 {
+		var next []CatalogCategory
+		for _, category := range pending {
+			if category.Parent != "" && bySlug[category.Parent] {
+				if _, done := im.categories[category.Parent]; !done {
+					next = append(next, category)
+					continue
+				}
+			}
+			if err := im.importCategory(category); err != nil {
+				return err
+			}
+		}
+		if len(next) == len(pending) {
+			return newValidationError("categories", "parent references form a cycle at %q", next[0].Slug)
+		}
+		pending = next
+	}
+	return nil
+}
+
+func (im *catalogImporter) importCategory(entry CatalogCategory) error {
+	var parentID *uint
+	if entry.Parent != "" {
+		id, err := im.categoryID(entry.Parent)
+		if err != nil {
+			return newValidationError("categories."+entry.Slug+".parent", "category %q does not exist", entry.Parent)
+		}
+		parentID = &id
+	}
+
+	var category models.Category
+	err := im.tx.Where("slug = ?", entry.Slug).First(&category).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if entry.Name == "" {
+			return newValidationError("categories."+entry.Slug+".name", "is required for new categories")
+		}
+		category = models.Category{
+			Slug:      entry.Slug,
+			Name:      entry.Name,
+			Icon:      entry.Icon,
+			SortOrder: entry.SortOrder,
+			ParentID:  parentID,
+		}
+		if err := im.tx.Create(&category).Error; err != nil {
+			return err
+		}
+		im.categories[entry.Slug] = category.ID
+		if _, err := im.upsertCategoryTranslations(category.ID, entry.Translations); err != nil {
+			return err
+		}
+		im.record("category", entry.Slug, CatalogActionCreate, nil)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	im.categories[entry.Slug] = category.ID
+
+	updates := make(map[string]interface{})
+	var fields []CatalogFieldChange
+	diff := func(field string, old, new interface{}) {
+		if fmt.Sprint(old) != fmt.Sprint(new) {
+			updates[field] = new
+			fields = append(fields, CatalogFieldChange{Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)})
+		}
+	}
+
+	if entry.Name != "" {
+		diff("name", category.Name, entry.Name)
+	}
+	if !entry.nameOnly {
+		diff("icon", category.Icon, entry.Icon)
+		diff("sort_order", category.SortOrder, entry.SortOrder)
+		if !sameID(category.ParentID, parentID) {
+			if parentID != nil {
+				nested, err := im.isNestedUnder(*parentID, category.ID)
+				if err != nil {
+					return err
+				}
+				if nested {
+					return newValidationError("categories."+entry.Slug+".parent", "a category cannot be nested under itself")
+				}
+			}
+			updates["parent_id"] = parentID
+			fields = append(fields, CatalogFieldChange{
+				Field: "parent",
+				Old:   im.categorySlug(category.ParentID),
+				New:   entry.Parent,
+			})
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := im.tx.Model(&category).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	translationChanges, err := im.upsertCategoryTranslations(category.ID, entry.Translations)
+	if err != nil {
+		return err
+	}
+	im.record("category", entry.Slug, CatalogActionUpdate, append(fields, translationChanges...))
+	return nil
+}
+
+// upsertCategoryTranslations writes the translated names of a category and returns what changed
+func (im *catalogImporter) upsertCategoryTranslations(categoryID uint, translations map[string]string) ([]CatalogFieldChange, error) {
+	var existing []models.CategoryTranslation
+	if err := im.tx.Where("category_id = ?", categoryID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[string]string, len(existing))
+	for _, translation := range existing {
+		current[translation.Locale] = translation.Name
+	}
+
+	var fields []CatalogFieldChange
+	for _, locale := range sortedKeys(translations) {
+		name := translations[locale]
+		if old, ok := current[locale]; ok && old == name {
+			continue
+		}
+		fields = append(fields, CatalogFieldChange{Field: "translations." + locale, Old: current[locale], New: name})
+
+		translation := models.CategoryTranslation{CategoryID: categoryID, Locale: locale, Name: name}
+		if err := im.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "category_id"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at"}),
+		}).Create(&translation).Error; err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// importTags upserts the declared tags and creates any tag a tool references
+// without declaring it, using the slug as its name
+func (im *catalogImporter) importTags(catalog *Catalog) error {
+	declared := make(map[string]bool, len(catalog.Tags))
+	for _, entry := range catalog.Tags {
+		declared[entry.Slug] = true
+		if err := im.importTag(entry); err != nil {
+			return err
+		}
+	}
+
+	for _, tool := range catalog.Tools {
+		for _, slug := range tool.Tags {
+			if declared[slug] {
+				continue
+			}
+			declared[slug] = true
+
+			var tag models.Tag
+			err := im.tx.Where("slug = ?", slug).First(&tag).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err := im.importTag(CatalogTag{Slug: slug, Name: slug}); err != nil {
+					return err
+				}
+			case err != nil:
+				return err
+			default:
+				im.tags[slug] = tag
+			}
+		}
+	}
+	return nil
+}
+
+func (im *catalogImporter) importTag(entry CatalogTag) error {
+	name := entry.Name
+	if name == "" {
+		name = entry.Slug
+	}
+
+	var tag models.Tag
+	err := im.tx.Where("slug = ?", entry.Slug).First(&tag).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		tag = models.Tag{Slug: entry.Slug, Name: name}
+		if err := im.tx.Create(&tag).Error; err != nil {
+			return err
+		}
+		im.tags[entry.Slug] = tag
+		im.record("tag", entry.Slug, CatalogActionCreate, nil)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	var fields []CatalogFieldChange
+	if tag.Name != name {
+		fields = append(fields, CatalogFieldChange{Field: "name", Old: tag.Name, New: name})
+		if err := im.tx.Model(&tag).Update("name", name).Error; err != nil {
+			return err
+		}
+	}
+	im.tags[entry.Slug] = tag
+	im.record("tag", entry.Slug, CatalogActionUpdate, fields)
+	return nil
+}
+
+func (im *catalogImporter) importTools(tools []CatalogTool) error {
+	for i, entry := range tools {
+		if err := im.importTool(i, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *catalogImporter) importTool(index int, entry CatalogTool) error {
+	categoryID, err := im.categoryID(entry.Category)
+	if err != nil {
+		return newValidationError(fmt.Sprintf("tools[%d].category", index), "category %q does not exist", entry.Category)
+	}
+
+	tags := make([]models.Tag, 0, len(entry.Tags))
+	for _, slug := range uniqueStrings(entry.Tags) {
+		tags = append(tags, im.tags[slug])
+	}
+
+	var tool models.Tool
+	err = im.tx.Preload("Tags").Where("name = ?", entry.Name).First(&tool).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		tool = models.Tool{
+			Name:        entry.Name,
+			Description: entry.Description,
+			CategoryID:  &categoryID,
+			Icon:        entry.Icon,
+			URL:         entry.URL,
+			IsActive:    entry.IsActive == nil || *entry.IsActive,
+			Tags:        tags,
+		}
+		// Create skips zero values of fields with a default, so deactivate explicitly
+		if err := im.tx.Create(&tool).Error; err != nil {
+			return err
+		}
+		if entry.IsActive != nil && !*entry.IsActive {
+			if err := im.tx.Model(&tool).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		if _, err := im.upsertToolTranslations(tool.ID, entry.Translations); err != nil {
+			return err
+		}
+		if err := recordToolRevision(im.tx, tool.ID, models.ToolRevisionCreate, im.actor, nil); err != nil {
+			return err
+		}
+		im.record("tool", entry.Name, CatalogActionCreate, nil)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	updates := make(map[string]interface{})
+	var fields []CatalogFieldChange
+	diff := func(field string, old, new interface{}) {
+		if fmt.Sprint(old) != fmt.Sprint(new) {
+			updates[field] = new
+			fields = append(fields, CatalogFieldChange{Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)})
+		}
+	}
+
+	diff("description", tool.Description, entry.Description)
+	diff("icon", tool.Icon, entry.Icon)
+	diff("url", tool.URL, entry.URL)
+	if entry.IsActive != nil {
+		diff("is_active", tool.IsActive, *entry.IsActive)
+	}
+	if !sameID(tool.CategoryID, &categoryID) {
+		updates["category_id"] = categoryID
+		fields = append(fields, CatalogFieldChange{
+			Field: "category",
+			Old:   im.categorySlug(tool.CategoryID),
+			New:   entry.Category,
+		})
+	}
+
+	if len(updates) > 0 {
+		if err := im.tx.Model(&tool).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	oldTags := tagSlugs(tool.Tags)
+	newTags := tagSlugs(tags)
+	if oldTags != newTags {
+		fields = append(fields, CatalogFieldChange{Field: "tags", Old: oldTags, New: newTags})
+		if err := im.tx.Model(&tool).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+	}
+
+	if err := recordToolRevision(im.tx, tool.ID, models.ToolRevisionUpdate, im.actor, nil); err != nil {
+		return err
+	}
+
+	translationChanges, err := im.upsertToolTranslations(tool.ID, entry.Translations)
+	if err != nil {
+		return err
+	}
+	im.record("tool", entry.Name, CatalogActionUpdate, append(fields, translationChanges...))
+	return nil
+}
+
+// upsertToolTranslations writes the translated text of a tool and returns what changed
+func (im *catalogImporter) upsertToolTranslations(toolID uint, translations map[string]CatalogText) ([]CatalogFieldChange, error) {
+	var existing []models.ToolTranslation
+	if err := im.tx.Where("tool_id = ?", toolID).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	current := make(map[string]models.ToolTranslation, len(existing))
+	for _, translation := range existing {
+		current[translation.Locale] = translation
+	}
+
+	var fields []CatalogFieldChange
+	for _, locale := range sortedKeys(translations) {
+		text := translations[locale]
+		old := current[locale]
+		if old.ID != 0 && old.Name == text.Name && old.Description == text.Description {
+			continue
+		}
+		if old.Name != text.Name {
+			fields = append(fields, CatalogFieldChange{Field: "translations." + locale + ".name", Old: old.Name, New: text.Name})
+		}
+		if old.Description != text.Description {
+			fields = append(fields, CatalogFieldChange{Field: "translations." + locale + ".description", Old: old.Description, New: text.Description})
+		}
+
+		translation := models.ToolTranslation{ToolID: toolID, Locale: locale, Name: text.Name, Description: text.Description}
+		if err := im.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tool_id"}, {Name: "locale"}},
+			DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_at"}),
+		}).Create(&translation).Error; err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// categoryID resolves a category slug from the catalog or the database
+func (im *catalogImporter) categoryID(slug string) (uint, error) {
+	if id, ok := im.categories[slug]; ok {
+		return id, nil
+	}
+	var category models.Category
+	if err := im.tx.Select("id").Where("slug = ?", slug).First(&category).Error; err != nil {
+		return 0, err
+	}
+	im.categories[slug] = category.ID
+	return category.ID, nil
+}
+
+// isNestedUnder reports whether a category is, or is nested under, the ancestor
+func (im *catalogImporter) isNestedUnder(id, ancestorID uint) (bool, error) {
+	seen := make(map[uint]bool)
+	for current := &id; current != nil && !seen[*current]; {
+		if *current == ancestorID {
+			return true, nil
+		}
+		seen[*current] = true
+
+		var category models.Category
+		if err := im.tx.Select("id", "parent_id").First(&category, *current).Error; err != nil {
+			return false, err
+		}
+		current = category.ParentID
+	}
+	return false, nil
+}
+
+// categorySlug resolves a category ID to its slug for change reports
+func (im *catalogImporter) categorySlug(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	var category models.Category
+	if err := im.tx.Select("slug").First(&category, *id).Error; err != nil {
+		return fmt.Sprint(*id)
+	}
+	return category.Slug
+}
+
+// sameID compares two optional IDs
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// tagSlugs formats tags as a sorted, comma separated list of slugs
+func tagSlugs(tags []models.Tag) string {
+	slugs := make([]string, len(tags))
+	for i, tag := range tags {
+		slugs[i] = tag.Slug
+	}
+	sort.Strings(slugs)
+	return strings.Join(slugs, ",")
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
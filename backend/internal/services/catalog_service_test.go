@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"tion.work/backend/internal/models"
+)
+
+func boolPtr(v bool) *bool { return &v }
+
+// testCatalog covers nesting, translations, undeclared tags and inactive tools
+func testCatalog() *Catalog {
+	return &Catalog{
+		Categories: []CatalogCategory{
+			{Slug: "text", Name: "Text", Icon: "📝", SortOrder: 1, Translations: map[string]string{"zh": "文本"}},
+			{Slug: "markdown", Name: "Markdown", SortOrder: 2, Parent: "text"},
+			{Slug: "image", Name: "Image", SortOrder: 3},
+		},
+		Tags: []CatalogTag{{Slug: "web", Name: "Web"}},
+		Tools: []CatalogTool{
+			{
+				Name: "Formatter", Description: "Format JSON", Category: "text", Tags: []string{"web", "json"},
+				URL: "https://tion.work/formatter", IsActive: boolPtr(true),
+				Translations: map[string]CatalogText{"zh": {Name: "格式化", Description: "格式化 JSON"}},
+			},
+			{Name: "Preview", Category: "markdown", Icon: "👁", URL: "https://tion.work/preview", IsActive: boolPtr(true)},
+			{Name: "Resizer", Category: "image", URL: "https://tion.work/resizer", IsActive: boolPtr(false)},
+		},
+	}
+}
+
+func importCatalog(t *testing.T, catalog *Catalog, dryRun bool) *ImportReport {
+	t.Helper()
+	report, err := NewCatalogService().Import(catalog, dryRun, "test")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	return report
+}
+
+func exportCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	catalog, err := NewCatalogService().Export()
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	return catalog
+}
+
+func TestCatalogRoundTrip(t *testing.T) {
+	for _, format := range []string{CatalogFormatJSON, CatalogFormatYAML, CatalogFormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			newTestDB(t)
+			report := importCatalog(t, testCatalog(), false)
+			// Three categories, the declared and the undeclared tag and three tools
+			if report.Created != 8 || report.Updated != 0 {
+				t.Fatalf("created %d, updated %d, want 8 created", report.Created, report.Updated)
+			}
+
+			exported := exportCatalog(t)
+			want := testCatalog()
+			want.Tags = []CatalogTag{{Slug: "json", Name: "json"}, {Slug: "web", Name: "Web"}}
+			want.Tools[0].Tags = []string{"json", "web"}
+			if !reflect.DeepEqual(exported, want) {
+				t.Fatalf("exported %+v\nwant %+v", exported, want)
+			}
+
+			var buf bytes.Buffer
+			if err := EncodeCatalog(&buf, exported, format); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeCatalog(&buf, format)
+			if err != nil {
+				t.Fatalf("decode %s: %v", buf.String(), err)
+			}
+
+			// Importing an export changes nothing
+			report = importCatalog(t, decoded, false)
+			if report.Created != 0 || report.Updated != 0 || len(report.Changes) != 0 {
+				t.Errorf("re-import changed %+v", report.Changes)
+			}
+
+			// And fills an empty database with the same tools
+			newTestDB(t)
+			importCatalog(t, decoded, false)
+			restored := exportCatalog(t)
+			if format == CatalogFormatCSV {
+				// CSV only carries category names, so compare the tools
+				if !reflect.DeepEqual(restored.Tools, want.Tools) {
+					t.Errorf("restored tools %+v\nwant %+v", restored.Tools, want.Tools)
+				}
+				return
+			}
+			if !reflect.DeepEqual(restored, want) {
+				t.Errorf("restored %+v\nwant %+v", restored, want)
+			}
+		})
+	}
+}
+
+func TestCatalogImportUpdates(t *testing.T) {
+	newTestDB(t)
+	importCatalog(t, testCatalog(), false)
+
+	changed := testCatalog()
+	changed.Categories[1].Parent = "image"
+	changed.Tools[0].Description = "Pretty print JSON"
+	changed.Tools[0].Tags = []string{"web"}
+	changed.Tools[0].Translations["zh"] = CatalogText{Name: "格式化工具", Description: "格式化 JSON"}
+	changed.Tools[2].IsActive = nil // keeps the current state
+	changed.Tools = append(changed.Tools, CatalogTool{Name: "Cropper", Category: "image", URL: "https://tion.work/cropper"})
+
+	report := importCatalog(t, changed, false)
+	if report.Created != 1 || report.Updated != 2 {
+		t.Errorf("created %d, updated %d, want 1 and 2", report.Created, report.Updated)
+	}
+	var got []string
+	for _, change := range report.Changes {
+		fields := make([]string, len(change.Fields))
+		for i, field := range change.Fields {
+			fields[i] = fmt.Sprintf("%s:%s>%s", field.Field, field.Old, field.New)
+		}
+		got = append(got, fmt.Sprintf("%s %s %s %v", change.Action, change.Kind, change.Key, fields))
+	}
+	want := []string{
+		"update category markdown [parent:text>image]",
+		"update tool Formatter [description:Format JSON>Pretty print JSON tags:json,web>web translations.zh.name:格式化>格式化工具]",
+		"create tool Cropper []",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("changes\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var resizer models.Tool
+	if err := NewToolService().db.Where("name = ?", "Resizer").First(&resizer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if resizer.IsActive {
+		t.Error("a tool without is_active in the catalog was activated")
+	}
+}
+
+func TestCatalogImportConflicts(t *testing.T) {
+	newTestDB(t)
+	importCatalog(t, testCatalog(), false)
+	before := exportCatalog(t)
+
+	invalid := &Catalog{
+		Categories: []CatalogCategory{
+			{Slug: "text", Name: "Text"},
+			{Slug: "text", Name: "Again"},
+			{Slug: "loop", Name: "Loop", Parent: "loop"},
+			{Slug: "", Name: "No slug", Translations: map[string]string{"not a locale": "x"}},
+		},
+		Tools: []CatalogTool{
+			{Name: "Formatter", Category: "text", URL: "https://tion.work/formatter"},
+			{Name: "Formatter", Category: "text", URL: "not a url"},
+			{Name: "Nameless", URL: "https://tion.work/x", Translations: map[string]CatalogText{"zh": {}}},
+		},
+	}
+	_, err := NewCatalogService().Import(invalid, false, "test")
+	var problems ValidationErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("import = %v, want validation errors", err)
+	}
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	want := []string{
+		"categories[1].slug", "categories[2].parent", "categories[3].slug", "categories[3].translations",
+		"tools[1].name", "tools[1].url", "tools[2].category", "tools[2].translations.zh.name",
+	}
+	if fmt.Sprint(fields) != fmt.Sprint(want) {
+		t.Errorf("problems %v, want %v", fields, want)
+	}
+
+	// Problems only found while writing roll back everything written before them
+	for name, catalog := range map[string]*Catalog{
+		"unknown category": {
+			Categories: []CatalogCategory{{Slug: "new", Name: "New"}},
+			Tools: []CatalogTool{
+				{Name: "Formatter", Category: "new", URL: "https://tion.work/formatter"},
+				{Name: "Orphan", Category: "missing", URL: "https://tion.work/orphan"},
+			},
+		},
+		"parent cycle": {
+			Categories: []CatalogCategory{
+				{Slug: "a", Name: "A", Parent: "b"},
+				{Slug: "b", Name: "B", Parent: "a"},
+			},
+		},
+		"nested under a descendant": {
+			Categories: []CatalogCategory{{Slug: "text", Name: "Text", Parent: "markdown"}},
+		},
+	} {
+		if _, err := NewCatalogService().Import(catalog, false, "test"); !errors.As(err, new(*ValidationError)) {
+			t.Errorf("%s: import = %v, want a validation error", name, err)
+		}
+	}
+	if after := exportCatalog(t); !reflect.DeepEqual(after, before) {
+		t.Errorf("failed imports changed the catalog to %+v", after)
+	}
+}
+
+func TestCatalogDryRun(t *testing.T) {
+	newTestDB(t)
+	importCatalog(t, testCatalog(), false)
+	before := exportCatalog(t)
+
+	changed := testCatalog()
+	changed.Tools[1].URL = "https://tion.work/markdown-preview"
+	changed.Tools = append(changed.Tools, CatalogTool{Name: "Cropper", Category: "image", URL: "https://tion.work/cropper"})
+
+	dryRun := importCatalog(t, changed, true)
+	if !dryRun.DryRun || dryRun.Created != 1 || dryRun.Updated != 1 {
+		t.Errorf("dry run report %+v, want one created and one updated tool", dryRun)
+	}
+	if after := exportCatalog(t); !reflect.DeepEqual(after, before) {
+		t.Errorf("dry run changed the catalog to %+v", after)
+	}
+
+	// The dry run reports exactly what the import then does
+	report := importCatalog(t, changed, false)
+	report.DryRun = true
+	if !reflect.DeepEqual(report, dryRun) {
+		t.Errorf("import report %+v, dry run reported %+v", report, dryRun)
+	}
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+type CategoryService struct {
+	db           *gorm.DB
+	translations *TranslationService
+}
+
+func NewCategoryService() *CategoryService {
+	return &CategoryService{
+		db:           database.GetDB(),
+		translations: NewTranslationService(),
+	}
+}
+
+// CategoryInput holds the fields for creating or updating a category
+type CategoryInput struct {
+	Slug      string
+	Name      string
+	Icon      string
+	SortOrder int
+	ParentID  *uint
+}
+
+// CategoryNode is a category with its active tool counts and nested children
+type CategoryNode struct {
+	models.Category
+	ToolCount      int64           `json:"tool_count"`
+	TotalToolCount int64           `json:"total_tool_count"`
+	Children       []*CategoryNode `json:"children,omitempty"`
+}
+
+// ListCategories gets all categories ordered by sort order with per-category tool counts.
+// TotalToolCount includes tools in nested categories. When tree is true only root
+// categories are returned, with descendants nested under Children.
+func (s *CategoryService) ListCategories(locales []string, tree bool) ([]*CategoryNode, error) {
+	var categories []models.Category
+	if err := s.db.Order("sort_order, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	if err := s.translations.LocalizeCategories(categories, locales); err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CategoryID uint
+		Count      int64
+	}
+	if err := s.db.Model(&models.Tool{}).
+		Select("category_id, COUNT(*) AS count").
+		Where("is_active = ? AND category_id IS NOT NULL", true).
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	ordered := make([]*CategoryNode, 0, len(categories))
+	for _, category := range categories {
+		node := &CategoryNode{Category: category}
+		nodes[category.ID] = node
+		ordered = append(ordered, node)
+	}
+	for _, count := range counts {
+		if node, ok := nodes[count.CategoryID]; ok {
+			node.ToolCount = count.Count
+		}
+	}
+
+	var roots []*CategoryNode
+	for _, node := range ordered {
+		if node.ParentID != nil {
+			if parent, ok := nodes[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	for _, root := range roots {
+		sumToolCounts(root)
+	}
+
+	if tree {
+		return roots, nil
+	}
+
+	for _, node := range ordered {
+		node.Children = nil
+	}
+	return ordered, nil
+}
+
+// GetCategory gets a category by ID or slug
+func (s *CategoryService) GetCategory(ref string) (*models.Category, error) {
+	var category models.Category
+	query := s.db.Where("slug = ?", ref)
+	if id, ok := parseID(ref); ok {
+		query = s.db.Where("id = ? OR slug = ?", id, ref)
+	}
+	if err := query.First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CreateCategory creates a new category
+func (s *CategoryService) CreateCategory(input CategoryInput) (*models.Category, error) {
+	slug := input.Slug
+	if slug == "" {
+		slug = utils.Slugify(input.Name)
+	}
+	if slug == "" {
+		return nil, newValidationError("slug", "could not be derived from the name")
+	}
+	if err := s.ensureSlugAvailable(slug, 0); err != nil {
+		return nil, err
+	}
+	if input.ParentID != nil {
+		if err := s.ensureCategoryExists(*input.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	category := &models.Category{
+		Slug:      slug,
+		Name:      input.Name,
+		Icon:      input.Icon,
+		SortOrder: input.SortOrder,
+		ParentID:  input.ParentID,
+	}
+	if err := s.db.Create(category).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory updates a category and returns the updated record
+func (s *CategoryService) UpdateCategory(id uint, updates map[string]interface{}) (*models.Category, error) {
+	var category models.Category
+	if err := s.db.First(&category, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+
+	if slug, ok := updates["slug"].(string); ok && slug != category.Slug {
+		if err := s.ensureSlugAvailable(slug, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if parentID, ok := updates["parent_id"].(*uint); ok && parentID != nil {
+		if err := s.ensureValidParent(id, *parentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Model(&category).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	// Tools are searched by the name of their category
+	invalidateFuzzyCandidates()
+	return &category, nil
+}
+
+// DeleteCategory deletes a category that has no tools or child categories
+func (s *CategoryService) DeleteCategory(id uint) error {
+	if err := s.ensureCategoryExists(id); err != nil {
+		return err
+	}
+
+	var inUse int64
+	if err := s.db.Model(&models.Tool{}).Where("category_id = ?", id).Count(&inUse).Error; err != nil {
+		return err
+	}
+	if inUse == 0 {
+		if err := s.db.Model(&models.Category{}).Where("parent_id = ?", id).Count(&inUse).Error; err != nil {
+			return err
+		}
+	}
+	if inUse > 0 {
+		return ErrCategoryInUse
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// Soft-deleted tools may still reference the category
+		if err := tx.Unscoped().Model(&models.Tool{}).
+			Where("category_id = ?", id).
+			Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("category_id = ?", id).Delete(&models.CategoryTranslation{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Category{}, id).Error
+	})
+}
+
+// DescendantIDs returns the ID of a category followed by the IDs of all nested categories
+func (s *CategoryService) DescendantIDs(id uint) ([]uint, error) {
+	var categories []models.Category
+	if err := s.db.Select("id", "parent_id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]uint)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []uint{id}
+	seen := map[uint]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range children[ids[i]] {
+			if !seen[child] {
+				seen[child] = true
+				ids = append(ids, child)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ensureValidParent checks that the parent exists and is not the category or one of its descendants
+func (s *CategoryService) ensureValidParent(id, parentID uint) error {
+	if err := s.ensureCategoryExists(parentID); err != nil {
+		return err
+	}
+	descendants, err := s.DescendantIDs(id)
+	if err != nil {
+		return err
+	}
+	for _, descendant := range descendants {
+		if descendant == parentID {
+			return newValidationError("parent_id", "a category cannot be nested under itself")
+		}
+	}
+	return nil
+}
+
+func (s *CategoryService) ensureCategoryExists(id uint) error {
+	var count int64
+	if err := s.db.Model(&models.Category{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+func (s *CategoryService) ensureSlugAvailable(slug string, excludeID uint) error {
+	var count int64
+	query := s.db.Model(&models.Category{}).Where("slug = ?", slug)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCategorySlugTaken
+	}
+	return nil
+}
+
+// sumToolCounts fills TotalToolCount for a node and its descendants and sorts children
+func sumToolCounts(node *CategoryNode) int64 {
+	total := node.ToolCount
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		return node.Children[i].SortOrder < node.Children[j].SortOrder
+	})
+	for _, child := range node.Children {
+		total += sumToolCounts(child)
+	}
+	node.TotalToolCount = total
+	return total
+}
+
+// parseID parses a positive numeric ID reference
+func parseID(ref string) (uint, bool) {
+	id, err := strconv.ParseUint(ref, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrToolNotFound is returned when a tool does not exist
+	ErrToolNotFound = errors.New("tool not found")
+
+	// ErrToolNameTaken is returned when another tool already uses the name
+	ErrToolNameTaken = errors.New("tool name already exists")
+
+	// ErrTranslationNotFound is returned when a tool or category has no translation for a locale
+	ErrTranslationNotFound = errors.New("translation not found")
+
+	// ErrCategoryNotFound is returned when a category does not exist
+	ErrCategoryNotFound = errors.New("category not found")
+
+	// ErrCategorySlugTaken is returned when another category already uses the slug
+	ErrCategorySlugTaken = errors.New("category slug already exists")
+
+	// ErrCategoryInUse is returned when deleting a category that still has tools or children
+	ErrCategoryInUse = errors.New("category still has tools or child categories")
+
+	// ErrTagNotFound is returned when a tag does not exist
+	ErrTagNotFound = errors.New("tag not found")
+
+	// ErrTagSlugTaken is returned when another tag already uses the slug
+	ErrTagSlugTaken = errors.New("tag slug already exists")
+
+	// ErrRevisionNotFound is returned when a tool has no revision with the given number
+	ErrRevisionNotFound = errors.New("revision not found")
+
+	// ErrLinkCheckRunning is returned when a link check run is already in progress
+	ErrLinkCheckRunning = errors.New("link check already running")
+
+	// ErrUsageQueueFull is returned when the usage queue stays full for the enqueue timeout
+	ErrUsageQueueFull = errors.New("usage queue is full")
+
+	// ErrUsageIngestClosed is returned when usage is recorded after ingestion was stopped
+	ErrUsageIngestClosed = errors.New("usage ingestion is stopped")
+
+	// ErrLiveFeedFull is returned when the live stats stream has no room for another client
+	ErrLiveFeedFull = errors.New("too many live stats clients")
+
+	// ErrLiveFeedClosed is returned when subscribing to live stats during shutdown
+	ErrLiveFeedClosed = errors.New("live stats are shutting down")
+
+	// ErrAPIKeyNotFound is returned when an API key does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// ErrAPIKeyInvalid is returned when authenticating with a key that does not exist
+	ErrAPIKeyInvalid = errors.New("invalid API key")
+
+	// ErrAPIKeyExpired is returned when authenticating with an expired key
+	ErrAPIKeyExpired = errors.New("API key has expired")
+
+	// ErrAPIKeyRevoked is returned when authenticating with a revoked key
+	ErrAPIKeyRevoked = errors.New("API key has been revoked")
+
+	// ErrUserNotFound is returned when a user does not exist
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUsernameTaken is returned when another user already has the username
+	ErrUsernameTaken = errors.New("username already exists")
+
+	// ErrLastAdmin is returned when a change would leave no enabled admin
+	ErrLastAdmin = errors.New("cannot remove the last enabled admin")
+
+	// ErrProjectGrantNotFound is returned when a subject has no grant on a project
+	ErrProjectGrantNotFound = errors.New("project grant not found")
+
+	// ErrAgentBudgetExceeded is returned when an actor has used up a daily
+	// agent run budget; the error is an *AgentBudgetError
+	ErrAgentBudgetExceeded = errors.New("daily agent budget exceeded")
+
+	// ErrAgentQueueFull is returned when no agent slot is free and the queue is full
+	ErrAgentQueueFull = errors.New("too many agent runs are waiting")
+
+	// ErrAgentQueueTimeout is returned when a queued agent run did not get a
+	// slot within the queue timeout
+	ErrAgentQueueTimeout = errors.New("timed out waiting for an agent slot")
+
+	// ErrInvalidCredentials is returned when signing in with an unknown user,
+	// a wrong password or a disabled account
+	ErrInvalidCredentials = errors.New("invalid username or password")
+
+	// ErrSessionInvalid is returned when authenticating with a session that
+	// does not exist, has expired or belongs to a disabled user
+	ErrSessionInvalid = errors.New("session is invalid or has expired")
+)
+
+// ValidationError describes an invalid input value
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+// newValidationError creates a validation error for a field
+func newValidationError(field, format string, args ...interface{}) error {
+	return &ValidationError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// ValidationErrors collects every invalid value found in a bulk input
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxLinkRedirects is the number of redirects followed before giving up
+	maxLinkRedirects = 10
+
+	// maxLinkBodyRead limits how much of a GET response is drained
+	maxLinkBodyRead = 64 << 10
+
+	linkCheckUserAgent = "tion-link-checker/1.0 (+https://tion.work)"
+)
+
+// LinkCheckerOptions configures a LinkChecker. Zero values fall back to defaults.
+type LinkCheckerOptions struct {
+	Client           *http.Client // injectable for tests; its CheckRedirect is replaced per probe
+	Interval         time.Duration
+	Timeout          time.Duration
+	Concurrency      int
+	FailureThreshold int
+	RetentionDays    int
+}
+
+// LinkCheckSummary reports the outcome of checking all tool links
+type LinkCheckSummary struct {
+	Checked    int           `json:"checked"`
+	Failed     int           `json:"failed"`
+	Flagged    int           `json:"flagged"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	PrunedRows int64         `json:"pruned_rows"`
+}
+
+// ToolLinkStatus is a tool with its most recent link check
+type ToolLinkStatus struct {
+	ToolID       uint                  `json:"tool_id"`
+	Name         string                `json:"name"`
+	URL          string                `json:"url"`
+	IsActive     bool                  `json:"is_active"`
+	LinkFailures int                   `json:"link_failures"`
+	LinkFlagged  bool                  `json:"link_flagged"`
+	LastCheck    *models.ToolLinkCheck `json:"last_check"`
+}
+
+// LinkChecker periodically probes tool URLs, records the results and flags
+// tools whose links keep failing
+type LinkChecker struct {
+	db      *gorm.DB
+	client  *http.Client
+	opts    LinkCheckerOptions
+	running atomic.Bool
+}
+
+func NewLinkChecker(opts LinkCheckerOptions) *LinkChecker {
+	if opts.Client == nil {
+		opts.Client = &http.Client{}
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 3
+	}
+	return &LinkChecker{
+		db:     database.GetDB(),
+		client: opts.Client,
+		opts:   opts,
+	}
+}
+
+// Start runs CheckAll every interval until the context is cancelled.
+// It does nothing when the interval is not positive.
+func (c *LinkChecker) Start(ctx context.Context) {
+	if c.opts.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				summary, err := c.CheckAll(ctx)
+				if errors.Is(err, ErrLinkCheckRunning) {
+					continue
+				}
+				c.logSummary(summary, err)
+			}
+		}
+	}()
+}
+
+func (c *LinkChecker) logSummary(summary *LinkCheckSummary, err error) {
+	if err != nil {
+		logging.Errorf("Link check failed: %v", err)
+		return
+	}
+	logging.Infof("Checked %d tool links in %s: %d failed, %d flagged",
+		summary.Checked, summary.Duration.Round(time.Millisecond), summary.Failed, summary.Flagged)
+}
+
+// CheckAll probes the absolute URLs of all tools, including inactive ones, and
+// prunes checks older than the retention period. Only one run may be in progress.
+func (c *LinkChecker) CheckAll(ctx context.Context) (*LinkCheckSummary, error) {
+	if !c.running.CompareAndSwap(false, true) {
+		return nil, ErrLinkCheckRunning
+	}
+	defer c.running.Store(false)
+
+	return c.checkAll(ctx)
+}
+
+// StartCheckAll runs CheckAll in the background and returns immediately
+func (c *LinkChecker) StartCheckAll(ctx context.Context) error {
+	if !c.running.CompareAndSwap(false, true) {
+		return ErrLinkCheckRunning
+	}
+
+	go func() {
+		defer c.running.Store(false)
+		c.logSummary(c.checkAll(ctx))
+	}()
+	return nil
+}
+
+func (c *LinkChecker) checkAll(ctx context.Context) (*LinkCheckSummary, error) {
+	summary := &LinkCheckSummary{StartedAt: time.Now()}
+
+	var tools []models.Tool
+	if err := c.db.Select("id", "url").Find(&tools).Error; err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.opts.Concurrency)
+
+	for _, tool := range tools {
+		if !isCheckableURL(tool.URL) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tool models.Tool) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			check := c.Probe(ctx, tool.URL)
+			if ctx.Err() != nil {
+				// Cancelled probes say nothing about the link
+				return
+			}
+
+			// Probes run concurrently but results are recorded one at a time,
+			// since SQLite does not allow concurrent writers
+			mu.Lock()
+			defer mu.Unlock()
+			flagged, err := c.recordCheck(tool.ID, &check)
+			if err != nil {
+				logging.Errorf("Failed to record link check for tool %d: %v", tool.ID, err)
+				return
+			}
+			summary.Checked++
+			if !check.OK {
+				summary.Failed++
+			}
+			if flagged {
+				summary.Flagged++
+			}
+		}(tool)
+	}
+	wg.Wait()
+
+	if c.opts.RetentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -c.opts.RetentionDays)
+		result := c.db.Where("checked_at < ?", cutoff).Delete(&models.ToolLinkCheck{})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		summary.PrunedRows = result.RowsAffected
+	}
+
+	summary.Duration = time.Since(summary.StartedAt)
+	return summary, ctx.Err()
+}
+
+// CheckTool probes the URL of a single tool now and records the result
+func (c *LinkChecker) CheckTool(ctx context.Context, toolID uint) (*models.ToolLinkCheck, error) {
+	var tool models.Tool
+	if err := c.db.Select("id", "url").First(&tool, toolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrToolNotFound
+		}
+		return nil, err
+	}
+	if !isCheckableURL(tool.URL) {
+		return nil, newValidationError("url", "%q is not an absolute http(s) URL", tool.URL)
+	}
+
+	check := c.Probe(ctx, tool.URL)
+	if _, err := c.recordCheck(tool.ID, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// GetToolChecks gets the most recent link checks of a tool, newest first
+func (c *LinkChecker) GetToolChecks(toolID uint, limit int) ([]models.ToolLinkCheck, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	var count int64
+	if err := c.db.Model(&models.Tool{}).Where("id = ?", toolID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrToolNotFound
+	}
+
+	var checks []models.ToolLinkCheck
+	err := c.db.Where("tool_id = ?", toolID).Order("checked_at DESC, id DESC").Limit(limit).Find(&checks).Error
+	return checks, err
+}
+
+// ListLinkStatus gets every tool with its latest link check, optionally only flagged tools
+func (c *LinkChecker) ListLinkStatus(flaggedOnly bool) ([]ToolLinkStatus, error) {
+	query := c.db.Model(&models.Tool{}).Order("id")
+	if flaggedOnly {
+		query = query.Where("link_flagged = ?", true)
+	}
+	var tools []models.Tool
+	if err := query.Find(&tools).Error; err != nil {
+		return nil, err
+	}
+
+	var checks []models.ToolLinkCheck
+	if err := c.db.Where("id IN (?)",
+		c.db.Model(&models.ToolLinkCheck{}).Select("MAX(id)").Group("tool_id"),
+	).Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	latest := make(map[uint]*models.ToolLinkCheck, len(checks))
+	for i := range checks {
+		latest[checks[i].ToolID] = &checks[i]
+	}
+
+	statuses := make([]ToolLinkStatus, 0, len(tools))
+	for _, tool := range tools {
+		statuses = append(statuses, ToolLinkStatus{
+			ToolID:       tool.ID,
+			Name:         tool.Name,
+			URL:          tool.URL,
+			IsActive:     tool.IsActive,
+			LinkFailures: tool.LinkFailures,
+			LinkFlagged:  tool.LinkFlagged,
+			LastCheck:    latest[tool.ID],
+		})
+	}
+	return statuses, nil
+}
+
+// Probe requests a URL with HEAD, retrying with GET when the server rejects
+// HEAD, and follows redirects. It does not touch the database.
+func (c *LinkChecker) Probe(ctx context.Context, rawURL string) models.ToolLinkCheck {
+	check := c.probe(ctx, http.MethodHead, rawURL)
+	if check.Error != "" || check.StatusCode >= 400 {
+		// Many servers answer HEAD with 403, 404 or 405 while GET works
+		check = c.probe(ctx, http.MethodGet, rawURL)
+	}
+	return check
+}
+
+func (c *LinkChecker) probe(ctx context.Context, method, rawURL string) models.ToolLinkCheck {
+	check := models.ToolLinkCheck{
+		URL:       rawURL,
+		Method:    method,
+		CheckedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	req.Header.Set("User-Agent", linkCheckUserAgent)
+
+	// Copy the client so counting redirects does not race between probes
+	client := *c.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		check.Redirects = len(via)
+		if len(via) >= maxLinkRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxLinkRedirects)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	check.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer resp.Body.Close()
+
+	if method == http.MethodGet {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxLinkBodyRead))
+	}
+
+	check.StatusCode = resp.StatusCode
+	check.FinalURL = resp.Request.URL.String()
+	check.OK = resp.StatusCode < 400
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		expires := resp.TLS.PeerCertificates[0].NotAfter
+		check.TLSExpiresAt = &expires
+	}
+	return check
+}
+
+// recordCheck stores a check and updates the failure count and flag of the
+// tool. It reports whether the tool is flagged afterwards.
+func (c *LinkChecker) recordCheck(toolID uint, check *models.ToolLinkCheck) (bool, error) {
+	check.ToolID = toolID
+
+	var flagged bool
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(check).Error; err != nil {
+			return err
+		}
+
+		tool := tx.Model(&models.Tool{}).Where("id = ?", toolID)
+		if check.OK {
+			return tool.UpdateColumns(map[string]interface{}{
+				"link_failures": 0,
+				"link_flagged":  false,
+			}).Error
+		}
+
+		if err := tool.UpdateColumn("link_failures", gorm.Expr("link_failures + 1")).Error; err != nil {
+			return err
+		}
+		var current models.Tool
+		if err := tx.Select("id", "link_failures").First(&current, toolID).Error; err != nil {
+			return err
+		}
+		flagged = current.LinkFailures >= c.opts.FailureThreshold
+		return tx.Model(&current).UpdateColumn("link_flagged", flagged).Error
+	})
+	return flagged, err
+}
+
+// isCheckableURL reports whether a URL points to an external http(s) site.
+// Relative URLs are internal pages of the site and are not probed.
+func isCheckableURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+// newLinkTestServer serves the paths the link checker tests probe
+func newLinkTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestLinkCheckerProbe(t *testing.T) {
+	newTestDB(t)
+	server := newLinkTestServer(t)
+	checker := NewLinkChecker(LinkCheckerOptions{
+		Client:  server.Client(),
+		Timeout: 100 * time.Millisecond,
+	})
+
+	tests := []struct {
+		path      string
+		ok        bool
+		status    int
+		method    string
+		redirects int
+		finalPath string
+		timeout   bool
+	}{
+		{path: "/ok", ok: true, status: http.StatusOK, method: http.MethodHead, finalPath: "/ok"},
+		{path: "/moved", ok: true, status: http.StatusOK, method: http.MethodHead, redirects: 1, finalPath: "/ok"},
+		{path: "/no-head", ok: true, status: http.StatusOK, method: http.MethodGet, finalPath: "/no-head"},
+		{path: "/missing", status: http.StatusNotFound, method: http.MethodGet, finalPath: "/missing"},
+		{path: "/broken", status: http.StatusServiceUnavailable, method: http.MethodGet, finalPath: "/broken"},
+		{path: "/slow", method: http.MethodGet, timeout: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.TrimPrefix(tt.path, "/"), func(t *testing.T) {
+			check := checker.Probe(context.Background(), server.URL+tt.path)
+
+			if check.OK != tt.ok {
+				t.Errorf("OK = %v, want %v (error %q)", check.OK, tt.ok, check.Error)
+			}
+			if check.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", check.StatusCode, tt.status)
+			}
+			if check.Method != tt.method {
+				t.Errorf("Method = %s, want %s", check.Method, tt.method)
+			}
+			if check.Redirects != tt.redirects {
+				t.Errorf("Redirects = %d, want %d", check.Redirects, tt.redirects)
+			}
+			if tt.finalPath != "" && check.FinalURL != server.URL+tt.finalPath {
+				t.Errorf("FinalURL = %s, want %s", check.FinalURL, server.URL+tt.finalPath)
+			}
+			if tt.timeout && !strings.Contains(check.Error, "deadline exceeded") {
+				t.Errorf("Error = %q, want a timeout", check.Error)
+			}
+		})
+	}
+}
+
+func TestLinkCheckerFailureThreshold(t *testing.T) {
+	db := newTestDB(t)
+	server := newLinkTestServer(t)
+	checker := NewLinkChecker(LinkCheckerOptions{
+		Client:           server.Client(),
+		Timeout:          time.Second,
+		FailureThreshold: 2,
+	})
+
+	tool := models.Tool{Name: "Broken", URL: server.URL + "/broken", IsActive: true}
+	internal := models.Tool{Name: "Internal", URL: "/tools/dca", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&internal).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	reload := func() models.Tool {
+		t.Helper()
+		var current models.Tool
+		if err := db.First(&current, tool.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return current
+	}
+
+	for run, wantFlagged := range []bool{false, true} {
+		summary, err := checker.CheckAll(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: %v", run+1, err)
+		}
+		if summary.Checked != 1 || summary.Failed != 1 {
+			t.Errorf("run %d: checked %d, failed %d, want 1 and 1 (relative URLs are skipped)", run+1, summary.Checked, summary.Failed)
+		}
+		current := reload()
+		if current.LinkFailures != run+1 || current.LinkFlagged != wantFlagged {
+			t.Errorf("run %d: failures %d, flagged %v, want %d and %v", run+1, current.LinkFailures, current.LinkFlagged, run+1, wantFlagged)
+		}
+	}
+
+	// The next successful check clears the flag
+	if err := db.Model(&tool).Update("url", server.URL+"/ok").Error; err != nil {
+		t.Fatal(err)
+	}
+	check, err := checker.CheckTool(context.Background(), tool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !check.OK {
+		t.Fatalf("check of /ok failed: %+v", check)
+	}
+	if current := reload(); current.LinkFailures != 0 || current.LinkFlagged {
+		t.Errorf("after success: failures %d, flagged %v, want 0 and false", current.LinkFailures, current.LinkFlagged)
+	}
+
+	var checks int64
+	db.Model(&models.ToolLinkCheck{}).Where("tool_id = ?", tool.ID).Count(&checks)
+	if checks != 3 {
+		t.Errorf("recorded %d checks, want 3", checks)
+	}
+}
+
+func TestLinkCheckerRetention(t *testing.T) {
+	db := newTestDB(t)
+	server := newLinkTestServer(t)
+	checker := NewLinkChecker(LinkCheckerOptions{
+		Client:        server.Client(),
+		Timeout:       time.Second,
+		RetentionDays: 30,
+	})
+
+	tool := models.Tool{Name: "OK", URL: server.URL + "/ok", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+	old := []models.ToolLinkCheck{
+		{ToolID: tool.ID, URL: tool.URL, OK: true, CheckedAt: time.Now().AddDate(0, 0, -31)},
+		{ToolID: tool.ID, URL: tool.URL, OK: true, CheckedAt: time.Now().AddDate(0, 0, -45)},
+		{ToolID: tool.ID, URL: tool.URL, OK: true, CheckedAt: time.Now().AddDate(0, 0, -29)},
+	}
+	if err := db.Create(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := checker.CheckAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.PrunedRows != 2 {
+		t.Errorf("pruned %d rows, want 2", summary.PrunedRows)
+	}
+
+	var kept int64
+	db.Model(&models.ToolLinkCheck{}).Count(&kept)
+	if kept != 2 {
+		t.Errorf("kept %d checks, want the recent one and the new one", kept)
+	}
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+)
+
+// Live event types
+const (
+	LiveEventUsage     = "usage"     // a tool use, carries an ID for resuming
+	LiveEventCounters  = "counters"  // rolling per-minute usage counters
+	LiveEventHeartbeat = "heartbeat" // keeps idle connections open
+	LiveEventDropped   = "dropped"   // usage events were dropped because the client fell behind
+	LiveEventReset     = "reset"     // events since Last-Event-ID are no longer available
+)
+
+const (
+	// liveCounterMinutes is how many minutes the rolling counters cover
+	liveCounterMinutes = 60
+
+	// liveCountersInterval is how often counters are pushed to subscribers
+	liveCountersInterval = 5 * time.Second
+)
+
+// LiveFeedOptions configures a LiveFeed. Zero values fall back to defaults.
+type LiveFeedOptions struct {
+	History    int // usage events kept for Last-Event-ID resume
+	BufferSize int // usage events buffered per subscriber before they are dropped
+	MaxClients int
+	Heartbeat  time.Duration // how often idle streams send a heartbeat
+}
+
+// LiveUsage is a tool use as shown on the live dashboard, without visitor data
+type LiveUsage struct {
+	ToolID      uint   `json:"tool_id"`
+	Source      string `json:"source,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	DeviceClass string `json:"device_class,omitempty"`
+	Position    int    `json:"position,omitempty"`
+}
+
+// LiveMinute is the number of uses in the minute starting at Minute
+type LiveMinute struct {
+	Minute time.Time `json:"minute"`
+	Count  int64     `json:"count"`
+}
+
+// LiveCounters are the usage counts of the last hour, oldest minute first
+type LiveCounters struct {
+	Minutes    []LiveMinute `json:"minutes"`
+	LastMinute int64        `json:"last_minute"`
+	LastHour   int64        `json:"last_hour"`
+}
+
+// LiveEvent is a message of the live stats stream
+type LiveEvent struct {
+	ID        uint64        `json:"id,omitempty"`
+	Type      string        `json:"type"`
+	Timestamp time.Time     `json:"timestamp"`
+	Usage     *LiveUsage    `json:"usage,omitempty"`
+	Counters  *LiveCounters `json:"counters,omitempty"`
+	Dropped   int64         `json:"dropped,omitempty"`
+}
+
+// LiveSubscription receives the events of a LiveFeed. Usage events arrive on
+// Events and are dropped while the buffer is full; counters are coalesced so
+// that only the latest snapshot is delivered.
+type LiveSubscription struct {
+	events   chan LiveEvent
+	counters chan struct{}
+
+	mu      sync.Mutex
+	latest  *LiveCounters
+	dropped int64
+}
+
+// Events delivers usage events. It is closed when the feed shuts down.
+func (s *LiveSubscription) Events() <-chan LiveEvent {
+	return s.events
+}
+
+// CountersReady signals that a new counters snapshot is available
+func (s *LiveSubscription) CountersReady() <-chan struct{} {
+	return s.counters
+}
+
+// Counters returns the latest counters snapshot
+func (s *LiveSubscription) Counters() *LiveCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
+
+// TakeDropped returns how many usage events were dropped since the last call
+func (s *LiveSubscription) TakeDropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// LiveFeed fans out recorded tool usage and rolling per-minute counters to
+// live stats subscribers
+type LiveFeed struct {
+	db   *gorm.DB
+	opts LiveFeedOptions
+
+	mu          sync.RWMutex
+	subscribers map[*LiveSubscription]struct{}
+	history     []LiveEvent // ring of the last opts.History usage events
+	next        int         // index in history of the next event
+	lastID      uint64
+	minutes     map[int64]int64 // unix minute to count
+	closed      bool
+}
+
+func NewLiveFeed(opts LiveFeedOptions) *LiveFeed {
+	if opts.History <= 0 {
+		opts.History = 500
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+	if opts.MaxClients <= 0 {
+		opts.MaxClients = 100
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = 15 * time.Second
+	}
+	return &LiveFeed{
+		db:          database.GetDB(),
+		opts:        opts,
+		subscribers: make(map[*LiveSubscription]struct{}),
+		// Event IDs continue from the start time, so that IDs from before a
+		// restart are recognised as unavailable instead of being reused
+		lastID:  uint64(time.Now().UnixMilli()) * 1000,
+		minutes: make(map[int64]int64),
+	}
+}
+
+// Start seeds the counters with the usage of the last hour and pushes them to
+// subscribers until the context is cancelled, which ends every subscription
+func (f *LiveFeed) Start(ctx context.Context) {
+	if err := f.seedCounters(); err != nil {
+		logging.Errorf("Failed to seed live usage counters: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(liveCountersInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				f.close()
+				return
+			case <-ticker.C:
+				f.pushCounters()
+			}
+		}
+	}()
+}
+
+// Subscribe registers a subscriber and returns the usage events after
+// lastEventID to replay, or a single reset event when some of them are no
+// longer kept. A zero lastEventID replays nothing.
+func (f *LiveFeed) Subscribe(lastEventID uint64) (*LiveSubscription, []LiveEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, nil, ErrLiveFeedClosed
+	}
+	if len(f.subscribers) >= f.opts.MaxClients {
+		return nil, nil, ErrLiveFeedFull
+	}
+
+	sub := &LiveSubscription{
+		events:   make(chan LiveEvent, f.opts.BufferSize),
+		counters: make(chan struct{}, 1),
+		latest:   f.countersLocked(time.Now()),
+	}
+	f.subscribers[sub] = struct{}{}
+
+	var replay []LiveEvent
+	if lastEventID != 0 && lastEventID != f.lastID {
+		history := f.historyLocked()
+		if lastEventID > f.lastID || len(history) == 0 || lastEventID+1 < history[0].ID {
+			replay = []LiveEvent{{Type: LiveEventReset, Timestamp: time.Now()}}
+		} else {
+			for _, event := range history {
+				if event.ID > lastEventID {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+	return sub, replay, nil
+}
+
+// Unsubscribe removes a subscriber
+func (f *LiveFeed) Unsubscribe(sub *LiveSubscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[sub]; ok {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Heartbeat returns how often idle streams should send a heartbeat
+func (f *LiveFeed) Heartbeat() time.Duration {
+	return f.opts.Heartbeat
+}
+
+// Clients returns the number of subscribers
+func (f *LiveFeed) Clients() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.subscribers)
+}
+
+// PublishUsage counts recorded usage rows and sends them to subscribers.
+// Subscribers whose buffer is full miss the events and are told how many.
+func (f *LiveFeed) PublishUsage(rows []models.ToolUsage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return
+	}
+
+	for _, row := range rows {
+		f.minutes[row.CreatedAt.Unix()/60]++
+
+		f.lastID++
+		event := LiveEvent{
+			ID:        f.lastID,
+			Type:      LiveEventUsage,
+			Timestamp: row.CreatedAt,
+			Usage: &LiveUsage{
+				ToolID:      row.ToolID,
+				Source:      row.Source,
+				Locale:      row.Locale,
+				DeviceClass: row.DeviceClass,
+				Position:    row.Position,
+			},
+		}
+		if len(f.history) < f.opts.History {
+			f.history = append(f.history, event)
+		} else {
+			f.history[f.next] = event
+		}
+		f.next = (f.next + 1) % f.opts.History
+
+		for sub := range f.subscribers {
+			select {
+			case sub.events <- event:
+			default:
+				sub.mu.Lock()
+				sub.dropped++
+				sub.mu.Unlock()
+			}
+		}
+	}
+}
+
+// pushCounters replaces the counters snapshot of every subscriber
+func (f *LiveFeed) pushCounters() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Unix()/60 - liveCounterMinutes
+	for minute := range f.minutes {
+		if minute <= cutoff {
+			delete(f.minutes, minute)
+		}
+	}
+
+	counters := f.countersLocked(now)
+	for sub := range f.subscribers {
+		sub.mu.Lock()
+		sub.latest = counters
+		sub.mu.Unlock()
+		select {
+		case sub.counters <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close ends every subscription and stops accepting new ones
+func (f *LiveFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for sub := range f.subscribers {
+		delete(f.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// countersLocked builds the counters of the hour up to now, zero-filled
+func (f *LiveFeed) countersLocked(now time.Time) *LiveCounters {
+	current := now.Unix() / 60
+	counters := &LiveCounters{Minutes: make([]LiveMinute, 0, liveCounterMinutes)}
+	for minute := current - liveCounterMinutes + 1; minute <= current; minute++ {
+		count := f.minutes[minute]
+		counters.Minutes = append(counters.Minutes, LiveMinute{Minute: time.Unix(minute*60, 0).UTC(), Count: count})
+		counters.LastHour += count
+	}
+	counters.LastMinute = f.minutes[current]
+	return counters
+}
+
+// historyLocked returns the kept usage events, oldest first
+func (f *LiveFeed) historyLocked() []LiveEvent {
+	if len(f.history) < f.opts.History {
+		return f.history
+	}
+	return append(append([]LiveEvent{}, f.history[f.next:]...), f.history[:f.next]...)
+}
+
+// seedCounters loads the per-minute usage of the last hour from the raw rows
+func (f *LiveFeed) seedCounters() error {
+	since := time.Now().Truncate(time.Minute).Add(-(liveCounterMinutes - 1) * time.Minute)
+
+	var rows []struct {
+		Minute int64
+		Count  int64
+	}
+	if err := f.db.Model(&models.ToolUsage{}).
+		Select(epochExpr(f.db, "created_at")+" / 60 AS minute, COUNT(*) AS count").
+		Where("created_at >= ?", timeArg(f.db, since)).
+		Group("minute").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, row := range rows {
+		f.minutes[row.Minute] += row.Count
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+// publishUses publishes one use per tool ID, made now
+func publishUses(feed *LiveFeed, toolIDs ...uint) {
+	rows := make([]models.ToolUsage, len(toolIDs))
+	for i, id := range toolIDs {
+		rows[i] = models.ToolUsage{ToolID: id, CreatedAt: time.Now()}
+	}
+	feed.PublishUsage(rows)
+}
+
+// receiveEvents reads n buffered events of a subscription
+func receiveEvents(t *testing.T, sub *LiveSubscription, n int) []LiveEvent {
+	t.Helper()
+	events := make([]LiveEvent, 0, n)
+	for len(events) < n {
+		select {
+		case event := <-sub.Events():
+			events = append(events, event)
+		default:
+			t.Fatalf("received %d events, want %d", len(events), n)
+		}
+	}
+	return events
+}
+
+// replayedTools lists the tool IDs of replayed usage events, or the types of other events
+func replayedTools(events []LiveEvent) string {
+	var tools []string
+	for _, event := range events {
+		if event.Usage != nil {
+			tools = append(tools, fmt.Sprint(event.Usage.ToolID))
+		} else {
+			tools = append(tools, event.Type)
+		}
+	}
+	return fmt.Sprint(tools)
+}
+
+func TestLiveFeedResume(t *testing.T) {
+	newTestDB(t)
+	feed := NewLiveFeed(LiveFeedOptions{History: 3})
+	watcher, _, err := feed.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishUses(feed, 1, 2, 3, 4, 5)
+	events := receiveEvents(t, watcher, 5)
+	for i := 1; i < len(events); i++ {
+		if events[i].ID != events[i-1].ID+1 {
+			t.Fatalf("event IDs %d and %d are not consecutive", events[i-1].ID, events[i].ID)
+		}
+	}
+
+	// Only the last three events are kept
+	tests := []struct {
+		lastEventID uint64
+		want        string
+	}{
+		{0, "[]"},
+		{events[4].ID, "[]"},
+		{events[2].ID, "[4 5]"},
+		{events[1].ID, "[3 4 5]"},
+		{events[0].ID, "[reset]"},
+		{events[4].ID + 1, "[reset]"}, // from before a restart
+	}
+	for _, tt := range tests {
+		sub, replay, err := feed.Subscribe(tt.lastEventID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := replayedTools(replay); got != tt.want {
+			t.Errorf("resume after %d replayed %s, want %s", tt.lastEventID, got, tt.want)
+		}
+		feed.Unsubscribe(sub)
+	}
+
+	// A feed started later, as after a restart, continues past the old IDs
+	time.Sleep(2 * time.Millisecond)
+	if restarted := NewLiveFeed(LiveFeedOptions{}); restarted.lastID <= events[4].ID {
+		t.Errorf("restarted feed continues at %d, before %d", restarted.lastID, events[4].ID)
+	}
+}
+
+func TestLiveFeedSlowSubscriber(t *testing.T) {
+	newTestDB(t)
+	feed := NewLiveFeed(LiveFeedOptions{BufferSize: 2})
+	slow, _, err := feed.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publishUses(feed, 1, 2, 3, 4, 5)
+	if got := replayedTools(receiveEvents(t, slow, 2)); got != "[1 2]" {
+		t.Errorf("slow subscriber received %s, want [1 2]", got)
+	}
+	select {
+	case event := <-slow.Events():
+		t.Errorf("received %+v past the buffer", event)
+	default:
+	}
+	if dropped := slow.TakeDropped(); dropped != 3 {
+		t.Errorf("dropped %d events, want 3", dropped)
+	}
+	if dropped := slow.TakeDropped(); dropped != 0 {
+		t.Errorf("dropped count not reset: %d", dropped)
+	}
+
+	// Counters are coalesced into a single signal with the latest snapshot
+	feed.pushCounters()
+	publishUses(feed, 6)
+	feed.pushCounters()
+	<-slow.CountersReady()
+	select {
+	case <-slow.CountersReady():
+		t.Error("two counter updates were queued")
+	default:
+	}
+	counters := slow.Counters()
+	if counters.LastHour != 6 || counters.LastMinute == 0 || len(counters.Minutes) != liveCounterMinutes {
+		t.Errorf("counters %d in the last hour, %d in the last minute over %d minutes, want 6 over %d",
+			counters.LastHour, counters.LastMinute, len(counters.Minutes), liveCounterMinutes)
+	}
+}
+
+func TestLiveFeedClients(t *testing.T) {
+	newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "calculator")[0]
+	recordUsageAt(t, tool.ID, time.Now().Add(-10*time.Minute), time.Now().Add(-2*time.Hour))
+
+	feed := NewLiveFeed(LiveFeedOptions{MaxClients: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	feed.Start(ctx)
+
+	sub, _, err := feed.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The counters are seeded with the last hour of stored usage
+	if counters := sub.Counters(); counters.LastHour != 1 {
+		t.Errorf("seeded %d uses in the last hour, want 1", counters.LastHour)
+	}
+	if _, _, err := feed.Subscribe(0); !errors.Is(err, ErrLiveFeedFull) {
+		t.Errorf("subscribe past the limit = %v, want ErrLiveFeedFull", err)
+	}
+	feed.Unsubscribe(sub)
+	if _, ok := <-sub.Events(); ok {
+		t.Error("events still open after unsubscribing")
+	}
+
+	// Shutting down ends the open subscriptions
+	sub, _, err = feed.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case _, ok := <-sub.Events():
+		if ok {
+			t.Error("received an event instead of the end of the stream")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription still open after shutdown")
+	}
+	if _, _, err := feed.Subscribe(0); !errors.Is(err, ErrLiveFeedClosed) {
+		t.Errorf("subscribe after shutdown = %v, want ErrLiveFeedClosed", err)
+	}
+}
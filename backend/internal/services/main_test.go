@@ -0,0 +1,37 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"tion.work/backend/internal/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB migrates a fresh SQLite database for one test and makes it the
+// database the services use. A file is used instead of memory, so that
+// concurrent writers wait for each other rather than fail.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database.SetLogger(logger.Discard)
+
+	// Immediate transactions wait on the busy timeout for the write lock; a
+	// deferred one that upgrades to writing fails at once with SQLITE_BUSY
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+	db, err := database.Connect(sqlite.Open(dsn))
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("test database handle: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	database.DB = db
+	// Cached search candidates belong to the database of the previous test
+	invalidateFuzzyCandidates()
+	return db
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const auditTargetProject = "project"
+
+// ProjectAccessService manages and checks the rights users and API keys hold
+// on workspace projects
+type ProjectAccessService struct {
+	db *gorm.DB
+}
+
+func NewProjectAccessService() *ProjectAccessService {
+	return &ProjectAccessService{
+		db: database.GetDB(),
+	}
+}
+
+// ProjectSubject is the user or API key a grant applies to
+type ProjectSubject struct {
+	Type string `json:"subject_type"`
+	ID   uint   `json:"subject_id"`
+}
+
+func (s ProjectSubject) String() string {
+	return fmt.Sprintf("%s:%d", s.Type, s.ID)
+}
+
+// ProjectGrantInput sets the rights of a subject on a project
+type ProjectGrantInput struct {
+	SubjectType string   `json:"subject_type"`
+	SubjectID   uint     `json:"subject_id"`
+	Rights      []string `json:"rights"`
+}
+
+// ListGrants gets the grants on a project, including grants on every
+// project, or all grants when project is empty
+func (s *ProjectAccessService) ListGrants(project string) ([]models.ProjectGrant, error) {
+	query := s.db.Model(&models.ProjectGrant{})
+	if project != "" {
+		query = query.Where("project IN ?", []string{project, models.AllProjects})
+	}
+
+	grants := make([]models.ProjectGrant, 0)
+	if err := query.Order("project, subject_type, subject_id").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// SetGrant replaces the rights of a subject on a project, creating the grant
+// when there is none
+func (s *ProjectAccessService) SetGrant(project string, input ProjectGrantInput, actor string) (*models.ProjectGrant, error) {
+	if project == "" {
+		return nil, newValidationError("project", "is required")
+	}
+	rights, err := normalizeProjectRights(input.Rights)
+	if err != nil {
+		return nil, err
+	}
+	subject := ProjectSubject{Type: input.SubjectType, ID: input.SubjectID}
+
+	var grant models.ProjectGrant
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkGrantSubject(tx, subject); err != nil {
+			return err
+		}
+
+		var old models.ProjectRightList
+		err := tx.Where("project = ? AND subject_type = ? AND subject_id = ?", project, subject.Type, subject.ID).
+			First(&grant).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			grant = models.ProjectGrant{
+				Project:     project,
+				SubjectType: subject.Type,
+				SubjectID:   subject.ID,
+				Rights:      rights,
+				GrantedBy:   actor,
+			}
+			if err := tx.Create(&grant).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			old = grant.Rights
+			if err := tx.Model(&grant).Updates(map[string]interface{}{
+				"rights":     rights,
+				"granted_by": actor,
+			}).Error; err != nil {
+				return err
+			}
+			grant.Rights = rights
+			grant.GrantedBy = actor
+		}
+
+		return recordAudit(tx, actor, models.AuditProjectGrant, auditTargetProject, project, models.AuditDetails{
+			"subject": subject.String(),
+			"old":     old,
+			"rights":  rights,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeGrant removes every right of a subject on a project
+func (s *ProjectAccessService) RevokeGrant(project string, subject ProjectSubject, actor string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var grant models.ProjectGrant
+		if err := tx.Where("project = ? AND subject_type = ? AND subject_id = ?", project, subject.Type, subject.ID).
+			First(&grant).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrProjectGrantNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&grant).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditProjectRevoke, auditTargetProject, project, models.AuditDetails{
+			"subject": subject.String(),
+			"rights":  grant.Rights,
+		})
+	})
+}
+
+// Rights gets the rights a subject holds on a project, through grants on the
+// project and on every project
+func (s *ProjectAccessService) Rights(subject ProjectSubject, project string) (models.ProjectRightList, error) {
+	var grants []models.ProjectGrant
+	if err := s.db.Where("subject_type = ? AND subject_id = ? AND project IN ?",
+		subject.Type, subject.ID, []string{project, models.AllProjects}).
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	var rights models.ProjectRightList
+	for _, grant := range grants {
+		rights = append(rights, grant.Rights...)
+	}
+	return rights, nil
+}
+
+// RightsByProject gets the rights of a subject on each project it has a grant
+// on. Rights on every project are under AllProjects.
+func (s *ProjectAccessService) RightsByProject(subject ProjectSubject) (map[string]models.ProjectRightList, error) {
+	var grants []models.ProjectGrant
+	if err := s.db.Where("subject_type = ? AND subject_id = ?", subject.Type, subject.ID).
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+
+	rights := make(map[string]models.ProjectRightList, len(grants))
+	for _, grant := range grants {
+		rights[grant.Project] = grant.Rights
+	}
+	return rights, nil
+}
+
+// checkGrantSubject checks that the user or API key of a grant exists
+func checkGrantSubject(tx *gorm.DB, subject ProjectSubject) error {
+	var model interface{}
+	var notFound error
+	switch subject.Type {
+	case models.GrantSubjectUser:
+		model, notFound = &models.User{}, ErrUserNotFound
+	case models.GrantSubjectAPIKey:
+		model, notFound = &models.APIKey{}, ErrAPIKeyNotFound
+	default:
+		return newValidationError("subject_type", "must be %s or %s", models.GrantSubjectUser, models.GrantSubjectAPIKey)
+	}
+
+	var count int64
+	if err := tx.Model(model).Where("id = ?", subject.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// normalizeProjectRights validates rights and removes duplicates
+func normalizeProjectRights(rights []string) (models.ProjectRightList, error) {
+	if len(rights) == 0 {
+		return nil, newValidationError("rights", "at least one right is required, revoke the grant to remove every right")
+	}
+	list := make(models.ProjectRightList, 0, len(rights))
+	for _, right := range uniqueStrings(rights) {
+		if !isProjectRight(right) {
+			return nil, newValidationError("rights", "unknown right %q, expected one of %s", right, strings.Join(models.ProjectRights, ", "))
+		}
+		list = append(list, right)
+	}
+	return list, nil
+}
+
+func isProjectRight(right string) bool {
+	for _, known := range models.ProjectRights {
+		if right == known {
+			return true
+		}
+	}
+	return false
+}
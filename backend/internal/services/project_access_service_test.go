@@ -0,0 +1,139 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+func TestProjectAccessServiceSetGrantValidation(t *testing.T) {
+	newTestDB(t)
+	users := NewUserService(time.Hour)
+	user := createTestUser(t, users, "erin", models.RoleDeveloper)
+	access := NewProjectAccessService()
+
+	tests := []struct {
+		name    string
+		project string
+		input   ProjectGrantInput
+		field   string
+		wantErr error
+	}{
+		{"no project", "", ProjectGrantInput{SubjectType: models.GrantSubjectUser, SubjectID: user.ID, Rights: []string{"read"}}, "project", nil},
+		{"no rights", "site", ProjectGrantInput{SubjectType: models.GrantSubjectUser, SubjectID: user.ID}, "rights", nil},
+		{"unknown right", "site", ProjectGrantInput{SubjectType: models.GrantSubjectUser, SubjectID: user.ID, Rights: []string{"admin"}}, "rights", nil},
+		{"unknown subject type", "site", ProjectGrantInput{SubjectType: "team", SubjectID: 1, Rights: []string{"read"}}, "subject_type", nil},
+		{"missing user", "site", ProjectGrantInput{SubjectType: models.GrantSubjectUser, SubjectID: 999, Rights: []string{"read"}}, "", ErrUserNotFound},
+		{"missing API key", "site", ProjectGrantInput{SubjectType: models.GrantSubjectAPIKey, SubjectID: 999, Rights: []string{"read"}}, "", ErrAPIKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := access.SetGrant(tt.project, tt.input, "test")
+			if tt.field != "" && !isValidationError(err, tt.field) {
+				t.Errorf("SetGrant = %v, want a validation error on %s", err, tt.field)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetGrant = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if grants, err := access.ListGrants(""); err != nil || len(grants) != 0 {
+		t.Errorf("ListGrants = %v, %v, want no grants", grants, err)
+	}
+}
+
+func TestProjectAccessServiceRights(t *testing.T) {
+	db := newTestDB(t)
+	users := NewUserService(time.Hour)
+	user := createTestUser(t, users, "frank", models.RoleDeveloper)
+	other := createTestUser(t, users, "grace", models.RoleDeveloper)
+	access := NewProjectAccessService()
+	subject := ProjectSubject{Type: models.GrantSubjectUser, ID: user.ID}
+
+	rightsOn := func(subject ProjectSubject, project string) models.ProjectRightList {
+		t.Helper()
+		rights, err := access.Rights(subject, project)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rights
+	}
+
+	// Without grants a subject holds no rights, not even read
+	if rights := rightsOn(subject, "site"); rights.Has(models.ProjectRightRead) {
+		t.Errorf("rights without grants %v", rights)
+	}
+
+	grant := func(project string, rights ...string) {
+		t.Helper()
+		input := ProjectGrantInput{SubjectType: subject.Type, SubjectID: subject.ID, Rights: rights}
+		if _, err := access.SetGrant(project, input, "admin"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grant("site", models.ProjectRightAgent, models.ProjectRightAgent)
+
+	rights := rightsOn(subject, "site")
+	if !rights.Has(models.ProjectRightRead) || !rights.Has(models.ProjectRightAgent) || rights.Has(models.ProjectRightCommit) {
+		t.Errorf("rights on site %v, want read and agent", rights)
+	}
+	if rights := rightsOn(subject, "blog"); len(rights) != 0 {
+		t.Errorf("rights on an ungranted project %v", rights)
+	}
+	if rights := rightsOn(ProjectSubject{Type: models.GrantSubjectUser, ID: other.ID}, "site"); len(rights) != 0 {
+		t.Errorf("rights of another user %v", rights)
+	}
+
+	// Setting a grant again replaces its rights
+	grant("site", models.ProjectRightCommit)
+	if rights := rightsOn(subject, "site"); rights.Has(models.ProjectRightAgent) || !rights.Has(models.ProjectRightCommit) {
+		t.Errorf("rights after the update %v, want only commit", rights)
+	}
+
+	// A grant on every project adds to the grants on each project
+	grant(models.AllProjects, models.ProjectRightDeploy)
+	if rights := rightsOn(subject, "blog"); !rights.Has(models.ProjectRightDeploy) || rights.Has(models.ProjectRightCommit) {
+		t.Errorf("rights on blog %v, want deploy from the grant on every project", rights)
+	}
+	if rights := rightsOn(subject, "site"); !rights.Has(models.ProjectRightDeploy) || !rights.Has(models.ProjectRightCommit) {
+		t.Errorf("rights on site %v, want commit and deploy", rights)
+	}
+	byProject, err := access.RightsByProject(subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(byProject); got != "map[*:[deploy] site:[commit]]" {
+		t.Errorf("RightsByProject = %s", got)
+	}
+
+	if err := access.RevokeGrant("site", subject, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if rights := rightsOn(subject, "site"); rights.Has(models.ProjectRightCommit) {
+		t.Errorf("rights on site after the revoke %v", rights)
+	}
+	if err := access.RevokeGrant("site", subject, "admin"); !errors.Is(err, ErrProjectGrantNotFound) {
+		t.Errorf("revoking twice = %v, want ErrProjectGrantNotFound", err)
+	}
+
+	entries := auditEntries(t, db)
+	// The user service audits the two user creations first
+	entries = entries[2:]
+	if got := auditActions(entries); got != "[project.grant project.grant project.grant project.revoke]" {
+		t.Fatalf("audit actions %s", got)
+	}
+	for _, entry := range entries {
+		if entry.Actor != "admin" || entry.TargetType != "project" || entry.Details["subject"] != subject.String() {
+			t.Errorf("audit entry %+v", entry)
+		}
+	}
+	if entries[0].TargetID != "site" || entries[2].TargetID != models.AllProjects {
+		t.Errorf("audit targets %s and %s", entries[0].TargetID, entries[2].TargetID)
+	}
+	if got := fmt.Sprint(entries[1].Details["old"], entries[1].Details["rights"]); got != "[agent] [commit]" {
+		t.Errorf("update audit details %s, want the old and new rights", got)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// requestMinute holds the request counters of one minute
+type requestMinute struct {
+	minute    int64 // unix minute, identifies the minute the slot currently holds
+	requests  int64
+	errors    int64
+	latencyMs int64
+}
+
+// RequestWindow sums the request counters over a time range
+type RequestWindow struct {
+	Requests  int64
+	Errors    int64 // responses with a 5xx status
+	LatencyMs int64 // total latency of all requests
+}
+
+// RequestMetrics counts API requests, server errors and latency per minute
+// in memory, as input for alert rules. Counts are lost on restart.
+type RequestMetrics struct {
+	mu      sync.Mutex
+	minutes []requestMinute // ring indexed by unix minute
+	started int64           // first unix minute that was fully recorded
+}
+
+// NewRequestMetrics keeps per-minute counters for the given retention, at
+// least one hour
+func NewRequestMetrics(retention time.Duration) *RequestMetrics {
+	size := int(retention / time.Minute)
+	if size < 60 {
+		size = 60
+	}
+	return &RequestMetrics{
+		minutes: make([]requestMinute, size),
+		started: time.Now().Unix()/60 + 1,
+	}
+}
+
+// Record counts a finished request
+func (m *RequestMetrics) Record(status int, latency time.Duration) {
+	minute := time.Now().Unix() / 60
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slot := &m.minutes[minute%int64(len(m.minutes))]
+	if slot.minute != minute {
+		*slot = requestMinute{minute: minute}
+	}
+	slot.requests++
+	if status >= http.StatusInternalServerError {
+		slot.errors++
+	}
+	slot.latencyMs += latency.Milliseconds()
+}
+
+// Window sums the counters of the whole minutes in [from, to). It reports
+// false when part of the range was not recorded, because it lies before the
+// process started or beyond the retention.
+func (m *RequestMetrics) Window(from, to time.Time) (RequestWindow, bool) {
+	first, last := from.Unix()/60, to.Unix()/60
+	current := time.Now().Unix() / 60
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if first < m.started || first <= current-int64(len(m.minutes)) || last > current+1 {
+		return RequestWindow{}, false
+	}
+
+	var window RequestWindow
+	for minute := first; minute < last; minute++ {
+		slot := m.minutes[minute%int64(len(m.minutes))]
+		if slot.minute != minute {
+			continue
+		}
+		window.Requests += slot.requests
+		window.Errors += slot.errors
+		window.LatencyMs += slot.latencyMs
+	}
+	return window, true
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const auditTargetTool = "tool"
+
+type RevisionService struct {
+	db    *gorm.DB
+	tools *ToolService
+}
+
+func NewRevisionService() *RevisionService {
+	return &RevisionService{
+		db:    database.GetDB(),
+		tools: NewToolService(),
+	}
+}
+
+// RevisionPage is a single page of tool revisions
+type RevisionPage struct {
+	Revisions []models.ToolRevision `json:"revisions"`
+	Total     int64                 `json:"total"`
+	Limit     int                   `json:"limit"`
+	Offset    int                   `json:"offset"`
+}
+
+// RevisionFieldChange is a field that differs between two revisions
+type RevisionFieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RevisionDiff lists the fields changed from one revision to another
+type RevisionDiff struct {
+	ToolID  uint                  `json:"tool_id"`
+	From    int                   `json:"from"`
+	To      int                   `json:"to"`
+	Changes []RevisionFieldChange `json:"changes"`
+}
+
+// ListRevisions gets the revisions of a tool, including a deleted one, newest first
+func (s *RevisionService) ListRevisions(toolID uint, limit, offset int) (*RevisionPage, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if offset < 0 {
+		return nil, newValidationError("offset", "must not be negative")
+	}
+
+	query := s.db.Model(&models.ToolRevision{}).Where("tool_id = ?", toolID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		if err := s.ensureToolExists(toolID); err != nil {
+			return nil, err
+		}
+	}
+
+	revisions := make([]models.ToolRevision, 0)
+	if err := query.Order("revision DESC").Offset(offset).Limit(limit).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	return &RevisionPage{
+		Revisions: revisions,
+		Total:     total,
+		Limit:     limit,
+		Offset:    offset,
+	}, nil
+}
+
+// GetRevision gets a single revision of a tool
+func (s *RevisionService) GetRevision(toolID uint, revision int) (*models.ToolRevision, error) {
+	var rev models.ToolRevision
+	err := s.db.Where("tool_id = ? AND revision = ?", toolID, revision).First(&rev).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := s.ensureToolExists(toolID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRevisionNotFound
+		}
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// DiffRevisions compares two revisions of a tool. A zero to selects the latest
+// revision and a zero from the one just before to.
+func (s *RevisionService) DiffRevisions(toolID uint, from, to int) (*RevisionDiff, error) {
+	if to == 0 {
+		var latest models.ToolRevision
+		if err := s.db.Where("tool_id = ?", toolID).Order("revision DESC").Limit(1).Find(&latest).Error; err != nil {
+			return nil, err
+		}
+		if latest.ID == 0 {
+			if err := s.ensureToolExists(toolID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRevisionNotFound
+		}
+		to = latest.Revision
+	}
+	if from == 0 {
+		from = to - 1
+		if from < 1 {
+			return nil, newValidationError("from", "revision %d has no earlier revision to compare with", to)
+		}
+	}
+
+	oldRev, err := s.GetRevision(toolID, from)
+	if err != nil {
+		return nil, err
+	}
+	newRev, err := s.GetRevision(toolID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RevisionDiff{
+		ToolID:  toolID,
+		From:    from,
+		To:      to,
+		Changes: diffSnapshots(oldRev.Snapshot, newRev.Snapshot),
+	}, nil
+}
+
+// RestoreRevision writes the state of a revision back to the tool, undeleting it
+// if needed, and records the result as a new revision. Tags deleted since the
+// revision was taken are left out.
+func (s *RevisionService) RestoreRevision(toolID uint, revision int, actor string) (*models.Tool, error) {
+	rev, err := s.GetRevision(toolID, revision)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := rev.Snapshot
+
+	var tool models.Tool
+	if err := s.db.Unscoped().First(&tool, toolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrToolNotFound
+		}
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Check the name and category inside the transaction so that a
+		// concurrent edit cannot invalidate them before the restore commits
+		tools := s.tools.withDB(tx)
+		if err := tools.ensureNameAvailable(snapshot.Name, toolID); err != nil {
+			return err
+		}
+		if err := tools.ensureValidCategory(snapshot.CategoryID); err != nil {
+			return err
+		}
+
+		tags := make([]models.Tag, 0, len(snapshot.TagIDs))
+		if len(snapshot.TagIDs) > 0 {
+			if err := tx.Where("id IN ?", snapshot.TagIDs).Find(&tags).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Model(&tool).Updates(map[string]interface{}{
+			"name":        snapshot.Name,
+			"description": snapshot.Description,
+			"category_id": snapshot.CategoryID,
+			"icon":        snapshot.Icon,
+			"url":         snapshot.URL,
+			"is_active":   snapshot.IsActive,
+			"deleted_at":  nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&tool).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+		return recordToolRevision(tx, toolID, models.ToolRevisionRestore, actor, &revision)
+	})
+	if err != nil {
+		return nil, s.tools.nameConflict(err)
+	}
+	invalidateFuzzyCandidates()
+
+	if err := s.db.Preload("Category").Preload("Tags").First(&tool, toolID).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// ensureToolExists checks that a tool exists, including a soft deleted one
+func (s *RevisionService) ensureToolExists(toolID uint) error {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.Tool{}).Where("id = ?", toolID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrToolNotFound
+	}
+	return nil
+}
+
+// recordToolRevision snapshots a tool, including a soft deleted one, as its next
+// revision and audits the change. Updates that leave the tool as it was in the
+// latest revision are not recorded.
+func recordToolRevision(tx *gorm.DB, toolID uint, action, actor string, restoredFrom *int) error {
+	var tool models.Tool
+	if err := tx.Unscoped().Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.id")
+	}).First(&tool, toolID).Error; err != nil {
+		return err
+	}
+	snapshot := models.NewToolSnapshot(&tool)
+
+	var latest models.ToolRevision
+	if err := tx.Where("tool_id = ?", toolID).Order("revision DESC").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+	if action == models.ToolRevisionUpdate && latest.ID != 0 && reflect.DeepEqual(latest.Snapshot, snapshot) {
+		return nil
+	}
+
+	revision := &models.ToolRevision{
+		ToolID:       toolID,
+		Revision:     latest.Revision + 1,
+		Action:       action,
+		Actor:        actor,
+		RestoredFrom: restoredFrom,
+		Snapshot:     snapshot,
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return err
+	}
+
+	details := models.AuditDetails{
+		"name":     tool.Name,
+		"revision": revision.Revision,
+	}
+	if restoredFrom != nil {
+		details["restored_from"] = *restoredFrom
+	}
+	return recordAudit(tx, actor, models.AuditToolPrefix+action, auditTargetTool, strconv.FormatUint(uint64(toolID), 10), details)
+}
+
+// diffSnapshots lists the fields that differ between two snapshots
+func diffSnapshots(old, new models.ToolSnapshot) []RevisionFieldChange {
+	changes := make([]RevisionFieldChange, 0)
+	diff := func(field string, oldValue, newValue interface{}) {
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, RevisionFieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	diff("name", old.Name, new.Name)
+	diff("description", old.Description, new.Description)
+	if !sameID(old.CategoryID, new.CategoryID) {
+		changes = append(changes, RevisionFieldChange{Field: "category_id", Old: old.CategoryID, New: new.CategoryID})
+	}
+	diff("icon", old.Icon, new.Icon)
+	diff("url", old.URL, new.URL)
+	diff("is_active", old.IsActive, new.IsActive)
+	diff("tag_ids", sortedIDs(old.TagIDs), sortedIDs(new.TagIDs))
+	diff("deleted", old.Deleted, new.Deleted)
+	return changes
+}
+
+// sortedIDs returns a sorted copy of ids, never nil
+func sortedIDs(ids []uint) []uint {
+	sorted := append(make([]uint, 0, len(ids)), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"tion.work/backend/internal/models"
+)
+
+// revisionSummary formats revisions as "number:action:actor", newest first
+func revisionSummary(t *testing.T, revisions *RevisionService, toolID uint, limit, offset int) string {
+	t.Helper()
+	page, err := revisions.ListRevisions(toolID, limit, offset)
+	if err != nil {
+		t.Fatalf("list revisions: %v", err)
+	}
+	summary := fmt.Sprintf("%d", page.Total)
+	for _, rev := range page.Revisions {
+		summary += fmt.Sprintf(" %d:%s:%s", rev.Revision, rev.Action, rev.Actor)
+	}
+	return summary
+}
+
+// changedFields lists the fields of a diff
+func changedFields(diff *RevisionDiff) []string {
+	fields := make([]string, len(diff.Changes))
+	for i, change := range diff.Changes {
+		fields[i] = change.Field
+	}
+	return fields
+}
+
+func TestRevisionHistory(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	revisions := NewRevisionService()
+	tag, err := NewTagService().CreateTag("Web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := createTestTools(t, tools, "calculator")[0]
+
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"name": "calc"}, nil, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// Updates that change nothing are not recorded
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"name": "calc"}, nil, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools.UpdateTool(tool.ID, nil, []uint{tag.ID}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tools.DeleteTool(tool.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := revisionSummary(t, revisions, tool.ID, 0, 0); got != "4 4:delete:bob 3:update:bob 2:update:alice 1:create:test" {
+		t.Errorf("revisions %s", got)
+	}
+	if got := revisionSummary(t, revisions, tool.ID, 2, 2); got != "4 2:update:alice 1:create:test" {
+		t.Errorf("second page %s", got)
+	}
+	if _, err := revisions.ListRevisions(tool.ID+1, 0, 0); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("revisions of a missing tool = %v, want ErrToolNotFound", err)
+	}
+
+	rev, err := revisions.GetRevision(tool.ID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Snapshot.Name != "calc" || fmt.Sprint(rev.Snapshot.TagIDs) != fmt.Sprint([]uint{tag.ID}) || rev.Snapshot.Deleted {
+		t.Errorf("snapshot %+v, want calc tagged web", rev.Snapshot)
+	}
+	if _, err := revisions.GetRevision(tool.ID, 9); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("missing revision = %v, want ErrRevisionNotFound", err)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	revisions := NewRevisionService()
+	tool := createTestTools(t, tools, "calculator")[0]
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"name": "calc", "icon": "🧮"}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"is_active": false}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		from, to int
+		want     []string
+	}{
+		{0, 0, []string{"is_active"}},
+		{0, 2, []string{"name", "icon"}},
+		{1, 3, []string{"name", "icon", "is_active"}},
+		{3, 1, []string{"name", "icon", "is_active"}},
+		{2, 2, []string{}},
+	}
+	for _, tt := range tests {
+		diff, err := revisions.DiffRevisions(tool.ID, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("diff %d..%d: %v", tt.from, tt.to, err)
+		}
+		if got := changedFields(diff); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("diff %d..%d changed %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	diff, err := revisions.DiffRevisions(tool.ID, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change := diff.Changes[0]; change.Old != "calculator" || change.New != "calc" {
+		t.Errorf("name change %+v", change)
+	}
+
+	if _, err := revisions.DiffRevisions(tool.ID, 0, 1); !isValidationError(err, "from") {
+		t.Errorf("diff of the first revision = %v, want a validation error", err)
+	}
+	if _, err := revisions.DiffRevisions(tool.ID, 1, 7); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("diff with a missing revision = %v, want ErrRevisionNotFound", err)
+	}
+	if _, err := revisions.DiffRevisions(tool.ID+1, 0, 0); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("diff of a missing tool = %v, want ErrToolNotFound", err)
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	revisions := NewRevisionService()
+	tags := NewTagService()
+	kept, err := tags.CreateTag("Kept", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := tags.CreateTag("Removed", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := createTestTools(t, tools, "calculator", "timer")
+	tool := created[0]
+
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"description": "Adds numbers"}, []uint{kept.ID, removed.ID}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.DeleteTag(removed.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := tools.DeleteTool(tool.ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restoring undeletes the tool and skips tags deleted since
+	restored, err := revisions.RestoreRevision(tool.ID, 2, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Description != "Adds numbers" || len(restored.Tags) != 1 || restored.Tags[0].ID != kept.ID {
+		t.Errorf("restored %+v, want revision 2 with the kept tag", restored)
+	}
+	if _, err := tools.GetToolByID(tool.ID); err != nil {
+		t.Errorf("restored tool not found: %v", err)
+	}
+	latest, err := revisions.GetRevision(tool.ID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Action != models.ToolRevisionRestore || latest.Actor != "carol" || latest.RestoredFrom == nil || *latest.RestoredFrom != 2 {
+		t.Errorf("restore revision %+v", latest)
+	}
+
+	// A revision whose name another tool took in the meantime cannot be restored
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"name": "adder"}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools.UpdateTool(created[1].ID, map[string]interface{}{"name": "calculator"}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revisions.RestoreRevision(tool.ID, 1, "test"); !errors.Is(err, ErrToolNameTaken) {
+		t.Errorf("restore a taken name = %v, want ErrToolNameTaken", err)
+	}
+
+	// Nor one whose category is gone
+	other := testCategory(t, "other")
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"category_id": *other}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools.UpdateTool(tool.ID, map[string]interface{}{"category_id": *tool.CategoryID}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCategoryService().DeleteCategory(*other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := revisions.RestoreRevision(tool.ID, 6, "test"); !isValidationError(err, "category_id") {
+		t.Errorf("restore a deleted category = %v, want a category_id validation error", err)
+	}
+
+	// Failed restores record nothing
+	if got := revisionSummary(t, revisions, tool.ID, 1, 0); got != "7 7:update:test" {
+		t.Errorf("latest revision %s, want 7:update:test", got)
+	}
+	if _, err := revisions.RestoreRevision(tool.ID, 9, "test"); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("restore a missing revision = %v, want ErrRevisionNotFound", err)
+	}
+}
+
+func TestRevisionsAreImmutable(t *testing.T) {
+	db := newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "calculator")[0]
+	rev, err := NewRevisionService().GetRevision(tool.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rev.Actor = "mallory"
+	if err := db.Save(rev).Error; !errors.Is(err, models.ErrRevisionImmutable) {
+		t.Errorf("save = %v, want ErrRevisionImmutable", err)
+	}
+	if err := db.Model(rev).Update("action", models.ToolRevisionRestore).Error; !errors.Is(err, models.ErrRevisionImmutable) {
+		t.Errorf("update = %v, want ErrRevisionImmutable", err)
+	}
+	if err := db.Delete(rev).Error; !errors.Is(err, models.ErrRevisionImmutable) {
+		t.Errorf("delete = %v, want ErrRevisionImmutable", err)
+	}
+
+	var stored models.ToolRevision
+	if err := db.First(&stored, rev.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Actor != "test" || stored.Action != models.ToolRevisionCreate {
+		t.Errorf("stored revision %+v was changed", stored)
+	}
+}
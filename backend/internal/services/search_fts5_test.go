@@ -0,0 +1,90 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"fmt"
+	"testing"
+	"tion.work/backend/internal/database"
+
+	"gorm.io/driver/sqlite"
+)
+
+func TestSearchFTS5(t *testing.T) {
+	s, tools, seeded := newLikeSearch(t)
+	if backend := database.GetSearchBackend(); backend != database.SearchBackendFTS5 {
+		t.Fatalf("search backend %q, want %q", backend, database.SearchBackendFTS5)
+	}
+	s.backend = database.SearchBackendFTS5
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"json", []string{"JSON Formatter", "Color Picker"}},
+		{"JSON pretty", []string{"JSON Formatter"}},
+		{"enc", []string{"Base64 Encoder"}},
+		{"pick", []string{"Color Picker"}},
+	}
+	for _, tt := range tests {
+		page := search(t, s, SearchOptions{Query: tt.query})
+		if got := resultNames(page); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+		if page.Fuzzy || page.Backend != database.SearchBackendFTS5 {
+			t.Errorf("search %q: fuzzy %v with backend %q", tt.query, page.Fuzzy, page.Backend)
+		}
+	}
+
+	// The triggers keep the index in sync with renames, including of categories, and deletes
+	if _, err := tools.UpdateTool(seeded[0].ID, map[string]interface{}{"name": "JSON Beautifier"}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if page := search(t, s, SearchOptions{Query: "beautifier"}); fmt.Sprint(resultNames(page)) != "[JSON Beautifier]" {
+		t.Errorf("results %v for the new name, want the renamed tool", resultNames(page))
+	}
+	if page := search(t, s, SearchOptions{Query: "pretty"}); page.Fuzzy || len(page.Results) != 1 {
+		t.Errorf("results %v (fuzzy %v) for the description of the renamed tool", resultNames(page), page.Fuzzy)
+	}
+	if _, err := NewCategoryService().UpdateCategory(*seeded[1].CategoryID, map[string]interface{}{"name": "Palette"}); err != nil {
+		t.Fatal(err)
+	}
+	if page := search(t, s, SearchOptions{Query: "palette"}); page.Fuzzy || fmt.Sprint(resultNames(page)) != "[Color Picker]" {
+		t.Errorf("results %v for the new category name, want the tool in it", resultNames(page))
+	}
+	if err := tools.DeleteTool(seeded[0].ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if page := search(t, s, SearchOptions{Query: "beautifier"}); len(page.Results) != 0 {
+		t.Errorf("results %v for a deleted tool, want none", resultNames(page))
+	}
+}
+
+func TestSearchIndexIsRepaired(t *testing.T) {
+	db := newTestDB(t)
+	createTestTools(t, NewToolService(), "stopwatch")
+
+	// Lose a trigger, as an interrupted setup would
+	if err := db.Exec(`DROP TRIGGER tools_fts_au`).Error; err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := database.Connect(sqlite.Open(db.Dialector.(*sqlite.Dialector).DSN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := reopened.DB()
+		sqlDB.Close()
+	})
+
+	var triggers int64
+	reopened.Raw(`SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'tools_fts_%'`).Scan(&triggers)
+	if triggers != 3 {
+		t.Errorf("%d search triggers after reconnecting, want 3", triggers)
+	}
+
+	s := &SearchService{db: reopened, backend: database.SearchBackendFTS5}
+	if page := search(t, s, SearchOptions{Query: "stopwatch"}); fmt.Sprint(resultNames(page)) != "[stopwatch]" {
+		t.Errorf("results %v, want the tool created before the index was rebuilt", resultNames(page))
+	}
+}
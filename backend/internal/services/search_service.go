@@ -0,0 +1,471 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Field weights used when ranking matches outside the database
+const (
+	nameWeight        = 3.0
+	categoryWeight    = 2.0
+	descriptionWeight = 1.0
+)
+
+// fuzzyCandidatesTTL is how long the words of active tools are reused for
+// typo-tolerant matching before they are loaded again
+const fuzzyCandidatesTTL = time.Minute
+
+type SearchService struct {
+	db           *gorm.DB
+	backend      string
+	translations *TranslationService
+}
+
+// fuzzyCandidate holds the distinct words of an active tool with the highest
+// weight each appears with
+type fuzzyCandidate struct {
+	ID    uint
+	Words []weightedText
+}
+
+// fuzzyCache holds the fuzzy candidates shared by all search services. Tool
+// writes clear it so renamed and removed tools stop being suggested.
+var fuzzyCache struct {
+	sync.Mutex
+	candidates []fuzzyCandidate
+	loadedAt   time.Time
+}
+
+// invalidateFuzzyCandidates makes the next fuzzy search load the tools again
+func invalidateFuzzyCandidates() {
+	fuzzyCache.Lock()
+	defer fuzzyCache.Unlock()
+	fuzzyCache.candidates = nil
+}
+
+func NewSearchService() *SearchService {
+	return &SearchService{
+		db:           database.GetDB(),
+		backend:      database.GetSearchBackend(),
+		translations: NewTranslationService(),
+	}
+}
+
+// SearchOptions controls a tool search
+type SearchOptions struct {
+	Query   string
+	Limit   int
+	Offset  int
+	Locales []string // locale fallback chain used to localize results
+}
+
+// SearchResult is a ranked tool match with highlighted fields
+type SearchResult struct {
+	Tool       models.Tool       `json:"tool"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// SearchPage is a single page of search results
+type SearchPage struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+	Total   int64          `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	Fuzzy   bool           `json:"fuzzy"`
+	Backend string         `json:"backend"`
+}
+
+// searchHit is a tool ID with its relevance score
+type searchHit struct {
+	ID    uint
+	Score float64
+}
+
+// Search ranks active tools against the query across name, description and category.
+// When the exact search finds nothing, a typo-tolerant fuzzy pass is used instead.
+func (s *SearchService) Search(opts SearchOptions) (*SearchPage, error) {
+	terms := searchTerms(opts.Query)
+	if len(terms) == 0 {
+		return nil, newValidationError("q", "search query is required")
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	if opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+	if opts.Offset < 0 {
+		return nil, newValidationError("offset", "must not be negative")
+	}
+
+	page := &SearchPage{
+		Query:   opts.Query,
+		Results: []SearchResult{},
+		Limit:   opts.Limit,
+		Offset:  opts.Offset,
+		Backend: s.backend,
+	}
+
+	var hits []searchHit
+	var total int64
+	var err error
+
+	switch s.backend {
+	case database.SearchBackendPostgres:
+		hits, total, err = s.searchPostgres(terms, opts.Limit, opts.Offset)
+	case database.SearchBackendFTS5:
+		hits, total, err = s.searchFTS5(terms, opts.Limit, opts.Offset)
+	default:
+		hits, total, err = s.searchLike(terms, opts.Limit, opts.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The full-text indexes only cover the original text, so fall back to
+	// matching translations before trying typo-tolerant matching
+	if total == 0 && s.backend != database.SearchBackendLike {
+		hits, total, err = s.searchLike(terms, opts.Limit, opts.Offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	highlightTerms := terms
+	if total == 0 {
+		var matched []string
+		hits, total, matched, err = s.searchFuzzy(terms, opts.Limit, opts.Offset)
+		if err != nil {
+			return nil, err
+		}
+		page.Fuzzy = true
+		highlightTerms = matched
+	}
+
+	page.Total = total
+	if len(hits) == 0 {
+		return page, nil
+	}
+
+	tools, err := s.loadTools(hits, opts.Locales)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range hits {
+		tool, ok := tools[hit.ID]
+		if !ok {
+			continue
+		}
+		highlights := map[string]string{
+			"name":        highlight(tool.Name, highlightTerms),
+			"description": highlight(tool.Description, highlightTerms),
+		}
+		if tool.Category != nil {
+			highlights["category"] = highlight(tool.Category.Name, highlightTerms)
+		}
+		page.Results = append(page.Results, SearchResult{
+			Tool:       tool,
+			Score:      hit.Score,
+			Highlights: highlights,
+		})
+	}
+
+	return page, nil
+}
+
+// searchPostgres matches against the weighted tsvector column with prefix queries
+func (s *SearchService) searchPostgres(terms []string, limit, offset int) ([]searchHit, int64, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	tsQuery := strings.Join(parts, " & ")
+
+	var total int64
+	err := s.db.Raw(`SELECT COUNT(*) FROM tools
+		WHERE search_vector @@ to_tsquery('simple', ?)
+		AND is_active = ? AND deleted_at IS NULL`, tsQuery, true).Scan(&total).Error
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	var hits []searchHit
+	err = s.db.Raw(`SELECT id, ts_rank(search_vector, to_tsquery('simple', ?)) AS score FROM tools
+		WHERE search_vector @@ to_tsquery('simple', ?)
+		AND is_active = ? AND deleted_at IS NULL
+		ORDER BY score DESC, id ASC LIMIT ? OFFSET ?`,
+		tsQuery, tsQuery, true, limit, offset).Scan(&hits).Error
+	return hits, total, err
+}
+
+// searchFTS5 matches against the tools_fts virtual table ranked by bm25
+func (s *SearchService) searchFTS5(terms []string, limit, offset int) ([]searchHit, int64, error) {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `"` + term + `"*`
+	}
+	match := strings.Join(parts, " ")
+
+	var total int64
+	err := s.db.Raw(`SELECT COUNT(*) FROM tools_fts
+		JOIN tools ON tools.id = tools_fts.rowid
+		WHERE tools_fts MATCH ? AND tools.is_active = ? AND tools.deleted_at IS NULL`,
+		match, true).Scan(&total).Error
+	if err != nil || total == 0 {
+		return nil, total, err
+	}
+
+	// bm25 returns lower values for better matches, so negate it into a score
+	var hits []searchHit
+	err = s.db.Raw(`SELECT tools.id AS id, -bm25(tools_fts, ?, ?, ?) AS score FROM tools_fts
+		JOIN tools ON tools.id = tools_fts.rowid
+		WHERE tools_fts MATCH ? AND tools.is_active = ? AND tools.deleted_at IS NULL
+		ORDER BY score DESC, tools.id ASC LIMIT ? OFFSET ?`,
+		nameWeight, descriptionWeight, categoryWeight, match, true, limit, offset).Scan(&hits).Error
+	return hits, total, err
+}
+
+// searchLike is the portable fallback that matches substrings of the original
+// text and all translations, and ranks in Go
+func (s *SearchService) searchLike(terms []string, limit, offset int) ([]searchHit, int64, error) {
+	query := s.searchableTools()
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		query = query.Where(
+			`(LOWER(name) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\'
+			OR id IN (SELECT tool_id FROM tool_translations
+				WHERE LOWER(name) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\')
+			OR category_id IN (SELECT id FROM categories WHERE LOWER(name) LIKE ? ESCAPE '\')
+			OR category_id IN (SELECT category_id FROM category_translations WHERE LOWER(name) LIKE ? ESCAPE '\'))`,
+			pattern, pattern, pattern, pattern, pattern, pattern,
+		)
+	}
+
+	var tools []models.Tool
+	if err := query.Find(&tools).Error; err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]searchHit, 0, len(tools))
+	for _, tool := range tools {
+		var score float64
+		for _, term := range terms {
+			best := 0.0
+			for _, text := range searchableTexts(tool) {
+				if fs := fieldScore(text.value, term, text.weight); fs > best {
+					best = fs
+				}
+			}
+			score += best
+		}
+		hits = append(hits, searchHit{ID: tool.ID, Score: score})
+	}
+
+	sortHits(hits)
+	return paginateHits(hits, limit, offset), int64(len(hits)), nil
+}
+
+// searchFuzzy tolerates typos by comparing query terms to indexed words by edit distance.
+// It returns the matched words so they can be highlighted.
+func (s *SearchService) searchFuzzy(terms []string, limit, offset int) ([]searchHit, int64, []string, error) {
+	candidates, err := s.loadFuzzyCandidates()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var hits []searchHit
+	matchedWords := make(map[string]bool)
+
+	for _, candidate := range candidates {
+		var score float64
+		matchedAll := true
+		var matched []string
+
+		for _, term := range terms {
+			best := 0.0
+			bestWord := ""
+			for _, word := range candidate.Words {
+				distance, ok := fuzzyMatch(term, word.value)
+				if !ok {
+					continue
+				}
+				similarity := word.weight * (1 - float64(distance)/float64(len([]rune(term))+1))
+				if similarity > best {
+					best = similarity
+					bestWord = word.value
+				}
+			}
+			if bestWord == "" {
+				matchedAll = false
+				break
+			}
+			score += best
+			matched = append(matched, bestWord)
+		}
+
+		if matchedAll {
+			hits = append(hits, searchHit{ID: candidate.ID, Score: score})
+			for _, word := range matched {
+				matchedWords[word] = true
+			}
+		}
+	}
+
+	words := make([]string, 0, len(matchedWords))
+	for word := range matchedWords {
+		words = append(words, word)
+	}
+
+	sortHits(hits)
+	return paginateHits(hits, limit, offset), int64(len(hits)), words, nil
+}
+
+// loadFuzzyCandidates returns the words of all active tools, loading them at
+// most once per fuzzyCandidatesTTL, so that queries without exact matches do
+// not each load every tool
+func (s *SearchService) loadFuzzyCandidates() ([]fuzzyCandidate, error) {
+	fuzzyCache.Lock()
+	defer fuzzyCache.Unlock()
+	if fuzzyCache.candidates != nil && time.Since(fuzzyCache.loadedAt) < fuzzyCandidatesTTL {
+		return fuzzyCache.candidates, nil
+	}
+
+	var tools []models.Tool
+	if err := s.searchableTools().Find(&tools).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]fuzzyCandidate, 0, len(tools))
+	for _, tool := range tools {
+		weights := make(map[string]float64)
+		var order []string
+		for _, text := range searchableTexts(tool) {
+			for _, word := range tokenize(text.value) {
+				weight, seen := weights[word]
+				if !seen {
+					order = append(order, word)
+				}
+				if text.weight > weight {
+					weights[word] = text.weight
+				}
+			}
+		}
+
+		candidate := fuzzyCandidate{ID: tool.ID, Words: make([]weightedText, len(order))}
+		for i, word := range order {
+			candidate.Words[i] = weightedText{word, weights[word]}
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	fuzzyCache.candidates = candidates
+	fuzzyCache.loadedAt = time.Now()
+	return candidates, nil
+}
+
+// loadTools loads the localized tools for a page of hits keyed by ID
+func (s *SearchService) loadTools(hits []searchHit, locales []string) (map[uint]models.Tool, error) {
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var tools []models.Tool
+	if err := s.db.Preload("Category").Preload("Tags").Where("id IN ?", ids).Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	if err := s.translations.LocalizeTools(tools, locales); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Tool, len(tools))
+	for _, tool := range tools {
+		byID[tool.ID] = tool
+	}
+	return byID, nil
+}
+
+// searchableTools queries active tools with all the text searchableTexts reads
+func (s *SearchService) searchableTools() *gorm.DB {
+	return s.db.Model(&models.Tool{}).
+		Preload("Translations").
+		Preload("Category.Translations").
+		Where("is_active = ?", true)
+}
+
+// weightedText is a searchable piece of tool text with its ranking weight
+type weightedText struct {
+	value  string
+	weight float64
+}
+
+// searchableTexts returns the original text of a tool and its category followed by their translations
+func searchableTexts(tool models.Tool) []weightedText {
+	texts := []weightedText{
+		{tool.Name, nameWeight},
+		{tool.Description, descriptionWeight},
+	}
+	for _, translation := range tool.Translations {
+		texts = append(texts,
+			weightedText{translation.Name, nameWeight},
+			weightedText{translation.Description, descriptionWeight},
+		)
+	}
+	if tool.Category != nil {
+		texts = append(texts, weightedText{tool.Category.Name, categoryWeight})
+		for _, translation := range tool.Category.Translations {
+			texts = append(texts, weightedText{translation.Name, categoryWeight})
+		}
+	}
+	return texts
+}
+
+// fieldScore weights how well a field matches a term, favouring prefix matches
+func fieldScore(field, term string, weight float64) float64 {
+	lower := strings.ToLower(field)
+	switch {
+	case lower == term:
+		return weight * 2
+	case strings.HasPrefix(lower, term):
+		return weight * 1.5
+	case strings.Contains(lower, term):
+		return weight
+	}
+	return 0
+}
+
+func sortHits(hits []searchHit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+}
+
+func paginateHits(hits []searchHit, limit, offset int) []searchHit {
+	if offset >= len(hits) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[offset:end]
+}
+
+// escapeLike escapes LIKE wildcards in a search term
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(term)
+}
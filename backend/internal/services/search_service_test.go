@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+)
+
+// newLikeSearch seeds a few tools and returns a search service using the LIKE
+// fallback, whatever backend the test binary was built with
+func newLikeSearch(t *testing.T) (*SearchService, *ToolService, []models.Tool) {
+	t.Helper()
+	db := newTestDB(t)
+	tools := NewToolService()
+	seeded := []models.Tool{
+		{Name: "JSON Formatter", Description: "Pretty print JSON documents", CategoryID: testCategory(t, "text"), IsActive: true},
+		{Name: "Color Picker", Description: "Pick colors from JSON themes", CategoryID: testCategory(t, "design"), IsActive: true},
+		{Name: "Base64 Encoder", Description: "Encode text as base64", CategoryID: testCategory(t, "encoding"), IsActive: true},
+		{Name: "JSON Validator", Description: "Validate JSON", CategoryID: testCategory(t, "text"), IsActive: true},
+	}
+	for i := range seeded {
+		seeded[i].URL = fmt.Sprintf("https://tion.work/tools/%d", i)
+		if err := tools.CreateTool(&seeded[i], nil, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The validator is hidden from search
+	if _, err := tools.UpdateTool(seeded[3].ID, map[string]interface{}{"is_active": false}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return &SearchService{db: db, backend: database.SearchBackendLike, translations: NewTranslationService()}, tools, seeded
+}
+
+func search(t *testing.T, s *SearchService, opts SearchOptions) *SearchPage {
+	t.Helper()
+	page, err := s.Search(opts)
+	if err != nil {
+		t.Fatalf("search %+v: %v", opts, err)
+	}
+	return page
+}
+
+func resultNames(page *SearchPage) []string {
+	names := make([]string, len(page.Results))
+	for i, result := range page.Results {
+		names[i] = result.Tool.Name
+	}
+	return names
+}
+
+func TestSearchLike(t *testing.T) {
+	s, _, _ := newLikeSearch(t)
+
+	tests := []struct {
+		name  string
+		opts  SearchOptions
+		want  []string
+		total int64
+	}{
+		// A match in the name outranks one in the description
+		{"ranked", SearchOptions{Query: "json"}, []string{"JSON Formatter", "Color Picker"}, 2},
+		{"every term must match", SearchOptions{Query: "JSON pretty"}, []string{"JSON Formatter"}, 1},
+		{"prefix of a word", SearchOptions{Query: "enc"}, []string{"Base64 Encoder"}, 1},
+		{"category", SearchOptions{Query: "design"}, []string{"Color Picker"}, 1},
+		{"second page", SearchOptions{Query: "json", Limit: 1, Offset: 1}, []string{"Color Picker"}, 2},
+		{"past the end", SearchOptions{Query: "json", Offset: 5}, []string{}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := search(t, s, tt.opts)
+			if got := resultNames(page); fmt.Sprint(got) != fmt.Sprint(tt.want) || page.Total != tt.total {
+				t.Errorf("results %v of %d, want %v of %d", got, page.Total, tt.want, tt.total)
+			}
+			if page.Fuzzy || page.Backend != database.SearchBackendLike {
+				t.Errorf("fuzzy %v with backend %q, want an exact LIKE search", page.Fuzzy, page.Backend)
+			}
+		})
+	}
+
+	page := search(t, s, SearchOptions{Query: "json"})
+	if got := page.Results[0].Highlights["name"]; got != "<mark>JSON</mark> Formatter" {
+		t.Errorf("highlighted name %q", got)
+	}
+
+	if _, err := s.Search(SearchOptions{Query: " ,. "}); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("search without terms = %v, want a validation error", err)
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	s, tools, seeded := newLikeSearch(t)
+
+	page := search(t, s, SearchOptions{Query: "formater"})
+	if got := resultNames(page); !page.Fuzzy || fmt.Sprint(got) != "[JSON Formatter]" {
+		t.Fatalf("results %v (fuzzy %v), want the formatter found by a fuzzy search", got, page.Fuzzy)
+	}
+	if got := page.Results[0].Highlights["name"]; got != "JSON <mark>Formatter</mark>" {
+		t.Errorf("highlighted name %q, want the matched word marked", got)
+	}
+	if page := search(t, s, SearchOptions{Query: "qwerty"}); !page.Fuzzy || len(page.Results) != 0 {
+		t.Errorf("results %v, want none", resultNames(page))
+	}
+
+	// Renamed and deleted tools are not suggested from the cached words
+	if _, err := tools.UpdateTool(seeded[0].ID, map[string]interface{}{"name": "JSON Beautifier"}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if page := search(t, s, SearchOptions{Query: "formater"}); len(page.Results) != 0 {
+		t.Errorf("results %v for the old name of a renamed tool, want none", resultNames(page))
+	}
+	if page := search(t, s, SearchOptions{Query: "beautifer"}); fmt.Sprint(resultNames(page)) != "[JSON Beautifier]" {
+		t.Errorf("results %v for the new name, want the renamed tool", resultNames(page))
+	}
+	if err := tools.DeleteTool(seeded[0].ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if page := search(t, s, SearchOptions{Query: "beautifer"}); len(page.Results) != 0 {
+		t.Errorf("results %v for a deleted tool, want none", resultNames(page))
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		term, word string
+		distance   int
+		ok         bool
+	}{
+		{"formatter", "formatter", 0, true},
+		{"formater", "formatter", 1, true},
+		{"calcualtor", "calculator", 2, true},   // long terms allow two edits
+		{"jsno", "json", 2, false},              // a swap is two edits
+		{"colr", "color", 1, true},              // also a prefix one edit away
+		{"cat", "car", 1, false},                // short terms must match exactly
+		{"enc", "encoder", 0, true},             // prefix
+		{"编码", "编码器", 0, true},                  // runes, not bytes
+		{"beautifer", "beautifier", 1, true},    // missing letter
+		{"qwerty", "formatter", 6, false},       // unrelated
+		{"picker", "pick", 2, false},            // longer than the word
+		{"encoding", "encoder", 3, false},       // too many edits
+		{"validate", "validator", 1, true},      // prefix one edit away
+		{"colours", "colors", 1, true},          // extra letter
+		{"", "json", 0, true},                   // empty prefix
+		{"base64", "base46", 2, false},          // digits count as letters
+		{"base64", "base64x", 0, true},          // prefix
+		{"formatterr", "formatter", 1, true},    // longer term
+		{"formatterrr", "formatter", 2, true},   // at the limit
+		{"formatterrrr", "formatter", 3, false}, // over the limit
+	}
+	for _, tt := range tests {
+		distance, ok := fuzzyMatch(tt.term, tt.word)
+		if distance != tt.distance || ok != tt.ok {
+			t.Errorf("fuzzyMatch(%q, %q) = %d, %v, want %d, %v", tt.term, tt.word, distance, ok, tt.distance, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"sort"
+	"time"
+	"tion.work/backend/internal/i18n"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// localeTopQueries is the number of top queries listed per locale
+const localeTopQueries = 5
+
+// SearchEventInput describes a search to record
+type SearchEventInput struct {
+	Query   string
+	Locale  string
+	Results int
+	Source  string // models.SearchSourceAPI or models.SearchSourceClient
+}
+
+// SearchStatsOptions filters search analytics. Empty Locale and Source match
+// every locale and source.
+type SearchStatsOptions struct {
+	From   time.Time
+	To     time.Time
+	Locale string
+	Source string
+	Limit  int
+}
+
+// QueryStat is the search volume of a normalized query
+type QueryStat struct {
+	Query        string    `json:"query"`
+	Searches     int64     `json:"searches"`
+	ZeroResults  int64     `json:"zero_results"`
+	AvgResults   float64   `json:"avg_results"`
+	LastSearched time.Time `json:"last_searched"`
+}
+
+// LocaleSearches is the search volume of a UI locale with its top queries
+type LocaleSearches struct {
+	Locale     string      `json:"locale"`
+	Searches   int64       `json:"searches"`
+	Queries    int64       `json:"queries"`
+	TopQueries []QueryStat `json:"top_queries"`
+}
+
+// QueryReport lists queries over whole UTC days
+type QueryReport struct {
+	From    time.Time   `json:"from"`
+	To      time.Time   `json:"to"`
+	Queries []QueryStat `json:"queries"`
+}
+
+// LocaleReport lists search volume per UI locale over whole UTC days
+type LocaleReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Locales []LocaleSearches `json:"locales"`
+}
+
+// SearchTermCTR is how often a search term led to a tool click. CTR is clicks
+// per search and can exceed 1 when visitors open several results. Conversion
+// is the share of visitors searching the term on a day who then clicked a tool
+// from its results on the same day. Both are nil for terms that were clicked
+// but not searched in the range.
+type SearchTermCTR struct {
+	Query       string   `json:"query"`
+	Searches    int64    `json:"searches"`
+	Clicks      int64    `json:"clicks"`
+	CTR         *float64 `json:"ctr"`
+	Sessions    int64    `json:"sessions"`
+	Converted   int64    `json:"converted"`
+	Conversion  *float64 `json:"conversion"`
+	AvgPosition *float64 `json:"avg_position"`
+}
+
+// SearchCTRReport lists search terms by volume over whole UTC days
+type SearchCTRReport struct {
+	From  time.Time       `json:"from"`
+	To    time.Time       `json:"to"`
+	Terms []SearchTermCTR `json:"terms"`
+}
+
+// RecordSearchEvent records a search under the anonymous visitor identity of
+// the IP address and user agent. Bots and empty queries are not recorded,
+// which is reported by returning false.
+func (s *StatsService) RecordSearchEvent(input SearchEventInput, ipAddress, userAgent string) (bool, error) {
+	if input.Results < 0 {
+		return false, newValidationError("results", "must not be negative")
+	}
+	if input.Source != models.SearchSourceAPI && input.Source != models.SearchSourceClient {
+		return false, newValidationError("source", "must be %q or %q", models.SearchSourceAPI, models.SearchSourceClient)
+	}
+
+	query := utils.NormalizeSearchQuery(input.Query)
+	agent := utils.ParseUserAgent(userAgent)
+	if query == "" || agent.Bot {
+		return false, nil
+	}
+
+	now := time.Now()
+	salt, err := s.salts.forTime(now)
+	if err != nil {
+		return false, err
+	}
+
+	event := &models.SearchEvent{
+		Query:       query,
+		Locale:      i18n.Normalize(input.Locale),
+		Results:     input.Results,
+		Source:      input.Source,
+		VisitorHash: utils.HashVisitor(salt, ipAddress, userAgent),
+		CreatedAt:   now,
+	}
+	if err := s.db.Create(event).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetTopQueries lists the most searched queries over whole UTC days
+func (s *StatsService) GetTopQueries(opts SearchStatsOptions) (*QueryReport, error) {
+	query, err := s.searchEvents(&opts)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := s.queryStats(query, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryReport{From: opts.From, To: opts.To, Queries: queries}, nil
+}
+
+// GetZeroResultQueries lists the most searched queries that returned no
+// results, the tools editors should consider adding to the catalog
+func (s *StatsService) GetZeroResultQueries(opts SearchStatsOptions) (*QueryReport, error) {
+	query, err := s.searchEvents(&opts)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := s.queryStats(query.Where("results = 0"), opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryReport{From: opts.From, To: opts.To, Queries: queries}, nil
+}
+
+// GetSearchLocales breaks down searches by UI locale, listing the top queries
+// of each locale
+func (s *StatsService) GetSearchLocales(opts SearchStatsOptions) (*LocaleReport, error) {
+	query, err := s.searchEvents(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Locale   string
+		Searches int64
+		Queries  int64
+	}
+	if err := query.
+		Select("locale, COUNT(*) AS searches, COUNT(DISTINCT query) AS queries").
+		Group("locale").
+		Order("searches DESC, locale").
+		Limit(opts.Limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	locales := make([]LocaleSearches, 0, len(rows))
+	for _, row := range rows {
+		top, err := s.queryStats(query.Where("locale = ?", row.Locale), localeTopQueries)
+		if err != nil {
+			return nil, err
+		}
+
+		locale := LocaleSearches{Locale: row.Locale, Searches: row.Searches, Queries: row.Queries, TopQueries: top}
+		if locale.Locale == "" {
+			locale.Locale = unknownLocale
+		}
+		locales = append(locales, locale)
+	}
+	return &LocaleReport{From: opts.From, To: opts.To, Locales: locales}, nil
+}
+
+// GetSearchCTR reports searches, clicks, click-through rate and conversion per
+// normalized search term over whole UTC days, ordered by search volume. Clicks
+// are read from raw usage rows, so days past the usage retention count no clicks.
+func (s *StatsService) GetSearchCTR(opts SearchStatsOptions) (*SearchCTRReport, error) {
+	events, err := s.searchEvents(&opts)
+	if err != nil {
+		return nil, err
+	}
+	from, end := opts.From, opts.To
+
+	var searches []struct {
+		Query    string
+		Searches int64
+	}
+	if err := events.
+		Select("query, COUNT(*) AS searches").
+		Group("query").
+		Scan(&searches).Error; err != nil {
+		return nil, err
+	}
+
+	clickQuery := func() *gorm.DB {
+		query := s.db.Model(&models.ToolUsage{}).
+			Where("search_query <> '' AND created_at >= ? AND created_at < ?", timeArg(s.db, from), timeArg(s.db, end))
+		if opts.Locale != "" {
+			query = query.Where("locale = ?", opts.Locale)
+		}
+		return query
+	}
+
+	var clicks []struct {
+		Query       string
+		Clicks      int64
+		AvgPosition *float64
+	}
+	if err := clickQuery().
+		Select("search_query AS query, COUNT(*) AS clicks, AVG(CASE WHEN position > 0 THEN position END) AS avg_position").
+		Group("search_query").
+		Scan(&clicks).Error; err != nil {
+		return nil, err
+	}
+
+	// A session is a visitor searching a term on a UTC day. Visitor hashes
+	// change daily, so sessions cannot span days anyway.
+	searchDay := "(" + epochExpr(s.db, "created_at") + " / 86400)"
+	searchSessions := events.
+		Select("DISTINCT query, visitor_hash, " + searchDay + " AS day")
+	clickSessions := clickQuery().
+		Select("DISTINCT search_query AS query, visitor_hash, " + searchDay + " AS day")
+
+	var sessions []struct {
+		Query     string
+		Sessions  int64
+		Converted int64
+	}
+	if err := s.db.Table("(?) AS s", searchSessions).
+		Select("s.query, COUNT(*) AS sessions, COUNT(c.query) AS converted").
+		Joins("LEFT JOIN (?) AS c ON c.query = s.query AND c.visitor_hash = s.visitor_hash AND c.day = s.day", clickSessions).
+		Group("s.query").
+		Scan(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	terms := make(map[string]*SearchTermCTR, len(searches)+len(clicks))
+	term := func(query string) *SearchTermCTR {
+		t, ok := terms[query]
+		if !ok {
+			t = &SearchTermCTR{Query: query}
+			terms[query] = t
+		}
+		return t
+	}
+	for _, row := range searches {
+		term(row.Query).Searches = row.Searches
+	}
+	for _, row := range clicks {
+		t := term(row.Query)
+		t.Clicks = row.Clicks
+		t.AvgPosition = row.AvgPosition
+	}
+	for _, row := range sessions {
+		t := term(row.Query)
+		t.Sessions = row.Sessions
+		t.Converted = row.Converted
+	}
+
+	report := &SearchCTRReport{From: from, To: end, Terms: make([]SearchTermCTR, 0, len(terms))}
+	for _, t := range terms {
+		if t.Searches > 0 {
+			ctr := float64(t.Clicks) / float64(t.Searches)
+			t.CTR = &ctr
+		}
+		if t.Sessions > 0 {
+			conversion := float64(t.Converted) / float64(t.Sessions)
+			t.Conversion = &conversion
+		}
+		report.Terms = append(report.Terms, *t)
+	}
+	sort.Slice(report.Terms, func(i, j int) bool {
+		a, b := report.Terms[i], report.Terms[j]
+		if a.Searches != b.Searches {
+			return a.Searches > b.Searches
+		}
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Query < b.Query
+	})
+	if len(report.Terms) > opts.Limit {
+		report.Terms = report.Terms[:opts.Limit]
+	}
+	return report, nil
+}
+
+// searchEvents validates the options, widening the range to whole UTC days and
+// applying the default limit, and returns the search events they select as a
+// query that can be reused
+func (s *StatsService) searchEvents(opts *SearchStatsOptions) (*gorm.DB, error) {
+	from, end, err := utcDayRange(opts.From, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	opts.From, opts.To = from, end
+	opts.Limit = clampLimit(opts.Limit)
+
+	if opts.Source != "" && opts.Source != models.SearchSourceAPI && opts.Source != models.SearchSourceClient {
+		return nil, newValidationError("source", "must be %q or %q", models.SearchSourceAPI, models.SearchSourceClient)
+	}
+	if opts.Locale != "" {
+		locale := i18n.Normalize(opts.Locale)
+		if locale == "" {
+			return nil, newValidationError("locale", "%q is not a valid locale", opts.Locale)
+		}
+		opts.Locale = locale
+	}
+
+	query := s.db.Model(&models.SearchEvent{}).
+		Where("created_at >= ? AND created_at < ?", timeArg(s.db, from), timeArg(s.db, end))
+	if opts.Locale != "" {
+		query = query.Where("locale = ?", opts.Locale)
+	}
+	if opts.Source != "" {
+		query = query.Where("source = ?", opts.Source)
+	}
+	return query.Session(&gorm.Session{}), nil
+}
+
+// queryStats groups the search events of the query by normalized query, most
+// searched first
+func (s *StatsService) queryStats(query *gorm.DB, limit int) ([]QueryStat, error) {
+	var rows []struct {
+		Query        string
+		Searches     int64
+		ZeroResults  int64
+		AvgResults   float64
+		LastSearched int64
+	}
+	if err := query.
+		Select("query, COUNT(*) AS searches, " +
+			"SUM(CASE WHEN results = 0 THEN 1 ELSE 0 END) AS zero_results, " +
+			"AVG(results) AS avg_results, " +
+			"MAX(" + epochExpr(s.db, "created_at") + ") AS last_searched").
+		Group("query").
+		Order("searches DESC, query").
+		Limit(limit).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := make([]QueryStat, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, QueryStat{
+			Query:        row.Query,
+			Searches:     row.Searches,
+			ZeroResults:  row.ZeroResults,
+			AvgResults:   row.AvgResults,
+			LastSearched: time.Unix(row.LastSearched, 0).UTC(),
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+// searchAs records a search of the visitor with the given IP address
+func searchAs(t *testing.T, stats *StatsService, ip string, input SearchEventInput) {
+	t.Helper()
+	if input.Source == "" {
+		input.Source = models.SearchSourceAPI
+	}
+	recorded, err := stats.RecordSearchEvent(input, ip, testUserAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recorded {
+		t.Fatalf("search %+v was not recorded", input)
+	}
+}
+
+// seedSearches records searches by four visitors and the clicks two of them made
+func seedSearches(t *testing.T) (*StatsService, []models.Tool) {
+	t.Helper()
+	newTestDB(t)
+	tools := createTestTools(t, NewToolService(), "formatter", "generator")
+	stats := NewStatsService()
+
+	const a, b, c, d = "203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"
+	searchAs(t, stats, a, SearchEventInput{Query: "json formatter", Locale: "en", Results: 3})
+	searchAs(t, stats, a, SearchEventInput{Query: "JSON  Formatter", Locale: "en", Results: 3})
+	searchAs(t, stats, b, SearchEventInput{Query: "json formatter", Locale: "zh_cn", Results: 3, Source: models.SearchSourceClient})
+	searchAs(t, stats, a, SearchEventInput{Query: "base64", Locale: "en", Results: 1})
+	searchAs(t, stats, c, SearchEventInput{Query: "qr code", Locale: "en", Results: 0})
+	searchAs(t, stats, c, SearchEventInput{Query: "qr code", Locale: "en", Results: 0})
+	searchAs(t, stats, d, SearchEventInput{Query: "pdf", Results: 2})
+
+	clickAs(t, stats, a, tools[0].ID, UsageContext{Query: "json formatter", Position: 1})
+	clickAs(t, stats, a, tools[0].ID, UsageContext{Query: "json formatter", Position: 3})
+	clickAs(t, stats, d, tools[1].ID, UsageContext{Query: "uuid", Position: 2})
+	return stats, tools
+}
+
+// formatQueries formats query stats as "query:searches/zero results/average results"
+func formatQueries(queries []QueryStat) []string {
+	var formatted []string
+	for _, query := range queries {
+		formatted = append(formatted, fmt.Sprintf("%s:%d/%d/%.1f", query.Query, query.Searches, query.ZeroResults, query.AvgResults))
+	}
+	return formatted
+}
+
+func TestRecordSearchEvent(t *testing.T) {
+	newTestDB(t)
+	stats := NewStatsService()
+
+	skipped := []struct {
+		query, agent string
+	}{
+		{"   ", testUserAgent},
+		{"json", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+	}
+	for _, search := range skipped {
+		recorded, err := stats.RecordSearchEvent(SearchEventInput{Query: search.query, Source: models.SearchSourceAPI}, "203.0.113.1", search.agent)
+		if err != nil || recorded {
+			t.Errorf("search %q by %q recorded %v (%v), want skipped", search.query, search.agent, recorded, err)
+		}
+	}
+
+	invalid := map[string]SearchEventInput{
+		"results": {Query: "json", Results: -1, Source: models.SearchSourceAPI},
+		"source":  {Query: "json", Source: "crawler"},
+	}
+	for field, input := range invalid {
+		if _, err := stats.RecordSearchEvent(input, "203.0.113.1", testUserAgent); !isValidationError(err, field) {
+			t.Errorf("search %+v = %v, want a %s validation error", input, err, field)
+		}
+	}
+
+	searchAs(t, stats, "203.0.113.1", SearchEventInput{Query: " JSON  Formatter ", Locale: "zh_tw", Results: 2})
+	var events []models.SearchEvent
+	if err := stats.db.Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("%d search events, want 1", len(events))
+	}
+	if event := events[0]; event.Query != "json formatter" || event.Locale != "zh-TW" || event.Results != 2 || len(event.VisitorHash) != 32 {
+		t.Errorf("search event %+v, want a normalized query and locale", event)
+	}
+}
+
+func TestGetTopQueries(t *testing.T) {
+	stats, _ := seedSearches(t)
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		opts SearchStatsOptions
+		want []string
+	}{
+		{"all", SearchStatsOptions{}, []string{"json formatter:3/0/3.0", "qr code:2/2/0.0", "base64:1/0/1.0", "pdf:1/0/2.0"}},
+		{"limit", SearchStatsOptions{Limit: 2}, []string{"json formatter:3/0/3.0", "qr code:2/2/0.0"}},
+		{"locale", SearchStatsOptions{Locale: "zh-cn"}, []string{"json formatter:1/0/3.0"}},
+		{"source", SearchStatsOptions{Source: models.SearchSourceClient}, []string{"json formatter:1/0/3.0"}},
+	}
+	for _, tt := range tests {
+		tt.opts.From, tt.opts.To = now, now
+		report, err := stats.GetTopQueries(tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := formatQueries(report.Queries); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: queries %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	zero, err := stats.GetZeroResultQueries(SearchStatsOptions{From: now, To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatQueries(zero.Queries); fmt.Sprint(got) != "[qr code:2/2/0.0]" {
+		t.Errorf("zero result queries %v, want [qr code:2/2/0.0]", got)
+	}
+
+	earlier, err := stats.GetTopQueries(SearchStatsOptions{From: now.AddDate(0, 0, -3), To: now.AddDate(0, 0, -2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(earlier.Queries) != 0 {
+		t.Errorf("queries %v on days without searches", formatQueries(earlier.Queries))
+	}
+
+	invalid := map[string]SearchStatsOptions{
+		"locale": {From: now, To: now, Locale: "not a locale"},
+		"source": {From: now, To: now, Source: "crawler"},
+		"from":   {From: now, To: now.AddDate(0, 0, -1)},
+	}
+	for field, opts := range invalid {
+		if _, err := stats.GetTopQueries(opts); !isValidationError(err, field) {
+			t.Errorf("options %+v = %v, want a %s validation error", opts, err, field)
+		}
+	}
+}
+
+func TestGetSearchLocales(t *testing.T) {
+	stats, _ := seedSearches(t)
+	now := time.Now()
+
+	report, err := stats.GetSearchLocales(SearchStatsOptions{From: now, To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, locale := range report.Locales {
+		got = append(got, fmt.Sprintf("%s:%d/%d %v", locale.Locale, locale.Searches, locale.Queries, formatQueries(locale.TopQueries)))
+	}
+	want := []string{
+		"en:5/3 [json formatter:2/0/3.0 qr code:2/2/0.0 base64:1/0/1.0]",
+		"(unknown):1/1 [pdf:1/0/2.0]",
+		"zh-CN:1/1 [json formatter:1/0/3.0]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("locales\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestGetSearchCTR(t *testing.T) {
+	stats, _ := seedSearches(t)
+	now := time.Now()
+
+	report, err := stats.GetSearchCTR(SearchStatsOptions{From: now, To: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, term := range report.Terms {
+		got = append(got, fmt.Sprintf("%s:%d/%d ctr %s converted %d/%d %s position %s",
+			term.Query, term.Clicks, term.Searches, formatRatio(term.CTR),
+			term.Converted, term.Sessions, formatRatio(term.Conversion), formatRatio(term.AvgPosition)))
+	}
+	// Visitor a searched json formatter twice and clicked two results, b
+	// searched it and clicked nothing, and d clicked a result of a search
+	// that was never recorded
+	want := []string{
+		"json formatter:2/3 ctr 0.67 converted 1/2 0.50 position 2.00",
+		"qr code:0/2 ctr 0.00 converted 0/1 0.00 position -",
+		"base64:0/1 ctr 0.00 converted 0/1 0.00 position -",
+		"pdf:0/1 ctr 0.00 converted 0/1 0.00 position -",
+		"uuid:1/0 ctr - converted 0/0 - position 2.00",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("terms\n%v\nwant\n%v", got, want)
+	}
+	if report.To.Sub(report.From) != 24*time.Hour {
+		t.Errorf("range %s to %s, want today in UTC", report.From, report.To)
+	}
+
+	report, err = stats.GetSearchCTR(SearchStatsOptions{From: now, To: now, Locale: "zh-CN"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Terms) != 1 || report.Terms[0].Query != "json formatter" || report.Terms[0].Clicks != 0 {
+		t.Errorf("zh-CN terms %+v, want json formatter without clicks", report.Terms)
+	}
+}
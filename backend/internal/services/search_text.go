@@ -0,0 +1,112 @@
+package services
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSearchTerms caps the number of terms taken from a single query
+const maxSearchTerms = 8
+
+// searchTerms splits a query into unique lowercase terms
+func searchTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, term := range tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// tokenize splits text into lowercase words on anything that is not a letter or digit
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// fuzzyMatch reports whether a term matches a word, or a prefix of it,
+// within an edit distance that grows with the term length
+func fuzzyMatch(term, word string) (int, bool) {
+	termRunes := []rune(term)
+	wordRunes := []rune(word)
+
+	maxDistance := 0
+	switch {
+	case len(termRunes) > 6:
+		maxDistance = 2
+	case len(termRunes) > 3:
+		maxDistance = 1
+	}
+
+	distance := levenshtein(termRunes, wordRunes)
+	if len(wordRunes) > len(termRunes) {
+		if prefix := levenshtein(termRunes, wordRunes[:len(termRunes)]); prefix < distance {
+			distance = prefix
+		}
+	}
+
+	return distance, distance <= maxDistance
+}
+
+// levenshtein computes the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// highlight HTML-escapes text and wraps case-insensitive occurrences of terms in <mark>
+func highlight(text string, terms []string) string {
+	if text == "" || len(terms) == 0 {
+		return html.EscapeString(text)
+	}
+
+	sorted := append([]string(nil), terms...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	quoted := make([]string, len(sorted))
+	for i, term := range sorted {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	pattern := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
@@ -2,19 +2,24 @@ package services
 
 import (
 	"time"
-	"tion-backend/internal/database"
-	"tion-backend/internal/models"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/i18n"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
 
 	"gorm.io/gorm"
 )
 
 type StatsService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	salts *visitorSalts
 }
 
 func NewStatsService() *StatsService {
+	db := database.GetDB()
 	return &StatsService{
-		db: database.GetDB(),
+		db:    db,
+		salts: newVisitorSalts(db),
 	}
 }
 
@@ -22,7 +27,11 @@ func NewStatsService() *StatsService {
 func (s *StatsService) GetToolStats() (map[string]interface{}, error) {
 	var totalTools int64
 	var activeTools int64
-	var categories []string
+	var categories []struct {
+		Slug      string `json:"slug"`
+		Name      string `json:"name"`
+		ToolCount int64  `json:"tool_count"`
+	}
 
 	// Count total tools
 	s.db.Model(&models.Tool{}).Count(&totalTools)
@@ -30,33 +39,39 @@ func (s *StatsService) GetToolStats() (map[string]interface{}, error) {
 	// Count active tools
 	s.db.Model(&models.Tool{}).Where("is_active = ?", true).Count(&activeTools)
 
-	// Get unique categories
-	s.db.Model(&models.Tool{}).Distinct("category").Pluck("category", &categories)
+	// Count active tools per category
+	err := s.db.Model(&models.Category{}).
+		Select("categories.slug, categories.name, COUNT(tools.id) AS tool_count").
+		Joins("LEFT JOIN tools ON tools.category_id = categories.id AND tools.is_active = ? AND tools.deleted_at IS NULL", true).
+		Group("categories.id, categories.slug, categories.name, categories.sort_order").
+		Order("categories.sort_order, categories.id").
+		Scan(&categories).Error
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
-		"total_tools":   totalTools,
-		"active_tools":  activeTools,
-		"categories":    categories,
+		"total_tools":      totalTools,
+		"active_tools":     activeTools,
+		"categories":       categories,
 		"total_categories": len(categories),
 	}, nil
 }
 
-// GetUsageStats gets usage statistics
-func (s *StatsService) GetUsageStats(days int) (map[string]interface{}, error) {
-	var totalUsage int64
-	var todayUsage int64
-	var weekUsage int64
-
-	// Count total usage
-	s.db.Model(&models.ToolUsage{}).Count(&totalUsage)
-
-	// Count today's usage
-	today := time.Now().Truncate(24 * time.Hour)
-	s.db.Model(&models.ToolUsage{}).Where("created_at >= ?", today).Count(&todayUsage)
-
-	// Count this week's usage
-	weekAgo := time.Now().AddDate(0, 0, -days)
-	s.db.Model(&models.ToolUsage{}).Where("created_at >= ?", weekAgo).Count(&weekUsage)
+// GetUsageStats gets usage statistics, with "today" starting at midnight in loc
+func (s *StatsService) GetUsageStats(days int, loc *time.Location) (map[string]interface{}, error) {
+	totalUsage, err := s.countUsage(time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	todayUsage, err := s.countUsage(startOfDay(time.Now(), loc), 0)
+	if err != nil {
+		return nil, err
+	}
+	weekUsage, err := s.countUsage(time.Now().AddDate(0, 0, -days), 0)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
 		"total_usage": totalUsage,
@@ -68,50 +83,79 @@ func (s *StatsService) GetUsageStats(days int) (map[string]interface{}, error) {
 
 // GetToolUsageStats gets usage stats for a specific tool
 func (s *StatsService) GetToolUsageStats(toolID uint, days int) (map[string]interface{}, error) {
-	var totalUsage int64
-	var recentUsage int64
-
-	// Count total usage for tool
-	s.db.Model(&models.ToolUsage{}).Where("tool_id = ?", toolID).Count(&totalUsage)
-
-	// Count recent usage
-	recentDate := time.Now().AddDate(0, 0, -days)
-	s.db.Model(&models.ToolUsage{}).Where("tool_id = ? AND created_at >= ?", toolID, recentDate).Count(&recentUsage)
+	totalUsage, err := s.countUsage(time.Time{}, toolID)
+	if err != nil {
+		return nil, err
+	}
+	recentUsage, err := s.countUsage(time.Now().AddDate(0, 0, -days), toolID)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
-		"tool_id":       toolID,
-		"total_usage":   totalUsage,
-		"recent_usage":  recentUsage,
-		"period_days":   days,
+		"tool_id":      toolID,
+		"total_usage":  totalUsage,
+		"recent_usage": recentUsage,
+		"period_days":  days,
 	}, nil
 }
 
-// RecordToolUsage records a tool usage
-func (s *StatsService) RecordToolUsage(toolID uint, ipAddress, userAgent string) error {
-	usage := &models.ToolUsage{
-		ToolID:    toolID,
-		IPAddress: ipAddress,
-		UserAgent: userAgent,
-		CreatedAt: time.Now(),
+// UsageContext describes where a tool was clicked
+type UsageContext struct {
+	Source   string // referrer URL or page path
+	Query    string // search query, when clicked from search results
+	Locale   string // UI locale
+	Position int    // 1-based position of the tool card, 0 if unknown
+}
+
+// NewToolUsage builds the usage row of a tool use under an anonymous visitor
+// identity derived from the IP address and user agent, which are not stored
+// themselves. Bots are not recorded, for them nil is returned.
+func (s *StatsService) NewToolUsage(toolID uint, ipAddress, userAgent string, usageCtx UsageContext) (*models.ToolUsage, error) {
+	if usageCtx.Position < 0 {
+		return nil, newValidationError("position", "must not be negative")
+	}
+
+	agent := utils.ParseUserAgent(userAgent)
+	if agent.Bot {
+		return nil, nil
+	}
+
+	now := time.Now()
+	salt, err := s.salts.forTime(now)
+	if err != nil {
+		return nil, err
 	}
-	return s.db.Create(usage).Error
+
+	return &models.ToolUsage{
+		ToolID:      toolID,
+		VisitorHash: utils.HashVisitor(salt, ipAddress, userAgent),
+		Browser:     agent.Browser,
+		OS:          agent.OS,
+		DeviceClass: agent.Device,
+		Source:      utils.NormalizeSource(usageCtx.Source),
+		SearchQuery: utils.NormalizeSearchQuery(usageCtx.Query),
+		Locale:      i18n.Normalize(usageCtx.Locale),
+		Position:    usageCtx.Position,
+		CreatedAt:   now,
+	}, nil
 }
 
-// GetOverviewStats gets overview statistics
-func (s *StatsService) GetOverviewStats() (map[string]interface{}, error) {
+// GetOverviewStats gets overview statistics, with "today" starting at midnight in loc
+func (s *StatsService) GetOverviewStats(loc *time.Location) (map[string]interface{}, error) {
 	var totalTools int64
-	var totalUsage int64
-	var todayUsage int64
 
 	// Count tools
 	s.db.Model(&models.Tool{}).Where("is_active = ?", true).Count(&totalTools)
 
-	// Count total usage
-	s.db.Model(&models.ToolUsage{}).Count(&totalUsage)
-
-	// Count today's usage
-	today := time.Now().Truncate(24 * time.Hour)
-	s.db.Model(&models.ToolUsage{}).Where("created_at >= ?", today).Count(&todayUsage)
+	totalUsage, err := s.countUsage(time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	todayUsage, err := s.countUsage(startOfDay(time.Now(), loc), 0)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
 		"total_tools":  totalTools,
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"tion.work/backend/pkg/utils"
+)
+
+func TestNewToolUsage(t *testing.T) {
+	newTestDB(t)
+	stats := NewStatsService()
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0"
+
+	for _, bot := range []string{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", ""} {
+		usage, err := stats.NewToolUsage(1, "203.0.113.7", bot, UsageContext{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage != nil {
+			t.Errorf("bot %q was recorded as %+v", bot, usage)
+		}
+	}
+
+	var hashes []string
+	for _, ip := range []string{"203.0.113.7", "203.0.113.7", "203.0.113.8"} {
+		usage, err := stats.NewToolUsage(1, ip, firefox, UsageContext{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage.ToolID != 1 || usage.Browser != "Firefox" || usage.OS != "Linux" || usage.DeviceClass != utils.DeviceDesktop {
+			t.Errorf("usage %+v, want Firefox on a Linux desktop", usage)
+		}
+		hashes = append(hashes, usage.VisitorHash)
+	}
+	salt, err := stats.salts.forTime(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := utils.HashVisitor(salt, "203.0.113.7", firefox); hashes[0] != want {
+		t.Errorf("visitor hash %q, want %q", hashes[0], want)
+	}
+	if hashes[1] != hashes[0] || hashes[2] == hashes[0] {
+		t.Errorf("visitor hashes %q, want the first two equal", hashes)
+	}
+}
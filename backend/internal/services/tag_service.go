@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+type TagService struct {
+	db *gorm.DB
+}
+
+func NewTagService() *TagService {
+	return &TagService{
+		db: database.GetDB(),
+	}
+}
+
+// TagWithCount is a tag with the number of active tools carrying it
+type TagWithCount struct {
+	models.Tag
+	ToolCount int64 `json:"tool_count"`
+}
+
+// ListTags gets all tags ordered by name with active tool counts
+func (s *TagService) ListTags() ([]TagWithCount, error) {
+	var tags []TagWithCount
+	err := s.db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(tools.id) AS tool_count").
+		Joins("LEFT JOIN tool_tags ON tool_tags.tag_id = tags.id").
+		Joins("LEFT JOIN tools ON tools.id = tool_tags.tool_id AND tools.is_active = ? AND tools.deleted_at IS NULL", true).
+		Group("tags.id").
+		Order("tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// CreateTag creates a new tag, deriving the slug from the name when empty
+func (s *TagService) CreateTag(name, slug string) (*models.Tag, error) {
+	if slug == "" {
+		slug = utils.Slugify(name)
+	}
+	if slug == "" {
+		return nil, newValidationError("slug", "could not be derived from the name")
+	}
+	if err := s.ensureSlugAvailable(slug, 0); err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{Slug: slug, Name: name}
+	if err := s.db.Create(tag).Error; err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// UpdateTag updates a tag and returns the updated record
+func (s *TagService) UpdateTag(id uint, updates map[string]interface{}) (*models.Tag, error) {
+	var tag models.Tag
+	if err := s.db.First(&tag, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+
+	if slug, ok := updates["slug"].(string); ok && slug != tag.Slug {
+		if err := s.ensureSlugAvailable(slug, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Model(&tag).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// DeleteTag deletes a tag and detaches it from all tools
+func (s *TagService) DeleteTag(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM tool_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Tag{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTagNotFound
+		}
+		return nil
+	})
+}
+
+// FindTags loads tags by ID, failing when any ID is unknown
+func (s *TagService) FindTags(ids []uint) ([]models.Tag, error) {
+	return findTags(s.db, ids)
+}
+
+func (s *TagService) ensureSlugAvailable(slug string, excludeID uint) error {
+	var count int64
+	query := s.db.Model(&models.Tag{}).Where("slug = ?", slug)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTagSlugTaken
+	}
+	return nil
+}
+
+// findTags loads tags by ID within the given transaction
+func findTags(db *gorm.DB, ids []uint) ([]models.Tag, error) {
+	if len(ids) == 0 {
+		return []models.Tag{}, nil
+	}
+
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	var tags []models.Tag
+	if err := db.Where("id IN ?", ids).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) != len(unique) {
+		return nil, newValidationError("tag_ids", "contains unknown tags")
+	}
+	return tags, nil
+}
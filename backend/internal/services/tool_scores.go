@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// popularityHorizon is how many half-lives of usage contribute to popularity;
+// older uses would weigh less than 2%
+const popularityHorizon = 6
+
+// ToolScorerOptions configures a ToolScorer. Zero values fall back to defaults.
+type ToolScorerOptions struct {
+	Interval       time.Duration // 0 disables scheduled refreshes
+	HalfLife       time.Duration // age at which a use counts half towards popularity
+	TrendWindow    time.Duration // recent window compared against the baseline
+	TrendBaseline  time.Duration // span before the window that sets the expected usage
+	MinTrendingUse int64         // recent uses a tool needs to be listed as trending
+}
+
+// ScoreSummary reports the outcome of a score refresh
+type ScoreSummary struct {
+	Tools      int           `json:"tools"`
+	ComputedAt time.Time     `json:"computed_at"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// TrendingTool is a tool whose recent usage rose above its baseline
+type TrendingTool struct {
+	Tool         models.Tool `json:"tool"`
+	Trending     float64     `json:"trending"`
+	RecentUses   int64       `json:"recent_uses"`
+	ExpectedUses float64     `json:"expected_uses"`
+	Delta        float64     `json:"delta"`
+	Growth       *float64    `json:"growth"` // RecentUses / ExpectedUses, nil without a baseline
+}
+
+// ToolScorer periodically recomputes the popularity and trending scores of
+// every tool. Popularity sums uses with an exponential time decay; trending
+// compares the uses of the recent window with the baseline before it,
+// scaled to the same length, as (recent - expected) / sqrt(expected + 1) so
+// that a few uses of an unknown tool do not outrank a real surge.
+type ToolScorer struct {
+	db    *gorm.DB
+	stats *StatsService
+	opts  ToolScorerOptions
+	mu    sync.Mutex
+}
+
+func NewToolScorer(opts ToolScorerOptions) *ToolScorer {
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = 7 * 24 * time.Hour
+	}
+	if opts.TrendWindow <= 0 {
+		opts.TrendWindow = 24 * time.Hour
+	}
+	if opts.TrendBaseline <= 0 {
+		opts.TrendBaseline = 7 * 24 * time.Hour
+	}
+	if opts.MinTrendingUse <= 0 {
+		opts.MinTrendingUse = 3
+	}
+	return &ToolScorer{
+		db:    database.GetDB(),
+		stats: NewStatsService(),
+		opts:  opts,
+	}
+}
+
+// Start refreshes the scores once and then every interval until the context is
+// cancelled. It does nothing when the interval is not positive.
+func (t *ToolScorer) Start(ctx context.Context) {
+	if t.opts.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(t.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := t.Refresh(); err != nil {
+				logging.Errorf("Tool score refresh failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh recomputes and stores the scores of all tools
+func (t *ToolScorer) Refresh() (*ScoreSummary, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	started := time.Now()
+	now := started.UTC()
+	windowStart := now.Add(-t.opts.TrendWindow)
+	baselineStart := windowStart.Add(-t.opts.TrendBaseline)
+	from := minTime(now.Add(-popularityHorizon*t.opts.HalfLife), baselineStart)
+
+	counts, err := t.stats.usageCounts(truncateHourUTC(from), now, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var toolIDs []uint
+	if err := t.db.Model(&models.Tool{}).Pluck("id", &toolIDs).Error; err != nil {
+		return nil, err
+	}
+
+	scores := make(map[uint]*models.ToolScore, len(toolIDs))
+	baselines := make(map[uint]int64, len(toolIDs))
+	for _, id := range toolIDs {
+		scores[id] = &models.ToolScore{ToolID: id, ComputedAt: now}
+	}
+	for _, count := range counts {
+		score, ok := scores[count.ToolID]
+		if !ok {
+			continue
+		}
+		start := time.Unix(count.Start, 0)
+		age := now.Sub(start)
+		if age < 0 {
+			age = 0
+		}
+		score.Popularity += float64(count.Count) * math.Exp2(-float64(age)/float64(t.opts.HalfLife))
+
+		switch {
+		case !start.Before(windowStart):
+			score.RecentUses += count.Count
+		case !start.Before(baselineStart):
+			baselines[count.ToolID] += count.Count
+		}
+	}
+
+	scale := float64(t.opts.TrendWindow) / float64(t.opts.TrendBaseline)
+	rows := make([]models.ToolScore, 0, len(scores))
+	for _, score := range scores {
+		score.ExpectedUses = float64(baselines[score.ToolID]) * scale
+		score.Delta = float64(score.RecentUses) - score.ExpectedUses
+		score.Trending = score.Delta / math.Sqrt(score.ExpectedUses+1)
+		rows = append(rows, *score)
+	}
+
+	err = t.db.Transaction(func(tx *gorm.DB) error {
+		if len(rows) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "tool_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"popularity", "trending", "recent_uses", "expected_uses", "delta", "computed_at"}),
+			}).CreateInBatches(rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		// Drop the scores of tools that were deleted since the last refresh
+		return tx.Where("computed_at < ?", now).Delete(&models.ToolScore{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ScoreSummary{Tools: len(rows), ComputedAt: now, Duration: time.Since(started)}, nil
+}
+
+// GetTrending lists active tools whose recent usage rose the most above their
+// baseline, from the last score refresh
+func (t *ToolScorer) GetTrending(limit int) ([]TrendingTool, error) {
+	limit = clampLimit(limit)
+
+	var scores []models.ToolScore
+	if err := t.db.Joins("JOIN tools ON tools.id = tool_scores.tool_id AND tools.deleted_at IS NULL").
+		Where("tools.is_active = ? AND tool_scores.delta > 0 AND tool_scores.recent_uses >= ?", true, t.opts.MinTrendingUse).
+		Order("tool_scores.trending DESC, tool_scores.tool_id").
+		Limit(limit).
+		Find(&scores).Error; err != nil {
+		return nil, err
+	}
+	if len(scores) == 0 {
+		return []TrendingTool{}, nil
+	}
+
+	ids := make([]uint, len(scores))
+	for i, score := range scores {
+		ids[i] = score.ToolID
+	}
+	var tools []models.Tool
+	if err := t.db.Preload("Category").Preload("Tags").Where("id IN ?", ids).Find(&tools).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Tool, len(tools))
+	for _, tool := range tools {
+		byID[tool.ID] = tool
+	}
+
+	trending := make([]TrendingTool, 0, len(scores))
+	for _, score := range scores {
+		tool, ok := byID[score.ToolID]
+		if !ok {
+			continue
+		}
+		item := TrendingTool{
+			Tool:         tool,
+			Trending:     score.Trending,
+			RecentUses:   score.RecentUses,
+			ExpectedUses: score.ExpectedUses,
+			Delta:        score.Delta,
+		}
+		if score.ExpectedUses > 0 {
+			growth := float64(score.RecentUses) / score.ExpectedUses
+			item.Growth = &growth
+		}
+		trending = append(trending, item)
+	}
+	return trending, nil
+}
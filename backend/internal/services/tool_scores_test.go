@@ -0,0 +1,125 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+// daysAgo returns the time the given number of days before now
+func daysAgo(now time.Time, days float64) time.Time {
+	return now.Add(-time.Duration(days * float64(24*time.Hour)))
+}
+
+// refreshScores recomputes the scores and returns them by tool ID
+func refreshScores(t *testing.T, scorer *ToolScorer) map[uint]models.ToolScore {
+	t.Helper()
+	if _, err := scorer.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	var scores []models.ToolScore
+	if err := scorer.db.Find(&scores).Error; err != nil {
+		t.Fatal(err)
+	}
+	byTool := make(map[uint]models.ToolScore, len(scores))
+	for _, score := range scores {
+		byTool[score.ToolID] = score
+	}
+	return byTool
+}
+
+func TestToolScorerPopularity(t *testing.T) {
+	newTestDB(t)
+	toolService := NewToolService()
+	tools := createTestTools(t, toolService, "fresh", "week", "ancient")
+	now := time.Now()
+	recordUsageAt(t, tools[0].ID, now.Add(-time.Minute))
+	recordUsageAt(t, tools[1].ID, daysAgo(now, 7))
+	// Past the horizon of six half-lives
+	recordUsageAt(t, tools[2].ID, daysAgo(now, 60))
+
+	scorer := NewToolScorer(ToolScorerOptions{HalfLife: 7 * 24 * time.Hour})
+	scores := refreshScores(t, scorer)
+	want := map[uint]float64{tools[0].ID: 1, tools[1].ID: 0.5, tools[2].ID: 0}
+	for id, popularity := range want {
+		// Uses are counted in 15 minute slots, which ages them slightly
+		if got := scores[id].Popularity; math.Abs(got-popularity) > 0.01 {
+			t.Errorf("tool %d popularity %.3f, want %.1f", id, got, popularity)
+		}
+	}
+
+	// Tools without scores yet rank as unused
+	createTestTools(t, toolService, "unscored")
+	for sort, want := range map[string]string{
+		"popular":  "[fresh week ancient unscored]",
+		"-popular": "[ancient unscored week fresh]",
+	} {
+		page := listTools(t, toolService, ToolListOptions{Sort: sort})
+		if got := fmt.Sprint(toolNames(page)); got != want {
+			t.Errorf("sort %s: %s, want %s", sort, got, want)
+		}
+	}
+
+	// Scores of deleted tools are dropped on the next refresh
+	if err := toolService.DeleteTool(tools[1].ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	scores = refreshScores(t, scorer)
+	if _, ok := scores[tools[1].ID]; ok || len(scores) != 3 {
+		t.Errorf("scores of tools %v, want the deleted tool dropped", scores)
+	}
+}
+
+func TestToolScorerTrending(t *testing.T) {
+	db := newTestDB(t)
+	tools := createTestTools(t, NewToolService(), "steady", "rising", "new", "few", "hidden")
+	now := time.Now()
+	// One use a day, as many in the last day as expected
+	for day := 0.5; day < 8; day++ {
+		recordUsageAt(t, tools[0].ID, daysAgo(now, day))
+	}
+	recordUsageAt(t, tools[1].ID, daysAgo(now, 3))
+	for i := 0; i < 5; i++ {
+		recordUsageAt(t, tools[1].ID, daysAgo(now, 0.1*float64(i+1)))
+		recordUsageAt(t, tools[4].ID, daysAgo(now, 0.1*float64(i+1)))
+	}
+	recordUsageAt(t, tools[2].ID, daysAgo(now, 0.2), daysAgo(now, 0.3), daysAgo(now, 0.4))
+	recordUsageAt(t, tools[3].ID, daysAgo(now, 0.2), daysAgo(now, 0.3))
+	if err := db.Model(&models.Tool{}).Where("id = ?", tools[4].ID).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	scorer := NewToolScorer(ToolScorerOptions{})
+	scores := refreshScores(t, scorer)
+	if score := scores[tools[0].ID]; score.RecentUses != 1 || math.Abs(score.ExpectedUses-1) > 1e-9 || math.Abs(score.Delta) > 1e-9 {
+		t.Errorf("steady tool %+v, want 1 use as expected", score)
+	}
+	// 5 uses against 1/7 expected: (5 - 1/7) / sqrt(8/7)
+	if got := scores[tools[1].ID].Trending; math.Abs(got-4.543) > 0.001 {
+		t.Errorf("rising tool trending %.3f, want 4.543", got)
+	}
+
+	trending, err := scorer.GetTrending(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range trending {
+		got = append(got, fmt.Sprintf("%s:%d/%.2f %s", item.Tool.Name, item.RecentUses, item.ExpectedUses, formatRatio(item.Growth)))
+	}
+	// Tools at their baseline, below the minimum uses or inactive are left out
+	want := []string{"rising:5/0.14 35.00", "new:3/0.00 -"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("trending %v, want %v", got, want)
+	}
+
+	trending, err = scorer.GetTrending(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trending) != 1 || trending[0].Tool.ID != tools[1].ID || trending[0].Tool.Category == nil {
+		t.Errorf("first trending tool %+v, want rising with its category", trending)
+	}
+}
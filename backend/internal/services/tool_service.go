@@ -1,65 +1,421 @@
 package services
 
 import (
-	"tion-backend/internal/database"
-	"tion-backend/internal/models"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
 
 	"gorm.io/gorm"
 )
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// toolSortColumns maps public sort keys to database columns
+var toolSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"category":   "category_id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// toolScoreColumns maps ranking sort keys to tool_scores columns. Ranked lists
+// put the highest score first; a "-" prefix reverses them.
+var toolScoreColumns = map[string]string{
+	"popular":  "tool_scores.popularity",
+	"trending": "tool_scores.trending",
+}
+
 type ToolService struct {
-	db *gorm.DB
+	db         *gorm.DB
+	categories *CategoryService
 }
 
 func NewToolService() *ToolService {
 	return &ToolService{
-		db: database.GetDB(),
+		db:         database.GetDB(),
+		categories: NewCategoryService(),
 	}
 }
 
+// withDB returns a copy of the service that runs its queries on db, usually a transaction
+func (s *ToolService) withDB(db *gorm.DB) *ToolService {
+	scoped := *s
+	scoped.db = db
+	return &scoped
+}
+
+// ToolListOptions controls filtering, sorting and pagination of tool lists
+type ToolListOptions struct {
+	Category        string   // category ID or slug, includes nested categories
+	Tags            []string // tag slugs
+	MatchAllTags    bool     // require every tag instead of any of them
+	Sort            string   // column name, prefix with "-" for descending order, or popular/trending
+	Limit           int
+	Offset          int
+	Cursor          string // keyset cursor, only valid when sorting by id
+	IncludeInactive bool
+}
+
+// ToolPage is a single page of tools
+type ToolPage struct {
+	Tools      []models.Tool `json:"tools"`
+	Total      int64         `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
 // GetAllTools gets all active tools
 func (s *ToolService) GetAllTools() ([]models.Tool, error) {
 	var tools []models.Tool
-	err := s.db.Where("is_active = ?", true).Find(&tools).Error
+	err := s.db.Preload("Category").Preload("Tags").Where("is_active = ?", true).Find(&tools).Error
 	return tools, err
 }
 
+// ListTools gets a filtered, sorted page of tools
+func (s *ToolService) ListTools(opts ToolListOptions) (*ToolPage, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = defaultPageSize
+	}
+	if opts.Limit > maxPageSize {
+		opts.Limit = maxPageSize
+	}
+	if opts.Offset < 0 {
+		return nil, newValidationError("offset", "must not be negative")
+	}
+
+	column, desc, err := parseToolSort(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Model(&models.Tool{})
+	if !opts.IncludeInactive {
+		query = query.Where("is_active = ?", true)
+	}
+	if opts.Category != "" {
+		category, err := s.categories.GetCategory(opts.Category)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := s.categories.DescendantIDs(category.ID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("category_id IN ?", ids)
+	}
+	if len(opts.Tags) > 0 {
+		query = filterByTags(query, opts.Tags, opts.MatchAllTags)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+
+	if opts.Cursor != "" {
+		if column != "id" {
+			return nil, newValidationError("cursor", "cursor pagination requires sort=id or sort=-id")
+		}
+		lastID, err := decodeToolCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if desc {
+			query = query.Where("id < ?", lastID)
+		} else {
+			query = query.Where("id > ?", lastID)
+		}
+		opts.Offset = 0
+	}
+
+	order := column + direction
+	if column != "id" {
+		order += ", id ASC"
+	}
+	if _, ranked := toolScoreColumns[strings.TrimPrefix(opts.Sort, "-")]; ranked {
+		// Tools without scores yet rank as unused
+		query = query.Select("tools.*").
+			Joins("LEFT JOIN tool_scores ON tool_scores.tool_id = tools.id")
+		order = "COALESCE(" + column + ", 0)" + direction + ", tools.id ASC"
+	}
+
+	var tools []models.Tool
+	if err := query.Preload("Category").Preload("Tags").
+		Order(order).Offset(opts.Offset).Limit(opts.Limit + 1).Find(&tools).Error; err != nil {
+		return nil, err
+	}
+
+	page := &ToolPage{
+		Total:  total,
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}
+
+	if len(tools) > opts.Limit {
+		tools = tools[:opts.Limit]
+		if column == "id" {
+			page.NextCursor = encodeToolCursor(tools[len(tools)-1].ID)
+		}
+	}
+	page.Tools = tools
+
+	return page, nil
+}
+
 // GetToolByID gets a tool by ID
 func (s *ToolService) GetToolByID(id uint) (*models.Tool, error) {
 	var tool models.Tool
-	err := s.db.Where("id = ? AND is_active = ?", id, true).First(&tool).Error
+	err := s.db.Preload("Category").Preload("Tags").Where("id = ? AND is_active = ?", id, true).First(&tool).Error
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrToolNotFound
+		}
 		return nil, err
 	}
 	return &tool, nil
 }
 
-// CreateTool creates a new tool
-func (s *ToolService) CreateTool(tool *models.Tool) error {
-	return s.db.Create(tool).Error
+// CreateTool creates a new tool with the given tags and records its first revision
+func (s *ToolService) CreateTool(tool *models.Tool, tagIDs []uint, actor string) error {
+	if err := s.ensureNameAvailable(tool.Name, 0); err != nil {
+		return err
+	}
+	if err := s.ensureValidCategory(tool.CategoryID); err != nil {
+		return err
+	}
+	tags, err := findTags(s.db, tagIDs)
+	if err != nil {
+		return err
+	}
+
+	// Create skips zero values of fields with a default, so deactivate explicitly
+	isActive := tool.IsActive
+	tool.Tags = tags
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tool).Error; err != nil {
+			return err
+		}
+		if !isActive {
+			if err := tx.Model(tool).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return recordToolRevision(tx, tool.ID, models.ToolRevisionCreate, actor, nil)
+	})
+	if err != nil {
+		return s.nameConflict(err)
+	}
+	invalidateFuzzyCandidates()
+	return s.db.Preload("Category").Preload("Tags").First(tool, tool.ID).Error
 }
 
-// UpdateTool updates an existing tool
-func (s *ToolService) UpdateTool(id uint, updates map[string]interface{}) error {
-	return s.db.Model(&models.Tool{}).Where("id = ?", id).Updates(updates).Error
+// UpdateTool updates an existing tool, records a revision if anything changed
+// and returns the updated record. A nil tagIDs leaves the tags unchanged, an
+// empty one removes them all.
+func (s *ToolService) UpdateTool(id uint, updates map[string]interface{}, tagIDs []uint, actor string) (*models.Tool, error) {
+	var tool models.Tool
+	if err := s.db.First(&tool, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrToolNotFound
+		}
+		return nil, err
+	}
+
+	if name, ok := updates["name"].(string); ok && name != tool.Name {
+		if err := s.ensureNameAvailable(name, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if categoryID, ok := updates["category_id"].(uint); ok {
+		if err := s.ensureValidCategory(&categoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	var tags []models.Tag
+	if tagIDs != nil {
+		var err error
+		if tags, err = findTags(s.db, tagIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&tool).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if tagIDs != nil {
+			if err := tx.Model(&tool).Association("Tags").Replace(tags); err != nil {
+				return err
+			}
+		}
+		return recordToolRevision(tx, id, models.ToolRevisionUpdate, actor, nil)
+	})
+	if err != nil {
+		return nil, s.nameConflict(err)
+	}
+	invalidateFuzzyCandidates()
+
+	if err := s.db.Preload("Category").Preload("Tags").First(&tool, id).Error; err != nil {
+		return nil, err
+	}
+	return &tool, nil
 }
 
-// DeleteTool soft deletes a tool
-func (s *ToolService) DeleteTool(id uint) error {
-	return s.db.Delete(&models.Tool{}, id).Error
+// DeleteTool soft deletes a tool and records the deletion as a revision
+func (s *ToolService) DeleteTool(id uint, actor string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Tool{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrToolNotFound
+		}
+		return recordToolRevision(tx, id, models.ToolRevisionDelete, actor, nil)
+	})
+	if err != nil {
+		return err
+	}
+	invalidateFuzzyCandidates()
+	return nil
 }
 
-// GetToolsByCategory gets tools by category
-func (s *ToolService) GetToolsByCategory(category string) ([]models.Tool, error) {
+// GetToolsByCategory gets the active tools of a category
+func (s *ToolService) GetToolsByCategory(categoryID uint) ([]models.Tool, error) {
 	var tools []models.Tool
-	err := s.db.Where("category = ? AND is_active = ?", category, true).Find(&tools).Error
+	err := s.db.Preload("Category").Preload("Tags").
+		Where("category_id = ? AND is_active = ?", categoryID, true).Find(&tools).Error
 	return tools, err
 }
 
-// SearchTools searches tools by name or description
+// SearchTools searches tools by name or description using portable case-insensitive matching.
+// Use SearchService for ranked full-text search.
 func (s *ToolService) SearchTools(query string) ([]models.Tool, error) {
 	var tools []models.Tool
-	err := s.db.Where("(name ILIKE ? OR description ILIKE ?) AND is_active = ?",
-		"%"+query+"%", "%"+query+"%", true).Find(&tools).Error
+	pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+	err := s.db.Where("(LOWER(name) LIKE ? ESCAPE '\\' OR LOWER(description) LIKE ? ESCAPE '\\') AND is_active = ?",
+		pattern, pattern, true).Find(&tools).Error
 	return tools, err
 }
+
+// ensureNameAvailable checks that no other tool uses the given name
+func (s *ToolService) ensureNameAvailable(name string, excludeID uint) error {
+	var count int64
+	query := s.db.Model(&models.Tool{}).Where("name = ?", name)
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrToolNameTaken
+	}
+	return nil
+}
+
+// nameConflict turns a violation of the unique tool name index, which a concurrent
+// write can cause after ensureNameAvailable passed, into ErrToolNameTaken
+func (s *ToolService) nameConflict(err error) error {
+	if translator, ok := s.db.Dialector.(gorm.ErrorTranslator); ok {
+		if errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+			return ErrToolNameTaken
+		}
+	}
+	return err
+}
+
+// ensureValidCategory checks that a tool references an existing category
+func (s *ToolService) ensureValidCategory(categoryID *uint) error {
+	if categoryID == nil {
+		return newValidationError("category_id", "is required")
+	}
+	var count int64
+	if err := s.db.Model(&models.Category{}).Where("id = ?", *categoryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return newValidationError("category_id", "category %d does not exist", *categoryID)
+	}
+	return nil
+}
+
+// filterByTags restricts a tool query to tools carrying any, or with matchAll every, tag slug
+func filterByTags(query *gorm.DB, slugs []string, matchAll bool) *gorm.DB {
+	tagged := query.Session(&gorm.Session{NewDB: true}).
+		Table("tool_tags").
+		Select("tool_tags.tool_id").
+		Joins("JOIN tags ON tags.id = tool_tags.tag_id").
+		Where("tags.slug IN ?", slugs)
+	if matchAll {
+		tagged = tagged.Group("tool_tags.tool_id").
+			Having("COUNT(DISTINCT tags.id) = ?", len(uniqueStrings(slugs)))
+	}
+	return query.Where("id IN (?)", tagged)
+}
+
+// uniqueStrings returns values without duplicates, keeping their order
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
+
+// parseToolSort converts a sort key such as "-created_at" into a column and direction
+func parseToolSort(sort string) (string, bool, error) {
+	if sort == "" {
+		return "id", false, nil
+	}
+
+	desc := strings.HasPrefix(sort, "-")
+	key := strings.TrimPrefix(sort, "-")
+	if column, ok := toolScoreColumns[key]; ok {
+		return column, !desc, nil
+	}
+	column, ok := toolSortColumns[key]
+	if !ok {
+		return "", false, newValidationError("sort", "unsupported sort key %q", sort)
+	}
+	return column, desc, nil
+}
+
+// encodeToolCursor encodes the last seen tool ID as an opaque cursor
+func encodeToolCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// decodeToolCursor decodes a cursor produced by encodeToolCursor
+func decodeToolCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, newValidationError("cursor", "malformed cursor")
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, newValidationError("cursor", "malformed cursor")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"tion.work/backend/internal/models"
+)
+
+// testCategory returns the ID of the category with the slug, creating it when missing
+func testCategory(t *testing.T, slug string) *uint {
+	t.Helper()
+	categories := NewCategoryService()
+	category, err := categories.GetCategory(slug)
+	if errors.Is(err, ErrCategoryNotFound) {
+		category, err = categories.CreateCategory(CategoryInput{Slug: slug, Name: slug})
+	}
+	if err != nil {
+		t.Fatalf("category %s: %v", slug, err)
+	}
+	return &category.ID
+}
+
+// createTestTools creates active tools in the text category with the given names in order
+func createTestTools(t *testing.T, tools *ToolService, names ...string) []models.Tool {
+	t.Helper()
+	categoryID := testCategory(t, "text")
+	created := make([]models.Tool, len(names))
+	for i, name := range names {
+		tool := models.Tool{Name: name, CategoryID: categoryID, URL: "https://tion.work/" + name, IsActive: true}
+		if err := tools.CreateTool(&tool, nil, "test"); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		created[i] = tool
+	}
+	return created
+}
+
+func toolNames(page *ToolPage) []string {
+	names := make([]string, len(page.Tools))
+	for i, tool := range page.Tools {
+		names[i] = tool.Name
+	}
+	return names
+}
+
+func listTools(t *testing.T, tools *ToolService, opts ToolListOptions) *ToolPage {
+	t.Helper()
+	page, err := tools.ListTools(opts)
+	if err != nil {
+		t.Fatalf("list %+v: %v", opts, err)
+	}
+	return page
+}
+
+func TestListToolsCursor(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	createTestTools(t, tools, "a", "b", "c", "d", "e")
+
+	for _, tt := range []struct {
+		sort string
+		want [][]string
+	}{
+		{"", [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"-id", [][]string{{"e", "d"}, {"c", "b"}, {"a"}}},
+	} {
+		var pages [][]string
+		cursor := ""
+		for {
+			page := listTools(t, tools, ToolListOptions{Sort: tt.sort, Limit: 2, Cursor: cursor})
+			if page.Total != 5 {
+				t.Errorf("sort %q: total %d, want 5", tt.sort, page.Total)
+			}
+			pages = append(pages, toolNames(page))
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if fmt.Sprint(pages) != fmt.Sprint(tt.want) {
+			t.Errorf("sort %q: pages %v, want %v", tt.sort, pages, tt.want)
+		}
+	}
+}
+
+func TestListToolsOffsetAndSort(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	imageID := testCategory(t, "image")
+	created := createTestTools(t, tools, "delta", "alpha", "charlie", "bravo")
+	if _, err := tools.UpdateTool(created[2].ID, map[string]interface{}{"category_id": *imageID}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tools.UpdateTool(created[3].ID, map[string]interface{}{"is_active": false}, nil, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts ToolListOptions
+		want []string
+	}{
+		{"by name", ToolListOptions{Sort: "name"}, []string{"alpha", "charlie", "delta"}},
+		{"by name descending", ToolListOptions{Sort: "-name"}, []string{"delta", "charlie", "alpha"}},
+		{"second page", ToolListOptions{Sort: "name", Limit: 2, Offset: 2}, []string{"delta"}},
+		{"ties broken by id", ToolListOptions{Sort: "category"}, []string{"charlie", "delta", "alpha"}},
+		{"category by id", ToolListOptions{Category: strconv.Itoa(int(*imageID))}, []string{"charlie"}},
+		{"category", ToolListOptions{Category: "text", Sort: "name"}, []string{"alpha", "delta"}},
+		{"inactive", ToolListOptions{Sort: "name", IncludeInactive: true}, []string{"alpha", "bravo", "charlie", "delta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := listTools(t, tools, tt.opts)
+			if got := toolNames(page); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("tools %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("next cursor %q when not sorting by id", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestListToolsInvalidOptions(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+
+	for _, opts := range []ToolListOptions{
+		{Sort: "popularity"},
+		{Offset: -1},
+		{Sort: "name", Cursor: encodeToolCursor(1)},
+		{Cursor: "not a cursor"},
+	} {
+		_, err := tools.ListTools(opts)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("list %+v = %v, want a validation error", opts, err)
+		}
+	}
+
+	page := listTools(t, tools, ToolListOptions{Limit: 1000})
+	if page.Limit != maxPageSize {
+		t.Errorf("limit %d, want it capped at %d", page.Limit, maxPageSize)
+	}
+}
+
+func TestToolNamesAreUnique(t *testing.T) {
+	db := newTestDB(t)
+	tools := NewToolService()
+	created := createTestTools(t, tools, "calculator", "timer")
+
+	duplicate := models.Tool{Name: "calculator", CategoryID: created[0].CategoryID, URL: "https://tion.work/other"}
+	if err := tools.CreateTool(&duplicate, nil, "test"); !errors.Is(err, ErrToolNameTaken) {
+		t.Errorf("create duplicate = %v, want ErrToolNameTaken", err)
+	}
+	if _, err := tools.UpdateTool(created[1].ID, map[string]interface{}{"name": "calculator"}, nil, "test"); !errors.Is(err, ErrToolNameTaken) {
+		t.Errorf("rename to a taken name = %v, want ErrToolNameTaken", err)
+	}
+
+	// The index rejects duplicates written past the service check
+	if err := tools.nameConflict(db.Create(&models.Tool{Name: "timer"}).Error); !errors.Is(err, ErrToolNameTaken) {
+		t.Errorf("insert duplicate = %v, want ErrToolNameTaken", err)
+	}
+
+	// Deleted tools give up their name
+	if err := tools.DeleteTool(created[0].ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+	createTestTools(t, tools, "calculator")
+}
+
+func TestConcurrentCreatesWithTheSameName(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	categoryID := testCategory(t, "text")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = tools.CreateTool(&models.Tool{Name: "calculator", CategoryID: categoryID, URL: "https://tion.work/calculator"}, nil, "test")
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrToolNameTaken):
+			t.Errorf("concurrent create = %v, want ErrToolNameTaken", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d tools created, want 1", created)
+	}
+}
+
+func TestToolCategoryValidation(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	created := createTestTools(t, tools, "calculator")[0]
+	missing := *created.CategoryID + 100
+
+	for _, tool := range []models.Tool{
+		{Name: "no category", URL: "https://tion.work/a"},
+		{Name: "unknown category", CategoryID: &missing, URL: "https://tion.work/b"},
+	} {
+		if err := tools.CreateTool(&tool, nil, "test"); !isValidationError(err, "category_id") {
+			t.Errorf("create %q = %v, want a category_id validation error", tool.Name, err)
+		}
+	}
+	if _, err := tools.UpdateTool(created.ID, map[string]interface{}{"category_id": missing}, nil, "test"); !isValidationError(err, "category_id") {
+		t.Errorf("move to an unknown category = %v, want a category_id validation error", err)
+	}
+	if _, err := tools.UpdateTool(created.ID, nil, []uint{42}, "test"); !isValidationError(err, "tag_ids") {
+		t.Errorf("unknown tag = %v, want a tag_ids validation error", err)
+	}
+
+	tool, err := tools.GetToolByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool.Category == nil || tool.Category.Slug != "text" {
+		t.Errorf("category %+v after rejected updates, want text", tool.Category)
+	}
+}
+
+func TestListToolsByCategoryTreeAndTags(t *testing.T) {
+	newTestDB(t)
+	tools := NewToolService()
+	tags := NewTagService()
+	web, err := tags.CreateTag("Web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli, err := tags.CreateTag("CLI", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := NewCategoryService().CreateCategory(CategoryInput{Name: "Markdown", ParentID: testCategory(t, "text")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := createTestTools(t, tools, "a", "b", "c")
+	for i, update := range []struct {
+		updates map[string]interface{}
+		tagIDs  []uint
+	}{
+		{nil, []uint{web.ID}},
+		{map[string]interface{}{"category_id": child.ID}, []uint{web.ID, cli.ID, cli.ID}},
+		{nil, []uint{cli.ID}},
+	} {
+		if _, err := tools.UpdateTool(created[i].ID, update.updates, update.tagIDs, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An empty list removes all tags
+	if _, err := tools.UpdateTool(created[2].ID, nil, []uint{}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts ToolListOptions
+		want []string
+	}{
+		{"parent includes nested categories", ToolListOptions{Category: "text"}, []string{"a", "b", "c"}},
+		{"nested category", ToolListOptions{Category: "markdown"}, []string{"b"}},
+		{"any tag", ToolListOptions{Tags: []string{"web", "cli"}}, []string{"a", "b"}},
+		{"all tags", ToolListOptions{Tags: []string{"web", "cli", "web"}, MatchAllTags: true}, []string{"b"}},
+		{"tag in category", ToolListOptions{Category: "markdown", Tags: []string{"web"}}, []string{"b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolNames(listTools(t, tools, tt.opts)); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("tools %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := tools.ListTools(ToolListOptions{Category: "missing"}); !errors.Is(err, ErrCategoryNotFound) {
+		t.Errorf("unknown category = %v, want ErrCategoryNotFound", err)
+	}
+}
+
+// isValidationError reports whether err is a validation error for the field
+func isValidationError(err error, field string) bool {
+	var validationErr *ValidationError
+	return errors.As(err, &validationErr) && validationErr.Field == field
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/i18n"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TranslationService struct {
+	db *gorm.DB
+}
+
+func NewTranslationService() *TranslationService {
+	return &TranslationService{
+		db: database.GetDB(),
+	}
+}
+
+// TranslationInput holds the localized text for a tool
+type TranslationInput struct {
+	Name        string
+	Description string
+}
+
+// GetTranslations gets all translations of a tool
+func (s *TranslationService) GetTranslations(toolID uint) ([]models.ToolTranslation, error) {
+	if err := s.ensureToolExists(toolID); err != nil {
+		return nil, err
+	}
+
+	var translations []models.ToolTranslation
+	err := s.db.Where("tool_id = ?", toolID).Order("locale").Find(&translations).Error
+	return translations, err
+}
+
+// UpsertTranslation creates or replaces the translation of a tool for a locale
+func (s *TranslationService) UpsertTranslation(toolID uint, locale string, input TranslationInput) (*models.ToolTranslation, error) {
+	normalized := i18n.Normalize(locale)
+	if normalized == "" {
+		return nil, newValidationError("locale", "%q is not a valid locale", locale)
+	}
+	if err := s.ensureToolExists(toolID); err != nil {
+		return nil, err
+	}
+
+	translation := &models.ToolTranslation{
+		ToolID:      toolID,
+		Locale:      normalized,
+		Name:        input.Name,
+		Description: input.Description,
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tool_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "description", "updated_at"}),
+	}).Create(translation).Error
+	if err != nil {
+		return nil, err
+	}
+	invalidateFuzzyCandidates()
+
+	if err := s.db.Where("tool_id = ? AND locale = ?", toolID, normalized).First(translation).Error; err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+// DeleteTranslation removes the translation of a tool for a locale
+func (s *TranslationService) DeleteTranslation(toolID uint, locale string) error {
+	result := s.db.Where("tool_id = ? AND locale = ?", toolID, i18n.Normalize(locale)).
+		Delete(&models.ToolTranslation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTranslationNotFound
+	}
+	invalidateFuzzyCandidates()
+	return nil
+}
+
+// LocalizeTool resolves the text of a single tool along the locale chain
+func (s *TranslationService) LocalizeTool(tool *models.Tool, locales []string) error {
+	tools := []models.Tool{*tool}
+	if err := s.LocalizeTools(tools, locales); err != nil {
+		return err
+	}
+	*tool = tools[0]
+	return nil
+}
+
+// LocalizeTools replaces the name and description of each tool, and the name of its
+// category, with the first translation found along the locale chain. Tools without
+// a matching translation keep their original text.
+func (s *TranslationService) LocalizeTools(tools []models.Tool, locales []string) error {
+	if len(tools) == 0 || len(locales) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(tools))
+	var categories []models.Category
+	for i, tool := range tools {
+		ids[i] = tool.ID
+		if tool.Category != nil {
+			categories = append(categories, *tool.Category)
+		}
+	}
+
+	var translations []models.ToolTranslation
+	if err := s.db.Where("tool_id IN ? AND locale IN ?", ids, locales).Find(&translations).Error; err != nil {
+		return err
+	}
+
+	byTool := make(map[uint]map[string]models.ToolTranslation)
+	for _, translation := range translations {
+		if byTool[translation.ToolID] == nil {
+			byTool[translation.ToolID] = make(map[string]models.ToolTranslation)
+		}
+		byTool[translation.ToolID][translation.Locale] = translation
+	}
+
+	if err := s.LocalizeCategories(categories, locales); err != nil {
+		return err
+	}
+	localizedCategories := make(map[uint]models.Category, len(categories))
+	for _, category := range categories {
+		localizedCategories[category.ID] = category
+	}
+
+	for i := range tools {
+		if tools[i].Category != nil {
+			category := localizedCategories[tools[i].Category.ID]
+			tools[i].Category = &category
+		}
+
+		for _, locale := range locales {
+			translation, ok := byTool[tools[i].ID][locale]
+			if !ok {
+				continue
+			}
+			tools[i].Name = translation.Name
+			if translation.Description != "" {
+				tools[i].Description = translation.Description
+			}
+			tools[i].Locale = locale
+			break
+		}
+	}
+
+	return nil
+}
+
+// LocalizeCategories replaces the name of each category with the first
+// translation found along the locale chain
+func (s *TranslationService) LocalizeCategories(categories []models.Category, locales []string) error {
+	if len(categories) == 0 || len(locales) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(categories))
+	for i, category := range categories {
+		ids[i] = category.ID
+	}
+
+	var translations []models.CategoryTranslation
+	if err := s.db.Where("category_id IN ? AND locale IN ?", ids, locales).Find(&translations).Error; err != nil {
+		return err
+	}
+
+	byCategory := make(map[uint]map[string]string)
+	for _, translation := range translations {
+		if byCategory[translation.CategoryID] == nil {
+			byCategory[translation.CategoryID] = make(map[string]string)
+		}
+		byCategory[translation.CategoryID][translation.Locale] = translation.Name
+	}
+
+	for i := range categories {
+		for _, locale := range locales {
+			if name, ok := byCategory[categories[i].ID][locale]; ok {
+				categories[i].Name = name
+				categories[i].Locale = locale
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetCategoryTranslations gets all translations of a category
+func (s *TranslationService) GetCategoryTranslations(categoryID uint) ([]models.CategoryTranslation, error) {
+	if err := s.ensureCategoryExists(categoryID); err != nil {
+		return nil, err
+	}
+
+	var translations []models.CategoryTranslation
+	err := s.db.Where("category_id = ?", categoryID).Order("locale").Find(&translations).Error
+	return translations, err
+}
+
+// UpsertCategoryTranslation creates or replaces the name of a category for a locale
+func (s *TranslationService) UpsertCategoryTranslation(categoryID uint, locale, name string) (*models.CategoryTranslation, error) {
+	normalized := i18n.Normalize(locale)
+	if normalized == "" {
+		return nil, newValidationError("locale", "%q is not a valid locale", locale)
+	}
+	if err := s.ensureCategoryExists(categoryID); err != nil {
+		return nil, err
+	}
+
+	translation := &models.CategoryTranslation{
+		CategoryID: categoryID,
+		Locale:     normalized,
+		Name:       name,
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category_id"}, {Name: "locale"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "updated_at"}),
+	}).Create(translation).Error
+	if err != nil {
+		return nil, err
+	}
+	invalidateFuzzyCandidates()
+
+	if err := s.db.Where("category_id = ? AND locale = ?", categoryID, normalized).First(translation).Error; err != nil {
+		return nil, err
+	}
+	return translation, nil
+}
+
+// DeleteCategoryTranslation removes the name of a category for a locale
+func (s *TranslationService) DeleteCategoryTranslation(categoryID uint, locale string) error {
+	result := s.db.Where("category_id = ? AND locale = ?", categoryID, i18n.Normalize(locale)).
+		Delete(&models.CategoryTranslation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTranslationNotFound
+	}
+	invalidateFuzzyCandidates()
+	return nil
+}
+
+// ensureToolExists checks that a tool exists, including inactive tools
+func (s *TranslationService) ensureToolExists(toolID uint) error {
+	var count int64
+	if err := s.db.Model(&models.Tool{}).Where("id = ?", toolID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrToolNotFound
+	}
+	return nil
+}
+
+// ensureCategoryExists checks that a category exists
+func (s *TranslationService) ensureCategoryExists(categoryID uint) error {
+	var count int64
+	if err := s.db.Model(&models.Category{}).Where("id = ?", categoryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
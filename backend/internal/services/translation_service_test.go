@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"tion.work/backend/internal/models"
+)
+
+func TestTranslationCRUD(t *testing.T) {
+	newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "Calculator")[0]
+	translations := NewTranslationService()
+
+	saved, err := translations.UpsertTranslation(tool.ID, "zh_cn", TranslationInput{Name: "计算器"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Locale != "zh-CN" || saved.Name != "计算器" || saved.ID == 0 {
+		t.Errorf("saved %+v, want a zh-CN translation", saved)
+	}
+
+	// Saving the same locale again replaces the translation
+	replaced, err := translations.UpsertTranslation(tool.ID, "zh-CN", TranslationInput{Name: "计算机", Description: "四则运算"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replaced.ID != saved.ID || replaced.Name != "计算机" || replaced.Description != "四则运算" {
+		t.Errorf("replaced %+v, want translation %d updated", replaced, saved.ID)
+	}
+	if _, err := translations.UpsertTranslation(tool.ID, "de", TranslationInput{Name: "Rechner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := translations.GetTranslations(tool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locales []string
+	for _, translation := range list {
+		locales = append(locales, translation.Locale+"="+translation.Name)
+	}
+	if fmt.Sprint(locales) != "[de=Rechner zh-CN=计算机]" {
+		t.Errorf("translations %v", locales)
+	}
+
+	if err := translations.DeleteTranslation(tool.ID, "zh_cn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := translations.DeleteTranslation(tool.ID, "zh-CN"); !errors.Is(err, ErrTranslationNotFound) {
+		t.Errorf("delete twice = %v, want ErrTranslationNotFound", err)
+	}
+
+	if _, err := translations.UpsertTranslation(tool.ID, "not a locale", TranslationInput{Name: "x"}); !errors.As(err, new(*ValidationError)) {
+		t.Errorf("invalid locale = %v, want a validation error", err)
+	}
+	if _, err := translations.UpsertTranslation(tool.ID+100, "fr", TranslationInput{Name: "x"}); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("missing tool = %v, want ErrToolNotFound", err)
+	}
+	if _, err := translations.GetTranslations(tool.ID + 100); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("list for a missing tool = %v, want ErrToolNotFound", err)
+	}
+}
+
+func TestLocalizeToolsFallbackOrder(t *testing.T) {
+	newTestDB(t)
+	created := createTestTools(t, NewToolService(), "Calculator", "Timer")
+	translations := NewTranslationService()
+	for _, tr := range []struct {
+		locale string
+		input  TranslationInput
+	}{
+		{"zh", TranslationInput{Name: "计算器", Description: "简体说明"}},
+		{"zh-Hant", TranslationInput{Name: "計算機"}},
+		{"en", TranslationInput{Name: "Calculator (en)"}},
+	} {
+		if _, err := translations.UpsertTranslation(created[0].ID, tr.locale, tr.input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		locales                   []string
+		name, description, locale string
+	}{
+		// The most specific translation wins and empty fields keep the original text
+		{[]string{"zh-Hant-TW", "zh-Hant", "zh", "en"}, "計算機", "", "zh-Hant"},
+		{[]string{"zh-CN", "zh", "en"}, "计算器", "简体说明", "zh"},
+		// The default locale comes last
+		{[]string{"fr-CA", "fr", "en"}, "Calculator (en)", "", "en"},
+		{[]string{"fr"}, "Calculator", "", ""},
+		{nil, "Calculator", "", ""},
+	}
+	for _, tt := range tests {
+		tools := []models.Tool{created[0], created[1]}
+		if err := translations.LocalizeTools(tools, tt.locales); err != nil {
+			t.Fatal(err)
+		}
+		if tools[0].Name != tt.name || tools[0].Description != tt.description || tools[0].Locale != tt.locale {
+			t.Errorf("locales %v: got %q/%q in %q, want %q/%q in %q", tt.locales,
+				tools[0].Name, tools[0].Description, tools[0].Locale, tt.name, tt.description, tt.locale)
+		}
+		if tools[1].Name != "Timer" || tools[1].Locale != "" {
+			t.Errorf("locales %v: untranslated tool became %q in %q", tt.locales, tools[1].Name, tools[1].Locale)
+		}
+	}
+
+	// Category names follow the same chain
+	if _, err := translations.UpsertCategoryTranslation(*created[0].CategoryID, "zh", "文本"); err != nil {
+		t.Fatal(err)
+	}
+	tool, err := NewToolService().GetToolByID(created[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := translations.LocalizeTool(tool, []string{"zh-Hant", "zh"}); err != nil {
+		t.Fatal(err)
+	}
+	if tool.Name != "計算機" || tool.Category.Name != "文本" || tool.Category.Locale != "zh" {
+		t.Errorf("localized %q in category %q (%q), want the zh category name", tool.Name, tool.Category.Name, tool.Category.Locale)
+	}
+}
+
+func TestSearchTranslations(t *testing.T) {
+	s, _, seeded := newLikeSearch(t)
+	if _, err := NewTranslationService().UpsertTranslation(seeded[0].ID, "zh", TranslationInput{Name: "JSON 格式化"}); err != nil {
+		t.Fatal(err)
+	}
+
+	page := search(t, s, SearchOptions{Query: "格式化", Locales: []string{"zh", "en"}})
+	if fmt.Sprint(resultNames(page)) != "[JSON 格式化]" || page.Results[0].Tool.Locale != "zh" {
+		t.Errorf("results %v, want the tool found by and shown in its translation", resultNames(page))
+	}
+	page = search(t, s, SearchOptions{Query: "格式化", Locales: []string{"en"}})
+	if fmt.Sprint(resultNames(page)) != "[JSON Formatter]" {
+		t.Errorf("results %v, want the tool in the original text", resultNames(page))
+	}
+}
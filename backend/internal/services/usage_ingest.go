@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+)
+
+// UsageIngestOptions configures a UsageIngestor. Zero values fall back to defaults.
+type UsageIngestOptions struct {
+	QueueSize      int           // rows waiting for the flush loop, on top of the batch being filled
+	BatchSize      int           // rows per multi-row insert; a full batch is flushed right away
+	FlushInterval  time.Duration // a partial batch is flushed after this long
+	EnqueueTimeout time.Duration // how long Enqueue waits for room in a full queue before dropping
+
+	// OnFlush is called with the rows of every batch written to the database
+	OnFlush func(rows []models.ToolUsage)
+}
+
+// IngestStats reports the state and counters of a UsageIngestor
+type IngestStats struct {
+	Queued    int        `json:"queued"`
+	Capacity  int        `json:"capacity"`
+	Accepted  uint64     `json:"accepted"`
+	Dropped   uint64     `json:"dropped"`   // rejected because the queue stayed full
+	Flushed   uint64     `json:"flushed"`   // inserted into the database
+	Discarded uint64     `json:"discarded"` // skipped at flush time because the tool is gone or inactive
+	Failed    uint64     `json:"failed"`    // lost because their batch could not be inserted
+	Batches   uint64     `json:"batches"`
+	LastFlush *time.Time `json:"last_flush,omitempty"`
+}
+
+// UsageIngestor buffers tool usage rows in memory and writes them in
+// multi-row inserts, so that recording a use does not wait for the database.
+// When the queue is full, Enqueue waits briefly and then drops the row.
+type UsageIngestor struct {
+	db    *gorm.DB
+	opts  UsageIngestOptions
+	queue chan models.ToolUsage
+	done  chan struct{}
+
+	// mu guards closing the queue against concurrent sends
+	mu      sync.RWMutex
+	started bool
+	closed  bool
+
+	accepted  atomic.Uint64
+	dropped   atomic.Uint64
+	flushed   atomic.Uint64
+	discarded atomic.Uint64
+	failed    atomic.Uint64
+	batches   atomic.Uint64
+	lastFlush atomic.Int64
+}
+
+func NewUsageIngestor(opts UsageIngestOptions) *UsageIngestor {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.EnqueueTimeout < 0 {
+		opts.EnqueueTimeout = 0
+	}
+	return &UsageIngestor{
+		db:    database.GetDB(),
+		opts:  opts,
+		queue: make(chan models.ToolUsage, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start runs the flush loop until Stop is called. The context is not used to
+// stop the loop, so that rows queued during shutdown are still written.
+func (i *UsageIngestor) Start(ctx context.Context) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.started || i.closed {
+		return
+	}
+	i.started = true
+
+	go i.run()
+}
+
+// Enqueue queues a usage row for the next flush. It returns
+// ErrUsageQueueFull when no room frees up within the enqueue timeout and
+// ErrUsageIngestClosed once the ingestor is stopped.
+func (i *UsageIngestor) Enqueue(usage models.ToolUsage) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.closed {
+		return ErrUsageIngestClosed
+	}
+
+	select {
+	case i.queue <- usage:
+		i.accepted.Add(1)
+		return nil
+	default:
+	}
+
+	if i.opts.EnqueueTimeout > 0 {
+		timer := time.NewTimer(i.opts.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case i.queue <- usage:
+			i.accepted.Add(1)
+			return nil
+		case <-timer.C:
+		}
+	}
+
+	i.dropped.Add(1)
+	return ErrUsageQueueFull
+}
+
+// Stop stops accepting rows and waits until the queued ones are written or
+// the context is done
+func (i *UsageIngestor) Stop(ctx context.Context) error {
+	i.mu.Lock()
+	if !i.closed {
+		i.closed = true
+		close(i.queue)
+		if !i.started {
+			// Nobody consumes the queue, drain it here
+			i.started = true
+			go i.run()
+		}
+	}
+	i.mu.Unlock()
+
+	select {
+	case <-i.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current queue length and counters
+func (i *UsageIngestor) Stats() IngestStats {
+	stats := IngestStats{
+		Queued:    len(i.queue),
+		Capacity:  cap(i.queue),
+		Accepted:  i.accepted.Load(),
+		Dropped:   i.dropped.Load(),
+		Flushed:   i.flushed.Load(),
+		Discarded: i.discarded.Load(),
+		Failed:    i.failed.Load(),
+		Batches:   i.batches.Load(),
+	}
+	if last := i.lastFlush.Load(); last != 0 {
+		lastFlush := time.Unix(0, last)
+		stats.LastFlush = &lastFlush
+	}
+	return stats
+}
+
+// run collects rows into batches and flushes them when a batch is full, when
+// the flush interval passes and when the queue is closed
+func (i *UsageIngestor) run() {
+	defer close(i.done)
+
+	ticker := time.NewTicker(i.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.ToolUsage, 0, i.opts.BatchSize)
+	for {
+		select {
+		case usage, ok := <-i.queue:
+			if !ok {
+				i.flush(batch)
+				return
+			}
+			batch = append(batch, usage)
+			if len(batch) >= i.opts.BatchSize {
+				i.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				i.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// flush inserts a batch in one statement, leaving out rows of tools that are
+// missing or inactive
+func (i *UsageIngestor) flush(batch []models.ToolUsage) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var activeIDs []uint
+	if err := i.db.Model(&models.Tool{}).
+		Where("id IN ? AND is_active = ?", uniqueToolIDs(batch), true).
+		Pluck("id", &activeIDs).Error; err != nil {
+		i.failed.Add(uint64(len(batch)))
+		logging.Errorf("Failed to flush %d usage rows: %v", len(batch), err)
+		return
+	}
+	active := make(map[uint]bool, len(activeIDs))
+	for _, id := range activeIDs {
+		active[id] = true
+	}
+
+	rows := make([]models.ToolUsage, 0, len(batch))
+	for _, usage := range batch {
+		if active[usage.ToolID] {
+			rows = append(rows, usage)
+		}
+	}
+	i.discarded.Add(uint64(len(batch) - len(rows)))
+	if len(rows) == 0 {
+		return
+	}
+
+	if err := i.db.Create(&rows).Error; err != nil {
+		i.failed.Add(uint64(len(rows)))
+		logging.Errorf("Failed to flush %d usage rows: %v", len(rows), err)
+		return
+	}
+	i.flushed.Add(uint64(len(rows)))
+	i.batches.Add(1)
+	i.lastFlush.Store(time.Now().UnixNano())
+
+	if i.opts.OnFlush != nil {
+		i.opts.OnFlush(rows)
+	}
+}
+
+// uniqueToolIDs returns the distinct tool IDs of a batch
+func uniqueToolIDs(batch []models.ToolUsage) []uint {
+	seen := make(map[uint]bool)
+	ids := make([]uint, 0)
+	for _, usage := range batch {
+		if !seen[usage.ToolID] {
+			seen[usage.ToolID] = true
+			ids = append(ids, usage.ToolID)
+		}
+	}
+	return ids
+}
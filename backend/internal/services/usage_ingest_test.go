@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+func TestUsageIngestorDrainsOnStop(t *testing.T) {
+	db := newTestDB(t)
+	active := models.Tool{Name: "Active", URL: "/tools/active", IsActive: true}
+	inactive := models.Tool{Name: "Inactive", URL: "/tools/inactive", IsActive: true}
+	if err := db.Create(&active).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&inactive).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&inactive).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var flushedBatches int
+	ingestor := NewUsageIngestor(UsageIngestOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour, // only full batches and Stop flush
+		OnFlush:       func(rows []models.ToolUsage) { flushedBatches++ },
+	})
+	ingestor.Start(context.Background())
+
+	for i := 0; i < 5; i++ {
+		if err := ingestor.Enqueue(models.ToolUsage{ToolID: active.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ingestor.Enqueue(models.ToolUsage{ToolID: inactive.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ingestor.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows int64
+	db.Model(&models.ToolUsage{}).Count(&rows)
+	if rows != 5 {
+		t.Errorf("wrote %d usage rows, want 5", rows)
+	}
+	stats := ingestor.Stats()
+	if stats.Accepted != 6 || stats.Flushed != 5 || stats.Discarded != 1 || stats.Queued != 0 {
+		t.Errorf("stats %+v, want 6 accepted, 5 flushed, 1 discarded and none queued", stats)
+	}
+	if stats.LastFlush == nil || flushedBatches != int(stats.Batches) {
+		t.Errorf("OnFlush called %d times for %d batches", flushedBatches, stats.Batches)
+	}
+
+	if err := ingestor.Enqueue(models.ToolUsage{ToolID: active.ID}); !errors.Is(err, ErrUsageIngestClosed) {
+		t.Errorf("Enqueue after Stop = %v, want ErrUsageIngestClosed", err)
+	}
+}
+
+func TestUsageIngestorQueueFull(t *testing.T) {
+	db := newTestDB(t)
+	tool := models.Tool{Name: "Active", URL: "/tools/active", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Without Start nothing consumes the queue until Stop drains it
+	ingestor := NewUsageIngestor(UsageIngestOptions{QueueSize: 2, EnqueueTimeout: 10 * time.Millisecond})
+	for i := 0; i < 2; i++ {
+		if err := ingestor.Enqueue(models.ToolUsage{ToolID: tool.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ingestor.Enqueue(models.ToolUsage{ToolID: tool.ID}); !errors.Is(err, ErrUsageQueueFull) {
+		t.Errorf("Enqueue into a full queue = %v, want ErrUsageQueueFull", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := ingestor.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stats := ingestor.Stats()
+	if stats.Accepted != 2 || stats.Dropped != 1 || stats.Flushed != 2 {
+		t.Errorf("stats %+v, want 2 accepted, 1 dropped and 2 flushed", stats)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/logging"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// usageRollupName identifies the watermark of the tool usage rollups
+	usageRollupName = "tool_usage"
+
+	// rollupChunk is the span of raw rows aggregated per transaction, so that a
+	// first run over a large table makes progress in steps
+	rollupChunk = 24 * time.Hour
+)
+
+// UsageRollupOptions configures a UsageRollup. Zero values fall back to defaults.
+type UsageRollupOptions struct {
+	Interval      time.Duration
+	Delay         time.Duration // how long an hour stays open for late rows before it is compacted
+	RetentionDays int           // raw rows older than this are deleted once compacted, 0 keeps them
+}
+
+// RollupSummary reports the outcome of a compaction run
+type RollupSummary struct {
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	HourlyRows int           `json:"hourly_rows"`
+	DailyRows  int           `json:"daily_rows"`
+	PrunedRows int64         `json:"pruned_rows"`
+	Watermark  time.Time     `json:"watermark"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// UsageRollup aggregates raw tool usage into hourly and daily per-tool rollups
+// and prunes raw rows past the retention period. Compaction resumes from a
+// watermark and recomputes whole hours, so re-running it is harmless.
+type UsageRollup struct {
+	db   *gorm.DB
+	opts UsageRollupOptions
+	mu   sync.Mutex
+}
+
+func NewUsageRollup(opts UsageRollupOptions) *UsageRollup {
+	if opts.Delay < 0 {
+		opts.Delay = 0
+	}
+	return &UsageRollup{
+		db:   database.GetDB(),
+		opts: opts,
+	}
+}
+
+// Start compacts once and then every interval until the context is cancelled.
+// It does nothing when the interval is not positive.
+func (r *UsageRollup) Start(ctx context.Context) {
+	if r.opts.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			summary, err := r.Compact(time.Time{})
+			if err != nil {
+				logging.Errorf("Usage rollup failed: %v", err)
+			} else if summary.HourlyRows > 0 || summary.PrunedRows > 0 {
+				logging.Infof("Rolled up usage until %s: %d hourly and %d daily rows, pruned %d raw rows",
+					summary.Watermark.Format(time.RFC3339), summary.HourlyRows, summary.DailyRows, summary.PrunedRows)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Compact aggregates raw usage from the watermark, or from since when that is
+// earlier, up to the last complete hour and advances the watermark. Days whose
+// raw rows were already pruned keep their rollup counts.
+func (r *UsageRollup) Compact(since time.Time) (*RollupSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	started := time.Now()
+	end := truncateHourUTC(started.Add(-r.opts.Delay))
+
+	watermark, err := usageWatermark(r.db)
+	if err != nil {
+		return nil, err
+	}
+
+	oldest, err := r.oldestUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	start := watermark
+	if !since.IsZero() && (start.IsZero() || since.Before(start)) {
+		// Days whose raw rows were pruned cannot be recomputed
+		start = maxTime(truncateHourUTC(since), truncateToBucket(oldest, BucketDay))
+	}
+	if start.IsZero() {
+		start = oldest
+		if start.IsZero() {
+			start = end
+		}
+	}
+
+	summary := &RollupSummary{From: start, To: end, Watermark: watermark}
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(rollupChunk) {
+		chunkEnd := chunkStart.Add(rollupChunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		if err := r.compactRange(chunkStart, chunkEnd, summary); err != nil {
+			return nil, err
+		}
+	}
+	if summary.Watermark.Before(end) {
+		if err := setUsageWatermark(r.db, end); err != nil {
+			return nil, err
+		}
+		summary.Watermark = end
+	}
+
+	if r.opts.RetentionDays > 0 {
+		// Prune whole days only, so that the unique visitors of a day can
+		// still be recomputed from its raw rows
+		cutoff := minTime(truncateHourUTC(started.AddDate(0, 0, -r.opts.RetentionDays)), summary.Watermark)
+		cutoff = truncateToBucket(cutoff, BucketDay)
+		result := r.db.Where("created_at < ?", timeArg(r.db, cutoff)).Delete(&models.ToolUsage{})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		summary.PrunedRows = result.RowsAffected
+
+		// Search events have no rollup and follow the same retention
+		if err := r.db.Where("created_at < ?", timeArg(r.db, cutoff)).Delete(&models.SearchEvent{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	summary.Duration = time.Since(started)
+	return summary, nil
+}
+
+// compactRange recomputes the hourly rollup of [from, to) and the daily usage
+// and unique visitor rollups of the days it touches, then moves the watermark
+// forward to to
+func (r *UsageRollup) compactRange(from, to time.Time, summary *RollupSummary) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var hours []struct {
+			ToolID uint
+			Hour   int64
+			Count  int64
+		}
+		if err := tx.Model(&models.ToolUsage{}).
+			Select("tool_id, "+epochExpr(tx, "created_at")+" / 3600 AS hour, COUNT(*) AS count").
+			Where("created_at >= ? AND created_at < ?", timeArg(tx, from), timeArg(tx, to)).
+			Group("tool_id, hour").
+			Scan(&hours).Error; err != nil {
+			return err
+		}
+
+		hourly := make([]models.ToolUsageHourly, 0, len(hours))
+		for _, row := range hours {
+			hourly = append(hourly, models.ToolUsageHourly{
+				ToolID: row.ToolID,
+				Hour:   time.Unix(row.Hour*3600, 0).UTC(),
+				Count:  row.Count,
+			})
+		}
+		if len(hourly) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "tool_id"}, {Name: "hour"}},
+				DoUpdates: clause.AssignmentColumns([]string{"count"}),
+			}).CreateInBatches(hourly, 500).Error; err != nil {
+				return err
+			}
+		}
+		summary.HourlyRows += len(hourly)
+
+		dayStart := truncateToBucket(from, BucketDay)
+		dayEnd := nextBucket(truncateToBucket(to.Add(-time.Nanosecond), BucketDay), BucketDay)
+
+		var days []struct {
+			ToolID uint
+			Day    int64
+			Count  int64
+		}
+		if err := tx.Model(&models.ToolUsageHourly{}).
+			Select("tool_id, "+epochExpr(tx, "hour")+" / 86400 AS day, SUM(count) AS count").
+			Where("hour >= ? AND hour < ?", dayStart, dayEnd).
+			Group("tool_id, day").
+			Scan(&days).Error; err != nil {
+			return err
+		}
+
+		// Visitors can only be counted from the raw rows, and only up to the
+		// end of the range so that a partial day matches its usage count
+		visitorsTo := minTime(dayEnd, to)
+		var toolVisitors []struct {
+			ToolID   uint
+			Day      int64
+			Visitors int64
+		}
+		if err := tx.Model(&models.ToolUsage{}).
+			Select("tool_id, "+epochExpr(tx, "created_at")+" / 86400 AS day, COUNT(DISTINCT visitor_hash) AS visitors").
+			Where("created_at >= ? AND created_at < ?", timeArg(tx, dayStart), timeArg(tx, visitorsTo)).
+			Group("tool_id, day").
+			Scan(&toolVisitors).Error; err != nil {
+			return err
+		}
+		visitors := make(map[[2]int64]int64, len(toolVisitors))
+		for _, row := range toolVisitors {
+			visitors[[2]int64{int64(row.ToolID), row.Day}] = row.Visitors
+		}
+
+		daily := make([]models.ToolUsageDaily, 0, len(days))
+		for _, row := range days {
+			daily = append(daily, models.ToolUsageDaily{
+				ToolID:   row.ToolID,
+				Day:      time.Unix(row.Day*86400, 0).UTC(),
+				Count:    row.Count,
+				Visitors: visitors[[2]int64{int64(row.ToolID), row.Day}],
+			})
+		}
+		if len(daily) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "tool_id"}, {Name: "day"}},
+				DoUpdates: clause.AssignmentColumns([]string{"count", "visitors"}),
+			}).CreateInBatches(daily, 500).Error; err != nil {
+				return err
+			}
+		}
+		summary.DailyRows += len(daily)
+
+		var siteVisitors []struct {
+			Day      int64
+			Visitors int64
+		}
+		if err := tx.Model(&models.ToolUsage{}).
+			Select(epochExpr(tx, "created_at")+" / 86400 AS day, COUNT(DISTINCT visitor_hash) AS visitors").
+			Where("created_at >= ? AND created_at < ?", timeArg(tx, dayStart), timeArg(tx, visitorsTo)).
+			Group("day").
+			Scan(&siteVisitors).Error; err != nil {
+			return err
+		}
+		if len(siteVisitors) > 0 {
+			rows := make([]models.VisitorsDaily, 0, len(siteVisitors))
+			for _, row := range siteVisitors {
+				rows = append(rows, models.VisitorsDaily{Day: time.Unix(row.Day*86400, 0).UTC(), Visitors: row.Visitors})
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "day"}},
+				DoUpdates: clause.AssignmentColumns([]string{"visitors"}),
+			}).Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+
+		if to.After(summary.Watermark) {
+			if err := setUsageWatermark(tx, to); err != nil {
+				return err
+			}
+			summary.Watermark = to
+		}
+		return nil
+	})
+}
+
+// oldestUsage returns the start of the hour of the oldest raw usage row, zero if there is none
+func (r *UsageRollup) oldestUsage() (time.Time, error) {
+	var oldest *int64
+	if err := r.db.Model(&models.ToolUsage{}).
+		Select("MIN(" + epochExpr(r.db, "created_at") + ")").
+		Scan(&oldest).Error; err != nil {
+		return time.Time{}, err
+	}
+	if oldest == nil {
+		return time.Time{}, nil
+	}
+	return truncateHourUTC(time.Unix(*oldest, 0)), nil
+}
+
+// usageWatermark returns the time up to which raw usage has been rolled up, zero if never
+func usageWatermark(db *gorm.DB) (time.Time, error) {
+	var state models.RollupWatermark
+	if err := db.Where("name = ?", usageRollupName).Limit(1).Find(&state).Error; err != nil {
+		return time.Time{}, err
+	}
+	if state.Watermark.IsZero() {
+		return time.Time{}, nil
+	}
+	return state.Watermark.UTC(), nil
+}
+
+// setUsageWatermark stores the usage rollup watermark
+func setUsageWatermark(db *gorm.DB, watermark time.Time) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"watermark", "updated_at"}),
+	}).Create(&models.RollupWatermark{Name: usageRollupName, Watermark: watermark.UTC()}).Error
+}
+
+// truncateHourUTC returns the start of the UTC hour containing t
+func truncateHourUTC(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
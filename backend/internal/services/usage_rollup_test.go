@@ -0,0 +1,225 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// seedUsage writes one raw usage row per visitor at the given time, in local
+// time like the rows the ingestor writes
+func seedUsage(t *testing.T, db *gorm.DB, toolID uint, at time.Time, visitors ...string) {
+	t.Helper()
+	for _, visitor := range visitors {
+		usage := models.ToolUsage{ToolID: toolID, VisitorHash: visitor, CreatedAt: at.Local()}
+		if err := db.Create(&usage).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func hourlyCount(t *testing.T, db *gorm.DB, toolID uint, hour time.Time) int64 {
+	t.Helper()
+	var row models.ToolUsageHourly
+	if err := db.Where("tool_id = ? AND hour = ?", toolID, hour.UTC()).Limit(1).Find(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row.Count
+}
+
+func dailyRollup(t *testing.T, db *gorm.DB, toolID uint, day time.Time) models.ToolUsageDaily {
+	t.Helper()
+	var row models.ToolUsageDaily
+	if err := db.Where("tool_id = ? AND day = ?", toolID, day.UTC()).Limit(1).Find(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	return row
+}
+
+func TestUsageRollupCompact(t *testing.T) {
+	db := newTestDB(t)
+	tool := models.Tool{Name: "Calculator", URL: "/tools/calculator", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	end := truncateHourUTC(time.Now())
+	day := truncateToBucket(end, BucketDay).AddDate(0, 0, -3)
+	seedUsage(t, db, tool.ID, day.Add(10*time.Hour+15*time.Minute), "a", "b")
+	seedUsage(t, db, tool.ID, day.Add(11*time.Hour+30*time.Minute), "a")
+	// The current hour is still open and left for the next run
+	seedUsage(t, db, tool.ID, end, "c")
+
+	rollup := NewUsageRollup(UsageRollupOptions{})
+	summary, err := rollup.Compact(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.Watermark.Equal(end) {
+		t.Errorf("watermark %s, want %s", summary.Watermark, end)
+	}
+	if summary.HourlyRows != 2 || summary.DailyRows != 1 {
+		t.Errorf("wrote %d hourly and %d daily rows, want 2 and 1", summary.HourlyRows, summary.DailyRows)
+	}
+	if got := hourlyCount(t, db, tool.ID, day.Add(10*time.Hour)); got != 2 {
+		t.Errorf("10:00 count %d, want 2", got)
+	}
+	if got := hourlyCount(t, db, tool.ID, day.Add(11*time.Hour)); got != 1 {
+		t.Errorf("11:00 count %d, want 1", got)
+	}
+	if got := hourlyCount(t, db, tool.ID, end); got != 0 {
+		t.Errorf("open hour count %d, want 0", got)
+	}
+	if daily := dailyRollup(t, db, tool.ID, day); daily.Count != 3 || daily.Visitors != 2 {
+		t.Errorf("daily count %d, visitors %d, want 3 and 2", daily.Count, daily.Visitors)
+	}
+	var site models.VisitorsDaily
+	db.Where("day = ?", day).Limit(1).Find(&site)
+	if site.Visitors != 2 {
+		t.Errorf("site visitors %d, want 2", site.Visitors)
+	}
+
+	// A second run starts at the watermark and rolls up nothing new
+	summary, err = rollup.Compact(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.HourlyRows != 0 || !summary.Watermark.Equal(end) {
+		t.Errorf("re-run wrote %d hourly rows up to %s, want 0 up to %s", summary.HourlyRows, summary.Watermark, end)
+	}
+	if daily := dailyRollup(t, db, tool.ID, day); daily.Count != 3 {
+		t.Errorf("re-run changed the daily count to %d", daily.Count)
+	}
+
+	// A late row behind the watermark is only picked up when recomputing since its day
+	seedUsage(t, db, tool.ID, day.Add(10*time.Hour+45*time.Minute), "d")
+	if _, err := rollup.Compact(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := hourlyCount(t, db, tool.ID, day.Add(10*time.Hour)); got != 2 {
+		t.Errorf("late row rolled up past the watermark: 10:00 count %d", got)
+	}
+	if _, err := rollup.Compact(day); err != nil {
+		t.Fatal(err)
+	}
+	if got := hourlyCount(t, db, tool.ID, day.Add(10*time.Hour)); got != 3 {
+		t.Errorf("recomputed 10:00 count %d, want 3", got)
+	}
+	if daily := dailyRollup(t, db, tool.ID, day); daily.Count != 4 || daily.Visitors != 3 {
+		t.Errorf("recomputed daily count %d, visitors %d, want 4 and 3", daily.Count, daily.Visitors)
+	}
+	if watermark, _ := usageWatermark(db); !watermark.Equal(end) {
+		t.Errorf("recompute moved the watermark to %s", watermark)
+	}
+}
+
+func TestUsageRollupRetention(t *testing.T) {
+	db := newTestDB(t)
+	tool := models.Tool{Name: "Calculator", URL: "/tools/calculator", IsActive: true}
+	if err := db.Create(&tool).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	today := truncateToBucket(time.Now(), BucketDay)
+	old := today.AddDate(0, 0, -5)
+	recent := today.AddDate(0, 0, -1)
+	seedUsage(t, db, tool.ID, old.Add(9*time.Hour), "a", "b")
+	seedUsage(t, db, tool.ID, recent.Add(time.Hour), "a")
+	events := []models.SearchEvent{
+		{Query: "calc", Source: models.SearchSourceAPI, CreatedAt: old.Add(9 * time.Hour).Local()},
+		{Query: "calc", Source: models.SearchSourceAPI, CreatedAt: recent.Add(time.Hour).Local()},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rollup := NewUsageRollup(UsageRollupOptions{RetentionDays: 3})
+	summary, err := rollup.Compact(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.PrunedRows != 2 {
+		t.Errorf("pruned %d raw rows, want 2", summary.PrunedRows)
+	}
+	var raw, searches int64
+	db.Model(&models.ToolUsage{}).Count(&raw)
+	db.Model(&models.SearchEvent{}).Count(&searches)
+	if raw != 1 || searches != 1 {
+		t.Errorf("kept %d usage rows and %d search events, want 1 and 1", raw, searches)
+	}
+
+	// Recomputing over the pruned day keeps its rollup
+	if _, err := rollup.Compact(old); err != nil {
+		t.Fatal(err)
+	}
+	if daily := dailyRollup(t, db, tool.ID, old); daily.Count != 2 || daily.Visitors != 2 {
+		t.Errorf("pruned day count %d, visitors %d, want 2 and 2", daily.Count, daily.Visitors)
+	}
+	if daily := dailyRollup(t, db, tool.ID, recent); daily.Count != 1 {
+		t.Errorf("recent day count %d, want 1", daily.Count)
+	}
+}
+
+func TestGetUsageSeriesAcrossTheWatermark(t *testing.T) {
+	db := newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "calculator")[0]
+
+	end := truncateHourUTC(time.Now())
+	day := truncateToBucket(end, BucketDay).AddDate(0, 0, -2)
+	recordUsageAt(t, tool.ID,
+		day.Add(10*time.Hour+15*time.Minute),
+		day.Add(10*time.Hour+20*time.Minute),
+		day.Add(47*time.Hour+30*time.Minute),
+		end,
+	)
+	rollup := NewUsageRollup(UsageRollupOptions{})
+	if _, err := rollup.Compact(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before the watermark only the rollups count: a pruned row is still
+	// included and a late row is not until it is rolled up
+	pruned := day.Add(24 * time.Hour)
+	if err := db.Where("created_at >= ? AND created_at < ?", timeArg(db, pruned), timeArg(db, end)).
+		Delete(&models.ToolUsage{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	recordUsageAt(t, tool.ID, day.Add(10*time.Hour+45*time.Minute))
+
+	series := func(bucket string, from, to time.Time) string {
+		t.Helper()
+		series, err := NewStatsService().GetUsageSeries(UsageSeriesOptions{Bucket: bucket, From: from, To: to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return formatPoints(series.Total, time.UTC)
+	}
+	dayFormat := func(offset int, count int) string {
+		return fmt.Sprintf("%s=%d", day.AddDate(0, 0, offset).Format("01-02T15:04"), count)
+	}
+
+	// Daily rollups, then hourly rollups and raw rows from the watermark on
+	want := dayFormat(0, 2) + " " + dayFormat(1, 1) + " " + dayFormat(2, 1)
+	if got := series(BucketDay, day, end.Add(time.Minute)); got != want {
+		t.Errorf("days %s, want %s", got, want)
+	}
+	hour := day.Add(10 * time.Hour)
+	want = fmt.Sprintf("%s=2 %s=0", hour.Format("01-02T15:04"), hour.Add(time.Hour).Format("01-02T15:04"))
+	if got := series(BucketHour, hour, hour.Add(2*time.Hour)); got != want {
+		t.Errorf("hours %s, want %s", got, want)
+	}
+	if got := series(BucketHour, end, end.Add(time.Hour)); got != end.Format("01-02T15:04")+"=1" {
+		t.Errorf("open hour %s, want the raw row", got)
+	}
+
+	if _, err := rollup.Compact(day); err != nil {
+		t.Fatal(err)
+	}
+	want = dayFormat(0, 3) + " " + dayFormat(1, 1) + " " + dayFormat(2, 1)
+	if got := series(BucketDay, day, end.Add(time.Minute)); got != want {
+		t.Errorf("days after recomputing %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"strconv"
+	"time"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Usage time series bucket sizes
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+const (
+	// maxUsageBuckets limits the number of points in a usage time series
+	maxUsageBuckets = 2000
+
+	// usageSlotSeconds is the granularity raw usage is grouped at in the database.
+	// Every timezone offset in use is a multiple of 15 minutes, so a slot always
+	// falls entirely within one local hour, day or week.
+	usageSlotSeconds = 15 * 60
+)
+
+// UsageSeriesOptions selects the tools, range, bucket size and timezone of a usage time series
+type UsageSeriesOptions struct {
+	ToolIDs  []uint // all tools with usage in the range when empty
+	Bucket   string // hour, day or week; weeks start on Monday
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+}
+
+// UsagePoint is the usage count of one bucket, starting at Time
+type UsagePoint struct {
+	Time  time.Time `json:"time"`
+	Count int64     `json:"count"`
+}
+
+// ToolUsageSeries is the usage of a single tool over time
+type ToolUsageSeries struct {
+	ToolID   uint         `json:"tool_id"`
+	ToolName string       `json:"tool_name"`
+	Total    int64        `json:"total"`
+	Points   []UsagePoint `json:"points"`
+}
+
+// UsageSeries is a zero-filled usage time series per tool plus the sum over all of them
+type UsageSeries struct {
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Total    []UsagePoint      `json:"total"`
+	Tools    []ToolUsageSeries `json:"tools"`
+}
+
+// GetUsageSeries counts tool usage per bucket. The range is widened to whole
+// buckets in the requested timezone, and buckets without usage are reported as
+// zero. Hour buckets follow the wall clock, so days with a DST change have 23
+// or 25 of them. Rolled up hours are attributed to the bucket their start falls
+// in, which is only approximate for timezones with a half-hour offset.
+func (s *StatsService) GetUsageSeries(opts UsageSeriesOptions) (*UsageSeries, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	switch opts.Bucket {
+	case BucketHour, BucketDay, BucketWeek:
+	default:
+		return nil, newValidationError("bucket", "unsupported bucket %q, expected hour, day or week", opts.Bucket)
+	}
+	if !opts.From.Before(opts.To) {
+		return nil, newValidationError("from", "must be before to")
+	}
+
+	from := truncateToBucket(opts.From.In(opts.Location), opts.Bucket)
+	to := truncateToBucket(opts.To.In(opts.Location), opts.Bucket)
+	if to.Before(opts.To) {
+		to = nextBucket(to, opts.Bucket)
+	}
+
+	var buckets []time.Time
+	index := make(map[int64]int)
+	for t := from; t.Before(to); t = nextBucket(t, opts.Bucket) {
+		if len(buckets) == maxUsageBuckets {
+			return nil, newValidationError("to", "range spans more than %d %s buckets", maxUsageBuckets, opts.Bucket)
+		}
+		index[t.Unix()] = len(buckets)
+		buckets = append(buckets, t)
+	}
+
+	// Day rollups line up with UTC buckets only
+	daily := opts.Location == time.UTC && opts.Bucket != BucketHour
+	counts, err := s.usageCounts(from, to, opts.ToolIDs, daily)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &UsageSeries{
+		Bucket:   opts.Bucket,
+		Timezone: opts.Location.String(),
+		From:     from,
+		To:       to,
+		Total:    zeroPoints(buckets),
+		Tools:    []ToolUsageSeries{},
+	}
+
+	byTool := make(map[uint]*ToolUsageSeries)
+	toolIDs := append([]uint{}, opts.ToolIDs...)
+	for _, id := range uniqueIDs(toolIDs) {
+		byTool[id] = &ToolUsageSeries{ToolID: id, Points: zeroPoints(buckets)}
+	}
+	for _, row := range counts {
+		start := time.Unix(row.Start, 0).In(opts.Location)
+		i, ok := index[truncateToBucket(start, opts.Bucket).Unix()]
+		if !ok {
+			continue
+		}
+		tool, ok := byTool[row.ToolID]
+		if !ok {
+			tool = &ToolUsageSeries{ToolID: row.ToolID, Points: zeroPoints(buckets)}
+			byTool[row.ToolID] = tool
+			toolIDs = append(toolIDs, row.ToolID)
+		}
+		tool.Points[i].Count += row.Count
+		tool.Total += row.Count
+		series.Total[i].Count += row.Count
+	}
+
+	toolIDs = uniqueIDs(toolIDs)
+	if len(toolIDs) > 0 {
+		var tools []models.Tool
+		if err := s.db.Unscoped().Select("id, name").Where("id IN ?", toolIDs).Find(&tools).Error; err != nil {
+			return nil, err
+		}
+		for _, tool := range tools {
+			byTool[tool.ID].ToolName = tool.Name
+		}
+	}
+	for _, id := range sortedIDs(toolIDs) {
+		series.Tools = append(series.Tools, *byTool[id])
+	}
+
+	return series, nil
+}
+
+// usageCount is the usage of a tool in the time slot starting at Start, in Unix seconds
+type usageCount struct {
+	ToolID uint
+	Start  int64
+	Count  int64
+}
+
+// usageCounts gets the usage in [from, to) per tool and time slot: UTC hours,
+// or UTC days when daily is set, from the rollups before the watermark and 15
+// minute slots from the raw rows after it
+func (s *StatsService) usageCounts(from, to time.Time, toolIDs []uint, daily bool) ([]usageCount, error) {
+	watermark, err := usageWatermark(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []usageCount
+	scan := func(query *gorm.DB) error {
+		if len(toolIDs) > 0 {
+			query = query.Where("tool_id IN ?", toolIDs)
+		}
+		var rows []usageCount
+		if err := query.Scan(&rows).Error; err != nil {
+			return err
+		}
+		counts = append(counts, rows...)
+		return nil
+	}
+
+	hourlyFrom := from
+	if daily {
+		dailyTo := minTime(to, truncateToBucket(watermark, BucketDay))
+		if from.Before(dailyTo) {
+			if err := scan(s.db.Model(&models.ToolUsageDaily{}).
+				Select("tool_id, "+epochExpr(s.db, "day")+" AS start, count").
+				Where("day >= ? AND day < ?", from.UTC(), dailyTo.UTC())); err != nil {
+				return nil, err
+			}
+			hourlyFrom = dailyTo
+		}
+	}
+
+	if hourlyTo := minTime(to, watermark); hourlyFrom.Before(hourlyTo) {
+		if err := scan(s.db.Model(&models.ToolUsageHourly{}).
+			Select("tool_id, "+epochExpr(s.db, "hour")+" AS start, count").
+			Where("hour >= ? AND hour < ?", hourlyFrom.UTC(), hourlyTo.UTC())); err != nil {
+			return nil, err
+		}
+	}
+
+	if rawFrom := maxTime(from, watermark); rawFrom.Before(to) {
+		slot := strconv.Itoa(usageSlotSeconds)
+		if err := scan(s.db.Model(&models.ToolUsage{}).
+			Select("tool_id, "+epochExpr(s.db, "created_at")+" / "+slot+" * "+slot+" AS start, COUNT(*) AS count").
+			Where("created_at >= ? AND created_at < ?", timeArg(s.db, rawFrom), timeArg(s.db, to)).
+			Group("tool_id, start")); err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+// countUsage counts the usage since from, of a single tool unless toolID is 0.
+// A zero from counts all usage. Rolled up usage is counted in whole hours.
+func (s *StatsService) countUsage(from time.Time, toolID uint) (int64, error) {
+	watermark, err := usageWatermark(s.db)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	sum := func(query *gorm.DB) error {
+		if toolID != 0 {
+			query = query.Where("tool_id = ?", toolID)
+		}
+		var count int64
+		if err := query.Scan(&count).Error; err != nil {
+			return err
+		}
+		total += count
+		return nil
+	}
+
+	if from.IsZero() {
+		if err := sum(s.db.Model(&models.ToolUsageDaily{}).Select("COALESCE(SUM(count), 0)")); err != nil {
+			return 0, err
+		}
+	} else if from.Before(watermark) {
+		if err := sum(s.db.Model(&models.ToolUsageHourly{}).Select("COALESCE(SUM(count), 0)").
+			Where("hour >= ?", from.UTC())); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := sum(s.db.Model(&models.ToolUsage{}).Select("COUNT(*)").
+		Where("created_at >= ?", timeArg(s.db, maxTime(from, watermark)))); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// epochExpr converts a timestamp column to Unix seconds in the current dialect
+func epochExpr(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == "postgres" {
+		return "CAST(EXTRACT(EPOCH FROM " + column + ") AS BIGINT)"
+	}
+	return "CAST(strftime('%s', " + column + ") AS INTEGER)"
+}
+
+// timeArg prepares a time for comparison with a raw usage timestamp. SQLite
+// compares timestamps as text, so the value must use the same offset as the
+// stored rows, which are written in the server's local time.
+func timeArg(db *gorm.DB, t time.Time) time.Time {
+	if db.Dialector.Name() == "postgres" {
+		return t
+	}
+	return t.In(time.Local)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// truncateToBucket returns the start of the bucket containing t, in t's location
+func truncateToBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketHour:
+		// Subtract the wall clock minutes rather than truncating the absolute
+		// time, which would be off for zones with half-hour offsets
+		return t.Add(-time.Duration(t.Minute())*time.Minute -
+			time.Duration(t.Second())*time.Second -
+			time.Duration(t.Nanosecond()))
+	case BucketWeek:
+		day := truncateToBucket(t, BucketDay)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// nextBucket returns the start of the bucket after the one starting at t
+func nextBucket(t time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketHour:
+		return t.Add(time.Hour)
+	case BucketWeek:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// startOfDay returns midnight of the day containing t in loc
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	return truncateToBucket(t.In(loc), BucketDay)
+}
+
+// zeroPoints creates a point with a zero count for every bucket
+func zeroPoints(buckets []time.Time) []UsagePoint {
+	points := make([]UsagePoint, len(buckets))
+	for i, t := range buckets {
+		points[i] = UsagePoint{Time: t}
+	}
+	return points
+}
+
+// uniqueIDs returns ids without duplicates, keeping their order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	_ "time/tzdata"
+	"tion.work/backend/internal/models"
+)
+
+// mustLoadLocation loads an IANA timezone
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// recordUsageAt stores one usage row per time, in the server's local time like
+// rows written by RecordToolUsage
+func recordUsageAt(t *testing.T, toolID uint, times ...time.Time) {
+	t.Helper()
+	for _, at := range times {
+		usage := models.ToolUsage{ToolID: toolID, CreatedAt: at.In(time.Local)}
+		if err := NewStatsService().db.Create(&usage).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// formatPoints formats the buckets of a series as "start=count" in loc
+func formatPoints(points []UsagePoint, loc *time.Location) string {
+	formatted := ""
+	for i, point := range points {
+		if i > 0 {
+			formatted += " "
+		}
+		formatted += fmt.Sprintf("%s=%d", point.Time.In(loc).Format("01-02T15:04"), point.Count)
+	}
+	return formatted
+}
+
+func TestGetUsageSeriesBuckets(t *testing.T) {
+	newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "calculator")[0]
+	utc := time.UTC
+	newYork := mustLoadLocation(t, "America/New_York")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+
+	recordUsageAt(t, tool.ID,
+		time.Date(2026, 3, 2, 9, 0, 0, 0, utc),
+		time.Date(2026, 3, 2, 23, 59, 0, 0, utc),
+		time.Date(2026, 3, 4, 0, 0, 0, 0, utc),
+		// Spring forward in New York: 01:59 EST is followed by 03:00 EDT
+		time.Date(2026, 3, 8, 1, 30, 0, 0, newYork),
+		time.Date(2026, 3, 8, 3, 30, 0, 0, newYork),
+		// Fall back: 01:30 happens twice
+		time.Date(2026, 11, 1, 5, 30, 0, 0, utc),
+		time.Date(2026, 11, 1, 6, 30, 0, 0, utc),
+		// 15:50 and the next day 00:20 in India
+		time.Date(2026, 5, 4, 10, 20, 0, 0, utc),
+		time.Date(2026, 5, 4, 18, 50, 0, 0, utc),
+	)
+
+	tests := []struct {
+		name     string
+		bucket   string
+		from, to time.Time
+		loc      *time.Location
+		want     string
+	}{
+		{
+			"zero filled days", BucketDay,
+			time.Date(2026, 3, 1, 0, 0, 0, 0, utc), time.Date(2026, 3, 5, 0, 0, 0, 0, utc), utc,
+			"03-01T00:00=0 03-02T00:00=2 03-03T00:00=0 03-04T00:00=1",
+		},
+		{
+			"range widened to whole buckets", BucketDay,
+			time.Date(2026, 3, 2, 12, 0, 0, 0, utc), time.Date(2026, 3, 3, 0, 0, 1, 0, utc), utc,
+			"03-02T00:00=2 03-03T00:00=0",
+		},
+		{
+			"days in a negative offset", BucketDay,
+			time.Date(2026, 3, 1, 0, 0, 0, 0, newYork), time.Date(2026, 3, 4, 0, 0, 0, 0, newYork), newYork,
+			"03-01T00:00=0 03-02T00:00=2 03-03T00:00=1",
+		},
+		{
+			"weeks start on monday", BucketWeek,
+			time.Date(2026, 3, 4, 0, 0, 0, 0, utc), time.Date(2026, 3, 10, 0, 0, 0, 0, utc), utc,
+			"03-02T00:00=5 03-09T00:00=0",
+		},
+		{
+			"spring forward has 23 hours", BucketHour,
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 8, 5, 0, 0, 0, newYork), newYork,
+			"03-08T00:00=0 03-08T01:00=1 03-08T03:00=1 03-08T04:00=0",
+		},
+		{
+			"spring forward day", BucketDay,
+			time.Date(2026, 3, 8, 0, 0, 0, 0, newYork), time.Date(2026, 3, 9, 0, 0, 0, 0, newYork), newYork,
+			"03-08T00:00=2",
+		},
+		{
+			"fall back repeats an hour", BucketHour,
+			time.Date(2026, 11, 1, 0, 0, 0, 0, newYork), time.Date(2026, 11, 1, 3, 0, 0, 0, newYork), newYork,
+			"11-01T00:00=0 11-01T01:00=1 11-01T01:00=1 11-01T02:00=0",
+		},
+		{
+			"half hour offset hours", BucketHour,
+			time.Date(2026, 5, 4, 15, 0, 0, 0, kolkata), time.Date(2026, 5, 4, 17, 0, 0, 0, kolkata), kolkata,
+			"05-04T15:00=1 05-04T16:00=0",
+		},
+		{
+			"half hour offset days", BucketDay,
+			time.Date(2026, 5, 4, 0, 0, 0, 0, kolkata), time.Date(2026, 5, 6, 0, 0, 0, 0, kolkata), kolkata,
+			"05-04T00:00=1 05-05T00:00=1",
+		},
+		{
+			"the same days in utc", BucketDay,
+			time.Date(2026, 5, 4, 0, 0, 0, 0, utc), time.Date(2026, 5, 5, 0, 0, 0, 0, utc), utc,
+			"05-04T00:00=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series, err := NewStatsService().GetUsageSeries(UsageSeriesOptions{
+				Bucket: tt.bucket, From: tt.from, To: tt.to, Location: tt.loc,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := formatPoints(series.Total, tt.loc); got != tt.want {
+				t.Errorf("total %s\nwant  %s", got, tt.want)
+			}
+			if series.Timezone != tt.loc.String() {
+				t.Errorf("timezone %s, want %s", series.Timezone, tt.loc)
+			}
+		})
+	}
+}
+
+func TestGetUsageSeriesTools(t *testing.T) {
+	newTestDB(t)
+	tools := createTestTools(t, NewToolService(), "calculator", "timer", "unused")
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	recordUsageAt(t, tools[1].ID, day.Add(time.Hour), day.Add(2*time.Hour))
+	recordUsageAt(t, tools[0].ID, day.Add(25*time.Hour))
+
+	stats := NewStatsService()
+	opts := UsageSeriesOptions{Bucket: BucketDay, From: day, To: day.AddDate(0, 0, 2)}
+	series, err := stats.GetUsageSeries(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tool := range series.Tools {
+		got = append(got, fmt.Sprintf("%s:%d [%s]", tool.ToolName, tool.Total, formatPoints(tool.Points, time.UTC)))
+	}
+	want := []string{
+		"calculator:1 [03-02T00:00=0 03-03T00:00=1]",
+		"timer:2 [03-02T00:00=2 03-03T00:00=0]",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("tools %v, want %v", got, want)
+	}
+
+	// Requested tools are reported even without usage
+	opts.ToolIDs = []uint{tools[2].ID, tools[1].ID, tools[2].ID}
+	series, err = stats.GetUsageSeries(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, tool := range series.Tools {
+		got = append(got, fmt.Sprintf("%s:%d", tool.ToolName, tool.Total))
+	}
+	if fmt.Sprint(got) != "[timer:2 unused:0]" {
+		t.Errorf("requested tools %v, want [timer:2 unused:0]", got)
+	}
+	if formatPoints(series.Total, time.UTC) != "03-02T00:00=2 03-03T00:00=0" {
+		t.Errorf("total of the requested tools %s", formatPoints(series.Total, time.UTC))
+	}
+}
+
+func TestGetUsageSeriesValidation(t *testing.T) {
+	newTestDB(t)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		opts  UsageSeriesOptions
+		field string
+	}{
+		{UsageSeriesOptions{Bucket: "month", From: from, To: from.AddDate(0, 1, 0)}, "bucket"},
+		{UsageSeriesOptions{Bucket: BucketDay, From: from, To: from}, "from"},
+		{UsageSeriesOptions{Bucket: BucketHour, From: from, To: from.AddDate(0, 0, 84)}, "to"},
+	}
+	for _, tt := range tests {
+		if _, err := NewStatsService().GetUsageSeries(tt.opts); !isValidationError(err, tt.field) {
+			t.Errorf("%+v = %v, want a %s validation error", tt.opts, err, tt.field)
+		}
+	}
+}
+
+// SQLite compares timestamps as text, so bounds must be converted to the
+// offset the rows were stored with
+func TestGetUsageSeriesLocalTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+9", 9*60*60)
+	t.Cleanup(func() { time.Local = local })
+
+	newTestDB(t)
+	tool := createTestTools(t, NewToolService(), "calculator")[0]
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	recordUsageAt(t, tool.ID,
+		from.Add(-time.Second), // stored as 08:59:59+09:00
+		from,                   // stored as 09:00:00+09:00
+		to.Add(-time.Second),
+		to,
+	)
+
+	series, err := NewStatsService().GetUsageSeries(UsageSeriesOptions{Bucket: BucketHour, From: from, To: to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatPoints(series.Total, time.UTC); got != "03-02T00:00=1 03-02T01:00=1" {
+		t.Errorf("total %s, want one use in each hour", got)
+	}
+}
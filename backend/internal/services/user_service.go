@@ -0,0 +1,340 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"tion.work/backend/internal/database"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	// minPasswordLength is the shortest accepted password. bcrypt ignores
+	// everything after 72 bytes, so longer passwords are rejected.
+	minPasswordLength = 10
+	maxPasswordLength = 72
+
+	// sessionTouchInterval limits how often the last use of a session is written
+	sessionTouchInterval = time.Minute
+
+	auditTargetUser = "user"
+)
+
+// usernamePattern allows lower case letters, digits, dots, dashes and underscores
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{2,49}$`)
+
+// dummyPasswordHash is compared against when signing in as an unknown user,
+// so that the response time does not reveal which usernames exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("tion-dummy-password"), bcrypt.DefaultCost)
+
+type UserService struct {
+	db         *gorm.DB
+	sessionTTL time.Duration
+}
+
+// NewUserService creates a user service whose sessions last sessionTTL
+func NewUserService(sessionTTL time.Duration) *UserService {
+	return &UserService{
+		db:         database.GetDB(),
+		sessionTTL: sessionTTL,
+	}
+}
+
+// UserInput describes a user to create
+type UserInput struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// UserUpdate changes the given fields of a user
+type UserUpdate struct {
+	Email    *string `json:"email"`
+	Password *string `json:"password"`
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+// LoginResult is a new session with its token, which is not stored
+type LoginResult struct {
+	User      models.User      `json:"user"`
+	Scopes    models.ScopeList `json:"scopes"`
+	Token     string           `json:"token"`
+	ExpiresAt time.Time        `json:"expires_at"`
+}
+
+// ListUsers gets every user by username
+func (s *UserService) ListUsers() ([]models.User, error) {
+	users := make([]models.User, 0)
+	if err := s.db.Order("username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetUser gets a user by ID
+func (s *UserService) GetUser(id uint) (*models.User, error) {
+	return s.findUser(s.db, id)
+}
+
+// CreateUser validates and stores a user with a hashed password
+func (s *UserService) CreateUser(input UserInput, actor string) (*models.User, error) {
+	username := strings.ToLower(strings.TrimSpace(input.Username))
+	if !usernamePattern.MatchString(username) {
+		return nil, newValidationError("username", "must be 3 to 50 lower case letters, digits, dots, dashes or underscores")
+	}
+	if !isRole(input.Role) {
+		return nil, newValidationError("role", "unknown role %q, expected one of %s", input.Role, strings.Join(models.Roles, ", "))
+	}
+	hash, err := hashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     username,
+		Email:        strings.TrimSpace(input.Email),
+		PasswordHash: hash,
+		Role:         input.Role,
+		CreatedBy:    actor,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUsernameTaken
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditUserCreate, auditTargetUser, userTarget(user.ID), models.AuditDetails{
+			"username": user.Username,
+			"role":     user.Role,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser changes the email, password, role or disabled state of a user.
+// Changing the password or role, or disabling the user, ends their sessions.
+func (s *UserService) UpdateUser(id uint, update UserUpdate, actor string) (*models.User, error) {
+	updates := make(map[string]interface{})
+	if update.Email != nil {
+		updates["email"] = strings.TrimSpace(*update.Email)
+	}
+	if update.Password != nil {
+		hash, err := hashPassword(*update.Password)
+		if err != nil {
+			return nil, err
+		}
+		updates["password_hash"] = hash
+	}
+	if update.Role != nil {
+		if !isRole(*update.Role) {
+			return nil, newValidationError("role", "unknown role %q, expected one of %s", *update.Role, strings.Join(models.Roles, ", "))
+		}
+		updates["role"] = *update.Role
+	}
+	if update.Disabled != nil {
+		updates["disabled"] = *update.Disabled
+	}
+	if len(updates) == 0 {
+		return nil, newValidationError("update", "no fields to update")
+	}
+
+	var user *models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if user, err = s.findUser(tx, id); err != nil {
+			return err
+		}
+
+		demoted := update.Role != nil && *update.Role != models.RoleAdmin
+		disabled := update.Disabled != nil && *update.Disabled
+		if user.Role == models.RoleAdmin && !user.Disabled && (demoted || disabled) {
+			var admins int64
+			if err := tx.Model(&models.User{}).
+				Where("role = ? AND disabled = ? AND id <> ?", models.RoleAdmin, false, id).
+				Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins == 0 {
+				return ErrLastAdmin
+			}
+		}
+
+		// The password itself is never written to the audit log
+		details := models.AuditDetails{}
+		for column, value := range updates {
+			switch column {
+			case "password_hash":
+				details["password"] = "changed"
+			case "email":
+				details[column] = map[string]interface{}{"old": user.Email, "new": value}
+			case "role":
+				details[column] = map[string]interface{}{"old": user.Role, "new": value}
+			case "disabled":
+				details[column] = map[string]interface{}{"old": user.Disabled, "new": value}
+			}
+		}
+
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if update.Password != nil || update.Role != nil || disabled {
+			if err := tx.Where("user_id = ?", id).Delete(&models.Session{}).Error; err != nil {
+				return err
+			}
+		}
+		if user, err = s.findUser(tx, id); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditUserUpdate, auditTargetUser, userTarget(id), details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks a username and password and starts a session for the user
+func (s *UserService) Login(username, password, ip, userAgent string) (*LoginResult, error) {
+	var user models.User
+	err := s.db.Where("username = ?", strings.ToLower(strings.TrimSpace(username))).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil || user.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, err := utils.NewSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	now := time.Now()
+	session := &models.Session{
+		UserID:     user.ID,
+		TokenHash:  utils.HashAPIKey(token),
+		ExpiresAt:  now.Add(s.sessionTTL),
+		LastSeenAt: now,
+		IP:         ip,
+		UserAgent:  userAgent,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Sessions are only read by token, so expired ones are cleared here
+		if err := tx.Where("expires_at <= ?", now).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&user).UpdateColumn("last_login_at", now).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, UserActor(&user), models.AuditUserLogin, auditTargetUser, userTarget(user.ID), models.AuditDetails{
+			"ip": ip,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	user.LastLoginAt = &now
+	return &LoginResult{
+		User:      user,
+		Scopes:    models.RoleScopes[user.Role],
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+	}, nil
+}
+
+// Logout ends the session of a token. Unknown tokens are ignored.
+func (s *UserService) Logout(token string) error {
+	return s.db.Where("token_hash = ?", utils.HashAPIKey(token)).Delete(&models.Session{}).Error
+}
+
+// Authenticate looks up the user of a session token and records the use of
+// the session from ip
+func (s *UserService) Authenticate(token, ip string) (*models.User, error) {
+	var session models.Session
+	if err := s.db.Preload("User").Where("token_hash = ?", utils.HashAPIKey(token)).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionInvalid
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !session.ExpiresAt.After(now) || session.User.Disabled {
+		return nil, ErrSessionInvalid
+	}
+
+	if session.IP != ip || now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.db.Model(&session).UpdateColumns(map[string]interface{}{
+			"last_seen_at": now,
+			"ip":           ip,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &session.User, nil
+}
+
+// UserActor identifies a user in revisions and the audit log
+func UserActor(user *models.User) string {
+	return "user:" + user.Username
+}
+
+func (s *UserService) findUser(tx *gorm.DB, id uint) (*models.User, error) {
+	var user models.User
+	if err := tx.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func userTarget(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// hashPassword checks the length of a password and hashes it with bcrypt
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", newValidationError("password", "must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return "", newValidationError("password", "must be at most %d bytes", maxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func isRole(role string) bool {
+	_, ok := models.RoleScopes[role]
+	return ok
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+func createTestUser(t *testing.T, users *UserService, username, role string) *models.User {
+	t.Helper()
+	user, err := users.CreateUser(UserInput{Username: username, Password: "correct horse", Role: role}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestUserServiceLastAdminGuard(t *testing.T) {
+	newTestDB(t)
+	users := NewUserService(time.Hour)
+	alice := createTestUser(t, users, "alice", models.RoleAdmin)
+	bob := createTestUser(t, users, "bob", models.RoleAdmin)
+	carol := createTestUser(t, users, "carol", models.RoleAdmin)
+
+	editor, disabled := models.RoleEditor, true
+
+	// Demoting and disabling admins is fine while another one is left
+	if _, err := users.UpdateUser(bob.ID, UserUpdate{Role: &editor}, "test"); err != nil {
+		t.Fatalf("demote bob: %v", err)
+	}
+	if _, err := users.UpdateUser(carol.ID, UserUpdate{Disabled: &disabled}, "test"); err != nil {
+		t.Fatalf("disable carol: %v", err)
+	}
+
+	// A disabled admin does not count, so alice is the last one
+	if _, err := users.UpdateUser(alice.ID, UserUpdate{Role: &editor}, "test"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("demote the last admin = %v, want ErrLastAdmin", err)
+	}
+	if _, err := users.UpdateUser(alice.ID, UserUpdate{Disabled: &disabled}, "test"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("disable the last admin = %v, want ErrLastAdmin", err)
+	}
+
+	// Other changes to the last admin are allowed
+	email := "alice@tion.work"
+	if _, err := users.UpdateUser(alice.ID, UserUpdate{Email: &email}, "test"); err != nil {
+		t.Errorf("update the email of the last admin: %v", err)
+	}
+	if user, _ := users.GetUser(alice.ID); user.Role != models.RoleAdmin || user.Disabled {
+		t.Errorf("last admin is %s, disabled %v", user.Role, user.Disabled)
+	}
+}
+
+func TestUserServiceSessions(t *testing.T) {
+	newTestDB(t)
+	users := NewUserService(time.Hour)
+	user := createTestUser(t, users, "dave", models.RoleViewer)
+
+	if _, err := users.Login("dave", "wrong password", "127.0.0.1", "test"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login with a wrong password = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := users.Login("nobody", "correct horse", "127.0.0.1", "test"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login as an unknown user = %v, want ErrInvalidCredentials", err)
+	}
+
+	login, err := users.Login(" Dave ", "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.Scopes.Allows(models.ScopeStatsRead) || login.Scopes.Allows(models.ScopeToolsWrite) {
+		t.Errorf("viewer scopes %v", login.Scopes)
+	}
+	if authenticated, err := users.Authenticate(login.Token, "127.0.0.1"); err != nil || authenticated.ID != user.ID {
+		t.Fatalf("Authenticate = %v, %v, want dave", authenticated, err)
+	}
+
+	// Changing the password ends the session
+	password := "another horse"
+	if _, err := users.UpdateUser(user.ID, UserUpdate{Password: &password}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate(login.Token, "127.0.0.1"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Authenticate after a password change = %v, want ErrSessionInvalid", err)
+	}
+
+	// Disabled users cannot sign in
+	login, err = users.Login("dave", password, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := true
+	if _, err := users.UpdateUser(user.ID, UserUpdate{Disabled: &disabled}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate(login.Token, "127.0.0.1"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Authenticate a disabled user = %v, want ErrSessionInvalid", err)
+	}
+	if _, err := users.Login("dave", password, "127.0.0.1", "test"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("login as a disabled user = %v, want ErrInvalidCredentials", err)
+	}
+
+	// Expired sessions are rejected
+	createTestUser(t, users, "erin", models.RoleViewer)
+	expired, err := NewUserService(-time.Minute).Login("erin", "correct horse", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := users.Authenticate(expired.Token, "127.0.0.1"); !errors.Is(err, ErrSessionInvalid) {
+		t.Errorf("Authenticate an expired session = %v, want ErrSessionInvalid", err)
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+	"tion.work/backend/internal/models"
+	"tion.work/backend/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// visitorSalts hands out the salt of the current UTC day. Salts live in the
+// database so that every server instance hashes a visitor the same way, and
+// are deleted once their day is over.
+type visitorSalts struct {
+	db   *gorm.DB
+	mu   sync.Mutex
+	day  string
+	salt []byte
+}
+
+func newVisitorSalts(db *gorm.DB) *visitorSalts {
+	return &visitorSalts{db: db}
+}
+
+// forTime returns the salt of the UTC day containing t, creating it if needed
+func (v *visitorSalts) forTime(t time.Time) ([]byte, error) {
+	day := t.UTC().Format("2006-01-02")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.day == day {
+		return v.salt, nil
+	}
+
+	fresh, err := utils.NewVisitorSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.VisitorSalt
+	err = v.db.Transaction(func(tx *gorm.DB) error {
+		// Another instance may have created the salt first, in which case it wins
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.VisitorSalt{Day: day, Salt: hex.EncodeToString(fresh)}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("day = ?", day).First(&stored).Error; err != nil {
+			return err
+		}
+		return tx.Where("day < ?", day).Delete(&models.VisitorSalt{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := hex.DecodeString(stored.Salt)
+	if err != nil {
+		return nil, err
+	}
+	v.day, v.salt = day, salt
+	return salt, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"tion.work/backend/internal/models"
+)
+
+func TestVisitorSaltRotation(t *testing.T) {
+	db := newTestDB(t)
+	today := time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC)
+	tomorrow := today.Add(2 * time.Hour)
+
+	salts := newVisitorSalts(db)
+	first, err := salts.forTime(today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := salts.forTime(today.Add(-22 * time.Hour)); !bytes.Equal(again, first) {
+		t.Error("the salt changed within a UTC day")
+	}
+	// Another instance hashes with the stored salt rather than its own
+	if shared, _ := newVisitorSalts(db).forTime(today); !bytes.Equal(shared, first) {
+		t.Error("a second instance created its own salt for the same day")
+	}
+	// Even in a timezone where the day has already changed
+	if local, _ := salts.forTime(today.In(time.FixedZone("UTC+8", 8*60*60))); !bytes.Equal(local, first) {
+		t.Error("the salt follows local days instead of UTC days")
+	}
+
+	next, err := salts.forTime(tomorrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(next, first) {
+		t.Error("the salt was not rotated on the next day")
+	}
+	var stored []models.VisitorSalt
+	if err := db.Find(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].Day != "2026-03-03" {
+		t.Errorf("stored salts %+v, want only the one of 2026-03-03", stored)
+	}
+}
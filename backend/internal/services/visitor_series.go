@@ -0,0 +1,114 @@
+package services
+
+import (
+	"time"
+	"tion.work/backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// VisitorPoint is the number of unique visitors in the UTC day starting at Day
+type VisitorPoint struct {
+	Day      time.Time `json:"day"`
+	Visitors int64     `json:"visitors"`
+}
+
+// VisitorSeries lists unique visitors per UTC day. Visitor identities change
+// every day, so Total is the sum of the daily counts rather than the number of
+// distinct people over the whole range.
+type VisitorSeries struct {
+	ToolID uint           `json:"tool_id,omitempty"`
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Total  int64          `json:"total"`
+	Days   []VisitorPoint `json:"days"`
+}
+
+// GetVisitorSeries counts unique visitors per UTC day in [from, to), widened to
+// whole days, across all tools or of a single tool unless toolID is 0. Days up
+// to the rollup watermark are read from the daily rollups, later ones from the
+// raw rows.
+func (s *StatsService) GetVisitorSeries(from, to time.Time, toolID uint) (*VisitorSeries, error) {
+	from, end, err := utcDayRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &VisitorSeries{ToolID: toolID, From: from, To: end, Days: []VisitorPoint{}}
+	index := make(map[int64]int)
+	for day := from; day.Before(end); day = nextBucket(day, BucketDay) {
+		if len(series.Days) == maxUsageBuckets {
+			return nil, newValidationError("to", "range spans more than %d days", maxUsageBuckets)
+		}
+		index[day.Unix()] = len(series.Days)
+		series.Days = append(series.Days, VisitorPoint{Day: day})
+	}
+
+	watermark, err := usageWatermark(s.db)
+	if err != nil {
+		return nil, err
+	}
+	// The day of the watermark is still open and is counted from raw rows
+	split := minTime(end, maxTime(from, truncateToBucket(watermark, BucketDay)))
+
+	var rows []struct {
+		Day      int64
+		Visitors int64
+	}
+	collect := func(query *gorm.DB) error {
+		rows = rows[:0]
+		if err := query.Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if i, ok := index[row.Day]; ok {
+				series.Days[i].Visitors = row.Visitors
+				series.Total += row.Visitors
+			}
+		}
+		return nil
+	}
+
+	if from.Before(split) {
+		var query *gorm.DB
+		if toolID != 0 {
+			query = s.db.Model(&models.ToolUsageDaily{}).Where("tool_id = ?", toolID)
+		} else {
+			query = s.db.Model(&models.VisitorsDaily{})
+		}
+		query = query.Select(epochExpr(s.db, "day")+" AS day, visitors").
+			Where("day >= ? AND day < ?", from, split)
+		if err := collect(query); err != nil {
+			return nil, err
+		}
+	}
+
+	if split.Before(end) {
+		day := "(" + epochExpr(s.db, "created_at") + " / 86400) * 86400"
+		query := s.db.Model(&models.ToolUsage{}).
+			Select(day+" AS day, COUNT(DISTINCT visitor_hash) AS visitors").
+			Where("created_at >= ? AND created_at < ?", timeArg(s.db, split), timeArg(s.db, end)).
+			Group(day)
+		if toolID != 0 {
+			query = query.Where("tool_id = ?", toolID)
+		}
+		if err := collect(query); err != nil {
+			return nil, err
+		}
+	}
+
+	return series, nil
+}
+
+// utcDayRange widens [from, to) to whole UTC days
+func utcDayRange(from, to time.Time) (time.Time, time.Time, error) {
+	from = truncateToBucket(from.UTC(), BucketDay)
+	end := truncateToBucket(to.UTC(), BucketDay)
+	if end.Before(to) {
+		end = nextBucket(end, BucketDay)
+	}
+	if !from.Before(end) {
+		return time.Time{}, time.Time{}, newValidationError("from", "must be before to")
+	}
+	return from, end, nil
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDField is the log field holding the ID of the request being served
+const RequestIDField = "request_id"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, so that everything
+// serving the request logs with the same fields
+func NewContext(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the global logger when it
+// carries none
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*logrus.Entry); ok {
+			return logger
+		}
+	}
+	return logrus.NewEntry(Logger)
+}
+
+// WithRequestID returns a copy of ctx whose logger adds the request ID to
+// every entry
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return NewContext(ctx, FromContext(ctx).WithField(RequestIDField, id))
+}
+
+// RequestID returns the request ID of the logger carried by ctx, empty when
+// there is none
+func RequestID(ctx context.Context) string {
+	id, _ := FromContext(ctx).Data[RequestIDField].(string)
+	return id
+}
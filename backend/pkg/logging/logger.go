@@ -1,26 +1,82 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 )
 
-var Logger *logrus.Logger
+// Log formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
 
-func InitLogging() {
-	Logger = logrus.New()
+// timestampFormat is the layout of log timestamps
+const timestampFormat = "2006-01-02 15:04:05"
 
-	// Set log level
-	Logger.SetLevel(logrus.InfoLevel)
+// Logger is the global logger. It logs JSON at info level until InitLogging
+// configures it.
+var Logger = newLogger()
 
-	// Set formatter
-	Logger.SetFormatter(&logrus.JSONFormatter{
-		TimestampFormat: "2006-01-02 15:04:05",
-	})
+// Options configures the global logger
+type Options struct {
+	Level  string    // panic, fatal, error, warn, info, debug or trace; info when empty
+	Format string    // json or text; json when empty
+	Output io.Writer // stdout when nil
+}
 
-	// Set output
-	Logger.SetOutput(os.Stdout)
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	logger.SetFormatter(&redactingFormatter{formatter: jsonFormatter()})
+	logger.SetOutput(os.Stdout)
+	return logger
+}
+
+// InitLogging configures the level, format and output of the global logger.
+// Sensitive values are redacted from every message and field.
+func InitLogging(opts Options) error {
+	level := logrus.InfoLevel
+	if opts.Level != "" {
+		parsed, err := logrus.ParseLevel(opts.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+		}
+		level = parsed
+	}
+
+	var formatter logrus.Formatter
+	switch strings.ToLower(opts.Format) {
+	case FormatJSON, "":
+		formatter = jsonFormatter()
+	case FormatText:
+		formatter = &logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: timestampFormat,
+		}
+	default:
+		return fmt.Errorf("invalid log format %q, expected %s or %s", opts.Format, FormatJSON, FormatText)
+	}
+
+	output := opts.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	Logger.SetLevel(level)
+	Logger.SetFormatter(&redactingFormatter{formatter: formatter})
+	Logger.SetOutput(output)
+	return nil
+}
+
+func jsonFormatter() logrus.Formatter {
+	return &logrus.JSONFormatter{
+		TimestampFormat: timestampFormat,
+	}
 }
 
 func Infof(format string, args ...interface{}) {
@@ -39,6 +95,10 @@ func Warnf(format string, args ...interface{}) {
 	Logger.Warnf(format, args...)
 }
 
+func Fatalf(format string, args ...interface{}) {
+	Logger.Fatalf(format, args...)
+}
+
 func Info(args ...interface{}) {
 	Logger.Info(args...)
 }
@@ -0,0 +1,322 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the histogram buckets in seconds used when none are given
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the services record to and /metrics exposes
+var Default = NewRegistry()
+
+// metric is a family of series that writes itself in the text format
+type metric interface {
+	write(w io.Writer, name string)
+}
+
+// Registry holds metric families by name and writes them in the Prometheus
+// text exposition format. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]metric
+	help    map[string]string
+	kinds   map[string]string
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]metric),
+		help:    make(map[string]string),
+		kinds:   make(map[string]string),
+	}
+}
+
+// register adds a family, panicking on a duplicate name, as metrics are
+// registered once at startup
+func (r *Registry) register(name, help, kind string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.metrics[name] = m
+	r.help[name] = help
+	r.kinds[name] = kind
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{labels: labels, series: make(map[string]*counterSeries)}
+	r.register(name, help, "counter", c)
+	return c
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds and
+// label names. DefaultBuckets are used when buckets is nil.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(name, help, "histogram", h)
+	return h
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, help, "gauge", gaugeFunc(fn))
+}
+
+// WriteText writes all families, sorted by name, in the text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.RLock()
+		m, help, kind := r.metrics[name], r.help[name], r.kinds[name]
+		r.mu.RUnlock()
+
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, kind); err != nil {
+			return err
+		}
+		m.write(w, name)
+	}
+	return nil
+}
+
+// Handler serves the registry in the text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// CounterVec is a counter partitioned by label values
+type CounterVec struct {
+	mu     sync.RWMutex
+	labels []string
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// Inc adds one to the series with the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series with the given
+// label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	key := seriesKey(c.labels, values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += delta
+}
+
+// Value returns the value of the series with the given label values, zero
+// when nothing was counted
+func (c *CounterVec) Value(values ...string) float64 {
+	key := seriesKey(c.labels, values)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(c.labels, s.values), formatValue(s.value))
+	}
+}
+
+// HistogramVec is a histogram partitioned by label values
+type HistogramVec struct {
+	mu      sync.RWMutex
+	labels  []string
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value in the series with the given label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := seriesKey(h.labels, values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			values: append([]string(nil), values...),
+			counts: make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations in the series with the given
+// label values
+func (h *HistogramVec) Count(values ...string) uint64 {
+	key := seriesKey(h.labels, values)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Sum returns the sum of the observations in the series with the given label
+// values
+func (h *HistogramVec) Sum(values ...string) float64 {
+	key := seriesKey(h.labels, values)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if s, ok := h.series[key]; ok {
+		return s.sum
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w io.Writer, name string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		values := append(append([]string(nil), s.values...), "")
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			values[len(values)-1] = formatValue(bound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels, values), cumulative)
+		}
+		values[len(values)-1] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels, values), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, formatLabels(h.labels, s.values), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(h.labels, s.values), s.count)
+	}
+}
+
+// gaugeFunc is a gauge without labels read on every scrape
+type gaugeFunc func() float64
+
+func (g gaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatValue(g()))
+}
+
+// seriesKey identifies a series by its label values, panicking when their
+// number does not match the label names
+func seriesKey(labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), labels))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[T any](series map[string]T) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("requests_total", "Requests.", "method", "status")
+
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "POST", "500")
+
+	if got := requests.Value("GET", "200"); got != 2 {
+		t.Errorf("GET 200 = %g, want 2", got)
+	}
+	if got := requests.Value("POST", "500"); got != 3 {
+		t.Errorf("POST 500 = %g, want 3", got)
+	}
+	if got := requests.Value("DELETE", "204"); got != 0 {
+		t.Errorf("unseen series = %g, want 0", got)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1}, "route")
+
+	for _, v := range []float64{0.05, 0.5, 2} {
+		latency.Observe(v, "/a")
+	}
+
+	if got := latency.Count("/a"); got != 3 {
+		t.Errorf("count = %d, want 3", got)
+	}
+	if got := latency.Sum("/a"); got != 2.55 {
+		t.Errorf("sum = %g, want 2.55", got)
+	}
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	// Buckets are sorted and cumulative
+	for _, want := range []string{
+		`latency_seconds_bucket{route="/a",le="0.1"} 1`,
+		`latency_seconds_bucket{route="/a",le="1"} 2`,
+		`latency_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`latency_seconds_sum{route="/a"} 2.55`,
+		`latency_seconds_count{route="/a"} 3`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("output lacks %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("b_total", "Second family.", "path").Inc(`/say"hi"`)
+	r.NewGaugeFunc("a_queue", "First family,\nwith a newline.", func() float64 { return 7 })
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("content type %q, want %q", got, ContentType)
+	}
+	want := "# HELP a_queue First family,\\nwith a newline.\n" +
+		"# TYPE a_queue gauge\n" +
+		"a_queue 7\n" +
+		"# HELP b_total Second family.\n" +
+		"# TYPE b_total counter\n" +
+		`b_total{path="/say\"hi\""} 1` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("output:\n%s\nwant:\n%s", w.Body.String(), want)
+	}
+}
+
+func TestRegistryPanics(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounterVec("dup_total", "Duplicate.", "label")
+
+	expectPanic := func(name string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s did not panic", name)
+			}
+		}()
+		fn()
+	}
+	expectPanic("registering a name twice", func() { r.NewCounterVec("dup_total", "Again.") })
+	expectPanic("wrong number of label values", func() { counter.Inc("a", "b") })
+}
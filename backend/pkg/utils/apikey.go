@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// apiKeyTag starts every generated API key, so leaked keys are easy to scan for
+	apiKeyTag = "tion_"
+
+	// apiKeyIDSize and apiKeySecretSize are the random bytes of the visible
+	// and secret parts of an API key
+	apiKeyIDSize     = 4
+	apiKeySecretSize = 24
+
+	// sessionTokenSize is the random bytes of a session token
+	sessionTokenSize = 32
+)
+
+// NewAPIKey generates an API key of the form tion_<id>_<secret>. The prefix,
+// tion_<id>, identifies the key in listings and logs without revealing it.
+func NewAPIKey() (key, prefix string, err error) {
+	id := make([]byte, apiKeyIDSize)
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyTag + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// NewSessionToken generates the random token of a session
+func NewSessionToken() (string, error) {
+	token := make([]byte, sessionTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of an API key or session token, which is
+// what gets stored. Both are long random strings, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
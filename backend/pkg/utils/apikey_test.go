@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	format := regexp.MustCompile(`^(tion_[0-9a-f]{8})_[A-Za-z0-9_-]{32}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		key, prefix, err := NewAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := format.FindStringSubmatch(key)
+		if match == nil || match[1] != prefix {
+			t.Fatalf("key %q with prefix %q, want tion_<8 hex>_<32 characters>", key, prefix)
+		}
+		if seen[key] {
+			t.Fatalf("key %q generated twice", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	hash := HashAPIKey("tion_1a2b3c4d_secret")
+	if len(hash) != 64 || hash != HashAPIKey("tion_1a2b3c4d_secret") {
+		t.Errorf("hash %q, want a stable hex SHA-256", hash)
+	}
+	if hash == HashAPIKey("tion_1a2b3c4d_secreT") {
+		t.Error("different keys have the same hash")
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxSourceLength and maxSearchQueryLength match the ToolUsage columns
+	maxSourceLength      = 255
+	maxSearchQueryLength = 100
+)
+
+// NormalizeSource reduces a referrer or page path to where a click came from:
+// the path of relative sources and host plus path of absolute URLs. Query
+// strings and fragments are dropped, as they may carry personal data.
+func NormalizeSource(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	path := u.EscapedPath()
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	source := path
+	if u.Host != "" {
+		source = strings.ToLower(u.Host) + path
+	} else if !strings.HasPrefix(source, "/") {
+		source = "/" + source
+	}
+	return truncateUTF8(source, maxSourceLength)
+}
+
+// NormalizeSearchQuery lowercases a search query and collapses its whitespace,
+// so that the same search is counted once however it was typed
+func NormalizeSearchQuery(query string) string {
+	query = strings.ToLower(strings.Join(strings.Fields(query), " "))
+	return truncateUTF8(query, maxSearchQueryLength)
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	for len(s) > 0 && !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify converts a name into a lowercase, hyphen-separated slug.
+// Letters and digits of any script are kept so non-Latin names stay readable.
+func Slugify(name string) string {
+	var b strings.Builder
+	pendingHyphen := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingHyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingHyphen = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingHyphen = true
+	}
+
+	return b.String()
+}
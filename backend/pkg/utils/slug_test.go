@@ -0,0 +1,23 @@
+package utils
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Text Tools":       "text-tools",
+		"  text  tools! ":  "text-tools",
+		"Base64 / Hex":     "base64-hex",
+		"文本 工具":            "文本-工具",
+		"開発ツール":            "開発ツール",
+		"Café-Outils":      "café-outils",
+		"--leading--":      "leading",
+		"!!!":              "",
+		"":                 "",
+		"Ünïcödé_Names 2x": "ünïcödé-names-2x",
+	}
+	for name, want := range tests {
+		if got := Slugify(name); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package utils
+
+import "strings"
+
+// Device classes reported by ParseUserAgent
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// UserAgent is the coarse classification of a User-Agent header. Versions are
+// left out on purpose so that the result cannot serve as a fingerprint.
+type UserAgent struct {
+	Browser string
+	OS      string
+	Device  string
+	Bot     bool
+}
+
+// botMarkers are lowercase substrings of crawler, monitoring and scripted
+// client user agents
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "archiver", "headless", "lighthouse",
+	"phantomjs", "selenium", "puppeteer", "playwright", "preview", "monitor",
+	"uptime", "pingdom", "feedfetcher", "mediapartners", "facebookexternalhit",
+	"curl/", "wget/", "python-requests", "python-urllib", "aiohttp", "go-http-client",
+	"java/", "okhttp", "apache-httpclient", "libwww", "httpclient", "axios",
+	"node-fetch", "scrapy",
+}
+
+// browserMarkers are checked in order, since most browsers also claim to be
+// Chrome, Safari or Mozilla
+var browserMarkers = []struct {
+	name    string
+	markers []string
+}{
+	{"WeChat", []string{"micromessenger"}},
+	{"Edge", []string{"edg/", "edge/", "edga/", "edgios/"}},
+	{"Opera", []string{"opr/", "opera"}},
+	{"Samsung Internet", []string{"samsungbrowser"}},
+	{"Firefox", []string{"firefox/", "fxios/"}},
+	{"Chrome", []string{"chrome/", "crios/", "chromium/"}},
+	{"Safari", []string{"safari/"}},
+	{"Internet Explorer", []string{"msie ", "trident/"}},
+}
+
+// osMarkers are checked in order, iOS before macOS and Android before Linux
+var osMarkers = []struct {
+	name    string
+	markers []string
+}{
+	{"Windows", []string{"windows"}},
+	{"iOS", []string{"iphone", "ipad", "ipod"}},
+	{"macOS", []string{"mac os x", "macintosh"}},
+	{"Android", []string{"android"}},
+	{"Chrome OS", []string{"cros"}},
+	{"Linux", []string{"linux", "x11"}},
+}
+
+// ParseUserAgent classifies a User-Agent header into browser, operating system
+// and device class, and detects bots. An empty header is treated as a bot.
+func ParseUserAgent(header string) UserAgent {
+	ua := strings.ToLower(strings.TrimSpace(header))
+	if ua == "" || containsAny(ua, botMarkers) {
+		return UserAgent{Browser: "Other", OS: "Other", Device: DeviceBot, Bot: true}
+	}
+
+	result := UserAgent{Browser: "Other", OS: "Other", Device: DeviceDesktop}
+	for _, browser := range browserMarkers {
+		if containsAny(ua, browser.markers) {
+			result.Browser = browser.name
+			break
+		}
+	}
+	for _, system := range osMarkers {
+		if containsAny(ua, system.markers) {
+			result.OS = system.name
+			break
+		}
+	}
+
+	switch {
+	case containsAny(ua, []string{"ipad", "tablet", "kindle", "silk/"}),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		result.Device = DeviceTablet
+	case containsAny(ua, []string{"mobi", "iphone", "ipod", "windows phone"}):
+		result.Device = DeviceMobile
+	}
+	return result
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package utils
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		header string
+		want   UserAgent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			UserAgent{Browser: "Chrome", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			UserAgent{Browser: "Edge", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			UserAgent{Browser: "Safari", OS: "macOS", Device: DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			UserAgent{Browser: "Firefox", OS: "Linux", Device: DeviceDesktop},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			UserAgent{Browser: "Safari", OS: "iOS", Device: DeviceMobile},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			UserAgent{Browser: "Chrome", OS: "iOS", Device: DeviceTablet},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36",
+			UserAgent{Browser: "Chrome", OS: "Android", Device: DeviceMobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Safari/537.36",
+			UserAgent{Browser: "Samsung Internet", OS: "Android", Device: DeviceTablet},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 MicroMessenger/8.0.47(0x18002f2c) NetType/WIFI Language/zh_CN",
+			UserAgent{Browser: "WeChat", OS: "iOS", Device: DeviceMobile},
+		},
+		{
+			"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			UserAgent{Browser: "Chrome", OS: "Chrome OS", Device: DeviceDesktop},
+		},
+		{"SomeClient/1.0", UserAgent{Browser: "Other", OS: "Other", Device: DeviceDesktop}},
+	}
+	for _, tt := range tests {
+		if got := ParseUserAgent(tt.header); got != tt.want {
+			t.Errorf("ParseUserAgent(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestParseUserAgentBots(t *testing.T) {
+	bots := []string{
+		"",
+		"   ",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+		"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36",
+		"Mozilla/5.0 (Linux; Android 11; moto g power (2022)) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Mobile Safari/537.36 Chrome-Lighthouse",
+		"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+		"curl/8.5.0",
+		"Wget/1.21.4",
+		"python-requests/2.31.0",
+		"Go-http-client/1.1",
+		"UptimeRobot/2.0",
+	}
+	for _, header := range bots {
+		got := ParseUserAgent(header)
+		if !got.Bot || got.Device != DeviceBot {
+			t.Errorf("ParseUserAgent(%q) = %+v, want a bot", header, got)
+		}
+	}
+}
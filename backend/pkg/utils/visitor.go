@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// visitorSaltSize is the length of a visitor salt in bytes
+const visitorSaltSize = 32
+
+// NewVisitorSalt generates a random salt for HashVisitor
+func NewVisitorSalt() ([]byte, error) {
+	salt := make([]byte, visitorSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// HashVisitor derives an anonymous visitor identity from an IP address and user
+// agent. Without the salt the hash cannot be linked back to the address, so once
+// a salt is discarded the identities made with it are anonymous.
+func HashVisitor(salt []byte, ip, userAgent string) string {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestHashVisitor(t *testing.T) {
+	salt := []byte("salt of today")
+	other := []byte("salt of tomorrow")
+	const ip, agent = "203.0.113.7", "Mozilla/5.0"
+
+	hash := HashVisitor(salt, ip, agent)
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(hash) {
+		t.Fatalf("hash %q is not 32 hex digits", hash)
+	}
+	if again := HashVisitor(salt, ip, agent); again != hash {
+		t.Errorf("the same visitor hashed to %q and %q", hash, again)
+	}
+
+	different := map[string]string{
+		"another salt":       HashVisitor(other, ip, agent),
+		"another ip":         HashVisitor(salt, "203.0.113.8", agent),
+		"another user agent": HashVisitor(salt, ip, "Mozilla/5.0 (X11)"),
+		// The separator keeps the address and user agent apart
+		"shifted boundary": HashVisitor(salt, ip+"M", "ozilla/5.0"),
+	}
+	for name, got := range different {
+		if got == hash {
+			t.Errorf("%s hashed to the same visitor", name)
+		}
+	}
+}
+
+func TestNewVisitorSalt(t *testing.T) {
+	first, err := NewVisitorSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewVisitorSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != visitorSaltSize || len(second) != visitorSaltSize {
+		t.Errorf("salts of %d and %d bytes, want %d", len(first), len(second), visitorSaltSize)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("two salts are equal")
+	}
+}
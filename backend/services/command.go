@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tion.work/backend/pkg/logging"
+)
+
+// 外部命令（git、npm、cursor-agent）和 Netlify 请求使用调用方传入的 ctx 记录日志，
+// 日志带有发起操作的请求 ID。ctx 取消时命令不会被终止：客户端断开后，
+// 提交、重置和部署等操作仍会完整执行，不会停在中途。
+
+// runCommand 运行会修改项目的命令并返回合并的输出，以 info 级别记录
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logCommand(ctx, cmd, start, err, logrus.InfoLevel)
+	recordCommand(cmd, start, err)
+	return output, err
+}
+
+// queryCommand 运行只读的查询命令并返回标准输出，以 debug 级别记录
+func queryCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	start := time.Now()
+	output, err := cmd.Output()
+	logCommand(ctx, cmd, start, err, logrus.DebugLevel)
+	recordCommand(cmd, start, err)
+	return output, err
+}
+
+// logCommand 记录命令、项目目录、耗时和结果，失败时以 warn 级别记录
+func logCommand(ctx context.Context, cmd *exec.Cmd, start time.Time, err error, level logrus.Level) {
+	entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"command":     commandName(cmd),
+		"dir":         filepath.Base(cmd.Dir),
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+	if err != nil {
+		entry.WithError(err).Warn("命令执行失败")
+		return
+	}
+	entry.Log(level, "命令执行完成")
+}
+
+// commandName 返回写入日志的命令名称，只包含程序和子命令，
+// 不包含提示词、提交信息和 API Key 等参数
+func commandName(cmd *exec.Cmd) string {
+	name := []string{filepath.Base(cmd.Args[0])}
+	if len(cmd.Args) > 1 && !strings.HasPrefix(cmd.Args[1], "-") {
+		name = append(name, cmd.Args[1])
+	}
+	return strings.Join(name, " ")
+}
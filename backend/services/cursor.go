@@ -2,11 +2,16 @@ package services
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"tion.work/backend/pkg/logging"
 )
 
 // CursorService Cursor Agent 服务
@@ -24,7 +29,7 @@ func NewCursorService(apiKey, workspace string) *CursorService {
 }
 
 // ExecuteCommand 执行 Cursor Agent 命令
-func (s *CursorService) ExecuteCommand(project, prompt string, handler func(string)) error {
+func (s *CursorService) ExecuteCommand(ctx context.Context, project, prompt string, handler func(string)) error {
 	// 构建项目路径
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
 
@@ -55,9 +60,13 @@ func (s *CursorService) ExecuteCommand(project, prompt string, handler func(stri
 	}
 
 	// 启动命令
+	start := time.Now()
 	if err := cmd.Start(); err != nil {
+		logCommand(ctx, cmd, start, err, logrus.InfoLevel)
+		recordCommand(cmd, start, err)
 		return fmt.Errorf("启动命令失败: %v", err)
 	}
+	logging.FromContext(ctx).WithField("dir", project).Info("cursor-agent 已启动")
 
 	// 使用 WaitGroup 等待所有 goroutine 完成
 	var wg sync.WaitGroup
@@ -86,7 +95,10 @@ func (s *CursorService) ExecuteCommand(project, prompt string, handler func(stri
 	// 等待命令完成
 	wg.Wait()
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	logCommand(ctx, cmd, start, err, logrus.InfoLevel)
+	recordCommand(cmd, start, err)
+	if err != nil {
 		return fmt.Errorf("命令执行失败: %v", err)
 	}
 
@@ -94,7 +106,7 @@ func (s *CursorService) ExecuteCommand(project, prompt string, handler func(stri
 }
 
 // ExecuteCommandStream 执行命令并流式返回结果
-func (s *CursorService) ExecuteCommandStream(project, prompt string, handler func(string)) error {
+func (s *CursorService) ExecuteCommandStream(ctx context.Context, project, prompt string, handler func(string)) error {
 	// 构建项目路径
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
 
@@ -125,9 +137,13 @@ func (s *CursorService) ExecuteCommandStream(project, prompt string, handler fun
 	}
 
 	// 启动命令
+	start := time.Now()
 	if err := cmd.Start(); err != nil {
+		logCommand(ctx, cmd, start, err, logrus.InfoLevel)
+		recordCommand(cmd, start, err)
 		return fmt.Errorf("启动命令失败: %v", err)
 	}
+	logging.FromContext(ctx).WithField("dir", project).Info("cursor-agent 已启动")
 
 	// 使用 WaitGroup 等待所有 goroutine 完成
 	var wg sync.WaitGroup
@@ -156,7 +172,10 @@ func (s *CursorService) ExecuteCommandStream(project, prompt string, handler fun
 	// 等待命令完成
 	wg.Wait()
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	logCommand(ctx, cmd, start, err, logrus.InfoLevel)
+	recordCommand(cmd, start, err)
+	if err != nil {
 		return fmt.Errorf("命令执行失败: %v", err)
 	}
 
@@ -251,7 +270,7 @@ func (s *CursorService) GetAvailableProjects() ([]string, error) {
 }
 
 // InstallDependencies 安装项目依赖
-func (s *CursorService) InstallDependencies(project string) error {
+func (s *CursorService) InstallDependencies(ctx context.Context, project string) error {
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
 
 	// 检查项目是否存在
@@ -263,7 +282,7 @@ func (s *CursorService) InstallDependencies(project string) error {
 	cmd := exec.Command("npm", "install", "--legacy-peer-deps")
 	cmd.Dir = projectPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("安装依赖失败: %v\n输出: %s", err, string(output))
 	}
@@ -272,7 +291,7 @@ func (s *CursorService) InstallDependencies(project string) error {
 }
 
 // BuildProject 构建项目
-func (s *CursorService) BuildProject(project string) error {
+func (s *CursorService) BuildProject(ctx context.Context, project string) error {
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
 
 	// 检查项目是否存在
@@ -284,7 +303,7 @@ func (s *CursorService) BuildProject(project string) error {
 	cmd := exec.Command("npm", "run", "build")
 	cmd.Dir = projectPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("构建项目失败: %v\n输出: %s", err, string(output))
 	}
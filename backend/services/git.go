@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -23,7 +24,7 @@ func NewGitService(workspace, token string) *GitService {
 }
 
 // CloneRepository 克隆仓库
-func (s *GitService) CloneRepository(repoURL, targetPath string) error {
+func (s *GitService) CloneRepository(ctx context.Context, repoURL, targetPath string) error {
 	// 检查目标路径是否存在
 	if _, err := os.Stat(targetPath); !os.IsNotExist(err) {
 		return fmt.Errorf("目标路径已存在: %s", targetPath)
@@ -37,7 +38,7 @@ func (s *GitService) CloneRepository(repoURL, targetPath string) error {
 		cmd.Env = append(os.Environ(), fmt.Sprintf("GITHUB_TOKEN=%s", s.Token))
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("克隆仓库失败: %v\n输出: %s", err, string(output))
 	}
@@ -46,7 +47,7 @@ func (s *GitService) CloneRepository(repoURL, targetPath string) error {
 }
 
 // PullRepository 拉取最新代码
-func (s *GitService) PullRepository(repoPath string) error {
+func (s *GitService) PullRepository(ctx context.Context, repoPath string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -56,7 +57,7 @@ func (s *GitService) PullRepository(repoPath string) error {
 	cmd := exec.Command("git", "pull", "origin", "main")
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("拉取代码失败: %v\n输出: %s", err, string(output))
 	}
@@ -65,7 +66,7 @@ func (s *GitService) PullRepository(repoPath string) error {
 }
 
 // GetStatus 获取 Git 状态
-func (s *GitService) GetStatus(repoPath string) (map[string]interface{}, error) {
+func (s *GitService) GetStatus(ctx context.Context, repoPath string) (map[string]interface{}, error) {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -76,7 +77,7 @@ func (s *GitService) GetStatus(repoPath string) (map[string]interface{}, error)
 	// 获取当前分支
 	cmd := exec.Command("git", "branch", "--show-current")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := queryCommand(ctx, cmd)
 	if err == nil {
 		status["currentBranch"] = strings.TrimSpace(string(output))
 	}
@@ -84,7 +85,7 @@ func (s *GitService) GetStatus(repoPath string) (map[string]interface{}, error)
 	// 获取状态
 	cmd = exec.Command("git", "status", "--porcelain")
 	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	output, err = queryCommand(ctx, cmd)
 	if err == nil {
 		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 		status["modifiedFiles"] = lines
@@ -94,7 +95,7 @@ func (s *GitService) GetStatus(repoPath string) (map[string]interface{}, error)
 	// 获取提交历史
 	cmd = exec.Command("git", "log", "--oneline", "-5")
 	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	output, err = queryCommand(ctx, cmd)
 	if err == nil {
 		commits := strings.Split(strings.TrimSpace(string(output)), "\n")
 		status["recentCommits"] = commits
@@ -104,7 +105,7 @@ func (s *GitService) GetStatus(repoPath string) (map[string]interface{}, error)
 }
 
 // AddFiles 添加文件到暂存区
-func (s *GitService) AddFiles(repoPath string, files ...string) error {
+func (s *GitService) AddFiles(ctx context.Context, repoPath string, files ...string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -115,7 +116,7 @@ func (s *GitService) AddFiles(repoPath string, files ...string) error {
 	cmd := exec.Command("git", args...)
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("添加文件失败: %v\n输出: %s", err, string(output))
 	}
@@ -124,7 +125,7 @@ func (s *GitService) AddFiles(repoPath string, files ...string) error {
 }
 
 // Commit 提交更改
-func (s *GitService) Commit(repoPath, message string) error {
+func (s *GitService) Commit(ctx context.Context, repoPath, message string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -134,7 +135,7 @@ func (s *GitService) Commit(repoPath, message string) error {
 	cmd := exec.Command("git", "commit", "-m", message)
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("提交失败: %v\n输出: %s", err, string(output))
 	}
@@ -143,7 +144,7 @@ func (s *GitService) Commit(repoPath, message string) error {
 }
 
 // Push 推送到远程仓库
-func (s *GitService) Push(repoPath, branch string) error {
+func (s *GitService) Push(ctx context.Context, repoPath, branch string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -158,7 +159,7 @@ func (s *GitService) Push(repoPath, branch string) error {
 		cmd.Env = append(os.Environ(), fmt.Sprintf("GITHUB_TOKEN=%s", s.Token))
 	}
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("推送失败: %v\n输出: %s", err, string(output))
 	}
@@ -167,7 +168,7 @@ func (s *GitService) Push(repoPath, branch string) error {
 }
 
 // CreateBranch 创建新分支
-func (s *GitService) CreateBranch(repoPath, branchName string) error {
+func (s *GitService) CreateBranch(ctx context.Context, repoPath, branchName string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -177,7 +178,7 @@ func (s *GitService) CreateBranch(repoPath, branchName string) error {
 	cmd := exec.Command("git", "checkout", "-b", branchName)
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("创建分支失败: %v\n输出: %s", err, string(output))
 	}
@@ -186,7 +187,7 @@ func (s *GitService) CreateBranch(repoPath, branchName string) error {
 }
 
 // SwitchBranch 切换分支
-func (s *GitService) SwitchBranch(repoPath, branchName string) error {
+func (s *GitService) SwitchBranch(ctx context.Context, repoPath, branchName string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -196,7 +197,7 @@ func (s *GitService) SwitchBranch(repoPath, branchName string) error {
 	cmd := exec.Command("git", "checkout", branchName)
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("切换分支失败: %v\n输出: %s", err, string(output))
 	}
@@ -205,7 +206,7 @@ func (s *GitService) SwitchBranch(repoPath, branchName string) error {
 }
 
 // GetBranches 获取所有分支
-func (s *GitService) GetBranches(repoPath string) ([]string, error) {
+func (s *GitService) GetBranches(ctx context.Context, repoPath string) ([]string, error) {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -214,7 +215,7 @@ func (s *GitService) GetBranches(repoPath string) ([]string, error) {
 	// 获取本地分支
 	cmd := exec.Command("git", "branch")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := queryCommand(ctx, cmd)
 	if err != nil {
 		return nil, fmt.Errorf("获取分支失败: %v", err)
 	}
@@ -232,7 +233,7 @@ func (s *GitService) GetBranches(repoPath string) ([]string, error) {
 }
 
 // GetDiff 获取差异
-func (s *GitService) GetDiff(repoPath string) (string, error) {
+func (s *GitService) GetDiff(ctx context.Context, repoPath string) (string, error) {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -241,7 +242,7 @@ func (s *GitService) GetDiff(repoPath string) (string, error) {
 	// 获取差异
 	cmd := exec.Command("git", "diff")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := queryCommand(ctx, cmd)
 	if err != nil {
 		return "", fmt.Errorf("获取差异失败: %v", err)
 	}
@@ -250,7 +251,7 @@ func (s *GitService) GetDiff(repoPath string) (string, error) {
 }
 
 // GetStagedDiff 获取暂存区差异
-func (s *GitService) GetStagedDiff(repoPath string) (string, error) {
+func (s *GitService) GetStagedDiff(ctx context.Context, repoPath string) (string, error) {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return "", fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -259,7 +260,7 @@ func (s *GitService) GetStagedDiff(repoPath string) (string, error) {
 	// 获取暂存区差异
 	cmd := exec.Command("git", "diff", "--cached")
 	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := queryCommand(ctx, cmd)
 	if err != nil {
 		return "", fmt.Errorf("获取暂存区差异失败: %v", err)
 	}
@@ -268,7 +269,7 @@ func (s *GitService) GetStagedDiff(repoPath string) (string, error) {
 }
 
 // ResetChanges 重置更改
-func (s *GitService) ResetChanges(repoPath string) error {
+func (s *GitService) ResetChanges(ctx context.Context, repoPath string) error {
 	// 检查仓库路径是否存在
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
 		return fmt.Errorf("仓库路径不存在: %s", repoPath)
@@ -278,7 +279,7 @@ func (s *GitService) ResetChanges(repoPath string) error {
 	cmd := exec.Command("git", "reset", "--hard", "HEAD")
 	cmd.Dir = repoPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("重置更改失败: %v\n输出: %s", err, string(output))
 	}
@@ -287,22 +288,22 @@ func (s *GitService) ResetChanges(repoPath string) error {
 }
 
 // GetProjectGitStatus 获取项目 Git 状态
-func (s *GitService) GetProjectGitStatus(project string) (map[string]interface{}, error) {
+func (s *GitService) GetProjectGitStatus(ctx context.Context, project string) (map[string]interface{}, error) {
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
-	return s.GetStatus(projectPath)
+	return s.GetStatus(ctx, projectPath)
 }
 
 // CommitProject 提交项目更改
-func (s *GitService) CommitProject(project, message string) error {
+func (s *GitService) CommitProject(ctx context.Context, project, message string) error {
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
 
 	// 添加所有更改
-	if err := s.AddFiles(projectPath, "."); err != nil {
+	if err := s.AddFiles(ctx, projectPath, "."); err != nil {
 		return fmt.Errorf("添加文件失败: %v", err)
 	}
 
 	// 提交更改
-	if err := s.Commit(projectPath, message); err != nil {
+	if err := s.Commit(ctx, projectPath, message); err != nil {
 		return fmt.Errorf("提交失败: %v", err)
 	}
 
@@ -310,7 +311,7 @@ func (s *GitService) CommitProject(project, message string) error {
 }
 
 // PushProject 推送项目更改
-func (s *GitService) PushProject(project, branch string) error {
+func (s *GitService) PushProject(ctx context.Context, project, branch string) error {
 	projectPath := filepath.Join(s.Workspace, "frontends", "frontends", project)
-	return s.Push(projectPath, branch)
+	return s.Push(ctx, projectPath, branch)
 }
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"tion.work/backend/pkg/metrics"
+)
+
+// longBuckets 是 AI 任务和部署耗时的直方图区间（秒），这些操作通常需要数分钟
+var longBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800}
+
+var (
+	agentRuns = metrics.Default.NewCounterVec("cursor_agent_runs_total",
+		"cursor-agent runs by exit code, start_failed when it did not start and signal when it was killed.", "exit_code")
+	agentRunDuration = metrics.Default.NewHistogramVec("cursor_agent_run_duration_seconds",
+		"Duration of cursor-agent runs by result.", longBuckets, "result")
+	gitCommands = metrics.Default.NewCounterVec("git_commands_total",
+		"git commands by subcommand.", "command")
+	gitCommandFailures = metrics.Default.NewCounterVec("git_command_failures_total",
+		"Failed git commands by subcommand.", "command")
+	deploys = metrics.Default.NewCounterVec("deploys_total",
+		"Netlify deploys by result.", "result")
+	deployDuration = metrics.Default.NewHistogramVec("deploy_duration_seconds",
+		"Duration of Netlify deploys, including the build, by result.", longBuckets, "result")
+)
+
+// recordCommand 记录 cursor-agent 的运行次数、耗时和退出码，以及 git 命令的执行和失败次数
+func recordCommand(cmd *exec.Cmd, start time.Time, err error) {
+	switch filepath.Base(cmd.Args[0]) {
+	case "cursor-agent":
+		agentRuns.Inc(exitCode(err))
+		agentRunDuration.Observe(time.Since(start).Seconds(), resultLabel(err))
+	case "git":
+		command := ""
+		if len(cmd.Args) > 1 {
+			command = cmd.Args[1]
+		}
+		gitCommands.Inc(command)
+		if err != nil {
+			gitCommandFailures.Inc(command)
+		}
+	}
+}
+
+// recordDeploy 记录一次部署的结果和耗时
+func recordDeploy(start time.Time, err error) {
+	deploys.Inc(resultLabel(err))
+	deployDuration.Observe(time.Since(start).Seconds(), resultLabel(err))
+}
+
+// exitCode 返回命令的退出码标签
+func exitCode(err error) string {
+	if err == nil {
+		return "0"
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return "start_failed"
+	}
+	if code := exitErr.ExitCode(); code >= 0 {
+		return strconv.Itoa(code)
+	}
+	return "signal"
+}
+
+// resultLabel 返回操作结果标签
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// fakeCommands 把脚本写成同名的可执行文件，并放到 PATH 的最前面
+func fakeCommands(t *testing.T, scripts map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAgentRunMetrics(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "frontends", "frontends", "site"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fakeCommands(t, map[string]string{"cursor-agent": `echo working; exit ${FAKE_AGENT_EXIT:-0}`})
+	cursor := NewCursorService("key", workspace)
+	ctx := context.Background()
+
+	succeeded, failed, notStarted := agentRuns.Value("0"), agentRuns.Value("3"), agentRuns.Value("start_failed")
+	successes, failures := agentRunDuration.Count("success"), agentRunDuration.Count("failure")
+
+	var lines []string
+	if err := cursor.ExecuteCommand(ctx, "site", "add a page", func(line string) { lines = append(lines, line) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0] != "[INFO] working" {
+		t.Errorf("output %q, want the agent output", lines)
+	}
+
+	t.Setenv("FAKE_AGENT_EXIT", "3")
+	if err := cursor.ExecuteCommand(ctx, "site", "add a page", func(string) {}); err == nil {
+		t.Error("a failing agent run returned no error")
+	}
+
+	// 找不到 cursor-agent 时命令无法启动
+	t.Setenv("PATH", t.TempDir())
+	if err := cursor.ExecuteCommandStream(ctx, "site", "add a page", func(string) {}); err == nil {
+		t.Error("an agent run that could not start returned no error")
+	}
+
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"exit code 0", agentRuns.Value("0") - succeeded, 1},
+		{"exit code 3", agentRuns.Value("3") - failed, 1},
+		{"start_failed", agentRuns.Value("start_failed") - notStarted, 1},
+		{"successful durations", float64(agentRunDuration.Count("success") - successes), 1},
+		{"failed durations", float64(agentRunDuration.Count("failure") - failures), 2},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s grew by %g, want %g", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestGitCommandMetrics(t *testing.T) {
+	repo := t.TempDir()
+	if output, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, output)
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@tion.work")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@tion.work")
+	if err := os.WriteFile(filepath.Join(repo, "index.html"), []byte("<h1>tion</h1>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	git := NewGitService(filepath.Dir(repo), "")
+	ctx := context.Background()
+	adds, commits, commitFailures := gitCommands.Value("add"), gitCommands.Value("commit"), gitCommandFailures.Value("commit")
+
+	if err := git.AddFiles(ctx, repo, "index.html"); err != nil {
+		t.Fatal(err)
+	}
+	if err := git.Commit(ctx, repo, "Add the index page"); err != nil {
+		t.Fatal(err)
+	}
+	// 没有改动时提交失败
+	if err := git.Commit(ctx, repo, "Nothing"); err == nil {
+		t.Error("an empty commit succeeded")
+	}
+
+	if got := gitCommands.Value("add") - adds; got != 1 {
+		t.Errorf("git add counted %g times, want 1", got)
+	}
+	if got := gitCommands.Value("commit") - commits; got != 2 {
+		t.Errorf("git commit counted %g times, want 2", got)
+	}
+	if got := gitCommandFailures.Value("commit") - commitFailures; got != 1 {
+		t.Errorf("git commit failures counted %g times, want 1", got)
+	}
+}
+
+// rewriteTransport 把 Netlify API 请求转发到测试服务器
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (r rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDeployMetrics(t *testing.T) {
+	var deployed DeployRequest
+	netlify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/sites/site-1/deploys" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&deployed)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"deploy-1","state":"uploaded","url":"https://site-1.netlify.app"}`))
+	}))
+	defer netlify.Close()
+	target, _ := url.Parse(netlify.URL)
+
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "package.json"), []byte(`{"name":"site"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fakeCommands(t, map[string]string{"npm": `mkdir -p dist && echo '<h1>tion</h1>' > dist/index.html`})
+
+	newNetlify := func(siteID string) *NetlifyService {
+		s := NewNetlifyService("token", siteID, filepath.Dir(project))
+		s.Client = &http.Client{Transport: rewriteTransport{target: target}}
+		return s
+	}
+	ctx := context.Background()
+	successes, failures := deploys.Value("success"), deploys.Value("failure")
+	timedSuccesses := deployDuration.Count("success")
+
+	deploy, err := newNetlify("site-1").DeployProject(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deploy.ID != "deploy-1" || deployed.Files["index.html"] != "<h1>tion</h1>\n" {
+		t.Errorf("deploy %+v with files %v, want deploy-1 with the built index page", deploy, deployed.Files)
+	}
+	if _, err := newNetlify("unknown").DeployProject(ctx, project); err == nil {
+		t.Error("a deploy the API rejected returned no error")
+	}
+
+	if got := deploys.Value("success") - successes; got != 1 {
+		t.Errorf("successful deploys grew by %g, want 1", got)
+	}
+	if got := deploys.Value("failure") - failures; got != 1 {
+		t.Errorf("failed deploys grew by %g, want 1", got)
+	}
+	if got := deployDuration.Count("success") - timedSuccesses; got != 1 {
+		t.Errorf("successful deploy durations grew by %d, want 1", got)
+	}
+}
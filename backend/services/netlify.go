@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	"tion.work/backend/pkg/logging"
 )
 
 // NetlifyService Netlify 部署服务
@@ -57,14 +61,22 @@ type DeployStatus struct {
 }
 
 // DeployProject 部署项目到 Netlify
-func (s *NetlifyService) DeployProject(projectPath string) (*DeployResponse, error) {
+func (s *NetlifyService) DeployProject(ctx context.Context, projectPath string) (*DeployResponse, error) {
+	start := time.Now()
+	deployResp, err := s.deployProject(ctx, projectPath)
+	recordDeploy(start, err)
+	return deployResp, err
+}
+
+// deployProject 构建项目并上传构建输出
+func (s *NetlifyService) deployProject(ctx context.Context, projectPath string) (*DeployResponse, error) {
 	// 检查项目路径是否存在
 	if _, err := os.Stat(projectPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("项目路径不存在: %s", projectPath)
 	}
 
 	// 构建项目
-	if err := s.buildProject(projectPath); err != nil {
+	if err := s.buildProject(ctx, projectPath); err != nil {
 		return nil, fmt.Errorf("构建项目失败: %v", err)
 	}
 
@@ -75,11 +87,11 @@ func (s *NetlifyService) DeployProject(projectPath string) (*DeployResponse, err
 	}
 
 	// 创建部署
-	return s.createDeploy(buildDir)
+	return s.createDeploy(ctx, buildDir)
 }
 
 // buildProject 构建项目
-func (s *NetlifyService) buildProject(projectPath string) error {
+func (s *NetlifyService) buildProject(ctx context.Context, projectPath string) error {
 	// 检查 package.json 是否存在
 	packageJsonPath := filepath.Join(projectPath, "package.json")
 	if _, err := os.Stat(packageJsonPath); os.IsNotExist(err) {
@@ -90,7 +102,7 @@ func (s *NetlifyService) buildProject(projectPath string) error {
 	cmd := exec.Command("npm", "run", "build")
 	cmd.Dir = projectPath
 
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, cmd)
 	if err != nil {
 		return fmt.Errorf("构建失败: %v\n输出: %s", err, string(output))
 	}
@@ -119,7 +131,7 @@ func (s *NetlifyService) getBuildDirectory(projectPath string) string {
 }
 
 // createDeploy 创建部署
-func (s *NetlifyService) createDeploy(buildDir string) (*DeployResponse, error) {
+func (s *NetlifyService) createDeploy(ctx context.Context, buildDir string) (*DeployResponse, error) {
 	// 读取构建目录中的所有文件
 	files, err := s.readDirectoryFiles(buildDir)
 	if err != nil {
@@ -149,7 +161,7 @@ func (s *NetlifyService) createDeploy(buildDir string) (*DeployResponse, error)
 	req.Header.Set("Authorization", "Bearer "+s.AuthToken)
 
 	// 发送请求
-	resp, err := s.Client.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
@@ -211,7 +223,7 @@ func (s *NetlifyService) readDirectoryFiles(dir string) (map[string]string, erro
 }
 
 // GetDeployStatus 获取部署状态
-func (s *NetlifyService) GetDeployStatus(deployID string) (*DeployStatus, error) {
+func (s *NetlifyService) GetDeployStatus(ctx context.Context, deployID string) (*DeployStatus, error) {
 	// 创建 HTTP 请求
 	url := fmt.Sprintf("https://api.netlify.com/api/v1/deploys/%s", deployID)
 	req, err := http.NewRequest("GET", url, nil)
@@ -223,7 +235,7 @@ func (s *NetlifyService) GetDeployStatus(deployID string) (*DeployStatus, error)
 	req.Header.Set("Authorization", "Bearer "+s.AuthToken)
 
 	// 发送请求
-	resp, err := s.Client.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
@@ -250,7 +262,7 @@ func (s *NetlifyService) GetDeployStatus(deployID string) (*DeployStatus, error)
 }
 
 // GetSiteInfo 获取站点信息
-func (s *NetlifyService) GetSiteInfo() (map[string]interface{}, error) {
+func (s *NetlifyService) GetSiteInfo(ctx context.Context) (map[string]interface{}, error) {
 	// 创建 HTTP 请求
 	url := fmt.Sprintf("https://api.netlify.com/api/v1/sites/%s", s.SiteID)
 	req, err := http.NewRequest("GET", url, nil)
@@ -262,7 +274,7 @@ func (s *NetlifyService) GetSiteInfo() (map[string]interface{}, error) {
 	req.Header.Set("Authorization", "Bearer "+s.AuthToken)
 
 	// 发送请求
-	resp, err := s.Client.Do(req)
+	resp, err := s.do(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("发送请求失败: %v", err)
 	}
@@ -288,8 +300,29 @@ func (s *NetlifyService) GetSiteInfo() (map[string]interface{}, error) {
 	return siteInfo, nil
 }
 
+// do 发送 Netlify API 请求，附带请求 ID 以便关联日志，并记录请求的结果和耗时
+func (s *NetlifyService) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+
+	start := time.Now()
+	resp, err := s.Client.Do(req)
+	entry := logging.FromContext(ctx).WithFields(logrus.Fields{
+		"method":      req.Method,
+		"path":        req.URL.Path,
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+	if err != nil {
+		entry.WithError(err).Warn("Netlify 请求失败")
+		return nil, err
+	}
+	entry.WithField("status", resp.StatusCode).Info("Netlify 请求完成")
+	return resp, nil
+}
+
 // DeployProjectFromPath 从路径部署项目
-func (s *NetlifyService) DeployProjectFromPath(project string, workspace string) (*DeployResponse, error) {
+func (s *NetlifyService) DeployProjectFromPath(ctx context.Context, project string, workspace string) (*DeployResponse, error) {
 	projectPath := filepath.Join(workspace, "frontends", project)
-	return s.DeployProject(projectPath)
+	return s.DeployProject(ctx, projectPath)
 }